@@ -0,0 +1,80 @@
+// Сверка снимков балансов (user_balances) с журналом (balance).
+// Печатает расхождения и аномалии журнала, записанные миграциями (ledger_anomalies);
+// с флагом -fix пересчитывает снимки по журналу. Отрицательный журнал автоматически
+// не исправляется - нужна ручная корректировка через админ-API.
+// Завершается с кодом 1, если найдены неисправленные расхождения.
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"time"
+
+	"github.com/ibeloyar/gophermart/internal/config"
+	"github.com/ibeloyar/gophermart/internal/repository/pg"
+	"github.com/ibeloyar/gophermart/pgk/logger"
+)
+
+func main() {
+	lg, err := logger.New()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer lg.Sync()
+
+	fix := flag.Bool("fix", false, "Rewrite drifted balance snapshots from the ledger")
+
+	cfg, err := config.Read()
+	if err != nil {
+		lg.Fatalf("reading config error")
+	}
+
+	storageRepo, err := pg.New(cfg.DatabaseURI, lg)
+	if err != nil {
+		lg.Fatalf("storage init error: %s", err)
+	}
+	defer storageRepo.Shutdown()
+
+	anomalies, err := storageRepo.GetLedgerAnomalies(context.Background())
+	if err != nil {
+		lg.Fatalf("get ledger anomalies error: %s", err)
+	}
+
+	for _, a := range anomalies {
+		lg.Warnf("ledger anomaly: %s user_id=%d order=%q amount=%s detected_at=%s",
+			a.Kind, a.UserID, a.Order, a.Amount, a.DetectedAt.Format(time.RFC3339))
+	}
+
+	drifts, err := storageRepo.ReconcileBalances(context.Background(), *fix)
+	if err != nil {
+		lg.Fatalf("reconcile balances error: %s", err)
+	}
+
+	negative := 0
+	for _, d := range drifts {
+		lg.Warnf("balance drift: user_id=%d snapshot current=%s withdrawn=%s, ledger current=%s withdrawn=%s",
+			d.UserID, d.SnapshotCurrent, d.SnapshotWithdrawn, d.LedgerCurrent, d.LedgerWithdrawn)
+
+		if d.NeedsAdjustment() {
+			negative++
+		}
+	}
+
+	switch {
+	case len(drifts) == 0:
+		lg.Info("balance snapshots match the ledger")
+	case *fix && negative > 0:
+		lg.Infof("fixed %d balance snapshots, %d users have a negative ledger and need a manual adjustment",
+			len(drifts)-negative, negative)
+		lg.Sync()
+		os.Exit(1)
+	case *fix:
+		lg.Infof("fixed %d balance snapshots", len(drifts))
+	default:
+		lg.Infof("found %d drifted balance snapshots, run with -fix to rebuild them", len(drifts))
+		lg.Sync()
+		os.Exit(1)
+	}
+}
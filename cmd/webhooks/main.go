@@ -0,0 +1,119 @@
+// Управление webhook'ом оператора программы лояльности.
+// События начислений и списаний не привязаны к магазину, поэтому активный endpoint один:
+// он получает данные всех пользователей (user_id, номер заказа, сумму) и не должен
+// принадлежать магазину. tenant - метка владельца; чтобы сменить адрес, отключите прежний.
+//
+//	webhooks [flags] add <tenant> <url> <secret> <event,...>
+//	webhooks [flags] list [tenant]
+//	webhooks [flags] disable <endpoint_id>
+//	webhooks [flags] requeue <endpoint_id> - вернуть доставки из dead-letter в очередь
+//
+// Флаги подключения к БД - как у сервера (-d или DATABASE_URI).
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/ibeloyar/gophermart/internal/config"
+	"github.com/ibeloyar/gophermart/internal/model"
+	"github.com/ibeloyar/gophermart/internal/repository/pg"
+	"github.com/ibeloyar/gophermart/pgk/logger"
+)
+
+func main() {
+	lg, err := logger.New()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer lg.Sync()
+
+	cfg, err := config.Read()
+	if err != nil {
+		lg.Fatalf("reading config error")
+	}
+
+	args := flag.Args()
+	if len(args) == 0 {
+		lg.Fatal("command required: add, list, disable or requeue")
+	}
+
+	storageRepo, err := pg.New(cfg.DatabaseURI, lg)
+	if err != nil {
+		lg.Fatalf("storage init error: %s", err)
+	}
+	defer storageRepo.Shutdown()
+
+	ctx := context.Background()
+
+	switch command, args := args[0], args[1:]; command {
+	case "add":
+		if len(args) != 4 {
+			lg.Fatal("usage: add <tenant> <url> <secret> <event,...>")
+		}
+
+		eventTypes := make([]model.WebhookEventType, 0)
+		for _, item := range strings.Split(args[3], ",") {
+			eventType := model.WebhookEventType(strings.TrimSpace(item))
+			if !eventType.IsValid() {
+				lg.Fatalf("unknown event type %q, expected %s or %s", eventType, model.WebhookEventOrderAccrued, model.WebhookEventPointsWithdrawn)
+			}
+			eventTypes = append(eventTypes, eventType)
+		}
+
+		endpoint, err := storageRepo.CreateWebhookEndpoint(ctx, model.WebhookEndpoint{
+			Tenant:     args[0],
+			URL:        args[1],
+			Secret:     args[2],
+			EventTypes: eventTypes,
+		})
+		if err != nil {
+			lg.Fatalf("creating webhook endpoint error: %s", err)
+		}
+
+		lg.Infof("webhook endpoint %d created for tenant %s", endpoint.ID, endpoint.Tenant)
+	case "list":
+		tenant := ""
+		if len(args) > 0 {
+			tenant = args[0]
+		}
+
+		endpoints, err := storageRepo.GetWebhookEndpoints(ctx, tenant)
+		if err != nil {
+			lg.Fatalf("listing webhook endpoints error: %s", err)
+		}
+
+		for _, e := range endpoints {
+			fmt.Printf("%d\t%s\t%s\t%v\tactive=%t\n", e.ID, e.Tenant, e.URL, e.EventTypes, e.Active)
+		}
+	case "disable", "requeue":
+		if len(args) != 1 {
+			lg.Fatalf("usage: %s <endpoint_id>", command)
+		}
+
+		id, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil {
+			lg.Fatalf("invalid endpoint id %q", args[0])
+		}
+
+		if command == "disable" {
+			if err = storageRepo.DisableWebhookEndpoint(ctx, id); err != nil {
+				lg.Fatalf("disabling webhook endpoint error: %s", err)
+			}
+			lg.Infof("webhook endpoint %d disabled", id)
+			return
+		}
+
+		requeued, err := storageRepo.RequeueDeadWebhookDeliveries(ctx, id)
+		if err != nil {
+			lg.Fatalf("requeueing webhook deliveries error: %s", err)
+		}
+		lg.Infof("requeued %d dead webhook deliveries", requeued)
+	default:
+		lg.Fatalf("unknown command %q", command)
+	}
+}
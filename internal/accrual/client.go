@@ -0,0 +1,114 @@
+package accrual
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ibeloyar/gophermart/internal/model"
+	"github.com/ibeloyar/gophermart/pgk/retryablehttp"
+)
+
+const defaultRetryAfter = 60 * time.Second
+
+// ErrOrderNotRegistered - система расчёта ещё не знает о заказе (204 No Content)
+var ErrOrderNotRegistered = errors.New("order is not registered in accrual system")
+
+// RateLimitError - система расчёта ответила 429, запросы нужно приостановить
+type RateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("rate limited: %v", e.RetryAfter)
+}
+
+// accrualResponse - ответ системы расчёта; начисление может прийти с точностью больше копейки
+type accrualResponse struct {
+	Order   string            `json:"order"`
+	Status  model.OrderStatus `json:"status"`
+	Accrual json.Number       `json:"accrual"`
+}
+
+type AccrualClient interface {
+	GetAccrual(ctx context.Context, orderNumber string) (*model.Accrual, error)
+}
+
+type HTTPClient struct {
+	address     string
+	retryClient *retryablehttp.RetryableClient
+}
+
+func NewHTTPClient(address string, retryClient *retryablehttp.RetryableClient) *HTTPClient {
+	return &HTTPClient{
+		address:     address,
+		retryClient: retryClient,
+	}
+}
+
+// GetAccrual - получить данные по начислению баллов для указанного заказа
+func (c *HTTPClient) GetAccrual(ctx context.Context, orderNumber string) (*model.Accrual, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.address+"/api/orders/"+orderNumber, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := c.retryClient.Do(ctx, req)
+	if err != nil {
+		if response != nil && response.StatusCode == http.StatusTooManyRequests {
+			response.Body.Close()
+			return nil, &RateLimitError{RetryAfter: getRetryAfter(response)}
+		}
+		return nil, err
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode == http.StatusNoContent {
+		return nil, ErrOrderNotRegistered
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("accrual update request failed: %s", http.StatusText(response.StatusCode))
+	}
+
+	var body accrualResponse
+	err = json.NewDecoder(response.Body).Decode(&body)
+
+	if err != nil {
+		return nil, err
+	}
+
+	accrual := model.Accrual{
+		Order:  body.Order,
+		Status: body.Status,
+	}
+
+	// строгий разбор отклонил бы лишние знаки, и задание опрашивалось бы бесконечно
+	if body.Accrual != "" {
+		accrual.Accrual, err = model.RoundMoney(body.Accrual.String())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// REGISTERED для пользователя неотличим от PROCESSING
+	if accrual.Status == model.AccrualStatusRegistered {
+		accrual.Status = model.OrderStatusProcessing
+	}
+
+	return &accrual, nil
+}
+
+func getRetryAfter(resp *http.Response) time.Duration {
+	if retryAfter := resp.Header.Get("Retry-After"); retryAfter != "" {
+		if seconds, err := strconv.ParseInt(retryAfter, 10, 64); err == nil {
+			return time.Duration(seconds) * time.Second
+		}
+	}
+	return defaultRetryAfter
+}
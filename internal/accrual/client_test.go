@@ -0,0 +1,165 @@
+package accrual
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ibeloyar/gophermart/internal/model"
+	"github.com/ibeloyar/gophermart/pgk/retryablehttp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRetryClient() *retryablehttp.RetryableClient {
+	return retryablehttp.NewRetryableClient(retryablehttp.RetryConfig{
+		MaxRetries: 1,
+		BaseDelay:  time.Millisecond,
+		MaxDelay:   time.Millisecond,
+		MaxJitter:  time.Millisecond,
+	})
+}
+
+func TestHTTPClient_GetAccrual(t *testing.T) {
+	tests := []struct {
+		name        string
+		mockStatus  int
+		mockBody    string
+		wantStatus  model.OrderStatus
+		wantAccrual model.Money
+		wantErr     error
+		wantAnyErr  bool
+	}{
+		{
+			name:        "успешный ответ",
+			mockStatus:  http.StatusOK,
+			mockBody:    `{"order": "order123", "status": "PROCESSED", "accrual": 100.5}`,
+			wantStatus:  model.OrderStatusProcessed,
+			wantAccrual: 10050,
+		},
+		{
+			name:        "начисление округляется до копейки",
+			mockStatus:  http.StatusOK,
+			mockBody:    `{"order": "order123", "status": "PROCESSED", "accrual": 100.555}`,
+			wantStatus:  model.OrderStatusProcessed,
+			wantAccrual: 10056,
+		},
+		{
+			name:       "INVALID статус",
+			mockStatus: http.StatusOK,
+			mockBody:   `{"order": "order123", "status": "INVALID"}`,
+			wantStatus: model.OrderStatusInvalid,
+		},
+		{
+			name:       "REGISTERED отображается как PROCESSING",
+			mockStatus: http.StatusOK,
+			mockBody:   `{"order": "order123", "status": "REGISTERED"}`,
+			wantStatus: model.OrderStatusProcessing,
+		},
+		{
+			name:       "заказ не зарегистрирован",
+			mockStatus: http.StatusNoContent,
+			wantErr:    ErrOrderNotRegistered,
+		},
+		{
+			name:       "невалидный JSON",
+			mockStatus: http.StatusOK,
+			mockBody:   `{invalid json}`,
+			wantAnyErr: true,
+		},
+		{
+			name:       "4xx ошибка (не retry)",
+			mockStatus: http.StatusBadRequest,
+			wantAnyErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "/api/orders/order123", r.URL.Path)
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(tt.mockStatus)
+				w.Write([]byte(tt.mockBody))
+			}))
+			defer server.Close()
+
+			client := NewHTTPClient(server.URL, newTestRetryClient())
+
+			accrual, err := client.GetAccrual(context.Background(), "order123")
+
+			switch {
+			case tt.wantErr != nil:
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Nil(t, accrual)
+			case tt.wantAnyErr:
+				assert.Error(t, err)
+				assert.Nil(t, accrual)
+			default:
+				require.NoError(t, err)
+				assert.Equal(t, tt.wantStatus, accrual.Status)
+				assert.Equal(t, tt.wantAccrual, accrual.Accrual)
+			}
+		})
+	}
+}
+
+func TestHTTPClient_GetAccrual_RetryThenSuccess(t *testing.T) {
+	var calls atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"order": "order123", "status": "PROCESSED", "accrual": 50}`))
+	}))
+	defer server.Close()
+
+	client := NewHTTPClient(server.URL, newTestRetryClient())
+
+	accrual, err := client.GetAccrual(context.Background(), "order123")
+
+	require.NoError(t, err)
+	assert.Equal(t, model.Money(5000), accrual.Accrual)
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestHTTPClient_GetAccrual_RateLimited(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "7")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	client := NewHTTPClient(server.URL, newTestRetryClient())
+
+	_, err := client.GetAccrual(context.Background(), "order123")
+
+	var rateLimitErr *RateLimitError
+	require.ErrorAs(t, err, &rateLimitErr)
+	assert.Equal(t, 7*time.Second, rateLimitErr.RetryAfter)
+}
+
+func TestGetRetryAfter(t *testing.T) {
+	tests := []struct {
+		header string
+		want   time.Duration
+	}{
+		{"10", 10 * time.Second},
+		{"", defaultRetryAfter},
+		{"not-a-number", defaultRetryAfter},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			resp := &http.Response{Header: http.Header{}}
+			resp.Header.Set("Retry-After", tt.header)
+
+			assert.Equal(t, tt.want, getRetryAfter(resp))
+		})
+	}
+}
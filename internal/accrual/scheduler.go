@@ -0,0 +1,305 @@
+package accrual
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ibeloyar/gophermart/internal/metrics"
+	"github.com/ibeloyar/gophermart/internal/model"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultPollInterval = 5 * time.Second
+	defaultBatchSize    = 100
+	defaultLease        = time.Minute
+	defaultBaseBackoff  = 5 * time.Second
+	defaultMaxBackoff   = 10 * time.Minute
+
+	// failingThreshold - сколько ошибок подряд, прежде чем считать систему расчёта недоступной
+	failingThreshold = 3
+)
+
+// исходы запросов к системе расчёта в метриках, помимо статусов заказа
+const (
+	outcomeNotRegistered = "not_registered"
+	outcomeRateLimited   = "rate_limited"
+	outcomeError         = "error"
+)
+
+// JobStore - хранилище заданий опроса системы расчёта
+type JobStore interface {
+	// ClaimAccrualJobs - забирает готовые к выполнению задания и откладывает их на lease,
+	// чтобы другие реплики не взяли те же заказы
+	ClaimAccrualJobs(ctx context.Context, limit int, lease time.Duration) ([]model.AccrualJob, error)
+	// UpdateOrderAccrual - сохраняет ответ системы расчёта; финальный статус закрывает задание,
+	// иначе задание переносится на nextAttemptAt. Возвращает событие, если статус изменился.
+	UpdateOrderAccrual(ctx context.Context, job model.AccrualJob, accrual model.Accrual, nextAttemptAt time.Time) (*model.OrderEvent, error)
+	// RescheduleAccrualJob - переносит задание; непустой lastErr увеличивает счётчик попыток
+	RescheduleAccrualJob(ctx context.Context, orderNumber string, nextAttemptAt time.Time, lastErr error) error
+}
+
+// EventPublisher - получатель событий об изменении заказов (например, хаб SSE)
+type EventPublisher interface {
+	Publish(event model.OrderEvent)
+}
+
+type Config struct {
+	PollInterval time.Duration
+	Workers      int
+	BatchSize    int
+	Lease        time.Duration
+	BaseBackoff  time.Duration
+	MaxBackoff   time.Duration
+}
+
+// Scheduler - периодически опрашивает систему расчёта по заданиям из JobStore
+type Scheduler struct {
+	store  JobStore
+	client AccrualClient
+	events EventPublisher
+	cfg    Config
+	lg     *zap.SugaredLogger
+
+	pauseMu     sync.Mutex
+	pausedUntil time.Time
+
+	failuresMu  sync.Mutex
+	failures    int
+	lastFailure error
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewScheduler - events может быть nil, если события доставляются иначе (LISTEN/NOTIFY)
+func NewScheduler(store JobStore, client AccrualClient, events EventPublisher, cfg Config, lg *zap.SugaredLogger) *Scheduler {
+	if cfg.PollInterval == 0 {
+		cfg.PollInterval = defaultPollInterval
+	}
+	if cfg.Workers == 0 {
+		cfg.Workers = runtime.NumCPU()
+	}
+	if cfg.BatchSize == 0 {
+		cfg.BatchSize = defaultBatchSize
+	}
+	if cfg.Lease == 0 {
+		cfg.Lease = defaultLease
+	}
+	if cfg.BaseBackoff == 0 {
+		cfg.BaseBackoff = defaultBaseBackoff
+	}
+	if cfg.MaxBackoff == 0 {
+		cfg.MaxBackoff = defaultMaxBackoff
+	}
+
+	return &Scheduler{
+		store:  store,
+		client: client,
+		events: events,
+		cfg:    cfg,
+		lg:     lg,
+	}
+}
+
+// Start - запускает опрос в отдельной горутине
+func (s *Scheduler) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	s.cancel = cancel
+	s.done = make(chan struct{})
+
+	go s.run(ctx)
+}
+
+// Shutdown - останавливает опрос и ждёт завершения текущего цикла.
+// Незавершённые задания вернутся в очередь по истечении lease.
+func (s *Scheduler) Shutdown(ctx context.Context) error {
+	if s.cancel == nil {
+		return nil
+	}
+
+	s.cancel()
+
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *Scheduler) run(ctx context.Context) {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.poll(ctx)
+		}
+	}
+}
+
+// poll - один цикл опроса: забирает пачку заданий и обрабатывает её пулом воркеров
+func (s *Scheduler) poll(ctx context.Context) {
+	if _, paused := s.pauseDeadline(); paused {
+		return
+	}
+
+	jobs, err := s.store.ClaimAccrualJobs(ctx, s.cfg.BatchSize, s.cfg.Lease)
+	if err != nil {
+		s.lg.Errorf("claiming accrual jobs error: %v", err)
+		return
+	}
+
+	if len(jobs) == 0 {
+		return
+	}
+
+	start := time.Now()
+	defer func() { metrics.AccrualPollDuration(time.Since(start)) }()
+
+	queue := make(chan model.AccrualJob)
+	wg := sync.WaitGroup{}
+
+	for i := 0; i < min(s.cfg.Workers, len(jobs)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range queue {
+				s.process(ctx, job)
+			}
+		}()
+	}
+
+	for _, job := range jobs {
+		queue <- job
+	}
+	close(queue)
+
+	wg.Wait()
+}
+
+func (s *Scheduler) process(ctx context.Context, job model.AccrualJob) {
+	// пока система расчёта просит подождать, откладываем оставшиеся задания
+	if until, paused := s.pauseDeadline(); paused {
+		s.reschedule(ctx, job.OrderNumber, until, nil)
+		return
+	}
+
+	accrual, err := s.client.GetAccrual(ctx, job.OrderNumber)
+	if err != nil {
+		var rateLimitErr *RateLimitError
+		if errors.As(err, &rateLimitErr) {
+			metrics.AccrualOutcome(outcomeRateLimited)
+			s.reschedule(ctx, job.OrderNumber, s.pause(rateLimitErr.RetryAfter), nil)
+			return
+		}
+
+		// 204 - ответ исправной системы расчёта, а не её отказ
+		if errors.Is(err, ErrOrderNotRegistered) {
+			metrics.AccrualOutcome(outcomeNotRegistered)
+			s.recordResult(nil)
+		} else {
+			metrics.AccrualOutcome(outcomeError)
+			s.recordResult(err)
+		}
+
+		s.lg.Errorf("getting accruals error: %v", err)
+		s.reschedule(ctx, job.OrderNumber, time.Now().Add(s.backoff(job.Attempts)), err)
+		return
+	}
+
+	metrics.AccrualOutcome(strings.ToLower(string(accrual.Status)))
+	s.recordResult(nil)
+
+	event, err := s.store.UpdateOrderAccrual(ctx, job, *accrual, time.Now().Add(s.cfg.PollInterval))
+	if err != nil {
+		s.lg.Errorf("updating order status error: %v", err)
+		return
+	}
+
+	if event != nil && s.events != nil {
+		s.events.Publish(*event)
+	}
+}
+
+func (s *Scheduler) reschedule(ctx context.Context, orderNumber string, nextAttemptAt time.Time, lastErr error) {
+	if err := s.store.RescheduleAccrualJob(ctx, orderNumber, nextAttemptAt, lastErr); err != nil {
+		s.lg.Errorf("rescheduling accrual job error: %v", err)
+	}
+}
+
+// backoff - экспоненциальная задержка повторной попытки
+func (s *Scheduler) backoff(attempts int) time.Duration {
+	delay := s.cfg.BaseBackoff
+	for i := 0; i < attempts && delay < s.cfg.MaxBackoff; i++ {
+		delay *= 2
+	}
+
+	return min(delay, s.cfg.MaxBackoff)
+}
+
+// pause - приостанавливает опрос на duration и возвращает момент возобновления
+func (s *Scheduler) pause(duration time.Duration) time.Time {
+	s.pauseMu.Lock()
+	defer s.pauseMu.Unlock()
+
+	until := time.Now().Add(duration)
+	if until.After(s.pausedUntil) {
+		// считаем только новые паузы и продления, а не каждый 429 от параллельных воркеров
+		metrics.AccrualRateLimitPause()
+		s.pausedUntil = until
+	}
+
+	return s.pausedUntil
+}
+
+func (s *Scheduler) pauseDeadline() (time.Time, bool) {
+	s.pauseMu.Lock()
+	defer s.pauseMu.Unlock()
+
+	return s.pausedUntil, time.Now().Before(s.pausedUntil)
+}
+
+// recordResult - учитывает ответ системы расчёта для Check: успех сбрасывает счётчик ошибок подряд
+func (s *Scheduler) recordResult(err error) {
+	s.failuresMu.Lock()
+	defer s.failuresMu.Unlock()
+
+	if err == nil {
+		s.failures = 0
+		s.lastFailure = nil
+		return
+	}
+
+	s.failures++
+	s.lastFailure = err
+}
+
+// Check - состояние системы расчёта для /readyz: ошибка, пока опрос на паузе после 429
+// или последние запросы подряд завершились ошибкой
+func (s *Scheduler) Check(_ context.Context) error {
+	if until, paused := s.pauseDeadline(); paused {
+		return fmt.Errorf("rate limited until %s", until.Format(time.RFC3339))
+	}
+
+	s.failuresMu.Lock()
+	defer s.failuresMu.Unlock()
+
+	if s.failures >= failingThreshold {
+		return fmt.Errorf("%d failed requests in a row, last: %w", s.failures, s.lastFailure)
+	}
+
+	return nil
+}
@@ -0,0 +1,240 @@
+package accrual
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ibeloyar/gophermart/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type fakeClient struct {
+	mu      sync.Mutex
+	calls   []string
+	results map[string]*model.Accrual
+	errs    map[string]error
+}
+
+func (c *fakeClient) GetAccrual(_ context.Context, orderNumber string) (*model.Accrual, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.calls = append(c.calls, orderNumber)
+
+	if err, ok := c.errs[orderNumber]; ok {
+		return nil, err
+	}
+
+	return c.results[orderNumber], nil
+}
+
+type rescheduled struct {
+	nextAttemptAt time.Time
+	lastErr       error
+}
+
+type fakeStore struct {
+	mu          sync.Mutex
+	jobs        []model.AccrualJob
+	updated     map[string]model.Accrual
+	rescheduled map[string]rescheduled
+}
+
+func newFakeStore(jobs ...model.AccrualJob) *fakeStore {
+	return &fakeStore{
+		jobs:        jobs,
+		updated:     make(map[string]model.Accrual),
+		rescheduled: make(map[string]rescheduled),
+	}
+}
+
+func (s *fakeStore) ClaimAccrualJobs(_ context.Context, limit int, _ time.Duration) ([]model.AccrualJob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := min(limit, len(s.jobs))
+	claimed := s.jobs[:n]
+	s.jobs = s.jobs[n:]
+
+	return claimed, nil
+}
+
+func (s *fakeStore) UpdateOrderAccrual(_ context.Context, job model.AccrualJob, accrual model.Accrual, _ time.Time) (*model.OrderEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.updated[job.OrderNumber] = accrual
+
+	return &model.OrderEvent{
+		ID:      int64(len(s.updated)),
+		UserID:  job.UserID,
+		Number:  job.OrderNumber,
+		Status:  accrual.Status,
+		Accrual: accrual.Accrual,
+	}, nil
+}
+
+type fakePublisher struct {
+	mu     sync.Mutex
+	events []model.OrderEvent
+}
+
+func (p *fakePublisher) Publish(event model.OrderEvent) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.events = append(p.events, event)
+}
+
+func (s *fakeStore) RescheduleAccrualJob(_ context.Context, orderNumber string, nextAttemptAt time.Time, lastErr error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.rescheduled[orderNumber] = rescheduled{nextAttemptAt: nextAttemptAt, lastErr: lastErr}
+
+	return nil
+}
+
+func newTestScheduler(store JobStore, client AccrualClient, workers int) *Scheduler {
+	return NewScheduler(store, client, nil, Config{
+		PollInterval: 10 * time.Millisecond,
+		Workers:      workers,
+		BaseBackoff:  time.Second,
+		MaxBackoff:   10 * time.Second,
+	}, zap.NewNop().Sugar())
+}
+
+func TestScheduler_Poll_UpdatesOrders(t *testing.T) {
+	store := newFakeStore(
+		model.AccrualJob{OrderNumber: "1", UserID: 10},
+		model.AccrualJob{OrderNumber: "2", UserID: 20},
+	)
+	client := &fakeClient{results: map[string]*model.Accrual{
+		"1": {Order: "1", Status: model.OrderStatusProcessed, Accrual: 10000},
+		"2": {Order: "2", Status: model.OrderStatusProcessing},
+	}}
+
+	publisher := &fakePublisher{}
+	scheduler := newTestScheduler(store, client, 2)
+	scheduler.events = publisher
+	scheduler.poll(context.Background())
+
+	assert.Equal(t, model.OrderStatusProcessed, store.updated["1"].Status)
+	assert.Equal(t, model.Money(10000), store.updated["1"].Accrual)
+	assert.Equal(t, model.OrderStatusProcessing, store.updated["2"].Status)
+	assert.Empty(t, store.rescheduled)
+	assert.Len(t, publisher.events, 2)
+}
+
+func TestScheduler_Poll_ErrorBacksOff(t *testing.T) {
+	clientErr := errors.New("connection refused")
+	store := newFakeStore(model.AccrualJob{OrderNumber: "1", Attempts: 2})
+	client := &fakeClient{errs: map[string]error{"1": clientErr}}
+
+	start := time.Now()
+	newTestScheduler(store, client, 1).poll(context.Background())
+
+	require.Contains(t, store.rescheduled, "1")
+	assert.ErrorIs(t, store.rescheduled["1"].lastErr, clientErr)
+	// 1s * 2^2
+	assert.WithinDuration(t, start.Add(4*time.Second), store.rescheduled["1"].nextAttemptAt, time.Second)
+	assert.Empty(t, store.updated)
+}
+
+func TestScheduler_Poll_RateLimitPausesRemainingJobs(t *testing.T) {
+	store := newFakeStore(
+		model.AccrualJob{OrderNumber: "1"},
+		model.AccrualJob{OrderNumber: "2"},
+		model.AccrualJob{OrderNumber: "3"},
+	)
+	client := &fakeClient{errs: map[string]error{"1": &RateLimitError{RetryAfter: time.Minute}}}
+
+	scheduler := newTestScheduler(store, client, 1)
+	scheduler.poll(context.Background())
+
+	// после 429 остальные задания не запрашиваются, а откладываются до конца паузы
+	assert.Equal(t, []string{"1"}, client.calls)
+	require.Len(t, store.rescheduled, 3)
+	for _, r := range store.rescheduled {
+		assert.NoError(t, r.lastErr)
+		assert.WithinDuration(t, time.Now().Add(time.Minute), r.nextAttemptAt, time.Second)
+	}
+
+	// следующий цикл во время паузы ничего не забирает
+	store.jobs = []model.AccrualJob{{OrderNumber: "4"}}
+	scheduler.poll(context.Background())
+	assert.Len(t, store.jobs, 1)
+}
+
+func TestScheduler_Backoff(t *testing.T) {
+	scheduler := newTestScheduler(newFakeStore(), &fakeClient{}, 1)
+
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{0, time.Second},
+		{1, 2 * time.Second},
+		{3, 8 * time.Second},
+		{10, 10 * time.Second},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, scheduler.backoff(tt.attempts))
+	}
+}
+
+func TestScheduler_StartShutdown(t *testing.T) {
+	store := newFakeStore(model.AccrualJob{OrderNumber: "1"})
+	client := &fakeClient{results: map[string]*model.Accrual{
+		"1": {Order: "1", Status: model.OrderStatusInvalid},
+	}}
+
+	scheduler := newTestScheduler(store, client, 1)
+	scheduler.Start()
+
+	assert.Eventually(t, func() bool {
+		store.mu.Lock()
+		defer store.mu.Unlock()
+		return len(store.updated) == 1
+	}, time.Second, 5*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	assert.NoError(t, scheduler.Shutdown(ctx))
+}
+
+func TestScheduler_Check(t *testing.T) {
+	clientErr := errors.New("connection refused")
+	jobs := make([]model.AccrualJob, failingThreshold)
+	for i := range jobs {
+		jobs[i] = model.AccrualJob{OrderNumber: strconv.Itoa(i)}
+	}
+
+	client := &fakeClient{errs: map[string]error{}}
+	for _, job := range jobs {
+		client.errs[job.OrderNumber] = clientErr
+	}
+
+	scheduler := newTestScheduler(newFakeStore(jobs...), client, 1)
+	require.NoError(t, scheduler.Check(context.Background()))
+
+	scheduler.poll(context.Background())
+	assert.ErrorIs(t, scheduler.Check(context.Background()), clientErr)
+
+	// успешный ответ сбрасывает счётчик
+	client.results = map[string]*model.Accrual{"ok": {Order: "ok", Status: model.OrderStatusProcessing}}
+	scheduler.store = newFakeStore(model.AccrualJob{OrderNumber: "ok"})
+	scheduler.poll(context.Background())
+	assert.NoError(t, scheduler.Check(context.Background()))
+
+	scheduler.pause(time.Minute)
+	assert.ErrorContains(t, scheduler.Check(context.Background()), "rate limited")
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -12,32 +13,193 @@ import (
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"github.com/ibeloyar/gophermart/internal/accrual"
 	"github.com/ibeloyar/gophermart/internal/config"
+	"github.com/ibeloyar/gophermart/internal/domainevents"
+	"github.com/ibeloyar/gophermart/internal/health"
+	"github.com/ibeloyar/gophermart/internal/metrics"
+	"github.com/ibeloyar/gophermart/internal/model"
+	"github.com/ibeloyar/gophermart/internal/orderevents"
+	"github.com/ibeloyar/gophermart/internal/pointsexpiry"
 	"github.com/ibeloyar/gophermart/internal/repository/pg"
 	"github.com/ibeloyar/gophermart/internal/service"
+	"github.com/ibeloyar/gophermart/internal/tracing"
+	"github.com/ibeloyar/gophermart/internal/webhooks"
+	"github.com/ibeloyar/gophermart/pgk/auth"
 	"github.com/ibeloyar/gophermart/pgk/logger"
+	"github.com/ibeloyar/gophermart/pgk/password"
+	"github.com/ibeloyar/gophermart/pgk/ratelimit"
+	"github.com/ibeloyar/gophermart/pgk/realip"
+	"github.com/ibeloyar/gophermart/pgk/retryablehttp"
 	"go.uber.org/zap"
 
 	httpController "github.com/ibeloyar/gophermart/internal/controller/http"
 )
 
+// accrualQueueDepthTimeout - предел запроса глубины очереди при сборе метрик, чтобы скрейп не зависал
+const accrualQueueDepthTimeout = time.Second
+
 func Run(cfg config.Config, zapLogger *zap.SugaredLogger) error {
-	storageRepo, err := pg.New(cfg.DatabaseURI, cfg.AccrualSystemAddress, zapLogger)
+	// до подключения к базе: запросы миграций тоже попадают в трассировку
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.TracingExporter)
+	if err != nil {
+		return fmt.Errorf("failed to set up tracing: %w", err)
+	}
+
+	storageRepo, err := pg.New(cfg.DatabaseURI, zapLogger)
 	if err != nil {
 		return fmt.Errorf("failed to create a DB connection: %w", err)
 	}
 
-	mainService := service.New(storageRepo, cfg.PassCost, cfg.TokenLifetime, cfg.SecretKey)
+	storageRepo.SetPointsExpiry(cfg.PointsExpiryPeriod, cfg.PointsExpiringSoon)
+	storageRepo.SetQueryTimeout(cfg.DatabaseTimeout)
+
+	orderEvents := orderevents.NewHub()
+
+	listenCtx, stopListen := context.WithCancel(context.Background())
+	defer stopListen()
+
+	var schedulerEvents accrual.EventPublisher = orderEvents
+	if cfg.OrderEventsNotify {
+		// события всех реплик, включая эту, приходят через LISTEN order_events
+		schedulerEvents = nil
+		go storageRepo.ListenOrderEvents(listenCtx, orderEvents.Publish)
+	}
+
+	accrualClient := accrual.NewHTTPClient(cfg.AccrualSystemAddress, retryablehttp.NewRetryableClient(retryablehttp.RetryConfig{}))
+	accrualScheduler := accrual.NewScheduler(storageRepo, accrualClient, schedulerEvents, accrual.Config{
+		PollInterval: cfg.AccrualPollInterval,
+		Workers:      cfg.AccrualWorkers,
+	}, zapLogger)
+	accrualScheduler.Start()
+
+	metrics.RegisterAccrualQueueDepth(func() float64 {
+		ctx, cancel := context.WithTimeout(context.Background(), accrualQueueDepthTimeout)
+		defer cancel()
+
+		count, err := storageRepo.CountDueAccrualJobs(ctx)
+		if err != nil {
+			zapLogger.Errorf("counting accrual jobs error: %v", err)
+		}
+		return float64(count)
+	})
+
+	webhookDispatcher := webhooks.NewDispatcher(storageRepo, retryablehttp.NewRetryableClient(retryablehttp.RetryConfig{}), webhooks.Config{
+		PollInterval: cfg.WebhookPollInterval,
+		MaxAttempts:  cfg.WebhookMaxAttempts,
+	}, zapLogger)
+	webhookDispatcher.Start()
+
+	// запускается и при PointsExpiryPeriod = 0: партии, начисленные со сроком раньше, всё равно сгорают
+	pointsExpirer := pointsexpiry.NewExpirer(storageRepo, pointsexpiry.Config{
+		Interval: cfg.PointsExpiryInterval,
+	}, zapLogger)
+	pointsExpirer.Start()
+
+	var eventsPublisher *domainevents.Publisher
+	if cfg.EventsSink != "" {
+		eventsSink, err := domainevents.NewSink(cfg.EventsSink)
+		if err != nil {
+			return fmt.Errorf("failed to open events sink: %w", err)
+		}
+
+		eventsPublisher = domainevents.NewPublisher(storageRepo, eventsSink, domainevents.Config{}, zapLogger)
+		eventsPublisher.Start()
+
+		if cfg.EventsRetention > 0 {
+			go storageRepo.PurgeOutboxEvents(listenCtx, cfg.EventsRetention, time.Hour)
+		}
+	}
+
+	tokenKeys := auth.NewHMACKeySet(cfg.SecretKey)
+	if len(cfg.JWTActiveKeys) > 0 {
+		tokenKeys, err = auth.LoadKeySet(cfg.JWTActiveKeys, cfg.JWTRetiredKeys)
+		if err != nil {
+			return fmt.Errorf("failed to load JWT keys: %w", err)
+		}
+	}
+
+	rateLimits, err := ratelimit.ParseRules(cfg.RateLimits)
+	if err != nil {
+		return fmt.Errorf("failed to parse rate limits: %w", err)
+	}
+
+	var rateLimitStore ratelimit.Store
+	switch cfg.RateLimitStore {
+	case "memory":
+		rateLimitStore = ratelimit.NewMemoryStore()
+	case "postgres":
+		rateLimitStore = storageRepo
+		go storageRepo.PurgeRateLimitBuckets(listenCtx, time.Hour)
+	default:
+		return fmt.Errorf("unknown rate limit store %q", cfg.RateLimitStore)
+	}
+	limiter := ratelimit.NewLimiter(rateLimitStore, rateLimits, zapLogger)
+
+	mainService := service.New(storageRepo, cfg.PassCost, cfg.TokenLifetime, cfg.RefreshTokenLifetime, cfg.IdempotencyKeyTTL, tokenKeys, orderEvents)
+	mainService.SetLoginLockout(model.LockoutPolicy{
+		MaxFailures: cfg.LoginMaxFailures,
+		Window:      cfg.LoginFailureWindow,
+		Lockout:     cfg.LoginLockout,
+		MaxLockout:  cfg.LoginMaxLockout,
+	})
+	mainService.SetPasswordResetTTL(cfg.PasswordResetTTL)
+	mainService.SetOrderEventsNotify(cfg.OrderEventsNotify)
+
+	// проверка старых хешей argon2id идёт и при bcrypt, поэтому ограничение ставится всегда
+	password.SetArgon2Concurrency(cfg.PasswordHashConcurrency)
+	switch cfg.PasswordHasher {
+	case "bcrypt":
+		mainService.SetPasswordHasher(password.NewBcryptHasher(cfg.PassCost))
+	case "argon2id":
+		mainService.SetPasswordHasher(password.NewArgon2idHasher(password.DefaultArgon2Params))
+	default:
+		return fmt.Errorf("unknown password hasher %q", cfg.PasswordHasher)
+	}
+
+	passwordPolicy := model.PasswordPolicy{MinClasses: cfg.PasswordMinClasses}
+	if cfg.PasswordDenylistFile != "" {
+		passwordPolicy.Denylist, err = password.ReadDenylist(cfg.PasswordDenylistFile)
+		if err != nil {
+			return fmt.Errorf("failed to read password denylist: %w", err)
+		}
+	}
+	mainService.SetPasswordPolicy(passwordPolicy)
+
+	healthChecker := health.New(0)
+	healthChecker.Add("database", true, storageRepo.Ping)
+	healthChecker.Add("migrations", true, storageRepo.CheckMigrations)
+	// недоступность системы расчёта не мешает обслуживать пользователей: заказы дождутся опроса
+	healthChecker.Add("accrual", false, accrualScheduler.Check)
+
+	trustedProxies, err := realip.ParseTrustedProxies(cfg.TrustedProxies)
+	if err != nil {
+		return fmt.Errorf("failed to parse trusted proxies: %w", err)
+	}
 
 	router := chi.NewRouter()
+	// до логирования и лимитов: и те, и другие должны видеть адрес клиента, а не прокси
+	router.Use(realip.Middleware(trustedProxies))
+	router.Use(tracing.Middleware)
+	router.Use(metrics.HTTPMiddleware)
 	router.Use(logger.LoggingMiddleware(zapLogger))
 	router.Use(middleware.Recoverer)
+	router.Get("/healthz", healthChecker.Liveness)
+	router.Get("/readyz", healthChecker.Readiness)
 	handlers := httpController.New(mainService, zapLogger)
 
+	// контекст всех запросов: при выходе из Run, в том числе по таймауту остановки,
+	// запросы к базе ещё работающих обработчиков прерываются, а не дорабатывают
+	requestsCtx, cancelRequests := context.WithCancel(context.Background())
+	defer cancelRequests()
+
 	srv := &http.Server{
-		Addr:    cfg.RunAddress,
-		Handler: httpController.InitRoutes(router, handlers, cfg.SecretKey),
+		Addr:        cfg.RunAddress,
+		Handler:     httpController.InitRoutes(router, handlers, tokenKeys, mainService.IsSessionRevoked, limiter),
+		BaseContext: func(net.Listener) context.Context { return requestsCtx },
 	}
+	// SSE-потоки не завершатся сами: закрываем подписки в начале остановки
+	srv.RegisterOnShutdown(orderEvents.Close)
 
 	signalCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
@@ -50,9 +212,35 @@ func Run(cfg config.Config, zapLogger *zap.SugaredLogger) error {
 		}
 	}()
 
+	var adminSrv *http.Server
+	if cfg.AdminAddress != "" {
+		adminMux := http.NewServeMux()
+		adminMux.Handle("GET /metrics", metrics.Handler())
+		adminMux.HandleFunc("GET /healthz", healthChecker.Liveness)
+		adminMux.HandleFunc("GET /readyz", healthChecker.Readiness)
+
+		adminSrv = &http.Server{
+			Addr:    cfg.AdminAddress,
+			Handler: adminMux,
+		}
+
+		zapLogger.Infof("starting admin server on %s", cfg.AdminAddress)
+
+		go func() {
+			if err := adminSrv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				zapLogger.Fatalf("admin server ListenAndServe error: %v", err)
+			}
+		}()
+	}
+
 	<-signalCtx.Done()
 	zapLogger.Info("shutting down server...")
 
+	healthChecker.SetShuttingDown()
+	if cfg.ShutdownDelay > 0 {
+		time.Sleep(cfg.ShutdownDelay)
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
@@ -60,10 +248,41 @@ func Run(cfg config.Config, zapLogger *zap.SugaredLogger) error {
 		return fmt.Errorf("shutdown (server) error: %v", err)
 	}
 
+	// метрики отдаём до конца остановки основного сервера
+	if adminSrv != nil {
+		if err := adminSrv.Shutdown(ctx); err != nil {
+			zapLogger.Warnf("admin server forced shutdown: %v", err)
+		}
+	}
+
+	if err := accrualScheduler.Shutdown(ctx); err != nil {
+		zapLogger.Warnf("accrual scheduler forced shutdown: %v", err)
+	}
+
+	if err := webhookDispatcher.Shutdown(ctx); err != nil {
+		zapLogger.Warnf("webhook dispatcher forced shutdown: %v", err)
+	}
+
+	if err := pointsExpirer.Shutdown(ctx); err != nil {
+		zapLogger.Warnf("points expirer forced shutdown: %v", err)
+	}
+
+	if eventsPublisher != nil {
+		if err := eventsPublisher.Shutdown(ctx); err != nil {
+			zapLogger.Warnf("domain events publisher forced shutdown: %v", err)
+		}
+	}
+
+	stopListen()
+
 	if err := storageRepo.Shutdown(); err != nil {
 		return fmt.Errorf("shutdown (repo) error: %v", err)
 	}
 
+	if err := shutdownTracing(ctx); err != nil {
+		zapLogger.Warnf("tracing flush error: %v", err)
+	}
+
 	zapLogger.Info("server shutdown success")
 	return nil
 }
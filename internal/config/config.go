@@ -2,6 +2,8 @@ package config
 
 import (
 	"flag"
+	"os"
+	"strings"
 	"time"
 
 	"github.com/caarlos0/env/v11"
@@ -13,35 +15,190 @@ const (
 	DefaultAccrualSystemAddress = "http://localhost:4000"
 	DefaultPassCost             = 3
 	DefaultSecretKey            = "secret"
-	DefaultTokenLifetime        = 3 * time.Hour
+	DefaultTokenLifetime        = 15 * time.Minute
+	DefaultRefreshTokenLifetime = 30 * 24 * time.Hour
+	DefaultAccrualPollInterval  = 5 * time.Second
+	DefaultAccrualWorkers       = 0 // 0 - по числу CPU
+	DefaultIdempotencyKeyTTL    = 24 * time.Hour
+	DefaultWebhookPollInterval  = 5 * time.Second
+	DefaultWebhookMaxAttempts   = 10
+	DefaultPointsExpiryPeriod   = 0 // 0 - баллы не сгорают
+	DefaultPointsExpiringSoon   = 30 * 24 * time.Hour
+	DefaultPointsExpiryInterval = time.Hour
+	DefaultRateLimitStore       = "memory"
+	DefaultLoginMaxFailures     = 5
+	DefaultLoginFailureWindow   = 15 * time.Minute
+	DefaultLoginLockout         = time.Minute
+	DefaultLoginMaxLockout      = 24 * time.Hour
+	DefaultPasswordMinClasses   = 1
+	DefaultPasswordResetTTL     = time.Hour
+	DefaultPasswordHasher       = "bcrypt"
+	DefaultShutdownDelay        = 0
+	DefaultDatabaseTimeout      = 5 * time.Second
+	DefaultEventsRetention      = 7 * 24 * time.Hour
 )
 
 type Config struct {
 	RunAddress           string        `env:"RUN_ADDRESS"`
 	DatabaseURI          string        `env:"DATABASE_URI"`
 	AccrualSystemAddress string        `env:"ACCRUAL_SYSTEM_ADDRESS"`
+	AccrualPollInterval  time.Duration `env:"ACCRUAL_POLL_INTERVAL"`
+	AccrualWorkers       int           `env:"ACCRUAL_WORKERS"`
 	PassCost             int           `env:"PASS_COST"`
 	SecretKey            string        `env:"SECRET_KEY"`
-	TokenLifetime        time.Duration `env:"TOKEN_LIFETIME" default:"3h"`
+	TokenLifetime        time.Duration `env:"TOKEN_LIFETIME" envDefault:"15m"`
+	RefreshTokenLifetime time.Duration `env:"REFRESH_TOKEN_LIFETIME" envDefault:"720h"`
+	IdempotencyKeyTTL    time.Duration `env:"IDEMPOTENCY_KEY_TTL"`
+	WebhookPollInterval  time.Duration `env:"WEBHOOK_POLL_INTERVAL"`
+	WebhookMaxAttempts   int           `env:"WEBHOOK_MAX_ATTEMPTS"`
+	// PointsExpiryPeriod - срок жизни начисленных баллов (например, 4320h - около полугода).
+	// Действует на новые начисления; 0 - бессрочно.
+	PointsExpiryPeriod time.Duration `env:"POINTS_EXPIRY_PERIOD"`
+	// PointsExpiringSoon - окно, за которое сгорающие баллы показываются в expiring_soon баланса
+	PointsExpiringSoon   time.Duration `env:"POINTS_EXPIRING_SOON"`
+	PointsExpiryInterval time.Duration `env:"POINTS_EXPIRY_INTERVAL"`
+	// EventsSink - куда публиковать ленту доменных событий: "stdout" или "file:<path>".
+	// Пусто - публикация выключена, события копятся в events_outbox.
+	EventsSink string `env:"EVENTS_SINK"`
+	// EventsRetention - сколько хранить опубликованные события в events_outbox; 0 - не удалять
+	EventsRetention time.Duration `env:"EVENTS_RETENTION"`
+	// OrderEventsNotify - доставлять события заказов через Postgres LISTEN/NOTIFY (несколько реплик)
+	OrderEventsNotify bool `env:"ORDER_EVENTS_NOTIFY"`
+	// PEM-файлы ключей подписи токенов (RS256/EdDSA): первый активный подписывает,
+	// выведенные из оборота только проверяют. Если не заданы - HS256 с SecretKey.
+	JWTActiveKeys  []string `env:"JWT_ACTIVE_KEYS" envSeparator:","`
+	JWTRetiredKeys []string `env:"JWT_RETIRED_KEYS" envSeparator:","`
+	// RateLimitStore - где хранятся корзины лимитов: "memory" (на реплику) или "postgres" (общие)
+	RateLimitStore string `env:"RATE_LIMIT_STORE"`
+	// RateLimits - лимиты маршрутов "имя=запросов/период[:всплеск]" для login, register, refresh,
+	// orders, withdraw, password, password_reset (например, "login=10/1m,register=10/1m");
+	// по умолчанию пусто - без ограничений
+	RateLimits []string `env:"RATE_LIMITS" envSeparator:","`
+	// TrustedProxies - адреса и подсети прокси перед сервисом; от них адрес клиента для лимитов
+	// и истории входов берётся из X-Forwarded-For. Пусто - только адрес соединения.
+	TrustedProxies []string `env:"TRUSTED_PROXIES" envSeparator:","`
+	// LoginMaxFailures - неудачных входов за LoginFailureWindow до блокировки логина (0 - не блокировать).
+	// Блокировка длится LoginLockout и удваивается с каждой следующей, но не дольше LoginMaxLockout.
+	LoginMaxFailures   int           `env:"LOGIN_MAX_FAILURES"`
+	LoginFailureWindow time.Duration `env:"LOGIN_FAILURE_WINDOW"`
+	LoginLockout       time.Duration `env:"LOGIN_LOCKOUT"`
+	LoginMaxLockout    time.Duration `env:"LOGIN_MAX_LOCKOUT"`
+	// PasswordMinClasses - сколько классов символов (строчные, прописные, цифры, прочие) нужно в новом пароле
+	PasswordMinClasses int `env:"PASSWORD_MIN_CLASSES"`
+	// PasswordDenylistFile - файл с запрещёнными паролями по одному в строке, в дополнение к встроенному списку
+	PasswordDenylistFile string        `env:"PASSWORD_DENYLIST_FILE"`
+	PasswordResetTTL     time.Duration `env:"PASSWORD_RESET_TTL"`
+	// PasswordHasher - алгоритм новых хешей паролей: "bcrypt" (со стоимостью PassCost) или "argon2id".
+	// Хеши другого алгоритма или стоимости пересчитываются при входе.
+	PasswordHasher string `env:"PASSWORD_HASHER"`
+	// PasswordHashConcurrency - сколько хешей argon2id (по 64 МиБ) считается одновременно; 0 - по числу CPU
+	PasswordHashConcurrency int `env:"PASSWORD_HASH_CONCURRENCY"`
+	// AdminAddress - отдельный служебный листенер с /metrics, /healthz и /readyz, не публикуемый наружу.
+	// По умолчанию выключен: /metrics открывается только явно заданным адресом (например, 127.0.0.1:9090)
+	AdminAddress string `env:"ADMIN_ADDRESS"`
+	// ShutdownDelay - сколько после сигнала /readyz отвечает 503 до остановки сервера,
+	// чтобы балансировщик успел снять под и новые запросы не обрывались
+	ShutdownDelay time.Duration `env:"SHUTDOWN_DELAY"`
+	// TracingExporter - куда отправлять спаны OpenTelemetry: "otlp" (адрес из OTEL_EXPORTER_OTLP_ENDPOINT)
+	// или "stdout" для локальной отладки; пусто - трассировка выключена
+	TracingExporter string `env:"TRACING_EXPORTER"`
+	// DatabaseTimeout - предел одной попытки операции с базой (запрос или транзакция целиком);
+	// повторы и ожидание между ними ограничены контекстом запроса. 0 - без ограничения.
+	DatabaseTimeout time.Duration `env:"DATABASE_TIMEOUT"`
+}
+
+// flagEnvDefaults - флаги полей с envDefault и их переменные окружения
+var flagEnvDefaults = map[string]string{
+	"h":  "TOKEN_LIFETIME",
+	"rh": "REFRESH_TOKEN_LIFETIME",
 }
 
 func Read() (Config, error) {
 	config := Config{}
 
 	flag.StringVar(&config.RunAddress, "a", DefaultRunAddress, "Server run address")
+	flag.StringVar(&config.AdminAddress, "aa", "", "Admin listener address for /metrics (empty - disabled)")
+	flag.DurationVar(&config.ShutdownDelay, "sd", DefaultShutdownDelay, "How long /readyz fails before the server stops on shutdown")
+	flag.StringVar(&config.TracingExporter, "te", "", "OpenTelemetry exporter: otlp or stdout (empty - disabled)")
 	flag.StringVar(&config.DatabaseURI, "d", DefaultDatabaseURI, "Database connect string")
+	flag.DurationVar(&config.DatabaseTimeout, "dt", DefaultDatabaseTimeout, "Database operation timeout per attempt (0 - no timeout)")
 	flag.StringVar(&config.AccrualSystemAddress, "r", DefaultAccrualSystemAddress, "Accrual system address protocol://hostname:port")
+	flag.DurationVar(&config.AccrualPollInterval, "ri", DefaultAccrualPollInterval, "Accrual system poll interval")
+	flag.IntVar(&config.AccrualWorkers, "rw", DefaultAccrualWorkers, "Accrual system poll workers (0 - number of CPUs)")
 
 	flag.IntVar(&config.PassCost, "p", DefaultPassCost, "Pass cost for password hash")
 	flag.StringVar(&config.SecretKey, "s", DefaultSecretKey, "Secret key for token")
-	flag.DurationVar(&config.TokenLifetime, "h", DefaultTokenLifetime, "Token lifetime (e.g. 1h, 30m, 2h30m)")
+	flag.DurationVar(&config.TokenLifetime, "h", DefaultTokenLifetime, "Access token lifetime (e.g. 1h, 30m, 2h30m)")
+	flag.DurationVar(&config.RefreshTokenLifetime, "rh", DefaultRefreshTokenLifetime, "Refresh token (session) lifetime")
+	flag.DurationVar(&config.IdempotencyKeyTTL, "it", DefaultIdempotencyKeyTTL, "How long Idempotency-Key responses are kept")
+	flag.DurationVar(&config.WebhookPollInterval, "wi", DefaultWebhookPollInterval, "Webhook outbox poll interval")
+	flag.IntVar(&config.WebhookMaxAttempts, "wa", DefaultWebhookMaxAttempts, "Webhook delivery attempts before dead-letter")
+	flag.DurationVar(&config.PointsExpiryPeriod, "pe", DefaultPointsExpiryPeriod, "Accrued points lifetime (0 - points never expire)")
+	flag.DurationVar(&config.PointsExpiringSoon, "ps", DefaultPointsExpiringSoon, "Window for expiring_soon in the balance")
+	flag.DurationVar(&config.PointsExpiryInterval, "pi", DefaultPointsExpiryInterval, "How often expired points are debited")
+	flag.StringVar(&config.EventsSink, "es", "", "Domain events sink: stdout or file:<path> (empty - disabled)")
+	flag.DurationVar(&config.EventsRetention, "er", DefaultEventsRetention, "How long published domain events are kept (0 - forever)")
+	flag.BoolVar(&config.OrderEventsNotify, "en", false, "Deliver order events across replicas via Postgres LISTEN/NOTIFY")
+
+	flag.Func("k", "Comma-separated PEM files with active JWT signing keys (first one signs)", func(value string) error {
+		config.JWTActiveKeys = splitList(value)
+		return nil
+	})
+	flag.Func("kr", "Comma-separated PEM files with retired JWT keys (verification only)", func(value string) error {
+		config.JWTRetiredKeys = splitList(value)
+		return nil
+	})
+
+	flag.StringVar(&config.RateLimitStore, "ls", DefaultRateLimitStore, "Rate limit buckets store: memory or postgres")
+	flag.IntVar(&config.LoginMaxFailures, "lf", DefaultLoginMaxFailures, "Failed logins within the window before lockout (0 - never lock)")
+	flag.DurationVar(&config.LoginFailureWindow, "lw", DefaultLoginFailureWindow, "Window for counting failed logins")
+	flag.DurationVar(&config.LoginLockout, "lo", DefaultLoginLockout, "First lockout duration, doubled for each next one")
+	flag.DurationVar(&config.LoginMaxLockout, "lm", DefaultLoginMaxLockout, "Maximum lockout duration")
+	flag.IntVar(&config.PasswordMinClasses, "pm", DefaultPasswordMinClasses, "Character classes required in new passwords (1-4)")
+	flag.StringVar(&config.PasswordDenylistFile, "pd", "", "File with denied passwords, one per line")
+	flag.DurationVar(&config.PasswordResetTTL, "pr", DefaultPasswordResetTTL, "Password reset token lifetime")
+	flag.StringVar(&config.PasswordHasher, "ph", DefaultPasswordHasher, "Password hasher for new hashes: bcrypt or argon2id")
+	flag.IntVar(&config.PasswordHashConcurrency, "pc", 0, "Concurrent argon2id hash computations (0 - number of CPUs)")
+	flag.Func("l", "Comma-separated route rate limits name=requests/period[:burst] (empty - disabled)", func(value string) error {
+		config.RateLimits = splitList(value)
+		return nil
+	})
+	flag.Func("tp", "Comma-separated trusted proxy addresses or CIDRs allowed to set X-Forwarded-For", func(value string) error {
+		config.TrustedProxies = splitList(value)
+		return nil
+	})
 
 	flag.Parse()
 
+	// env.Parse подставляет envDefault и поверх явно заданного флага, если переменная пуста
+	explicit := make(map[string]string)
+	flag.Visit(func(f *flag.Flag) {
+		if name, ok := flagEnvDefaults[f.Name]; ok && os.Getenv(name) == "" {
+			explicit[f.Name] = f.Value.String()
+		}
+	})
+
 	err := env.Parse(&config)
 	if err != nil {
 		return config, err
 	}
 
+	for name, value := range explicit {
+		if err = flag.Set(name, value); err != nil {
+			return config, err
+		}
+	}
+
 	return config, nil
 }
+
+func splitList(value string) []string {
+	result := make([]string, 0)
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+
+	return result
+}
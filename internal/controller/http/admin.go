@@ -0,0 +1,184 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ibeloyar/gophermart/internal/model"
+	"github.com/ibeloyar/gophermart/pgk/auth"
+)
+
+func (c *Controller) AdminSearchUsers(w http.ResponseWriter, r *http.Request) {
+	users, apiErr := c.service.AdminSearchUsers(r.Context(), auth.GetTokenInfo[model.TokenInfo](r).ID, r.URL.Query().Get("login"))
+	if apiErr != nil {
+		http.Error(w, apiErr.Message, apiErr.Code)
+		return
+	}
+
+	writeJSON(w, c.lg, users, http.StatusOK)
+}
+
+func (c *Controller) AdminGetUserOrders(w http.ResponseWriter, r *http.Request) {
+	userID, ok := userIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	filter, err := parseListFilter(r, "uploaded", true)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	page, apiErr := c.service.AdminGetUserOrders(r.Context(), auth.GetTokenInfo[model.TokenInfo](r).ID, userID, filter)
+	if apiErr != nil {
+		http.Error(w, apiErr.Message, apiErr.Code)
+		return
+	}
+
+	writeNextPageHeaders(w, r, page.Next)
+	writeJSON(w, c.lg, page.Orders, http.StatusOK)
+}
+
+func (c *Controller) AdminGetUserLedger(w http.ResponseWriter, r *http.Request) {
+	userID, ok := userIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	filter, err := parseListFilter(r, "created", false)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	page, apiErr := c.service.AdminGetUserLedger(r.Context(), auth.GetTokenInfo[model.TokenInfo](r).ID, userID, filter)
+	if apiErr != nil {
+		http.Error(w, apiErr.Message, apiErr.Code)
+		return
+	}
+
+	writeNextPageHeaders(w, r, page.Next)
+	writeJSON(w, c.lg, page.Entries, http.StatusOK)
+}
+
+func (c *Controller) AdminAdjustBalance(w http.ResponseWriter, r *http.Request) {
+	userID, ok := userIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	body, err := readBody[model.BalanceAdjustmentDTO](r)
+	if err != nil {
+		if errors.Is(err, model.ErrInvalidMoney) {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	entry, apiErr := c.service.AdminAdjustBalance(r.Context(), auth.GetTokenInfo[model.TokenInfo](r).ID, userID, body)
+	if apiErr != nil {
+		http.Error(w, apiErr.Message, apiErr.Code)
+		return
+	}
+
+	writeJSON(w, c.lg, entry, http.StatusCreated)
+}
+
+func (c *Controller) AdminReprocessOrder(w http.ResponseWriter, r *http.Request) {
+	body, err := readBody[model.AdminOrderActionDTO](r)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	apiErr := c.service.AdminReprocessOrder(r.Context(), auth.GetTokenInfo[model.TokenInfo](r).ID, chi.URLParam(r, "number"), body.Reason)
+	if apiErr != nil {
+		http.Error(w, apiErr.Message, apiErr.Code)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (c *Controller) AdminInvalidateOrder(w http.ResponseWriter, r *http.Request) {
+	body, err := readBody[model.AdminOrderActionDTO](r)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	apiErr := c.service.AdminInvalidateOrder(r.Context(), auth.GetTokenInfo[model.TokenInfo](r).ID, chi.URLParam(r, "number"), body.Reason)
+	if apiErr != nil {
+		http.Error(w, apiErr.Message, apiErr.Code)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (c *Controller) AdminGrantRole(w http.ResponseWriter, r *http.Request) {
+	userID, ok := userIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	apiErr := c.service.AdminGrantRole(r.Context(), auth.GetTokenInfo[model.TokenInfo](r).ID, userID, model.Role(chi.URLParam(r, "role")))
+	if apiErr != nil {
+		http.Error(w, apiErr.Message, apiErr.Code)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (c *Controller) AdminRevokeRole(w http.ResponseWriter, r *http.Request) {
+	userID, ok := userIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	apiErr := c.service.AdminRevokeRole(r.Context(), auth.GetTokenInfo[model.TokenInfo](r).ID, userID, model.Role(chi.URLParam(r, "role")))
+	if apiErr != nil {
+		http.Error(w, apiErr.Message, apiErr.Code)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func userIDParam(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil || userID <= 0 {
+		http.Error(w, model.ErrUserNotFoundMessage, http.StatusNotFound)
+		return 0, false
+	}
+
+	return userID, true
+}
+
+// AdminIssuePasswordReset - одноразовый токен сброса пароля; передаётся пользователю вне системы
+func (c *Controller) AdminIssuePasswordReset(w http.ResponseWriter, r *http.Request) {
+	userID, ok := userIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	body, err := readBody[model.AdminPasswordResetDTO](r)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	token, apiErr := c.service.AdminIssuePasswordReset(r.Context(), auth.GetTokenInfo[model.TokenInfo](r).ID, userID, body)
+	if apiErr != nil {
+		http.Error(w, apiErr.Message, apiErr.Code)
+		return
+	}
+
+	writeJSON(w, c.lg, token, http.StatusCreated)
+}
@@ -0,0 +1,138 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/golang/mock/gomock"
+	"github.com/ibeloyar/gophermart/internal/model"
+	"github.com/ibeloyar/gophermart/pgk/auth"
+	"github.com/stretchr/testify/assert"
+
+	service "github.com/ibeloyar/gophermart/internal/service/mocks"
+)
+
+func newAdminRouter(controller *Controller) *chi.Mux {
+	router := chi.NewRouter()
+	router.Route("/api/admin", func(r chi.Router) {
+		r.With(auth.RequireRole[model.TokenInfo](model.RoleSupport, model.RoleAdmin)).Get("/users", controller.AdminSearchUsers)
+
+		r.Group(func(r chi.Router) {
+			r.Use(auth.RequireRole[model.TokenInfo](model.RoleAdmin))
+
+			r.Post("/users/{id}/adjustments", controller.AdminAdjustBalance)
+			r.Put("/users/{id}/roles/{role}", controller.AdminGrantRole)
+			r.Post("/orders/{number}/invalidate", controller.AdminInvalidateOrder)
+		})
+	})
+
+	return router
+}
+
+func TestController_Admin_ForbiddenWithoutRole(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	router := newAdminRouter(New(service.NewMockService(ctrl), nil))
+
+	req := auth.NewAuthenticatedRequest(http.MethodGet, "/api/admin/users?login=bob", &model.TokenInfo{ID: 1, Roles: []model.Role{model.RoleUser}}, nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// поддержка только читает
+	body := strings.NewReader(`{"amount": 10, "reason": "bonus"}`)
+	req = auth.NewAuthenticatedRequest(http.MethodPost, "/api/admin/users/7/adjustments", &model.TokenInfo{ID: 1, Roles: []model.Role{model.RoleSupport}}, body)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestController_AdminGrantRole(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockSvc := service.NewMockService(ctrl)
+	router := newAdminRouter(New(mockSvc, nil))
+
+	mockSvc.EXPECT().AdminGrantRole(gomock.Any(), int64(1), int64(7), model.RoleMerchant).Return(nil).Times(1)
+
+	req := auth.NewAuthenticatedRequest(http.MethodPut, "/api/admin/users/7/roles/merchant", &model.TokenInfo{ID: 1, Roles: []model.Role{model.RoleAdmin}}, nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+}
+
+func TestController_AdminSearchUsers(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockSvc := service.NewMockService(ctrl)
+	router := newAdminRouter(New(mockSvc, nil))
+
+	mockSvc.EXPECT().
+		AdminSearchUsers(gomock.Any(), int64(1), "bob").
+		Return([]model.UserSummary{{ID: 7, Login: "bobby"}}, nil).
+		Times(1)
+
+	req := auth.NewAuthenticatedRequest(http.MethodGet, "/api/admin/users?login=bob", &model.TokenInfo{ID: 1, Roles: []model.Role{model.RoleAdmin}}, nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"login":"bobby"`)
+	assert.NotContains(t, w.Body.String(), "password")
+}
+
+func TestController_AdminAdjustBalance(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockSvc := service.NewMockService(ctrl)
+	router := newAdminRouter(New(mockSvc, nil))
+
+	mockSvc.EXPECT().
+		AdminAdjustBalance(gomock.Any(), int64(1), int64(7), model.BalanceAdjustmentDTO{Amount: -1050, Reason: "chargeback"}).
+		Return(&model.LedgerEntry{ID: 3, Amount: -1050, Kind: model.LedgerKindAdjustment, Reason: "chargeback"}, nil).
+		Times(1)
+
+	body := strings.NewReader(`{"amount": -10.50, "reason": "chargeback"}`)
+	req := auth.NewAuthenticatedRequest(http.MethodPost, "/api/admin/users/7/adjustments", &model.TokenInfo{ID: 1, Roles: []model.Role{model.RoleAdmin}}, body)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Contains(t, w.Body.String(), `"kind":"ADJUSTMENT"`)
+
+	req = auth.NewAuthenticatedRequest(http.MethodPost, "/api/admin/users/abc/adjustments", &model.TokenInfo{ID: 1, Roles: []model.Role{model.RoleAdmin}}, body)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestController_AdminInvalidateOrder_Conflict(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockSvc := service.NewMockService(ctrl)
+	router := newAdminRouter(New(mockSvc, nil))
+
+	mockSvc.EXPECT().
+		AdminInvalidateOrder(gomock.Any(), int64(1), "12345678903", "fraud").
+		Return(&model.APIError{Code: http.StatusConflict, Message: model.ErrOrderAlreadyProcessedMessage}).
+		Times(1)
+
+	body := strings.NewReader(`{"reason": "fraud"}`)
+	req := auth.NewAuthenticatedRequest(http.MethodPost, "/api/admin/orders/12345678903/invalidate", &model.TokenInfo{ID: 1, Roles: []model.Role{model.RoleAdmin}}, body)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
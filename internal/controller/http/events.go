@@ -0,0 +1,89 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ibeloyar/gophermart/internal/model"
+	"github.com/ibeloyar/gophermart/pgk/auth"
+)
+
+// sseKeepAlive - период комментариев-пингов, чтобы прокси не закрывали простаивающий поток
+const sseKeepAlive = 15 * time.Second
+
+// OrderEvents - SSE-поток изменений статусов заказов пользователя (GET /api/user/orders/events)
+func (c *Controller) OrderEvents(w http.ResponseWriter, r *http.Request) {
+	var lastEventID int64
+	if header := r.Header.Get("Last-Event-ID"); header != "" {
+		id, err := strconv.ParseInt(header, 10, 64)
+		if err != nil || id < 0 {
+			http.Error(w, "invalid Last-Event-ID", http.StatusBadRequest)
+			return
+		}
+		lastEventID = id
+	}
+
+	events, apiErr := c.service.SubscribeOrderEvents(r.Context(), auth.GetTokenInfo[model.TokenInfo](r).ID, lastEventID)
+	if apiErr != nil {
+		http.Error(w, apiErr.Message, apiErr.Code)
+		return
+	}
+
+	rc := http.NewResponseController(w)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	if err := rc.Flush(); err != nil {
+		c.lg.Errorf("SSE is not supported by response writer: %v", err)
+		return
+	}
+
+	keepAlive := time.NewTicker(sseKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+
+			if err := writeOrderEvent(w, event); err != nil {
+				return
+			}
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		}
+
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// writeOrderEvent - пишет событие в поток. Сброс отправляется без id, чтобы Last-Event-ID
+// клиента не сдвинулся, пока он заново не запросит список заказов
+func writeOrderEvent(w http.ResponseWriter, event model.OrderEvent) error {
+	if event.Reset {
+		_, err := fmt.Fprint(w, "event: reset\ndata: {}\n\n")
+		return err
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "id: %d\nevent: order\ndata: %s\n\n", event.ID, data)
+
+	return err
+}
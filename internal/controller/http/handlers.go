@@ -1,22 +1,46 @@
 package http
 
 import (
+	"context"
+	"errors"
 	"net/http"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/ibeloyar/gophermart/internal/model"
 	"github.com/ibeloyar/gophermart/pgk/auth"
 	"go.uber.org/zap"
 )
 
+// idempotencyKeyHeader - заголовок, по которому повторные запросы на списание не выполняются дважды
+const idempotencyKeyHeader = "Idempotency-Key"
+
 type Service interface {
-	Register(input model.RegisterDTO) (string, *model.APIError)
-	Login(input model.LoginDTO) (string, *model.APIError)
+	Register(ctx context.Context, input model.RegisterDTO) (*model.Tokens, *model.APIError)
+	Login(ctx context.Context, input model.LoginDTO, client model.ClientInfo) (*model.Tokens, *model.APIError)
+	RefreshTokens(ctx context.Context, refreshToken string) (*model.Tokens, *model.APIError)
+	Logout(ctx context.Context, sessionID string) *model.APIError
+	ChangePassword(ctx context.Context, userID int64, sessionID string, input model.ChangePasswordDTO) *model.APIError
+	ResetPassword(ctx context.Context, input model.ResetPasswordDTO) *model.APIError
+
+	CreateOrder(ctx context.Context, userID int64, orderNumber string) *model.APIError
+	GetOrders(ctx context.Context, userID int64, filter model.ListFilter) (*model.OrdersPage, *model.APIError)
+	GetOrder(ctx context.Context, userID int64, number string) (*model.OrderDetails, *model.APIError)
+	SubscribeOrderEvents(ctx context.Context, userID, lastEventID int64) (<-chan model.OrderEvent, *model.APIError)
+	GetBalance(ctx context.Context, userID int64) (*model.Balance, *model.APIError)
+	SetWithdraw(ctx context.Context, userID int64, input model.SetWithdrawDTO, idempotencyKey string) *model.APIError
+	GetWithdraws(ctx context.Context, userID int64, filter model.ListFilter) (*model.WithdrawsPage, *model.APIError)
+	CancelWithdraw(ctx context.Context, actorID int64, order string, input model.CancelWithdrawDTO) (*model.LedgerEntry, *model.APIError)
+	GetLoginAttempts(ctx context.Context, userID int64, filter model.ListFilter) (*model.LoginAttemptsPage, *model.APIError)
 
-	CreateOrder(userID int64, orderNumber string) *model.APIError
-	GetOrders(userID int64) ([]model.Order, *model.APIError)
-	GetBalance(userID int64) (*model.Balance, *model.APIError)
-	SetWithdraw(userID int64, input model.SetWithdrawDTO) *model.APIError
-	GetWithdraws(userID int64) ([]model.Withdraw, *model.APIError)
+	AdminSearchUsers(ctx context.Context, adminID int64, login string) ([]model.UserSummary, *model.APIError)
+	AdminGetUserOrders(ctx context.Context, adminID, userID int64, filter model.ListFilter) (*model.OrdersPage, *model.APIError)
+	AdminGetUserLedger(ctx context.Context, adminID, userID int64, filter model.ListFilter) (*model.LedgerPage, *model.APIError)
+	AdminAdjustBalance(ctx context.Context, adminID, userID int64, input model.BalanceAdjustmentDTO) (*model.LedgerEntry, *model.APIError)
+	AdminReprocessOrder(ctx context.Context, adminID int64, number, reason string) *model.APIError
+	AdminInvalidateOrder(ctx context.Context, adminID int64, number, reason string) *model.APIError
+	AdminGrantRole(ctx context.Context, adminID, userID int64, role model.Role) *model.APIError
+	AdminRevokeRole(ctx context.Context, adminID, userID int64, role model.Role) *model.APIError
+	AdminIssuePasswordReset(ctx context.Context, adminID, userID int64, input model.AdminPasswordResetDTO) (*model.PasswordResetToken, *model.APIError)
 }
 
 type Controller struct {
@@ -39,14 +63,14 @@ func (c *Controller) Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	bearerToken, apiErr := c.service.Register(body)
+	tokens, apiErr := c.service.Register(r.Context(), body)
 	if apiErr != nil {
 		http.Error(w, apiErr.Message, apiErr.Code)
 		return
 	}
 
-	w.Header().Set("Authorization", bearerToken)
-	w.WriteHeader(http.StatusOK)
+	w.Header().Set("Authorization", tokens.AccessToken)
+	writeJSON(w, c.lg, tokens, http.StatusOK)
 }
 
 func (c *Controller) Login(w http.ResponseWriter, r *http.Request) {
@@ -57,16 +81,77 @@ func (c *Controller) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	bearerToken, apiErr := c.service.Login(body)
+	tokens, apiErr := c.service.Login(r.Context(), body, clientInfo(r))
+	if apiErr != nil {
+		http.Error(w, apiErr.Message, apiErr.Code)
+		return
+	}
+
+	w.Header().Set("Authorization", tokens.AccessToken)
+	writeJSON(w, c.lg, tokens, http.StatusOK)
+}
+
+func (c *Controller) RefreshTokens(w http.ResponseWriter, r *http.Request) {
+	body, err := readBody[model.RefreshTokenDTO](r)
+	if err != nil {
+		c.lg.Errorf("failed to parse request body: %v", err)
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	tokens, apiErr := c.service.RefreshTokens(r.Context(), body.RefreshToken)
+	if apiErr != nil {
+		http.Error(w, apiErr.Message, apiErr.Code)
+		return
+	}
+
+	w.Header().Set("Authorization", tokens.AccessToken)
+	writeJSON(w, c.lg, tokens, http.StatusOK)
+}
+
+func (c *Controller) Logout(w http.ResponseWriter, r *http.Request) {
+	apiErr := c.service.Logout(r.Context(), auth.GetSessionID(r))
 	if apiErr != nil {
 		http.Error(w, apiErr.Message, apiErr.Code)
 		return
 	}
 
-	w.Header().Set("Authorization", bearerToken)
 	w.WriteHeader(http.StatusOK)
 }
 
+// ChangePassword - смена пароля; текущая сессия остаётся, остальные отзываются
+func (c *Controller) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	body, err := readBody[model.ChangePasswordDTO](r)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	apiErr := c.service.ChangePassword(r.Context(), auth.GetTokenInfo[model.TokenInfo](r).ID, auth.GetSessionID(r), body)
+	if apiErr != nil {
+		http.Error(w, apiErr.Message, apiErr.Code)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ResetPassword - новый пароль по одноразовому токену от администратора
+func (c *Controller) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	body, err := readBody[model.ResetPasswordDTO](r)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	if apiErr := c.service.ResetPassword(r.Context(), body); apiErr != nil {
+		http.Error(w, apiErr.Message, apiErr.Code)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
 func (c *Controller) CreateOrder(w http.ResponseWriter, r *http.Request) {
 	orderNumber, err := readBody[string](r)
 	if err != nil {
@@ -75,7 +160,7 @@ func (c *Controller) CreateOrder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	apiErr := c.service.CreateOrder(auth.GetTokenInfo[model.TokenInfo](r).ID, orderNumber)
+	apiErr := c.service.CreateOrder(r.Context(), auth.GetTokenInfo[model.TokenInfo](r).ID, orderNumber)
 	if apiErr != nil {
 		// Если order уже был добавлен текущим пользователем
 		if apiErr.Code == http.StatusOK {
@@ -91,17 +176,34 @@ func (c *Controller) CreateOrder(w http.ResponseWriter, r *http.Request) {
 }
 
 func (c *Controller) GetOrders(w http.ResponseWriter, r *http.Request) {
-	orders, apiErr := c.service.GetOrders(auth.GetTokenInfo[model.TokenInfo](r).ID)
+	filter, err := parseListFilter(r, "uploaded", true)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	page, apiErr := c.service.GetOrders(r.Context(), auth.GetTokenInfo[model.TokenInfo](r).ID, filter)
 	if apiErr != nil {
 		http.Error(w, apiErr.Message, apiErr.Code)
 		return
 	}
 
-	writeJSON(w, c.lg, orders, http.StatusOK)
+	writeNextPageHeaders(w, r, page.Next)
+	writeJSON(w, c.lg, page.Orders, http.StatusOK)
+}
+
+func (c *Controller) GetOrder(w http.ResponseWriter, r *http.Request) {
+	order, apiErr := c.service.GetOrder(r.Context(), auth.GetTokenInfo[model.TokenInfo](r).ID, chi.URLParam(r, "number"))
+	if apiErr != nil {
+		http.Error(w, apiErr.Message, apiErr.Code)
+		return
+	}
+
+	writeJSON(w, c.lg, order, http.StatusOK)
 }
 
 func (c *Controller) GetBalance(w http.ResponseWriter, r *http.Request) {
-	balance, apiErr := c.service.GetBalance(auth.GetTokenInfo[model.TokenInfo](r).ID)
+	balance, apiErr := c.service.GetBalance(r.Context(), auth.GetTokenInfo[model.TokenInfo](r).ID)
 	if apiErr != nil {
 		http.Error(w, apiErr.Message, apiErr.Code)
 		return
@@ -113,12 +215,15 @@ func (c *Controller) GetBalance(w http.ResponseWriter, r *http.Request) {
 func (c *Controller) SetWithdrawal(w http.ResponseWriter, r *http.Request) {
 	body, err := readBody[model.SetWithdrawDTO](r)
 	if err != nil {
-		c.lg.Errorf("failed to parse request body: %v", err)
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		if errors.Is(err, model.ErrInvalidMoney) {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
 		return
 	}
 
-	apiErr := c.service.SetWithdraw(auth.GetTokenInfo[model.TokenInfo](r).ID, body)
+	apiErr := c.service.SetWithdraw(r.Context(), auth.GetTokenInfo[model.TokenInfo](r).ID, body, r.Header.Get(idempotencyKeyHeader))
 	if apiErr != nil {
 		http.Error(w, apiErr.Message, apiErr.Code)
 		return
@@ -128,16 +233,63 @@ func (c *Controller) SetWithdrawal(w http.ResponseWriter, r *http.Request) {
 }
 
 func (c *Controller) GetWithdrawals(w http.ResponseWriter, r *http.Request) {
-	withdrawals, apiErr := c.service.GetWithdraws(auth.GetTokenInfo[model.TokenInfo](r).ID)
+	filter, err := parseListFilter(r, "processed", false)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	page, apiErr := c.service.GetWithdraws(r.Context(), auth.GetTokenInfo[model.TokenInfo](r).ID, filter)
+	if apiErr != nil {
+		http.Error(w, apiErr.Message, apiErr.Code)
+		return
+	}
+
+	if len(page.Withdraws) == 0 {
+		writeJSON(w, c.lg, page.Withdraws, http.StatusNoContent)
+		return
+	}
+
+	writeNextPageHeaders(w, r, page.Next)
+	writeJSON(w, c.lg, page.Withdraws, http.StatusOK)
+}
+
+// CancelWithdrawal - возврат баллов по отменённому заказу магазина (только администратор)
+func (c *Controller) CancelWithdrawal(w http.ResponseWriter, r *http.Request) {
+	body, err := readBody[model.CancelWithdrawDTO](r)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	entry, apiErr := c.service.CancelWithdraw(r.Context(), auth.GetTokenInfo[model.TokenInfo](r).ID, chi.URLParam(r, "order"), body)
+	if apiErr != nil {
+		http.Error(w, apiErr.Message, apiErr.Code)
+		return
+	}
+
+	writeJSON(w, c.lg, entry, http.StatusOK)
+}
+
+// GetLoginAttempts - последние входы в аккаунт с IP и User-Agent
+func (c *Controller) GetLoginAttempts(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseListFilter(r, "created", false)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	page, apiErr := c.service.GetLoginAttempts(r.Context(), auth.GetTokenInfo[model.TokenInfo](r).ID, filter)
 	if apiErr != nil {
 		http.Error(w, apiErr.Message, apiErr.Code)
 		return
 	}
 
-	if len(withdrawals) == 0 {
-		writeJSON(w, c.lg, withdrawals, http.StatusNoContent)
+	if len(page.Attempts) == 0 {
+		writeJSON(w, c.lg, page.Attempts, http.StatusNoContent)
 		return
 	}
 
-	writeJSON(w, c.lg, withdrawals, http.StatusOK)
+	writeNextPageHeaders(w, r, page.Next)
+	writeJSON(w, c.lg, page.Attempts, http.StatusOK)
 }
@@ -6,7 +6,9 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/golang/mock/gomock"
 	"github.com/ibeloyar/gophermart/internal/model"
 	"github.com/ibeloyar/gophermart/pgk/auth"
@@ -28,8 +30,8 @@ func TestController_Register_Success(t *testing.T) {
 	}
 
 	mockSvc.EXPECT().
-		Register(input).
-		Return("Bearer token123", nil).
+		Register(gomock.Any(), input).
+		Return(&model.Tokens{AccessToken: "Bearer token123", RefreshToken: "refresh123"}, nil).
 		Times(1)
 
 	body, _ := json.Marshal(input)
@@ -40,6 +42,7 @@ func TestController_Register_Success(t *testing.T) {
 
 	assert.Equal(t, http.StatusOK, w.Code)
 	assert.Equal(t, "Bearer token123", w.Header().Get("Authorization"))
+	assert.Contains(t, w.Body.String(), `"refresh_token":"refresh123"`)
 }
 
 func TestController_Login_Success(t *testing.T) {
@@ -56,17 +59,84 @@ func TestController_Login_Success(t *testing.T) {
 
 	body, _ := json.Marshal(input)
 	req := httptest.NewRequest(http.MethodPost, "/login", bytes.NewReader(body))
+	req.Header.Set("User-Agent", "curl/8.0")
 	w := httptest.NewRecorder()
 
 	mockSvc.EXPECT().
-		Login(input).
-		Return("Bearer token123", nil).
+		Login(gomock.Any(), input, model.ClientInfo{IP: "192.0.2.1", UserAgent: "curl/8.0"}).
+		Return(&model.Tokens{AccessToken: "Bearer token123", RefreshToken: "refresh123"}, nil).
 		Times(1)
 
 	controller.Login(w, req)
 
 	assert.Equal(t, http.StatusOK, w.Code)
 	assert.Equal(t, "Bearer token123", w.Header().Get("Authorization"))
+	assert.Contains(t, w.Body.String(), `"refresh_token":"refresh123"`)
+}
+
+func TestController_RefreshTokens_Success(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockSvc := service.NewMockService(ctrl)
+	controller := New(mockSvc, nil)
+
+	mockSvc.EXPECT().
+		RefreshTokens(gomock.Any(), "refresh123").
+		Return(&model.Tokens{AccessToken: "Bearer token456", RefreshToken: "refresh456"}, nil).
+		Times(1)
+
+	body, _ := json.Marshal(model.RefreshTokenDTO{RefreshToken: "refresh123"})
+	req := httptest.NewRequest(http.MethodPost, "/api/user/token/refresh", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+
+	controller.RefreshTokens(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "Bearer token456", w.Header().Get("Authorization"))
+	assert.Contains(t, w.Body.String(), `"refresh_token":"refresh456"`)
+}
+
+func TestController_RefreshTokens_Unauthorized(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockSvc := service.NewMockService(ctrl)
+	controller := New(mockSvc, nil)
+
+	mockSvc.EXPECT().
+		RefreshTokens(gomock.Any(), "stale").
+		Return(nil, &model.APIError{Code: http.StatusUnauthorized, Message: model.ErrInvalidRefreshTokenMessage}).
+		Times(1)
+
+	body, _ := json.Marshal(model.RefreshTokenDTO{RefreshToken: "stale"})
+	req := httptest.NewRequest(http.MethodPost, "/api/user/token/refresh", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+
+	controller.RefreshTokens(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestController_Logout_Success(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockSvc := service.NewMockService(ctrl)
+	controller := New(mockSvc, nil)
+
+	mockSvc.EXPECT().
+		Logout(gomock.Any(), "session-1").
+		Return(nil).
+		Times(1)
+
+	req := auth.NewAuthenticatedRequest(http.MethodPost, "/api/user/logout", &model.TokenInfo{ID: 123}, nil)
+	req = req.WithContext(auth.ContextWithSessionID(req.Context(), "session-1"))
+	w := httptest.NewRecorder()
+
+	controller.Logout(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
 }
 
 func TestController_CreateOrder_Success(t *testing.T) {
@@ -80,7 +150,7 @@ func TestController_CreateOrder_Success(t *testing.T) {
 	userID := int64(123)
 
 	mockSvc.EXPECT().
-		CreateOrder(userID, orderNumber).
+		CreateOrder(gomock.Any(), userID, orderNumber).
 		Return(nil).
 		Times(1)
 
@@ -111,7 +181,7 @@ func TestController_CreateOrder_AlreadyLoadedCurrentUser(t *testing.T) {
 	}
 
 	mockSvc.EXPECT().
-		CreateOrder(userID, orderNumber).
+		CreateOrder(gomock.Any(), userID, orderNumber).
 		Return(apiErr).
 		Times(1)
 
@@ -142,7 +212,7 @@ func TestController_CreateOrder_ConflictOtherUser(t *testing.T) {
 	}
 
 	mockSvc.EXPECT().
-		CreateOrder(userID, orderNumber).
+		CreateOrder(gomock.Any(), userID, orderNumber).
 		Return(apiErr).
 		Times(1)
 
@@ -169,8 +239,8 @@ func TestController_GetOrders_Success(t *testing.T) {
 	orders := []model.Order{{Number: "order-123"}}
 
 	mockSvc.EXPECT().
-		GetOrders(userID).
-		Return(orders, nil).
+		GetOrders(gomock.Any(), userID, model.ListFilter{}).
+		Return(&model.OrdersPage{Orders: orders}, nil).
 		Times(1)
 
 	req := auth.NewAuthenticatedRequest(http.MethodGet, "/orders", &model.TokenInfo{ID: userID}, nil)
@@ -198,7 +268,7 @@ func TestController_GetOrders_ServiceError(t *testing.T) {
 	}
 
 	mockSvc.EXPECT().
-		GetOrders(userID).
+		GetOrders(gomock.Any(), userID, model.ListFilter{}).
 		Return(nil, apiErr).
 		Times(1)
 
@@ -213,6 +283,146 @@ func TestController_GetOrders_ServiceError(t *testing.T) {
 	assert.Equal(t, http.StatusInternalServerError, w.Code)
 }
 
+func TestController_GetOrders_Paginated(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockSvc := service.NewMockService(ctrl)
+	controller := New(mockSvc, nil)
+
+	userID := int64(123)
+	after := model.Cursor{At: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), ID: 10}
+	next := model.Cursor{At: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), ID: 7}
+	from := time.Date(2023, 12, 1, 0, 0, 0, 0, time.UTC)
+
+	mockSvc.EXPECT().
+		GetOrders(gomock.Any(), userID, model.ListFilter{
+			Limit:    2,
+			After:    &model.Cursor{At: after.At.Local(), ID: 10},
+			Statuses: []model.OrderStatus{model.OrderStatusNew, model.OrderStatusProcessed},
+			From:     &from,
+			Sort:     model.SortAsc,
+		}).
+		Return(&model.OrdersPage{Orders: []model.Order{{Number: "1"}, {Number: "2"}}, Next: &next}, nil).
+		Times(1)
+
+	url := "/api/user/orders?limit=2&sort=asc&status=NEW,processed&uploaded_from=2023-12-01T00:00:00Z&after=" + after.String()
+	req := auth.NewAuthenticatedRequest(http.MethodGet, url, &model.TokenInfo{ID: userID}, nil)
+	w := httptest.NewRecorder()
+
+	controller.GetOrders(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, next.String(), w.Header().Get("X-Next-Cursor"))
+	assert.Contains(t, w.Header().Get("Link"), "after="+next.String())
+	assert.Contains(t, w.Header().Get("Link"), `rel="next"`)
+}
+
+func TestController_GetOrders_InvalidQuery(t *testing.T) {
+	tests := []string{
+		"/api/user/orders?limit=abc",
+		"/api/user/orders?after=not-a-cursor",
+		"/api/user/orders?uploaded_to=yesterday",
+	}
+
+	for _, url := range tests {
+		t.Run(url, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			controller := New(service.NewMockService(ctrl), nil)
+
+			req := auth.NewAuthenticatedRequest(http.MethodGet, url, &model.TokenInfo{ID: 123}, nil)
+			w := httptest.NewRecorder()
+
+			controller.GetOrders(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+		})
+	}
+}
+
+func TestController_GetOrder(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockSvc := service.NewMockService(ctrl)
+	controller := New(mockSvc, nil)
+
+	userID := int64(123)
+
+	mockSvc.EXPECT().
+		GetOrder(gomock.Any(), userID, "12345678903").
+		Return(&model.OrderDetails{
+			Order:   model.Order{Number: "12345678903", Status: model.OrderStatusProcessing},
+			History: []model.OrderStatusChange{{Status: model.OrderStatusNew}, {Status: model.OrderStatusProcessing}},
+		}, nil).
+		Times(1)
+	mockSvc.EXPECT().
+		GetOrder(gomock.Any(), userID, "unknown").
+		Return(nil, &model.APIError{Code: http.StatusNotFound, Message: model.ErrOrderNotFoundMessage}).
+		Times(1)
+
+	router := chi.NewRouter()
+	router.Get("/api/user/orders/{number}", controller.GetOrder)
+
+	req := auth.NewAuthenticatedRequest(http.MethodGet, "/api/user/orders/12345678903", &model.TokenInfo{ID: userID}, nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"history":[{"status":"NEW"`)
+
+	req = auth.NewAuthenticatedRequest(http.MethodGet, "/api/user/orders/unknown", &model.TokenInfo{ID: userID}, nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestController_OrderEvents(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockSvc := service.NewMockService(ctrl)
+	controller := New(mockSvc, nil)
+
+	userID := int64(123)
+	events := make(chan model.OrderEvent, 1)
+	events <- model.OrderEvent{ID: 42, UserID: userID, Number: "12345678903", Status: model.OrderStatusProcessed, Accrual: 50000}
+	close(events)
+
+	mockSvc.EXPECT().
+		SubscribeOrderEvents(gomock.Any(), userID, int64(41)).
+		Return((<-chan model.OrderEvent)(events), nil).
+		Times(1)
+
+	req := auth.NewAuthenticatedRequest(http.MethodGet, "/api/user/orders/events", &model.TokenInfo{ID: userID}, nil)
+	req.Header.Set("Last-Event-ID", "41")
+	w := httptest.NewRecorder()
+
+	controller.OrderEvents(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/event-stream", w.Header().Get("Content-Type"))
+	assert.Equal(t, "id: 42\nevent: order\ndata: {\"number\":\"12345678903\",\"status\":\"PROCESSED\",\"accrual\":500,\"changed_at\":\"\"}\n\n", w.Body.String())
+}
+
+func TestController_OrderEvents_InvalidLastEventID(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	controller := New(service.NewMockService(ctrl), nil)
+
+	req := auth.NewAuthenticatedRequest(http.MethodGet, "/api/user/orders/events", &model.TokenInfo{ID: 123}, nil)
+	req.Header.Set("Last-Event-ID", "abc")
+	w := httptest.NewRecorder()
+
+	controller.OrderEvents(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
 func TestController_GetBalance_Success(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -221,10 +431,10 @@ func TestController_GetBalance_Success(t *testing.T) {
 	controller := New(mockSvc, nil)
 
 	userID := int64(123)
-	balance := &model.Balance{Current: 100.5, Withdrawn: 50.0}
+	balance := &model.Balance{Current: 10050, Withdrawn: 5000}
 
 	mockSvc.EXPECT().
-		GetBalance(userID).
+		GetBalance(gomock.Any(), userID).
 		Return(balance, nil).
 		Times(1)
 
@@ -247,10 +457,10 @@ func TestController_SetWithdrawal_Success(t *testing.T) {
 	controller := New(mockSvc, nil)
 
 	userID := int64(123)
-	withdraw := model.SetWithdrawDTO{Order: "order-123", Sum: 10.5}
+	withdraw := model.SetWithdrawDTO{Order: "order-123", Sum: 1050}
 
 	mockSvc.EXPECT().
-		SetWithdraw(userID, withdraw).
+		SetWithdraw(gomock.Any(), userID, withdraw, "").
 		Return(nil).
 		Times(1)
 
@@ -267,6 +477,49 @@ func TestController_SetWithdrawal_Success(t *testing.T) {
 	assert.Equal(t, http.StatusOK, w.Code)
 }
 
+func TestController_SetWithdrawal_IdempotencyKey(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockSvc := service.NewMockService(ctrl)
+	controller := New(mockSvc, nil)
+
+	userID := int64(123)
+	withdraw := model.SetWithdrawDTO{Order: "order-123", Sum: 1050}
+
+	mockSvc.EXPECT().
+		SetWithdraw(gomock.Any(), userID, withdraw, "key-1").
+		Return(&model.APIError{Code: http.StatusUnprocessableEntity, Message: model.ErrIdempotencyKeyReusedMessage}).
+		Times(1)
+
+	body, _ := json.Marshal(withdraw)
+
+	req := auth.NewAuthenticatedRequest(http.MethodPost, "/withdraw", &model.TokenInfo{ID: userID}, bytes.NewReader(body))
+	req.Header.Set("Idempotency-Key", "key-1")
+	w := httptest.NewRecorder()
+
+	controller.SetWithdrawal(w, req)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+}
+
+func TestController_SetWithdrawal_InvalidSumPrecision(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockSvc := service.NewMockService(ctrl)
+	controller := New(mockSvc, nil)
+
+	body := []byte(`{"order": "order-123", "sum": 10.555}`)
+
+	req := auth.NewAuthenticatedRequest(http.MethodPost, "/withdraw", &model.TokenInfo{ID: 123}, bytes.NewReader(body))
+	w := httptest.NewRecorder()
+
+	controller.SetWithdrawal(w, req)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+}
+
 func TestController_GetWithdrawals_Empty(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -277,8 +530,8 @@ func TestController_GetWithdrawals_Empty(t *testing.T) {
 	userID := int64(123)
 
 	mockSvc.EXPECT().
-		GetWithdraws(userID).
-		Return([]model.Withdraw{}, nil).
+		GetWithdraws(gomock.Any(), userID, model.ListFilter{}).
+		Return(&model.WithdrawsPage{Withdraws: []model.Withdraw{}}, nil).
 		Times(1)
 
 	req := auth.NewAuthenticatedRequest(http.MethodGet, "/withdrawals", &model.TokenInfo{ID: userID}, nil)
@@ -304,8 +557,8 @@ func TestController_GetWithdrawals_WithData(t *testing.T) {
 	withdrawals := []model.Withdraw{{OrderNumber: "order-123"}}
 
 	mockSvc.EXPECT().
-		GetWithdraws(userID).
-		Return(withdrawals, nil).
+		GetWithdraws(gomock.Any(), userID, model.ListFilter{}).
+		Return(&model.WithdrawsPage{Withdraws: withdrawals}, nil).
 		Times(1)
 
 	req := auth.NewAuthenticatedRequest(http.MethodGet, "/withdrawals", &model.TokenInfo{ID: userID}, nil)
@@ -318,3 +571,77 @@ func TestController_GetWithdrawals_WithData(t *testing.T) {
 
 	assert.Equal(t, http.StatusOK, w.Code)
 }
+
+func TestController_CancelWithdrawal(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockSvc := service.NewMockService(ctrl)
+	controller := New(mockSvc, nil)
+
+	router := chi.NewRouter()
+	router.With(auth.RequireRole[model.TokenInfo](model.RoleAdmin)).
+		Post("/api/user/withdrawals/{order}/cancel", controller.CancelWithdrawal)
+
+	mockSvc.EXPECT().
+		CancelWithdraw(gomock.Any(), int64(5), "12345678903", model.CancelWithdrawDTO{Reason: "order cancelled"}).
+		Return(&model.LedgerEntry{ID: 12, Order: "12345678903", Amount: 1050, Kind: model.LedgerKindRefund}, nil).
+		Times(1)
+
+	body := []byte(`{"reason": "order cancelled"}`)
+	req := auth.NewAuthenticatedRequest(http.MethodPost, "/api/user/withdrawals/12345678903/cancel",
+		&model.TokenInfo{ID: 5, Roles: []model.Role{model.RoleAdmin}}, bytes.NewReader(body))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"kind":"REFUND"`)
+
+	// обычный пользователь не может вернуть себе баллы, мерчант - списания чужих магазинов
+	for _, role := range []model.Role{model.RoleUser, model.RoleMerchant} {
+		req = auth.NewAuthenticatedRequest(http.MethodPost, "/api/user/withdrawals/12345678903/cancel",
+			&model.TokenInfo{ID: 123, Roles: []model.Role{role}}, bytes.NewReader(body))
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	}
+}
+
+func TestController_SetWithdrawal_MalformedBody(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	controller := New(service.NewMockService(ctrl), nil)
+
+	body := []byte(`{"order": 27220117637, "sum": "ten"}`)
+	req := auth.NewAuthenticatedRequest(http.MethodPost, "/withdraw", &model.TokenInfo{ID: 123}, bytes.NewReader(body))
+	w := httptest.NewRecorder()
+
+	controller.SetWithdrawal(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestController_GetLoginAttempts(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockSvc := service.NewMockService(ctrl)
+	controller := New(mockSvc, nil)
+
+	mockSvc.EXPECT().
+		GetLoginAttempts(gomock.Any(), int64(123), model.ListFilter{Limit: 10}).
+		Return(&model.LoginAttemptsPage{Attempts: []model.LoginAttempt{
+			{ID: 2, Login: "testuser", UserID: 123, Result: model.LoginResultInvalidCredentials, IP: "10.0.0.1", UserAgent: "curl/8.0"},
+		}}, nil)
+
+	req := auth.NewAuthenticatedRequest(http.MethodGet, "/api/user/security/logins?limit=10", &model.TokenInfo{ID: 123}, nil)
+	w := httptest.NewRecorder()
+
+	controller.GetLoginAttempts(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"result":"INVALID_CREDENTIALS","ip":"10.0.0.1","user_agent":"curl/8.0"`)
+	assert.NotContains(t, w.Body.String(), "testuser")
+}
@@ -4,12 +4,17 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"strings"
 
+	"github.com/ibeloyar/gophermart/internal/model"
 	"go.uber.org/zap"
 )
 
+// maxUserAgentLength - длиннее в истории входов не храним
+const maxUserAgentLength = 512
+
 // readBody - читает и парсит JSON и Text/Plain тело запроса в структуру T
 func readBody[T any](r *http.Request) (T, error) {
 	var body T
@@ -62,3 +67,21 @@ func writeJSON(w http.ResponseWriter, lg *zap.SugaredLogger, data interface{}, s
 
 	w.Write(response)
 }
+
+// clientInfo - адрес и User-Agent клиента для истории входов
+func clientInfo(r *http.Request) model.ClientInfo {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	userAgent := r.UserAgent()
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = strings.ToValidUTF8(userAgent[:maxUserAgentLength], "")
+	}
+
+	return model.ClientInfo{
+		IP:        host,
+		UserAgent: userAgent,
+	}
+}
@@ -0,0 +1,85 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ibeloyar/gophermart/internal/model"
+)
+
+const nextCursorHeader = "X-Next-Cursor"
+
+// parseListFilter - разбирает limit, after, sort, status и границы периода
+// (<rangeParam>_from, <rangeParam>_to в RFC 3339) из строки запроса
+func parseListFilter(r *http.Request, rangeParam string, withStatus bool) (model.ListFilter, error) {
+	query := r.URL.Query()
+	filter := model.ListFilter{Sort: model.SortOrder(query.Get("sort"))}
+
+	if limit := query.Get("limit"); limit != "" {
+		value, err := strconv.Atoi(limit)
+		if err != nil {
+			return filter, fmt.Errorf("invalid limit: %w", err)
+		}
+		filter.Limit = value
+	}
+
+	if after := query.Get("after"); after != "" {
+		cursor, err := model.ParseCursor(after)
+		if err != nil {
+			return filter, err
+		}
+		filter.After = cursor
+	}
+
+	if status := query.Get("status"); status != "" {
+		if !withStatus {
+			return filter, fmt.Errorf("status filter is not supported")
+		}
+		for _, s := range strings.Split(status, ",") {
+			filter.Statuses = append(filter.Statuses, model.OrderStatus(strings.ToUpper(strings.TrimSpace(s))))
+		}
+	}
+
+	var err error
+	if filter.From, err = parseTimeParam(query.Get(rangeParam + "_from")); err != nil {
+		return filter, err
+	}
+	if filter.To, err = parseTimeParam(query.Get(rangeParam + "_to")); err != nil {
+		return filter, err
+	}
+
+	return filter, nil
+}
+
+func parseTimeParam(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time %q: %w", value, err)
+	}
+
+	return &t, nil
+}
+
+// writeNextPageHeaders - курсор следующей страницы в X-Next-Cursor и ссылка в Link (RFC 8288)
+func writeNextPageHeaders(w http.ResponseWriter, r *http.Request, next *model.Cursor) {
+	if next == nil {
+		return
+	}
+
+	cursor := next.String()
+
+	query := r.URL.Query()
+	query.Set("after", cursor)
+	nextURL := *r.URL
+	nextURL.RawQuery = query.Encode()
+
+	w.Header().Set(nextCursorHeader, cursor)
+	w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, nextURL.RequestURI()))
+}
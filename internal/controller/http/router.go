@@ -2,36 +2,101 @@ package http
 
 import (
 	"net/http"
+	"strconv"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/ibeloyar/gophermart/internal/model"
 	"github.com/ibeloyar/gophermart/pgk/auth"
+	"github.com/ibeloyar/gophermart/pgk/ratelimit"
 )
 
 type Handlers interface {
 	Register(w http.ResponseWriter, r *http.Request)
 	Login(w http.ResponseWriter, r *http.Request)
+	RefreshTokens(w http.ResponseWriter, r *http.Request)
+	Logout(w http.ResponseWriter, r *http.Request)
+	ChangePassword(w http.ResponseWriter, r *http.Request)
+	ResetPassword(w http.ResponseWriter, r *http.Request)
 	CreateOrder(w http.ResponseWriter, r *http.Request)
 	GetOrders(w http.ResponseWriter, r *http.Request)
+	GetOrder(w http.ResponseWriter, r *http.Request)
+	OrderEvents(w http.ResponseWriter, r *http.Request)
 	GetBalance(w http.ResponseWriter, r *http.Request)
 	SetWithdrawal(w http.ResponseWriter, r *http.Request)
 	GetWithdrawals(w http.ResponseWriter, r *http.Request)
+	CancelWithdrawal(w http.ResponseWriter, r *http.Request)
+	GetLoginAttempts(w http.ResponseWriter, r *http.Request)
+
+	AdminSearchUsers(w http.ResponseWriter, r *http.Request)
+	AdminGetUserOrders(w http.ResponseWriter, r *http.Request)
+	AdminGetUserLedger(w http.ResponseWriter, r *http.Request)
+	AdminAdjustBalance(w http.ResponseWriter, r *http.Request)
+	AdminReprocessOrder(w http.ResponseWriter, r *http.Request)
+	AdminInvalidateOrder(w http.ResponseWriter, r *http.Request)
+	AdminGrantRole(w http.ResponseWriter, r *http.Request)
+	AdminRevokeRole(w http.ResponseWriter, r *http.Request)
+	AdminIssuePasswordReset(w http.ResponseWriter, r *http.Request)
 }
 
-func InitRoutes(r *chi.Mux, handlers Handlers, secret string) *chi.Mux {
-	r.Post("/api/user/register", handlers.Register)
-	r.Post("/api/user/login", handlers.Login)
+// userRateKey - ключ лимита по пользователю из токена
+func userRateKey(r *http.Request) (string, bool) {
+	info := auth.GetTokenInfo[model.TokenInfo](r)
+	if info == nil {
+		return "", false
+	}
+
+	return "user:" + strconv.FormatInt(info.ID, 10), true
+}
+
+func InitRoutes(r *chi.Mux, handlers Handlers, keys *auth.KeySet, isRevoked auth.SessionRevokedFunc, limiter *ratelimit.Limiter) *chi.Mux {
+	r.Get("/.well-known/jwks.json", auth.JWKSHandler(keys))
+
+	r.With(limiter.Handler("register", ratelimit.ByIP)).Post("/api/user/register", handlers.Register)
+	r.With(limiter.Handler("login", ratelimit.ByIP)).Post("/api/user/login", handlers.Login)
+	r.With(limiter.Handler("refresh", ratelimit.ByIP)).Post("/api/user/token/refresh", handlers.RefreshTokens)
+	r.With(limiter.Handler("password_reset", ratelimit.ByIP)).Post("/api/user/password/reset", handlers.ResetPassword)
 
 	r.Group(func(r chi.Router) {
-		authMiddleware := auth.AuthBearerMiddlewareInit[model.TokenInfo](secret)
+		authMiddleware := auth.AuthBearerMiddlewareInit[model.TokenInfo](keys, isRevoked)
 
 		r.Use(authMiddleware)
 
-		r.Post("/api/user/orders", handlers.CreateOrder)
+		r.Post("/api/user/logout", handlers.Logout)
+		r.With(limiter.Handler("password", userRateKey)).Post("/api/user/password", handlers.ChangePassword)
+
+		r.With(limiter.Handler("orders", userRateKey)).Post("/api/user/orders", handlers.CreateOrder)
 		r.Get("/api/user/orders", handlers.GetOrders)
+		r.Get("/api/user/orders/events", handlers.OrderEvents)
+		r.Get("/api/user/orders/{number}", handlers.GetOrder)
 		r.Get("/api/user/balance", handlers.GetBalance)
-		r.Post("/api/user/balance/withdraw", handlers.SetWithdrawal)
+		r.With(limiter.Handler("withdraw", userRateKey)).Post("/api/user/balance/withdraw", handlers.SetWithdrawal)
 		r.Get("/api/user/withdrawals", handlers.GetWithdrawals)
+		// списания не привязаны к магазину, поэтому мерчант мог бы вернуть любое - пока только администратор
+		r.With(auth.RequireRole[model.TokenInfo](model.RoleAdmin)).
+			Post("/api/user/withdrawals/{order}/cancel", handlers.CancelWithdrawal)
+		r.Get("/api/user/security/logins", handlers.GetLoginAttempts)
+
+		r.Route("/api/admin", func(r chi.Router) {
+			// просмотр доступен поддержке, изменения - только администраторам
+			r.Group(func(r chi.Router) {
+				r.Use(auth.RequireRole[model.TokenInfo](model.RoleSupport, model.RoleAdmin))
+
+				r.Get("/users", handlers.AdminSearchUsers)
+				r.Get("/users/{id}/orders", handlers.AdminGetUserOrders)
+				r.Get("/users/{id}/ledger", handlers.AdminGetUserLedger)
+			})
+
+			r.Group(func(r chi.Router) {
+				r.Use(auth.RequireRole[model.TokenInfo](model.RoleAdmin))
+
+				r.Post("/users/{id}/adjustments", handlers.AdminAdjustBalance)
+				r.Put("/users/{id}/roles/{role}", handlers.AdminGrantRole)
+				r.Delete("/users/{id}/roles/{role}", handlers.AdminRevokeRole)
+				r.Post("/users/{id}/password-reset", handlers.AdminIssuePasswordReset)
+				r.Post("/orders/{number}/reprocess", handlers.AdminReprocessOrder)
+				r.Post("/orders/{number}/invalidate", handlers.AdminInvalidateOrder)
+			})
+		})
 	})
 
 	return r
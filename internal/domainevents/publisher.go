@@ -0,0 +1,112 @@
+package domainevents
+
+import (
+	"context"
+	"time"
+
+	"github.com/ibeloyar/gophermart/internal/model"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultPollInterval = time.Second
+	defaultBatchSize    = 500
+)
+
+// OutboxStore - хранилище events_outbox
+type OutboxStore interface {
+	// PublishOutboxEvents - передаёт publish пачку неопубликованных событий по порядку
+	// и помечает их опубликованными при успехе
+	PublishOutboxEvents(ctx context.Context, limit int, publish func([]model.DomainEvent) error) (int, error)
+}
+
+type Config struct {
+	PollInterval time.Duration
+	BatchSize    int
+}
+
+// Publisher - периодически переносит события из outbox в sink
+type Publisher struct {
+	store OutboxStore
+	sink  Sink
+	cfg   Config
+	lg    *zap.SugaredLogger
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func NewPublisher(store OutboxStore, sink Sink, cfg Config, lg *zap.SugaredLogger) *Publisher {
+	if cfg.PollInterval == 0 {
+		cfg.PollInterval = defaultPollInterval
+	}
+	if cfg.BatchSize == 0 {
+		cfg.BatchSize = defaultBatchSize
+	}
+
+	return &Publisher{
+		store: store,
+		sink:  sink,
+		cfg:   cfg,
+		lg:    lg,
+	}
+}
+
+// Start - запускает публикацию в отдельной горутине
+func (p *Publisher) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	p.cancel = cancel
+	p.done = make(chan struct{})
+
+	go p.run(ctx)
+}
+
+// Shutdown - останавливает публикацию, ждёт текущую пачку и закрывает sink
+func (p *Publisher) Shutdown(ctx context.Context) error {
+	if p.cancel == nil {
+		return nil
+	}
+
+	p.cancel()
+
+	select {
+	case <-p.done:
+		return p.sink.Close()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (p *Publisher) run(ctx context.Context) {
+	defer close(p.done)
+
+	ticker := time.NewTicker(p.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.poll(ctx)
+		}
+	}
+}
+
+// poll - публикует пачки, пока в outbox есть события
+func (p *Publisher) poll(ctx context.Context) {
+	for ctx.Err() == nil {
+		published, err := p.store.PublishOutboxEvents(ctx, p.cfg.BatchSize, func(events []model.DomainEvent) error {
+			return p.sink.Write(ctx, events)
+		})
+		if err != nil {
+			p.lg.Errorf("publishing domain events error: %v", err)
+			return
+		}
+
+		if published < p.cfg.BatchSize {
+			return
+		}
+	}
+}
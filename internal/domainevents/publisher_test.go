@@ -0,0 +1,124 @@
+package domainevents
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ibeloyar/gophermart/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type fakeStore struct {
+	mu     sync.Mutex
+	events []model.DomainEvent
+}
+
+func (s *fakeStore) PublishOutboxEvents(_ context.Context, limit int, publish func([]model.DomainEvent) error) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := min(limit, len(s.events))
+	if n == 0 {
+		return 0, nil
+	}
+
+	if err := publish(s.events[:n]); err != nil {
+		return 0, err
+	}
+	s.events = s.events[n:]
+
+	return n, nil
+}
+
+type failingSink struct{}
+
+func (failingSink) Write(context.Context, []model.DomainEvent) error { return errors.New("disk full") }
+func (failingSink) Close() error                                     { return nil }
+
+func testEvents(n int) []model.DomainEvent {
+	events := make([]model.DomainEvent, n)
+	for i := range events {
+		events[i] = model.DomainEvent{
+			ID:     int64(i + 1),
+			Type:   model.DomainEventOrderUploaded,
+			UserID: 5,
+			Data:   json.RawMessage(`{"order":"123"}`),
+		}
+	}
+
+	return events
+}
+
+func TestPublisher_Poll_DrainsOutboxInOrder(t *testing.T) {
+	store := &fakeStore{events: testEvents(5)}
+	out := &bytes.Buffer{}
+
+	publisher := NewPublisher(store, NewJSONLinesSink(out), Config{BatchSize: 2}, zap.NewNop().Sugar())
+	publisher.poll(context.Background())
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 5)
+	for i, line := range lines {
+		var event model.DomainEvent
+		require.NoError(t, json.Unmarshal([]byte(line), &event))
+		assert.Equal(t, int64(i+1), event.ID)
+		assert.JSONEq(t, `{"order":"123"}`, string(event.Data))
+	}
+	assert.Empty(t, store.events)
+}
+
+func TestPublisher_Poll_SinkErrorKeepsEvents(t *testing.T) {
+	store := &fakeStore{events: testEvents(2)}
+
+	NewPublisher(store, failingSink{}, Config{}, zap.NewNop().Sugar()).poll(context.Background())
+
+	assert.Len(t, store.events, 2)
+}
+
+func TestFileSink_Appends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+
+	for i := 0; i < 2; i++ {
+		sink, err := NewSink("file:" + path)
+		require.NoError(t, err)
+		require.NoError(t, sink.Write(context.Background(), testEvents(1)))
+		require.NoError(t, sink.Close())
+	}
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, 2, strings.Count(string(data), "\n"))
+}
+
+func TestNewSink_Unknown(t *testing.T) {
+	_, err := NewSink("kafka://localhost")
+	assert.Error(t, err)
+}
+
+func TestPublisher_StartShutdown(t *testing.T) {
+	store := &fakeStore{events: testEvents(1)}
+
+	publisher := NewPublisher(store, NewJSONLinesSink(&bytes.Buffer{}), Config{PollInterval: 5 * time.Millisecond}, zap.NewNop().Sugar())
+	publisher.Start()
+
+	assert.Eventually(t, func() bool {
+		store.mu.Lock()
+		defer store.mu.Unlock()
+		return len(store.events) == 0
+	}, time.Second, 5*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	assert.NoError(t, publisher.Shutdown(ctx))
+}
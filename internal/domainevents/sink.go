@@ -0,0 +1,91 @@
+package domainevents
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/ibeloyar/gophermart/internal/model"
+)
+
+// Sink - получатель ленты доменных событий. Write получает события в порядке ленты;
+// ошибка означает, что пачка будет передана повторно (доставка at-least-once).
+type Sink interface {
+	Write(ctx context.Context, events []model.DomainEvent) error
+	Close() error
+}
+
+// JSONLinesSink - пишет события по одному JSON-объекту на строку
+type JSONLinesSink struct {
+	mu     sync.Mutex
+	w      io.Writer
+	closer io.Closer
+	syncer interface{ Sync() error }
+}
+
+func NewJSONLinesSink(w io.Writer) *JSONLinesSink {
+	return &JSONLinesSink{w: w}
+}
+
+// NewStdoutSink - JSON-строки в стандартный вывод
+func NewStdoutSink() *JSONLinesSink {
+	return NewJSONLinesSink(os.Stdout)
+}
+
+// NewFileSink - JSON-строки с дозаписью в файл; каждая пачка сбрасывается на диск
+func NewFileSink(path string) (*JSONLinesSink, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+	if err != nil {
+		return nil, err
+	}
+
+	return &JSONLinesSink{w: file, closer: file, syncer: file}, nil
+}
+
+// NewSink - sink по строке конфигурации: "stdout" или "file:<path>"
+func NewSink(spec string) (Sink, error) {
+	switch {
+	case spec == "stdout":
+		return NewStdoutSink(), nil
+	case strings.HasPrefix(spec, "file:"):
+		return NewFileSink(strings.TrimPrefix(spec, "file:"))
+	default:
+		return nil, fmt.Errorf("unknown events sink %q", spec)
+	}
+}
+
+func (s *JSONLinesSink) Write(_ context.Context, events []model.DomainEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	buf := bufio.NewWriter(s.w)
+	encoder := json.NewEncoder(buf)
+	for _, event := range events {
+		if err := encoder.Encode(event); err != nil {
+			return err
+		}
+	}
+
+	if err := buf.Flush(); err != nil {
+		return err
+	}
+
+	if s.syncer != nil {
+		return s.syncer.Sync()
+	}
+
+	return nil
+}
+
+func (s *JSONLinesSink) Close() error {
+	if s.closer != nil {
+		return s.closer.Close()
+	}
+
+	return nil
+}
@@ -0,0 +1,155 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const defaultCheckTimeout = 2 * time.Second
+
+// Статусы проверок и итоговый статус готовности
+const (
+	StatusOK       = "ok"
+	StatusFail     = "fail"
+	StatusDegraded = "degraded" // отказала некритичная проверка, под остаётся готовым
+)
+
+// CheckFunc - проверка зависимости; nil - исправна
+type CheckFunc func(ctx context.Context) error
+
+type check struct {
+	name     string
+	critical bool
+	fn       CheckFunc
+}
+
+type CheckResult struct {
+	Name      string  `json:"name"`
+	Status    string  `json:"status"`
+	Critical  bool    `json:"critical"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+type Report struct {
+	Status string        `json:"status"`
+	Checks []CheckResult `json:"checks"`
+}
+
+// Checker - проверки для /healthz и /readyz
+type Checker struct {
+	checks       []check
+	timeout      time.Duration
+	shuttingDown atomic.Bool
+}
+
+// New - timeout ограничивает каждую проверку (0 - 2s)
+func New(timeout time.Duration) *Checker {
+	if timeout == 0 {
+		timeout = defaultCheckTimeout
+	}
+
+	return &Checker{timeout: timeout}
+}
+
+// Add - регистрирует проверку готовности; отказ некритичной только переводит статус в degraded.
+// Проверки регистрируются до запуска сервера.
+func (c *Checker) Add(name string, critical bool, fn CheckFunc) {
+	c.checks = append(c.checks, check{name: name, critical: critical, fn: fn})
+}
+
+// SetShuttingDown - с этого момента /readyz отвечает 503, чтобы балансировщик снял под до остановки сервера
+func (c *Checker) SetShuttingDown() {
+	c.shuttingDown.Store(true)
+}
+
+// Ready - выполняет проверки параллельно и собирает отчёт
+func (c *Checker) Ready(ctx context.Context) Report {
+	report := Report{
+		Status: StatusOK,
+		Checks: make([]CheckResult, len(c.checks)),
+	}
+
+	wg := sync.WaitGroup{}
+	for i, ch := range c.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			report.Checks[i] = c.run(ctx, ch)
+		}()
+	}
+	wg.Wait()
+
+	for _, result := range report.Checks {
+		if result.Status == StatusOK {
+			continue
+		}
+		if result.Critical {
+			report.Status = StatusFail
+		} else if report.Status == StatusOK {
+			report.Status = StatusDegraded
+		}
+	}
+
+	if c.shuttingDown.Load() {
+		report.Status = StatusFail
+		report.Checks = append(report.Checks, CheckResult{
+			Name:     "shutdown",
+			Status:   StatusFail,
+			Critical: true,
+			Error:    "server is shutting down",
+		})
+	}
+
+	return report
+}
+
+func (c *Checker) run(ctx context.Context, ch check) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := ch.fn(ctx)
+
+	result := CheckResult{
+		Name:      ch.name,
+		Status:    StatusOK,
+		Critical:  ch.critical,
+		LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		result.Status = StatusFail
+		result.Error = err.Error()
+	}
+
+	return result
+}
+
+// Liveness - /healthz: процесс жив и обслуживает запросы, зависимости не проверяются
+func (c *Checker) Liveness(w http.ResponseWriter, r *http.Request) {
+	writeReport(w, Report{Status: StatusOK, Checks: []CheckResult{}}, http.StatusOK)
+}
+
+// Readiness - /readyz: 503, если отказала критичная проверка или идёт остановка
+func (c *Checker) Readiness(w http.ResponseWriter, r *http.Request) {
+	report := c.Ready(r.Context())
+
+	statusCode := http.StatusOK
+	if report.Status == StatusFail {
+		statusCode = http.StatusServiceUnavailable
+	}
+
+	writeReport(w, report, statusCode)
+}
+
+func writeReport(w http.ResponseWriter, report Report, statusCode int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(statusCode)
+
+	_ = json.NewEncoder(w).Encode(report)
+}
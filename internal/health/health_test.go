@@ -0,0 +1,98 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func ok(context.Context) error { return nil }
+
+func readiness(t *testing.T, checker *Checker) (int, Report) {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	checker.Readiness(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	var report Report
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&report))
+
+	return w.Code, report
+}
+
+func TestChecker_Readiness(t *testing.T) {
+	tests := []struct {
+		name       string
+		dbErr      error
+		accrualErr error
+		wantCode   int
+		wantStatus string
+	}{
+		{"all ok", nil, nil, http.StatusOK, StatusOK},
+		{"non critical failure", nil, errors.New("rate limited"), http.StatusOK, StatusDegraded},
+		{"critical failure", errors.New("connection refused"), nil, http.StatusServiceUnavailable, StatusFail},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker := New(0)
+			checker.Add("database", true, func(context.Context) error { return tt.dbErr })
+			checker.Add("accrual", false, func(context.Context) error { return tt.accrualErr })
+
+			code, report := readiness(t, checker)
+
+			assert.Equal(t, tt.wantCode, code)
+			assert.Equal(t, tt.wantStatus, report.Status)
+			require.Len(t, report.Checks, 2)
+			assert.Equal(t, "database", report.Checks[0].Name)
+			assert.Equal(t, "accrual", report.Checks[1].Name)
+			if tt.dbErr != nil {
+				assert.Equal(t, tt.dbErr.Error(), report.Checks[0].Error)
+			}
+		})
+	}
+}
+
+func TestChecker_Readiness_Timeout(t *testing.T) {
+	checker := New(10 * time.Millisecond)
+	checker.Add("database", true, func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	code, report := readiness(t, checker)
+
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, context.DeadlineExceeded.Error(), report.Checks[0].Error)
+}
+
+func TestChecker_Readiness_ShuttingDown(t *testing.T) {
+	checker := New(0)
+	checker.Add("database", true, ok)
+	checker.SetShuttingDown()
+
+	code, report := readiness(t, checker)
+
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, StatusFail, report.Status)
+	assert.Equal(t, "shutdown", report.Checks[len(report.Checks)-1].Name)
+}
+
+func TestChecker_Liveness_IgnoresChecks(t *testing.T) {
+	checker := New(0)
+	checker.Add("database", true, func(context.Context) error { return errors.New("down") })
+	checker.SetShuttingDown()
+
+	w := httptest.NewRecorder()
+	checker.Liveness(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"status":"ok","checks":[]}`, w.Body.String())
+}
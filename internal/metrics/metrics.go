@@ -0,0 +1,155 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/ibeloyar/gophermart/internal/model"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "gophermart"
+
+// unmatchedRoute - метка для запросов, не попавших ни в один маршрут (чтобы не плодить метки по URL)
+const unmatchedRoute = "unmatched"
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "HTTP requests by chi route pattern, method and status.",
+	}, []string{"route", "method", "status"})
+
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "HTTP request latency by chi route pattern, method and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	dbErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "db",
+		Name:      "errors_total",
+		Help:      "Driver and server errors of DB operations by error classifier result.",
+	}, []string{"classification"})
+
+	dbRetries = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "db",
+		Name:      "retries_total",
+		Help:      "DB operations repeated after a retriable error.",
+	})
+
+	accrualPollDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "accrual",
+		Name:      "poll_duration_seconds",
+		Help:      "Duration of accrual poll cycles that claimed jobs.",
+		Buckets:   prometheus.ExponentialBuckets(0.01, 2, 12),
+	})
+
+	accrualPauses = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "accrual",
+		Name:      "rate_limit_pauses_total",
+		Help:      "Accrual polling pauses caused by 429 responses.",
+	})
+
+	accrualOutcomes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "accrual",
+		Name:      "requests_total",
+		Help:      "Accrual system requests by outcome: order status, not_registered, rate_limited or error.",
+	}, []string{"outcome"})
+
+	pointsCredited = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "points",
+		Name:      "credited_total",
+		Help:      "Points credited for processed orders.",
+	})
+
+	pointsWithdrawn = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "points",
+		Name:      "withdrawn_total",
+		Help:      "Points withdrawn by users.",
+	})
+)
+
+// Handler - выдача метрик для /metrics
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// HTTPMiddleware - считает запросы и их длительность по шаблону маршрута chi.
+// Шаблон известен только после маршрутизации, поэтому метки берутся после next.
+func HTTPMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		next.ServeHTTP(ww, r)
+
+		route := unmatchedRoute
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		labels := prometheus.Labels{"route": route, "method": r.Method, "status": strconv.Itoa(status)}
+		httpRequests.With(labels).Inc()
+		httpDuration.With(labels).Observe(time.Since(start).Seconds())
+	})
+}
+
+// DBError - ошибка драйвера или сервера базы и решение классификатора (retriable, non_retriable)
+func DBError(classification string) {
+	dbErrors.WithLabelValues(classification).Inc()
+}
+
+func DBRetry() {
+	dbRetries.Inc()
+}
+
+func AccrualPollDuration(d time.Duration) {
+	accrualPollDuration.Observe(d.Seconds())
+}
+
+func AccrualRateLimitPause() {
+	accrualPauses.Inc()
+}
+
+func AccrualOutcome(outcome string) {
+	accrualOutcomes.WithLabelValues(outcome).Inc()
+}
+
+// RegisterAccrualQueueDepth - число заданий опроса, время которых наступило; считается при каждом сборе метрик
+func RegisterAccrualQueueDepth(depth func() float64) {
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "accrual",
+		Name:      "queue_depth",
+		Help:      "Accrual jobs due for polling.",
+	}, depth)
+}
+
+func PointsCredited(amount model.Money) {
+	pointsCredited.Add(amount.Float64())
+}
+
+func PointsWithdrawn(amount model.Money) {
+	pointsWithdrawn.Add(amount.Float64())
+}
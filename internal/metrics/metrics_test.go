@@ -0,0 +1,80 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ibeloyar/gophermart/internal/model"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTTPMiddleware_RoutePatternLabel(t *testing.T) {
+	router := chi.NewRouter()
+	router.Use(HTTPMiddleware)
+	router.Get("/api/user/orders/{number}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	before := testutil.ToFloat64(httpRequests.WithLabelValues("/api/user/orders/{number}", http.MethodGet, "204"))
+
+	for _, number := range []string{"12345678903", "79927398713"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/user/orders/"+number, nil))
+	}
+
+	// номер заказа не попадает в метки
+	after := testutil.ToFloat64(httpRequests.WithLabelValues("/api/user/orders/{number}", http.MethodGet, "204"))
+	assert.Equal(t, float64(2), after-before)
+}
+
+func TestHTTPMiddleware_UnmatchedRoute(t *testing.T) {
+	router := chi.NewRouter()
+	router.Use(HTTPMiddleware)
+	router.Get("/api/user/balance", func(w http.ResponseWriter, r *http.Request) {})
+
+	before := testutil.ToFloat64(httpRequests.WithLabelValues(unmatchedRoute, http.MethodGet, "404"))
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/random/path", nil))
+
+	after := testutil.ToFloat64(httpRequests.WithLabelValues(unmatchedRoute, http.MethodGet, "404"))
+	assert.Equal(t, float64(1), after-before)
+}
+
+func TestHTTPMiddleware_ImplicitOK(t *testing.T) {
+	router := chi.NewRouter()
+	router.Use(HTTPMiddleware)
+	router.Get("/ping", func(w http.ResponseWriter, r *http.Request) {})
+
+	before := testutil.ToFloat64(httpRequests.WithLabelValues("/ping", http.MethodGet, "200"))
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/ping", nil))
+
+	after := testutil.ToFloat64(httpRequests.WithLabelValues("/ping", http.MethodGet, "200"))
+	assert.Equal(t, float64(1), after-before)
+}
+
+func TestPointsCounters(t *testing.T) {
+	credited := testutil.ToFloat64(pointsCredited)
+	withdrawn := testutil.ToFloat64(pointsWithdrawn)
+
+	PointsCredited(model.Money(72998))
+	PointsWithdrawn(model.Money(1050))
+
+	assert.InDelta(t, 729.98, testutil.ToFloat64(pointsCredited)-credited, 1e-9)
+	assert.InDelta(t, 10.5, testutil.ToFloat64(pointsWithdrawn)-withdrawn, 1e-9)
+}
+
+func TestHandler_ExposesMetrics(t *testing.T) {
+	AccrualPollDuration(150 * time.Millisecond)
+	DBError("retriable")
+
+	w := httptest.NewRecorder()
+	Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "gophermart_accrual_poll_duration_seconds_count")
+	assert.Contains(t, w.Body.String(), `gophermart_db_errors_total{classification="retriable"}`)
+}
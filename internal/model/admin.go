@@ -0,0 +1,41 @@
+package model
+
+// AdminAction - действие администратора в журнале аудита
+type AdminAction string
+
+const (
+	AdminActionSearchUsers     AdminAction = "USER_SEARCH"
+	AdminActionViewOrders      AdminAction = "USER_ORDERS_VIEW"
+	AdminActionViewLedger      AdminAction = "USER_LEDGER_VIEW"
+	AdminActionAdjustBalance   AdminAction = "BALANCE_ADJUST"
+	AdminActionReprocessOrder  AdminAction = "ORDER_REPROCESS"
+	AdminActionInvalidateOrder AdminAction = "ORDER_INVALIDATE"
+	AdminActionGrantRole       AdminAction = "ROLE_GRANT"
+	AdminActionRevokeRole      AdminAction = "ROLE_REVOKE"
+	AdminActionRefundWithdraw  AdminAction = "WITHDRAWAL_REFUND"
+	AdminActionPasswordReset   AdminAction = "PASSWORD_RESET_ISSUE"
+)
+
+// AdminAuditEntry - запись журнала аудита; UserID и OrderNumber заполняются, если действие их касается
+type AdminAuditEntry struct {
+	AdminID     int64
+	Action      AdminAction
+	UserID      int64
+	OrderNumber string
+	Details     string
+}
+
+// BalanceAdjustmentDTO - ручное начисление (Amount > 0) или списание (Amount < 0)
+type BalanceAdjustmentDTO struct {
+	Amount Money  `json:"amount"`
+	Reason string `json:"reason"`
+	Order  string `json:"order"`
+}
+
+type AdminOrderActionDTO struct {
+	Reason string `json:"reason"`
+}
+
+type AdminPasswordResetDTO struct {
+	Reason string `json:"reason"`
+}
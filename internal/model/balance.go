@@ -1,6 +1,67 @@
 package model
 
+import "time"
+
+// LedgerKind - тип записи в журнале баланса
+type LedgerKind string
+
+const (
+	LedgerKindAccrual    LedgerKind = "ACCRUAL"
+	LedgerKindWithdrawal LedgerKind = "WITHDRAWAL"
+	// LedgerKindAccrualDuplicate - повторное начисление по заказу, найденное миграцией 000004;
+	// сумма остаётся в балансе, запись только выведена из уникального индекса
+	LedgerKindAccrualDuplicate LedgerKind = "ACCRUAL_DUP"
+	// LedgerKindAdjustment - ручная корректировка администратором (со знаком)
+	LedgerKindAdjustment LedgerKind = "ADJUSTMENT"
+	// LedgerKindExpiration - списание сгоревших баллов
+	LedgerKindExpiration LedgerKind = "EXPIRATION"
+	// LedgerKindRefund - возврат списания, ссылается на исходную запись WITHDRAWAL
+	LedgerKindRefund LedgerKind = "REFUND"
+)
+
 type Balance struct {
-	Current   float32 `json:"current"`
-	Withdrawn float32 `json:"withdrawn"`
+	Current   Money `json:"current"`
+	Withdrawn Money `json:"withdrawn"`
+	// ExpiringSoon - часть Current, которая сгорит в ближайшее время
+	ExpiringSoon Money `json:"expiring_soon"`
+}
+
+// BalanceDrift - расхождение снимка баланса пользователя с журналом
+type BalanceDrift struct {
+	UserID            int64
+	SnapshotCurrent   Money
+	SnapshotWithdrawn Money
+	LedgerCurrent     Money
+	LedgerWithdrawn   Money
+}
+
+// NeedsAdjustment - журнал ушёл в минус: снимок по нему не пересчитать, нужна ручная корректировка
+func (d BalanceDrift) NeedsAdjustment() bool {
+	return d.LedgerCurrent < 0
+}
+
+// LedgerAnomaly - расхождение журнала, найденное миграцией и требующее ручного разбора
+type LedgerAnomaly struct {
+	ID     int64
+	UserID int64
+	// Kind - DUPLICATE_ACCRUAL (000004) или NEGATIVE_BALANCE (000006)
+	Kind       string
+	Order      string
+	Amount     Money
+	DetectedAt time.Time
+}
+
+// LedgerEntry - запись журнала баланса
+type LedgerEntry struct {
+	ID        int64      `json:"id"`
+	Order     string     `json:"order,omitempty"`
+	Amount    Money      `json:"amount"`
+	Kind      LedgerKind `json:"kind"`
+	Reason    string     `json:"reason,omitempty"`
+	CreatedAt string     `json:"created_at"`
+}
+
+type LedgerPage struct {
+	Entries []LedgerEntry
+	Next    *Cursor
 }
@@ -8,19 +8,58 @@ type APIError struct {
 }
 
 const (
-	ErrInternalServerMessage         = "internal server error"
-	ErrInvalidLoginOrPasswordMessage = "invalid login or password"
-	ErrUserAlreadyExistMessage       = "user already exists"
-	ErrOrdersNotFoundMessage         = "no orders found"
-	ErrOrderNumberRequiredMessage    = "invalid order is required"
-	ErrOrderInvalidNumberMessage     = "invalid order number"
-	ErrInsufficientFundsMessage      = "insufficient funds"
+	ErrInternalServerMessage          = "internal server error"
+	ErrInvalidLoginOrPasswordMessage  = "invalid login or password"
+	ErrUserAlreadyExistMessage        = "user already exists"
+	ErrOrdersNotFoundMessage          = "no orders found"
+	ErrOrderNotFoundMessage           = "order not found"
+	ErrOrderForbiddenMessage          = "order belongs to another user"
+	ErrOrderNumberRequiredMessage     = "invalid order is required"
+	ErrOrderInvalidNumberMessage      = "invalid order number"
+	ErrInsufficientFundsMessage       = "insufficient funds"
+	ErrInvalidRefreshTokenMessage     = "invalid refresh token"
+	ErrIdempotencyKeyInvalidMessage   = "invalid Idempotency-Key"
+	ErrInvalidListFilterMessage       = "invalid limit, sort or status filter"
+	ErrIdempotencyKeyReusedMessage    = "Idempotency-Key has already been used with a different request"
+	ErrIdempotencyKeyPendingMessage   = "request with this Idempotency-Key is still in progress"
+	ErrUserNotFoundMessage            = "user not found"
+	ErrAdminReasonRequiredMessage     = "reason is required and must not exceed 500 characters"
+	ErrAdjustmentAmountMessage        = "adjustment amount must not be zero"
+	ErrOrderAlreadyProcessedMessage   = "order has already been processed, use a balance adjustment instead"
+	ErrLoginQueryRequiredMessage      = "login query is required"
+	ErrInvalidRoleMessage             = "unknown role"
+	ErrRevokeOwnAdminRoleMessage      = "admin role cannot be revoked from yourself"
+	ErrWithdrawNotFoundMessage        = "withdrawal not found"
+	ErrWithdrawAlreadyRefundedMessage = "withdrawal has already been refunded"
+	ErrWithdrawSumMessage             = "withdrawal sum must be positive"
+	ErrWithdrawOrderUsedMessage       = "order number has already been used for a withdrawal"
+	ErrLoginLockedMessage             = "too many failed login attempts, try again later"
+	ErrWrongPasswordMessage           = "current password is incorrect"
+	ErrPasswordTooWeakMessage         = "password must mix more character classes: lowercase, uppercase, digits, symbols"
+	ErrPasswordTooCommonMessage       = "password is too common"
+	ErrInvalidResetTokenMessage       = "invalid or expired password reset token"
 )
 
 var (
 	ErrInsufficientFunds      = errors.New(ErrInsufficientFundsMessage)
 	ErrInvalidLoginOrPassword = errors.New(ErrInvalidLoginOrPasswordMessage)
+	ErrPasswordTooWeak        = errors.New(ErrPasswordTooWeakMessage)
+	ErrPasswordTooCommon      = errors.New(ErrPasswordTooCommonMessage)
+	ErrResetTokenInvalid      = errors.New(ErrInvalidResetTokenMessage)
 
 	ErrOrderHasBeenLoadedCurrentUser = errors.New("order has been loaded current user")
 	ErrOrderHasBeenLoadedSomeUser    = errors.New("order has been loaded some user")
+	ErrOrderNotFound                 = errors.New(ErrOrderNotFoundMessage)
+	ErrOrderAlreadyProcessed         = errors.New(ErrOrderAlreadyProcessedMessage)
+
+	ErrUserNotFound = errors.New(ErrUserNotFoundMessage)
+
+	ErrWithdrawNotFound        = errors.New(ErrWithdrawNotFoundMessage)
+	ErrWithdrawAlreadyRefunded = errors.New(ErrWithdrawAlreadyRefundedMessage)
+	ErrWithdrawOrderUsed       = errors.New(ErrWithdrawOrderUsedMessage)
+
+	ErrSessionNotFound = errors.New("session not found")
+
+	ErrWebhookEndpointNotFound = errors.New("webhook endpoint not found")
+	ErrWebhookSinkExists       = errors.New("active webhook endpoint already exists, disable it first")
 )
@@ -0,0 +1,57 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// DomainEventType - тип доменного события из events_outbox
+type DomainEventType string
+
+const (
+	DomainEventUserRegistered  DomainEventType = "user.registered"
+	DomainEventOrderUploaded   DomainEventType = "order.uploaded"
+	DomainEventPointsCredited  DomainEventType = "points.credited"
+	DomainEventPointsWithdrawn DomainEventType = "points.withdrawn"
+	DomainEventPointsAdjusted  DomainEventType = "points.adjusted"
+	DomainEventPointsExpired   DomainEventType = "points.expired"
+	DomainEventPointsRefunded  DomainEventType = "points.refunded"
+)
+
+// DomainEvent - запись ленты событий для аналитики и антифрода.
+// Данные начисления и списания - OrderAccruedData и PointsWithdrawnData, как у webhook'ов.
+// Порядок ленты - по Seq (возрастает, возможны пропуски); при повторной доставке
+// событие может прийти ещё раз с другим Seq - дубликаты отсекаются по ID.
+type DomainEvent struct {
+	ID        int64           `json:"id"`
+	Seq       int64           `json:"seq"`
+	Type      DomainEventType `json:"type"`
+	UserID    int64           `json:"user_id"`
+	Data      json.RawMessage `json:"data"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+type UserRegisteredData struct {
+	Login string `json:"login"`
+}
+
+type OrderUploadedData struct {
+	Order string `json:"order"`
+}
+
+type PointsAdjustedData struct {
+	AdminID int64  `json:"admin_id"`
+	Order   string `json:"order,omitempty"`
+	Amount  Money  `json:"amount"`
+	Reason  string `json:"reason"`
+}
+
+type PointsExpiredData struct {
+	Amount Money `json:"amount"`
+}
+
+type PointsRefundedData struct {
+	Order  string `json:"order"`
+	Sum    Money  `json:"sum"`
+	Reason string `json:"reason"`
+}
@@ -0,0 +1,13 @@
+package model
+
+import "time"
+
+// IdempotencyRecord - сохранённый результат запроса с заголовком Idempotency-Key
+type IdempotencyRecord struct {
+	UserID       int64
+	Key          string
+	RequestHash  string
+	ResponseCode int // 0 - запрос ещё выполняется
+	ResponseBody string
+	ExpiresAt    time.Time
+}
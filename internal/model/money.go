@@ -0,0 +1,191 @@
+package model
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidMoney - сумма не является десятичным числом не более чем с двумя знаками после точки
+var ErrInvalidMoney = errors.New("invalid money amount")
+
+// maxMoneyIntegerDigits - ограничение целой части, чтобы копейки помещались в int64
+const maxMoneyIntegerDigits = 15
+
+// Money - денежная сумма (в баллах) с точностью до копейки, хранится в копейках.
+// В JSON и SQL представляется десятичным числом без потери точности.
+type Money int64
+
+// ParseMoney - разбирает десятичную запись суммы ("729.98", "-10.5", "500")
+func ParseMoney(s string) (Money, error) {
+	value := s
+	negative := false
+	if strings.HasPrefix(value, "-") {
+		negative = true
+		value = value[1:]
+	}
+
+	integer, fraction, hasFraction := strings.Cut(value, ".")
+	if integer == "" || len(integer) > maxMoneyIntegerDigits || !isDigits(integer) {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidMoney, s)
+	}
+	if hasFraction && (fraction == "" || len(fraction) > 2 || !isDigits(fraction)) {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidMoney, s)
+	}
+
+	units, err := strconv.ParseInt(integer, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidMoney, s)
+	}
+
+	var cents int64
+	if fraction != "" {
+		cents, _ = strconv.ParseInt(fraction, 10, 64)
+		if len(fraction) == 1 {
+			cents *= 10
+		}
+	}
+
+	amount := units*100 + cents
+	if negative {
+		amount = -amount
+	}
+
+	return Money(amount), nil
+}
+
+// RoundMoney - как ParseMoney, но лишние знаки после точки округляются до копейки (половина - от нуля).
+// Для сумм от внешних систем, которые могут прислать больше двух знаков; ввод пользователя разбирает ParseMoney
+func RoundMoney(s string) (Money, error) {
+	integer, fraction, ok := strings.Cut(s, ".")
+	if !ok || len(fraction) <= 2 {
+		return ParseMoney(s)
+	}
+	if !isDigits(fraction) {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidMoney, s)
+	}
+
+	amount, err := ParseMoney(integer + "." + fraction[:2])
+	if err != nil {
+		return 0, err
+	}
+
+	if fraction[2] >= '5' {
+		if strings.HasPrefix(integer, "-") {
+			amount--
+		} else {
+			amount++
+		}
+	}
+
+	return amount, nil
+}
+
+// String - десятичная запись без лишних нулей: 729.98, 10.5, 500
+func (m Money) String() string {
+	sign := ""
+	amount := int64(m)
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+
+	units, cents := amount/100, amount%100
+	switch {
+	case cents == 0:
+		return fmt.Sprintf("%s%d", sign, units)
+	case cents%10 == 0:
+		return fmt.Sprintf("%s%d.%d", sign, units, cents/10)
+	default:
+		return fmt.Sprintf("%s%d.%02d", sign, units, cents)
+	}
+}
+
+// Float64 - сумма в баллах с плавающей точкой; только для метрик, не для расчётов
+func (m Money) Float64() float64 {
+	return float64(m) / 100
+}
+
+// Abs - абсолютное значение суммы
+func (m Money) Abs() Money {
+	if m < 0 {
+		return -m
+	}
+	return m
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+func (m *Money) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+
+	amount, err := ParseMoney(string(data))
+	if err != nil {
+		return err
+	}
+
+	*m = amount
+
+	return nil
+}
+
+// Value - сумма передаётся в NUMERIC строкой, чтобы не терять точность
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
+
+func (m *Money) Scan(src any) error {
+	switch value := src.(type) {
+	case nil:
+		*m = 0
+		return nil
+	case int64:
+		*m = Money(value * 100)
+		return nil
+	case float64:
+		*m = Money(math.Round(value * 100))
+		return nil
+	case []byte:
+		return m.scanString(string(value))
+	case string:
+		return m.scanString(value)
+	default:
+		return fmt.Errorf("cannot scan %T into Money", src)
+	}
+}
+
+// scanString - разбирает NUMERIC; у вычисленных значений масштаб может быть больше двух знаков
+func (m *Money) scanString(s string) error {
+	if integer, fraction, ok := strings.Cut(s, "."); ok && len(fraction) > 2 {
+		trimmed := strings.TrimRight(fraction[2:], "0")
+		if trimmed != "" {
+			return fmt.Errorf("%w: %q", ErrInvalidMoney, s)
+		}
+		s = integer + "." + fraction[:2]
+	}
+
+	amount, err := ParseMoney(s)
+	if err != nil {
+		return err
+	}
+
+	*m = amount
+
+	return nil
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,118 @@
+package model
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Money
+		wantErr bool
+	}{
+		{input: "729.98", want: 72998},
+		{input: "10.5", want: 1050},
+		{input: "500", want: 50000},
+		{input: "0.01", want: 1},
+		{input: "-42.1", want: -4210},
+		{input: "10.555", wantErr: true},
+		{input: "1e2", wantErr: true},
+		{input: ".5", wantErr: true},
+		{input: "5.", wantErr: true},
+		{input: "", wantErr: true},
+		{input: "1234567890123456", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseMoney(tt.input)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidMoney)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRoundMoney(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Money
+		wantErr bool
+	}{
+		{input: "10.5", want: 1050},
+		{input: "10.554", want: 1055},
+		{input: "10.555", want: 1056},
+		{input: "0.999", want: 100},
+		{input: "-0.005", want: -1},
+		{input: "-10.5549", want: -1055},
+		{input: "10.5x5", wantErr: true},
+		{input: "1e2", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := RoundMoney(tt.input)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidMoney)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestMoney_String(t *testing.T) {
+	assert.Equal(t, "729.98", Money(72998).String())
+	assert.Equal(t, "10.5", Money(1050).String())
+	assert.Equal(t, "500", Money(50000).String())
+	assert.Equal(t, "0.01", Money(1).String())
+	assert.Equal(t, "-0.5", Money(-50).String())
+}
+
+func TestMoney_JSON(t *testing.T) {
+	var balance Balance
+	require.NoError(t, json.Unmarshal([]byte(`{"current": 729.98, "withdrawn": 42}`), &balance))
+	assert.Equal(t, Money(72998), balance.Current)
+	assert.Equal(t, Money(4200), balance.Withdrawn)
+
+	data, err := json.Marshal(balance)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"current": 729.98, "withdrawn": 42, "expiring_soon": 0}`, string(data))
+
+	var dto SetWithdrawDTO
+	assert.ErrorIs(t, json.Unmarshal([]byte(`{"order": "1", "sum": 0.001}`), &dto), ErrInvalidMoney)
+}
+
+func TestMoney_Scan(t *testing.T) {
+	tests := []struct {
+		src     any
+		want    Money
+		wantErr bool
+	}{
+		{src: "729.98", want: 72998},
+		{src: []byte("100.50"), want: 10050},
+		{src: "100.5000", want: 10050},
+		{src: int64(7), want: 700},
+		{src: nil, want: 0},
+		{src: "100.505", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		var m Money
+		err := m.Scan(tt.src)
+		if tt.wantErr {
+			assert.Error(t, err)
+			continue
+		}
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, m)
+	}
+}
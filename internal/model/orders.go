@@ -7,20 +7,74 @@ const (
 	OrderStatusProcessing OrderStatus = "PROCESSING"
 	OrderStatusInvalid    OrderStatus = "INVALID"
 	OrderStatusProcessed  OrderStatus = "PROCESSED"
+
+	// AccrualStatusRegistered - заказ зарегистрирован в системе расчёта, но не обработан
+	AccrualStatusRegistered OrderStatus = "REGISTERED"
 )
 
+// IsValid - статус заказа, который может храниться в системе
+func (s OrderStatus) IsValid() bool {
+	switch s {
+	case OrderStatusNew, OrderStatusProcessing, OrderStatusInvalid, OrderStatusProcessed:
+		return true
+	}
+	return false
+}
+
+// IsFinal - статус, после которого опрашивать систему расчёта больше не нужно
+func (s OrderStatus) IsFinal() bool {
+	return s == OrderStatusProcessed || s == OrderStatusInvalid
+}
+
 type Order struct {
+	ID         int64       `json:"-"`
 	UserID     int64       `json:"-"`
 	Number     string      `json:"number"`
 	Status     OrderStatus `json:"status"`
-	Accrual    float64     `json:"accrual"`
+	Accrual    Money       `json:"accrual"`
 	UploadedAt string      `json:"uploaded_at"`
 }
 
 type GetOrdersResponse = []Order
 
+// OrderStatusChange - смена статуса заказа, замеченная при опросе системы расчёта
+type OrderStatusChange struct {
+	Status    OrderStatus `json:"status"`
+	ChangedAt string      `json:"changed_at"`
+}
+
+// OrderDetails - заказ вместе с историей смены статусов
+type OrderDetails struct {
+	Order
+	History []OrderStatusChange `json:"history"`
+}
+
 type Accrual struct {
 	Order   string      `json:"order"`
 	Status  OrderStatus `json:"status"`
-	Accrual float32     `json:"accrual,omitempty"`
+	Accrual Money       `json:"accrual,omitempty"`
+}
+
+// AccrualJob - задание на опрос системы расчёта по заказу
+type AccrualJob struct {
+	OrderNumber string
+	UserID      int64
+	Attempts    int
 }
+
+// OrderEvent - изменение статуса (и начисления) заказа: по опросу системы расчёта или действию администратора.
+// ID совпадает с id записи order_status_history и служит идентификатором SSE-события.
+type OrderEvent struct {
+	ID        int64       `json:"-"`
+	UserID    int64       `json:"-"`
+	Number    string      `json:"number"`
+	Status    OrderStatus `json:"status"`
+	Accrual   Money       `json:"accrual,omitempty"`
+	ChangedAt string      `json:"changed_at"`
+	// Reset - см. OrderEventsReset
+	Reset bool `json:"-"`
+}
+
+// OrderEventsReset - сигнал в потоке событий: пропущено больше событий, чем отдаётся
+// при переподключении, клиент должен заново запросить список заказов
+var OrderEventsReset = OrderEvent{Reset: true}
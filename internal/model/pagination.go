@@ -0,0 +1,81 @@
+package model
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultPageLimit = 100
+	MaxPageLimit     = 1000
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+type SortOrder string
+
+const (
+	SortDesc SortOrder = "desc"
+	SortAsc  SortOrder = "asc"
+)
+
+// Cursor - позиция в выдаче: время записи и её id (для записей с одинаковым временем)
+type Cursor struct {
+	At time.Time
+	ID int64
+}
+
+// String - непрозрачное представление курсора для параметра after
+func (c Cursor) String() string {
+	raw := fmt.Sprintf("%d:%d", c.At.UnixMicro(), c.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func ParseCursor(s string) (*Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	at, id, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return nil, ErrInvalidCursor
+	}
+
+	micros, err := strconv.ParseInt(at, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	cursorID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return &Cursor{At: time.UnixMicro(micros), ID: cursorID}, nil
+}
+
+// ListFilter - параметры постраничной выборки истории пользователя.
+// From/To ограничивают uploaded_at для заказов и processed_at для списаний.
+type ListFilter struct {
+	Limit    int
+	After    *Cursor
+	Statuses []OrderStatus
+	From     *time.Time
+	To       *time.Time
+	Sort     SortOrder
+}
+
+type OrdersPage struct {
+	Orders []Order
+	Next   *Cursor
+}
+
+type WithdrawsPage struct {
+	Withdraws []Withdraw
+	Next      *Cursor
+}
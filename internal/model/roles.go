@@ -0,0 +1,33 @@
+package model
+
+import "slices"
+
+// Role - роль пользователя (таблица user_roles, claim roles в access-токене)
+type Role string
+
+const (
+	RoleUser     Role = "user"
+	RoleSupport  Role = "support"
+	RoleAdmin    Role = "admin"
+	RoleMerchant Role = "merchant"
+)
+
+func (r Role) IsValid() bool {
+	switch r {
+	case RoleUser, RoleSupport, RoleAdmin, RoleMerchant:
+		return true
+	default:
+		return false
+	}
+}
+
+// HasAnyRole - для auth.RequireRole
+func (t TokenInfo) HasAnyRole(roles ...Role) bool {
+	for _, role := range roles {
+		if slices.Contains(t.Roles, role) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,52 @@
+package model
+
+import "time"
+
+type LoginResult string
+
+const (
+	LoginResultSuccess            LoginResult = "SUCCESS"
+	LoginResultInvalidCredentials LoginResult = "INVALID_CREDENTIALS"
+	LoginResultLocked             LoginResult = "LOCKED"
+)
+
+// ClientInfo - откуда пришёл запрос входа
+type ClientInfo struct {
+	IP        string
+	UserAgent string
+}
+
+// LoginAttempt - запись истории входов; UserID = 0, если логин не найден
+type LoginAttempt struct {
+	ID        int64       `json:"id"`
+	Login     string      `json:"-"`
+	UserID    int64       `json:"-"`
+	Result    LoginResult `json:"result"`
+	IP        string      `json:"ip"`
+	UserAgent string      `json:"user_agent"`
+	CreatedAt string      `json:"created_at"`
+}
+
+type LoginAttemptsPage struct {
+	Attempts []LoginAttempt
+	Next     *Cursor
+}
+
+// LockoutPolicy - после MaxFailures неудач за Window логин блокируется на Lockout;
+// каждая следующая блокировка подряд вдвое длиннее, но не дольше MaxLockout
+type LockoutPolicy struct {
+	MaxFailures int
+	Window      time.Duration
+	Lockout     time.Duration
+	MaxLockout  time.Duration
+}
+
+// LockoutDuration - длительность блокировки с номером n (с единицы)
+func (p LockoutPolicy) LockoutDuration(n int) time.Duration {
+	d := p.Lockout
+	for i := 1; i < n && d < p.MaxLockout; i++ {
+		d *= 2
+	}
+
+	return min(d, p.MaxLockout)
+}
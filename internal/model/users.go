@@ -5,12 +5,22 @@ import "time"
 type TokenInfo struct {
 	ID    int64  `json:"id"`
 	Login string `json:"login"`
+	Roles []Role `json:"roles,omitempty"`
 }
 
 type User struct {
 	ID        int64     `json:"id"`
 	Login     string    `json:"login"`
 	Password  string    `json:"password"`
+	Roles     []Role    `json:"roles"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// UserSummary - пользователь в выдаче /api/admin без хеша пароля
+type UserSummary struct {
+	ID        int64     `json:"id"`
+	Login     string    `json:"login"`
+	Roles     []Role    `json:"roles"`
 	CreatedAt time.Time `json:"created_at"`
 }
 
@@ -23,3 +33,26 @@ type RegisterDTO struct {
 	Login    string `json:"login"`
 	Password string `json:"password"`
 }
+
+type ChangePasswordDTO struct {
+	OldPassword string `json:"old_password"`
+	NewPassword string `json:"new_password"`
+}
+
+type ResetPasswordDTO struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"new_password"`
+}
+
+// PasswordResetToken - одноразовый токен сброса; показывается администратору один раз
+type PasswordResetToken struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// PasswordPolicy - требования к новым паролям: минимум классов символов
+// (строчные, прописные, цифры, прочие) и запрет распространённых паролей (в нижнем регистре)
+type PasswordPolicy struct {
+	MinClasses int
+	Denylist   []string
+}
@@ -0,0 +1,75 @@
+package model
+
+import "time"
+
+// WebhookEventType - тип события, на которое подписывается endpoint
+type WebhookEventType string
+
+const (
+	WebhookEventOrderAccrued    WebhookEventType = "order.accrued"
+	WebhookEventPointsWithdrawn WebhookEventType = "points.withdrawn"
+)
+
+func (t WebhookEventType) IsValid() bool {
+	switch t {
+	case WebhookEventOrderAccrued, WebhookEventPointsWithdrawn:
+		return true
+	default:
+		return false
+	}
+}
+
+// WebhookDeliveryStatus - состояние доставки в outbox
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryPending   WebhookDeliveryStatus = "PENDING"
+	WebhookDeliveryDelivered WebhookDeliveryStatus = "DELIVERED"
+	WebhookDeliveryDead      WebhookDeliveryStatus = "DEAD"
+)
+
+// WebhookEndpoint - адрес, на который доставляются события. Tenant - метка владельца:
+// события не привязаны к магазину, поэтому активен один endpoint оператора и он получает данные всех пользователей
+type WebhookEndpoint struct {
+	ID         int64
+	Tenant     string
+	URL        string
+	Secret     string
+	EventTypes []WebhookEventType
+	Active     bool
+	CreatedAt  time.Time
+}
+
+// WebhookDelivery - запись outbox, готовая к отправке на endpoint
+type WebhookDelivery struct {
+	ID         int64
+	EndpointID int64
+	URL        string
+	Secret     string
+	EventID    string
+	EventType  WebhookEventType
+	Payload    []byte
+	Attempts   int
+}
+
+// WebhookEvent - тело запроса к endpoint'у
+type WebhookEvent struct {
+	ID        string           `json:"id"`
+	Type      WebhookEventType `json:"type"`
+	CreatedAt string           `json:"created_at"`
+	Data      any              `json:"data"`
+}
+
+// OrderAccruedData - данные события order.accrued
+type OrderAccruedData struct {
+	UserID  int64  `json:"user_id"`
+	Order   string `json:"order"`
+	Accrual Money  `json:"accrual"`
+}
+
+// PointsWithdrawnData - данные события points.withdrawn
+type PointsWithdrawnData struct {
+	UserID int64  `json:"user_id"`
+	Order  string `json:"order"`
+	Sum    Money  `json:"sum"`
+}
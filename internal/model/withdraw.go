@@ -1,14 +1,29 @@
 package model
 
+// WithdrawStatus - состояние списания в истории пользователя
+type WithdrawStatus string
+
+const (
+	WithdrawStatusProcessed WithdrawStatus = "PROCESSED"
+	// WithdrawStatusRefunded - баллы возвращены после отмены заказа магазина
+	WithdrawStatusRefunded WithdrawStatus = "REFUNDED"
+)
+
 type Withdraw struct {
-	ID          int64   `json:"-"`
-	UserID      int64   `json:"-"`
-	OrderNumber string  `json:"order"`
-	Amount      float64 `json:"sum"`
-	UploadedAt  string  `json:"processed_at"`
+	ID          int64          `json:"-"`
+	UserID      int64          `json:"-"`
+	OrderNumber string         `json:"order"`
+	Amount      Money          `json:"sum"`
+	Status      WithdrawStatus `json:"status"`
+	UploadedAt  string         `json:"processed_at"`
 }
 
 type SetWithdrawDTO struct {
-	Order string  `json:"order"`
-	Sum   float64 `json:"sum"`
+	Order string `json:"order"`
+	Sum   Money  `json:"sum"`
+}
+
+// CancelWithdrawDTO - причина возврата списания, попадает в журнал и аудит
+type CancelWithdrawDTO struct {
+	Reason string `json:"reason"`
 }
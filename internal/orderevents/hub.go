@@ -0,0 +1,106 @@
+package orderevents
+
+import (
+	"sync"
+
+	"github.com/ibeloyar/gophermart/internal/model"
+)
+
+// subscriptionBuffer - сколько событий может накопиться у медленного клиента
+const subscriptionBuffer = 64
+
+// Hub - in-process рассылка событий заказов подписчикам конкретного пользователя
+type Hub struct {
+	mu     sync.Mutex
+	subs   map[int64]map[*Subscription]struct{}
+	closed bool
+}
+
+// Subscription - подписка на события пользователя. Канал закрывается при Close,
+// остановке хаба или переполнении буфера (клиент должен переподключиться с Last-Event-ID).
+type Subscription struct {
+	hub    *Hub
+	userID int64
+	events chan model.OrderEvent
+}
+
+func NewHub() *Hub {
+	return &Hub{subs: make(map[int64]map[*Subscription]struct{})}
+}
+
+func (h *Hub) Subscribe(userID int64) *Subscription {
+	sub := &Subscription{
+		hub:    h,
+		userID: userID,
+		events: make(chan model.OrderEvent, subscriptionBuffer),
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		close(sub.events)
+		return sub
+	}
+
+	if h.subs[userID] == nil {
+		h.subs[userID] = make(map[*Subscription]struct{})
+	}
+	h.subs[userID][sub] = struct{}{}
+
+	return sub
+}
+
+// Publish - рассылает событие подписчикам владельца заказа, не блокируясь на медленных
+func (h *Hub) Publish(event model.OrderEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for sub := range h.subs[event.UserID] {
+		select {
+		case sub.events <- event:
+		default:
+			h.removeLocked(sub)
+		}
+	}
+}
+
+// Close - закрывает все подписки; используется при остановке сервера
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for _, subs := range h.subs {
+		for sub := range subs {
+			h.removeLocked(sub)
+		}
+	}
+}
+
+func (h *Hub) removeLocked(sub *Subscription) {
+	subs, ok := h.subs[sub.userID]
+	if !ok {
+		return
+	}
+	if _, ok := subs[sub]; !ok {
+		return
+	}
+
+	delete(subs, sub)
+	if len(subs) == 0 {
+		delete(h.subs, sub.userID)
+	}
+	close(sub.events)
+}
+
+func (s *Subscription) Events() <-chan model.OrderEvent {
+	return s.events
+}
+
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+
+	s.hub.removeLocked(s)
+}
@@ -0,0 +1,52 @@
+package orderevents
+
+import (
+	"testing"
+
+	"github.com/ibeloyar/gophermart/internal/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHub_PublishToOwnerOnly(t *testing.T) {
+	hub := NewHub()
+
+	own := hub.Subscribe(1)
+	other := hub.Subscribe(2)
+	defer own.Close()
+	defer other.Close()
+
+	hub.Publish(model.OrderEvent{ID: 10, UserID: 1, Number: "123"})
+
+	assert.Equal(t, int64(10), (<-own.Events()).ID)
+	assert.Len(t, other.Events(), 0)
+}
+
+func TestHub_SlowSubscriberIsDropped(t *testing.T) {
+	hub := NewHub()
+	sub := hub.Subscribe(1)
+
+	for i := 0; i <= subscriptionBuffer; i++ {
+		hub.Publish(model.OrderEvent{ID: int64(i), UserID: 1})
+	}
+
+	received := 0
+	for range sub.Events() {
+		received++
+	}
+
+	assert.Equal(t, subscriptionBuffer, received)
+	sub.Close() // повторное закрытие безопасно
+}
+
+func TestHub_Close(t *testing.T) {
+	hub := NewHub()
+	sub := hub.Subscribe(1)
+
+	hub.Close()
+
+	_, ok := <-sub.Events()
+	assert.False(t, ok)
+
+	_, ok = <-hub.Subscribe(1).Events()
+	assert.False(t, ok)
+}
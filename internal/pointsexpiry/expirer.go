@@ -0,0 +1,110 @@
+package pointsexpiry
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	defaultInterval  = time.Hour
+	defaultBatchSize = 100
+)
+
+// PointsStore - хранилище партий баллов
+type PointsStore interface {
+	// ExpirePoints - списывает остатки партий, срок которых истёк к now, не более чем у limit пользователей
+	ExpirePoints(ctx context.Context, now time.Time, limit int) (int, error)
+}
+
+type Config struct {
+	Interval  time.Duration
+	BatchSize int
+}
+
+// Expirer - периодически списывает сгоревшие баллы
+type Expirer struct {
+	store PointsStore
+	cfg   Config
+	lg    *zap.SugaredLogger
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func NewExpirer(store PointsStore, cfg Config, lg *zap.SugaredLogger) *Expirer {
+	if cfg.Interval == 0 {
+		cfg.Interval = defaultInterval
+	}
+	if cfg.BatchSize == 0 {
+		cfg.BatchSize = defaultBatchSize
+	}
+
+	return &Expirer{
+		store: store,
+		cfg:   cfg,
+		lg:    lg,
+	}
+}
+
+// Start - запускает списание в отдельной горутине; первый проход сразу после старта
+func (e *Expirer) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	e.cancel = cancel
+	e.done = make(chan struct{})
+
+	go e.run(ctx)
+}
+
+// Shutdown - останавливает списание и ждёт текущую пачку
+func (e *Expirer) Shutdown(ctx context.Context) error {
+	if e.cancel == nil {
+		return nil
+	}
+
+	e.cancel()
+
+	select {
+	case <-e.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (e *Expirer) run(ctx context.Context) {
+	defer close(e.done)
+
+	ticker := time.NewTicker(e.cfg.Interval)
+	defer ticker.Stop()
+
+	e.poll(ctx)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			e.poll(ctx)
+		}
+	}
+}
+
+// poll - обрабатывает пачки, пока находятся пользователи со сгоревшими партиями
+func (e *Expirer) poll(ctx context.Context) {
+	now := time.Now()
+
+	for ctx.Err() == nil {
+		processed, err := e.store.ExpirePoints(ctx, now, e.cfg.BatchSize)
+		if err != nil {
+			e.lg.Errorf("expiring points error: %v", err)
+			return
+		}
+
+		if processed < e.cfg.BatchSize {
+			return
+		}
+	}
+}
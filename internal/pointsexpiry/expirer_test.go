@@ -0,0 +1,71 @@
+package pointsexpiry
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type fakeStore struct {
+	mu      sync.Mutex
+	pending int
+	calls   int
+	err     error
+}
+
+func (s *fakeStore) ExpirePoints(_ context.Context, _ time.Time, limit int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.calls++
+	if s.err != nil {
+		return 0, s.err
+	}
+
+	n := min(limit, s.pending)
+	s.pending -= n
+
+	return n, nil
+}
+
+func TestExpirer_PollDrainsFullBatches(t *testing.T) {
+	store := &fakeStore{pending: 5}
+	expirer := NewExpirer(store, Config{BatchSize: 2}, zap.NewNop().Sugar())
+
+	expirer.poll(context.Background())
+
+	assert.Equal(t, 0, store.pending)
+	assert.Equal(t, 3, store.calls)
+}
+
+func TestExpirer_PollStopsOnError(t *testing.T) {
+	store := &fakeStore{pending: 5, err: errors.New("connection refused")}
+	expirer := NewExpirer(store, Config{BatchSize: 2}, zap.NewNop().Sugar())
+
+	expirer.poll(context.Background())
+
+	assert.Equal(t, 1, store.calls)
+}
+
+func TestExpirer_StartShutdown(t *testing.T) {
+	store := &fakeStore{pending: 1}
+	expirer := NewExpirer(store, Config{Interval: time.Hour}, zap.NewNop().Sugar())
+
+	expirer.Start()
+
+	require.Eventually(t, func() bool {
+		store.mu.Lock()
+		defer store.mu.Unlock()
+		return store.pending == 0
+	}, time.Second, 10*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, expirer.Shutdown(ctx))
+}
@@ -5,7 +5,9 @@
 package pg
 
 import (
+	context "context"
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 	model "github.com/ibeloyar/gophermart/internal/model"
@@ -34,104 +36,526 @@ func (m *MockStorageRepo) EXPECT() *MockStorageRepoMockRecorder {
 	return m.recorder
 }
 
+// AdjustBalance mocks base method.
+func (m *MockStorageRepo) AdjustBalance(ctx context.Context, adminID, userID int64, input model.BalanceAdjustmentDTO) (*model.LedgerEntry, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AdjustBalance", ctx, adminID, userID, input)
+	ret0, _ := ret[0].(*model.LedgerEntry)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AdjustBalance indicates an expected call of AdjustBalance.
+func (mr *MockStorageRepoMockRecorder) AdjustBalance(ctx, adminID, userID, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AdjustBalance", reflect.TypeOf((*MockStorageRepo)(nil).AdjustBalance), ctx, adminID, userID, input)
+}
+
+// ChangePassword mocks base method.
+func (m *MockStorageRepo) ChangePassword(ctx context.Context, userID int64, passwordHash, keepSessionID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ChangePassword", ctx, userID, passwordHash, keepSessionID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ChangePassword indicates an expected call of ChangePassword.
+func (mr *MockStorageRepoMockRecorder) ChangePassword(ctx, userID, passwordHash, keepSessionID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ChangePassword", reflect.TypeOf((*MockStorageRepo)(nil).ChangePassword), ctx, userID, passwordHash, keepSessionID)
+}
+
+// CompleteIdempotencyKey mocks base method.
+func (m *MockStorageRepo) CompleteIdempotencyKey(ctx context.Context, userID int64, key string, responseCode int, responseBody string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CompleteIdempotencyKey", ctx, userID, key, responseCode, responseBody)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CompleteIdempotencyKey indicates an expected call of CompleteIdempotencyKey.
+func (mr *MockStorageRepoMockRecorder) CompleteIdempotencyKey(ctx, userID, key, responseCode, responseBody interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CompleteIdempotencyKey", reflect.TypeOf((*MockStorageRepo)(nil).CompleteIdempotencyKey), ctx, userID, key, responseCode, responseBody)
+}
+
 // CreateOrder mocks base method.
-func (m *MockStorageRepo) CreateOrder(userID int64, number string) error {
+func (m *MockStorageRepo) CreateOrder(ctx context.Context, userID int64, number string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateOrder", userID, number)
+	ret := m.ctrl.Call(m, "CreateOrder", ctx, userID, number)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // CreateOrder indicates an expected call of CreateOrder.
-func (mr *MockStorageRepoMockRecorder) CreateOrder(userID, number interface{}) *gomock.Call {
+func (mr *MockStorageRepoMockRecorder) CreateOrder(ctx, userID, number interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateOrder", reflect.TypeOf((*MockStorageRepo)(nil).CreateOrder), ctx, userID, number)
+}
+
+// CreatePasswordResetToken mocks base method.
+func (m *MockStorageRepo) CreatePasswordResetToken(ctx context.Context, adminID, userID int64, tokenHash string, expiresAt time.Time, reason string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreatePasswordResetToken", ctx, adminID, userID, tokenHash, expiresAt, reason)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreatePasswordResetToken indicates an expected call of CreatePasswordResetToken.
+func (mr *MockStorageRepoMockRecorder) CreatePasswordResetToken(ctx, adminID, userID, tokenHash, expiresAt, reason interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateOrder", reflect.TypeOf((*MockStorageRepo)(nil).CreateOrder), userID, number)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreatePasswordResetToken", reflect.TypeOf((*MockStorageRepo)(nil).CreatePasswordResetToken), ctx, adminID, userID, tokenHash, expiresAt, reason)
+}
+
+// CreateSession mocks base method.
+func (m *MockStorageRepo) CreateSession(ctx context.Context, session model.Session) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateSession", ctx, session)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateSession indicates an expected call of CreateSession.
+func (mr *MockStorageRepoMockRecorder) CreateSession(ctx, session interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateSession", reflect.TypeOf((*MockStorageRepo)(nil).CreateSession), ctx, session)
 }
 
 // CreateUser mocks base method.
-func (m *MockStorageRepo) CreateUser(user model.User) (int64, error) {
+func (m *MockStorageRepo) CreateUser(ctx context.Context, user model.User) (int64, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateUser", user)
+	ret := m.ctrl.Call(m, "CreateUser", ctx, user)
 	ret0, _ := ret[0].(int64)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // CreateUser indicates an expected call of CreateUser.
-func (mr *MockStorageRepoMockRecorder) CreateUser(user interface{}) *gomock.Call {
+func (mr *MockStorageRepoMockRecorder) CreateUser(ctx, user interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUser", reflect.TypeOf((*MockStorageRepo)(nil).CreateUser), user)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUser", reflect.TypeOf((*MockStorageRepo)(nil).CreateUser), ctx, user)
 }
 
 // GetBalanceByUserID mocks base method.
-func (m *MockStorageRepo) GetBalanceByUserID(userID int64) (*model.Balance, error) {
+func (m *MockStorageRepo) GetBalanceByUserID(ctx context.Context, userID int64) (*model.Balance, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetBalanceByUserID", userID)
+	ret := m.ctrl.Call(m, "GetBalanceByUserID", ctx, userID)
 	ret0, _ := ret[0].(*model.Balance)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetBalanceByUserID indicates an expected call of GetBalanceByUserID.
-func (mr *MockStorageRepoMockRecorder) GetBalanceByUserID(userID interface{}) *gomock.Call {
+func (mr *MockStorageRepoMockRecorder) GetBalanceByUserID(ctx, userID interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBalanceByUserID", reflect.TypeOf((*MockStorageRepo)(nil).GetBalanceByUserID), userID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBalanceByUserID", reflect.TypeOf((*MockStorageRepo)(nil).GetBalanceByUserID), ctx, userID)
+}
+
+// GetLedgerByUserID mocks base method.
+func (m *MockStorageRepo) GetLedgerByUserID(ctx context.Context, userID int64, filter model.ListFilter) (*model.LedgerPage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLedgerByUserID", ctx, userID, filter)
+	ret0, _ := ret[0].(*model.LedgerPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLedgerByUserID indicates an expected call of GetLedgerByUserID.
+func (mr *MockStorageRepoMockRecorder) GetLedgerByUserID(ctx, userID, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLedgerByUserID", reflect.TypeOf((*MockStorageRepo)(nil).GetLedgerByUserID), ctx, userID, filter)
+}
+
+// GetLoginAttemptsByUserID mocks base method.
+func (m *MockStorageRepo) GetLoginAttemptsByUserID(ctx context.Context, userID int64, filter model.ListFilter) (*model.LoginAttemptsPage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLoginAttemptsByUserID", ctx, userID, filter)
+	ret0, _ := ret[0].(*model.LoginAttemptsPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLoginAttemptsByUserID indicates an expected call of GetLoginAttemptsByUserID.
+func (mr *MockStorageRepoMockRecorder) GetLoginAttemptsByUserID(ctx, userID, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLoginAttemptsByUserID", reflect.TypeOf((*MockStorageRepo)(nil).GetLoginAttemptsByUserID), ctx, userID, filter)
+}
+
+// GetLoginLockedUntil mocks base method.
+func (m *MockStorageRepo) GetLoginLockedUntil(ctx context.Context, login string) (*time.Time, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLoginLockedUntil", ctx, login)
+	ret0, _ := ret[0].(*time.Time)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLoginLockedUntil indicates an expected call of GetLoginLockedUntil.
+func (mr *MockStorageRepoMockRecorder) GetLoginLockedUntil(ctx, login interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLoginLockedUntil", reflect.TypeOf((*MockStorageRepo)(nil).GetLoginLockedUntil), ctx, login)
+}
+
+// GetOrderDetails mocks base method.
+func (m *MockStorageRepo) GetOrderDetails(ctx context.Context, number string) (*model.OrderDetails, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOrderDetails", ctx, number)
+	ret0, _ := ret[0].(*model.OrderDetails)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOrderDetails indicates an expected call of GetOrderDetails.
+func (mr *MockStorageRepoMockRecorder) GetOrderDetails(ctx, number interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrderDetails", reflect.TypeOf((*MockStorageRepo)(nil).GetOrderDetails), ctx, number)
+}
+
+// GetOrderEventsAfter mocks base method.
+func (m *MockStorageRepo) GetOrderEventsAfter(ctx context.Context, userID, afterID int64, limit int) ([]model.OrderEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOrderEventsAfter", ctx, userID, afterID, limit)
+	ret0, _ := ret[0].([]model.OrderEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOrderEventsAfter indicates an expected call of GetOrderEventsAfter.
+func (mr *MockStorageRepoMockRecorder) GetOrderEventsAfter(ctx, userID, afterID, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrderEventsAfter", reflect.TypeOf((*MockStorageRepo)(nil).GetOrderEventsAfter), ctx, userID, afterID, limit)
 }
 
 // GetOrdersByUserID mocks base method.
-func (m *MockStorageRepo) GetOrdersByUserID(userID int64) ([]model.Order, error) {
+func (m *MockStorageRepo) GetOrdersByUserID(ctx context.Context, userID int64, filter model.ListFilter) (*model.OrdersPage, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetOrdersByUserID", userID)
-	ret0, _ := ret[0].([]model.Order)
+	ret := m.ctrl.Call(m, "GetOrdersByUserID", ctx, userID, filter)
+	ret0, _ := ret[0].(*model.OrdersPage)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetOrdersByUserID indicates an expected call of GetOrdersByUserID.
-func (mr *MockStorageRepoMockRecorder) GetOrdersByUserID(userID interface{}) *gomock.Call {
+func (mr *MockStorageRepoMockRecorder) GetOrdersByUserID(ctx, userID, filter interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrdersByUserID", reflect.TypeOf((*MockStorageRepo)(nil).GetOrdersByUserID), userID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrdersByUserID", reflect.TypeOf((*MockStorageRepo)(nil).GetOrdersByUserID), ctx, userID, filter)
+}
+
+// GetSessionByID mocks base method.
+func (m *MockStorageRepo) GetSessionByID(ctx context.Context, id string) (*model.Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSessionByID", ctx, id)
+	ret0, _ := ret[0].(*model.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSessionByID indicates an expected call of GetSessionByID.
+func (mr *MockStorageRepoMockRecorder) GetSessionByID(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSessionByID", reflect.TypeOf((*MockStorageRepo)(nil).GetSessionByID), ctx, id)
+}
+
+// GetUserByID mocks base method.
+func (m *MockStorageRepo) GetUserByID(ctx context.Context, id int64) *model.User {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserByID", ctx, id)
+	ret0, _ := ret[0].(*model.User)
+	return ret0
+}
+
+// GetUserByID indicates an expected call of GetUserByID.
+func (mr *MockStorageRepoMockRecorder) GetUserByID(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByID", reflect.TypeOf((*MockStorageRepo)(nil).GetUserByID), ctx, id)
 }
 
 // GetUserByLogin mocks base method.
-func (m *MockStorageRepo) GetUserByLogin(login string) *model.User {
+func (m *MockStorageRepo) GetUserByLogin(ctx context.Context, login string) *model.User {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetUserByLogin", login)
+	ret := m.ctrl.Call(m, "GetUserByLogin", ctx, login)
 	ret0, _ := ret[0].(*model.User)
 	return ret0
 }
 
 // GetUserByLogin indicates an expected call of GetUserByLogin.
-func (mr *MockStorageRepoMockRecorder) GetUserByLogin(login interface{}) *gomock.Call {
+func (mr *MockStorageRepoMockRecorder) GetUserByLogin(ctx, login interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByLogin", reflect.TypeOf((*MockStorageRepo)(nil).GetUserByLogin), login)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByLogin", reflect.TypeOf((*MockStorageRepo)(nil).GetUserByLogin), ctx, login)
 }
 
 // GetWithdrawsByUserID mocks base method.
-func (m *MockStorageRepo) GetWithdrawsByUserID(userID int64) ([]model.Withdraw, error) {
+func (m *MockStorageRepo) GetWithdrawsByUserID(ctx context.Context, userID int64, filter model.ListFilter) (*model.WithdrawsPage, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetWithdrawsByUserID", userID)
-	ret0, _ := ret[0].([]model.Withdraw)
+	ret := m.ctrl.Call(m, "GetWithdrawsByUserID", ctx, userID, filter)
+	ret0, _ := ret[0].(*model.WithdrawsPage)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetWithdrawsByUserID indicates an expected call of GetWithdrawsByUserID.
-func (mr *MockStorageRepoMockRecorder) GetWithdrawsByUserID(userID interface{}) *gomock.Call {
+func (mr *MockStorageRepoMockRecorder) GetWithdrawsByUserID(ctx, userID, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWithdrawsByUserID", reflect.TypeOf((*MockStorageRepo)(nil).GetWithdrawsByUserID), ctx, userID, filter)
+}
+
+// GrantRole mocks base method.
+func (m *MockStorageRepo) GrantRole(ctx context.Context, adminID, userID int64, role model.Role) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GrantRole", ctx, adminID, userID, role)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// GrantRole indicates an expected call of GrantRole.
+func (mr *MockStorageRepoMockRecorder) GrantRole(ctx, adminID, userID, role interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GrantRole", reflect.TypeOf((*MockStorageRepo)(nil).GrantRole), ctx, adminID, userID, role)
+}
+
+// InvalidateOrder mocks base method.
+func (m *MockStorageRepo) InvalidateOrder(ctx context.Context, adminID int64, number, reason string) (*model.OrderEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InvalidateOrder", ctx, adminID, number, reason)
+	ret0, _ := ret[0].(*model.OrderEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// InvalidateOrder indicates an expected call of InvalidateOrder.
+func (mr *MockStorageRepoMockRecorder) InvalidateOrder(ctx, adminID, number, reason interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InvalidateOrder", reflect.TypeOf((*MockStorageRepo)(nil).InvalidateOrder), ctx, adminID, number, reason)
+}
+
+// IsRefreshTokenRotated mocks base method.
+func (m *MockStorageRepo) IsRefreshTokenRotated(ctx context.Context, id, hash string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IsRefreshTokenRotated", ctx, id, hash)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// IsRefreshTokenRotated indicates an expected call of IsRefreshTokenRotated.
+func (mr *MockStorageRepoMockRecorder) IsRefreshTokenRotated(ctx, id, hash interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsRefreshTokenRotated", reflect.TypeOf((*MockStorageRepo)(nil).IsRefreshTokenRotated), ctx, id, hash)
+}
+
+// IsSessionRevoked mocks base method.
+func (m *MockStorageRepo) IsSessionRevoked(ctx context.Context, id string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IsSessionRevoked", ctx, id)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// IsSessionRevoked indicates an expected call of IsSessionRevoked.
+func (mr *MockStorageRepoMockRecorder) IsSessionRevoked(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsSessionRevoked", reflect.TypeOf((*MockStorageRepo)(nil).IsSessionRevoked), ctx, id)
+}
+
+// RecordLoginAttempt mocks base method.
+func (m *MockStorageRepo) RecordLoginAttempt(ctx context.Context, attempt model.LoginAttempt) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecordLoginAttempt", ctx, attempt)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RecordLoginAttempt indicates an expected call of RecordLoginAttempt.
+func (mr *MockStorageRepoMockRecorder) RecordLoginAttempt(ctx, attempt interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordLoginAttempt", reflect.TypeOf((*MockStorageRepo)(nil).RecordLoginAttempt), ctx, attempt)
+}
+
+// RecordLoginFailure mocks base method.
+func (m *MockStorageRepo) RecordLoginFailure(ctx context.Context, attempt model.LoginAttempt, policy model.LockoutPolicy) (*time.Time, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecordLoginFailure", ctx, attempt, policy)
+	ret0, _ := ret[0].(*time.Time)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RecordLoginFailure indicates an expected call of RecordLoginFailure.
+func (mr *MockStorageRepoMockRecorder) RecordLoginFailure(ctx, attempt, policy interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWithdrawsByUserID", reflect.TypeOf((*MockStorageRepo)(nil).GetWithdrawsByUserID), userID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordLoginFailure", reflect.TypeOf((*MockStorageRepo)(nil).RecordLoginFailure), ctx, attempt, policy)
+}
+
+// RefundWithdraw mocks base method.
+func (m *MockStorageRepo) RefundWithdraw(ctx context.Context, actorID int64, order, reason string) (*model.LedgerEntry, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RefundWithdraw", ctx, actorID, order, reason)
+	ret0, _ := ret[0].(*model.LedgerEntry)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RefundWithdraw indicates an expected call of RefundWithdraw.
+func (mr *MockStorageRepoMockRecorder) RefundWithdraw(ctx, actorID, order, reason interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RefundWithdraw", reflect.TypeOf((*MockStorageRepo)(nil).RefundWithdraw), ctx, actorID, order, reason)
+}
+
+// ReleaseIdempotencyKey mocks base method.
+func (m *MockStorageRepo) ReleaseIdempotencyKey(ctx context.Context, userID int64, key string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReleaseIdempotencyKey", ctx, userID, key)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReleaseIdempotencyKey indicates an expected call of ReleaseIdempotencyKey.
+func (mr *MockStorageRepoMockRecorder) ReleaseIdempotencyKey(ctx, userID, key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReleaseIdempotencyKey", reflect.TypeOf((*MockStorageRepo)(nil).ReleaseIdempotencyKey), ctx, userID, key)
+}
+
+// ReserveIdempotencyKey mocks base method.
+func (m *MockStorageRepo) ReserveIdempotencyKey(ctx context.Context, record model.IdempotencyRecord) (*model.IdempotencyRecord, bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReserveIdempotencyKey", ctx, record)
+	ret0, _ := ret[0].(*model.IdempotencyRecord)
+	ret1, _ := ret[1].(bool)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// ReserveIdempotencyKey indicates an expected call of ReserveIdempotencyKey.
+func (mr *MockStorageRepoMockRecorder) ReserveIdempotencyKey(ctx, record interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReserveIdempotencyKey", reflect.TypeOf((*MockStorageRepo)(nil).ReserveIdempotencyKey), ctx, record)
+}
+
+// ResetOrderToNew mocks base method.
+func (m *MockStorageRepo) ResetOrderToNew(ctx context.Context, adminID int64, number, reason string) (*model.OrderEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResetOrderToNew", ctx, adminID, number, reason)
+	ret0, _ := ret[0].(*model.OrderEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ResetOrderToNew indicates an expected call of ResetOrderToNew.
+func (mr *MockStorageRepoMockRecorder) ResetOrderToNew(ctx, adminID, number, reason interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResetOrderToNew", reflect.TypeOf((*MockStorageRepo)(nil).ResetOrderToNew), ctx, adminID, number, reason)
+}
+
+// ResetPassword mocks base method.
+func (m *MockStorageRepo) ResetPassword(ctx context.Context, tokenHash, passwordHash string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResetPassword", ctx, tokenHash, passwordHash)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ResetPassword indicates an expected call of ResetPassword.
+func (mr *MockStorageRepoMockRecorder) ResetPassword(ctx, tokenHash, passwordHash interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResetPassword", reflect.TypeOf((*MockStorageRepo)(nil).ResetPassword), ctx, tokenHash, passwordHash)
+}
+
+// RevokeRole mocks base method.
+func (m *MockStorageRepo) RevokeRole(ctx context.Context, adminID, userID int64, role model.Role) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeRole", ctx, adminID, userID, role)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeRole indicates an expected call of RevokeRole.
+func (mr *MockStorageRepoMockRecorder) RevokeRole(ctx, adminID, userID, role interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeRole", reflect.TypeOf((*MockStorageRepo)(nil).RevokeRole), ctx, adminID, userID, role)
+}
+
+// RevokeSession mocks base method.
+func (m *MockStorageRepo) RevokeSession(ctx context.Context, id string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeSession", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeSession indicates an expected call of RevokeSession.
+func (mr *MockStorageRepoMockRecorder) RevokeSession(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeSession", reflect.TypeOf((*MockStorageRepo)(nil).RevokeSession), ctx, id)
+}
+
+// RotateSession mocks base method.
+func (m *MockStorageRepo) RotateSession(ctx context.Context, id, oldHash, newHash string, expiresAt time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RotateSession", ctx, id, oldHash, newHash, expiresAt)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RotateSession indicates an expected call of RotateSession.
+func (mr *MockStorageRepoMockRecorder) RotateSession(ctx, id, oldHash, newHash, expiresAt interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RotateSession", reflect.TypeOf((*MockStorageRepo)(nil).RotateSession), ctx, id, oldHash, newHash, expiresAt)
+}
+
+// SearchUsersByLogin mocks base method.
+func (m *MockStorageRepo) SearchUsersByLogin(ctx context.Context, login string) ([]model.UserSummary, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SearchUsersByLogin", ctx, login)
+	ret0, _ := ret[0].([]model.UserSummary)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SearchUsersByLogin indicates an expected call of SearchUsersByLogin.
+func (mr *MockStorageRepoMockRecorder) SearchUsersByLogin(ctx, login interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SearchUsersByLogin", reflect.TypeOf((*MockStorageRepo)(nil).SearchUsersByLogin), ctx, login)
 }
 
 // SetWithdraw mocks base method.
-func (m *MockStorageRepo) SetWithdraw(userID int64, input model.SetWithdrawDTO) error {
+func (m *MockStorageRepo) SetWithdraw(ctx context.Context, userID int64, input model.SetWithdrawDTO, idempotencyKey string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "SetWithdraw", userID, input)
+	ret := m.ctrl.Call(m, "SetWithdraw", ctx, userID, input, idempotencyKey)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // SetWithdraw indicates an expected call of SetWithdraw.
-func (mr *MockStorageRepoMockRecorder) SetWithdraw(userID, input interface{}) *gomock.Call {
+func (mr *MockStorageRepoMockRecorder) SetWithdraw(ctx, userID, input, idempotencyKey interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetWithdraw", reflect.TypeOf((*MockStorageRepo)(nil).SetWithdraw), ctx, userID, input, idempotencyKey)
+}
+
+// UpdatePasswordHash mocks base method.
+func (m *MockStorageRepo) UpdatePasswordHash(ctx context.Context, userID int64, oldHash, newHash string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdatePasswordHash", ctx, userID, oldHash, newHash)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdatePasswordHash indicates an expected call of UpdatePasswordHash.
+func (mr *MockStorageRepoMockRecorder) UpdatePasswordHash(ctx, userID, oldHash, newHash interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdatePasswordHash", reflect.TypeOf((*MockStorageRepo)(nil).UpdatePasswordHash), ctx, userID, oldHash, newHash)
+}
+
+// WriteAdminAudit mocks base method.
+func (m *MockStorageRepo) WriteAdminAudit(ctx context.Context, entry model.AdminAuditEntry) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WriteAdminAudit", ctx, entry)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// WriteAdminAudit indicates an expected call of WriteAdminAudit.
+func (mr *MockStorageRepoMockRecorder) WriteAdminAudit(ctx, entry interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetWithdraw", reflect.TypeOf((*MockStorageRepo)(nil).SetWithdraw), userID, input)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WriteAdminAudit", reflect.TypeOf((*MockStorageRepo)(nil).WriteAdminAudit), ctx, entry)
 }
@@ -4,17 +4,19 @@ import (
 	"context"
 	"database/sql"
 	"errors"
-	"math"
 	"path/filepath"
 	"time"
 
 	"github.com/golang-migrate/migrate/v4"
 	"github.com/golang-migrate/migrate/v4/database/postgres"
 	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/ibeloyar/gophermart/internal/metrics"
 	"github.com/ibeloyar/gophermart/internal/model"
-	"github.com/ibeloyar/gophermart/pgk/retryablehttp"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/lib/pq"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
@@ -27,20 +29,29 @@ const (
 )
 
 type Repository struct {
-	db             *sql.DB
-	lg             *zap.SugaredLogger
-	accrualAddress string
-	classifier     *PostgresErrorClassifier
-	retryClient    *retryablehttp.RetryableClient
-	workerPool     *WorkerPool
-
-	shutdownCtx     context.Context
-	shutdownCancel  context.CancelFunc
-	stopAccrualChan chan struct{}
+	db         *sql.DB
+	pool       *pgxpool.Pool
+	lg         *zap.SugaredLogger
+	classifier *PostgresErrorClassifier
+
+	// queryTimeout - предел одной попытки операции с базой; 0 - только ctx вызывающего
+	queryTimeout time.Duration
+
+	// migrationVersion - последняя миграция из migrationsPath, применённая при старте
+	migrationVersion uint
+
+	pointsExpiry time.Duration
+	expiringSoon time.Duration
 }
 
-func New(databaseURI, accrualAddress string, lg *zap.SugaredLogger) (*Repository, error) {
-	pool, err := pgxpool.New(context.Background(), databaseURI)
+func New(databaseURI string, lg *zap.SugaredLogger) (*Repository, error) {
+	poolConfig, err := pgxpool.ParseConfig(databaseURI)
+	if err != nil {
+		return nil, err
+	}
+	poolConfig.ConnConfig.Tracer = queryTracer{}
+
+	pool, err := pgxpool.NewWithConfig(context.Background(), poolConfig)
 	if err != nil {
 		return nil, err
 	}
@@ -69,34 +80,33 @@ func New(databaseURI, accrualAddress string, lg *zap.SugaredLogger) (*Repository
 		return nil, err
 	}
 
-	shutdownCtx, shutdownCancel := context.WithCancel(context.Background())
-
-	repo := &Repository{
-		db:              db,
-		lg:              lg,
-		accrualAddress:  accrualAddress,
-		classifier:      NewPostgresErrorClassifier(),
-		retryClient:     retryablehttp.NewRetryableClient(retryablehttp.RetryConfig{}),
-		workerPool:      NewWorkerPool(),
-		shutdownCtx:     shutdownCtx,
-		shutdownCancel:  shutdownCancel,
-		stopAccrualChan: make(chan struct{}),
+	version, _, err := m.Version()
+	if err != nil {
+		return nil, err
 	}
 
-	repo.RunOrdersAccrualUpdater()
-
-	return repo, nil
+	return &Repository{
+		db:               db,
+		pool:             pool,
+		lg:               lg,
+		classifier:       NewPostgresErrorClassifier(),
+		migrationVersion: version,
+	}, nil
 }
 
-func (r *Repository) GetUserByLogin(login string) *model.User {
+func (r *Repository) GetUserByLogin(ctx context.Context, login string) *model.User {
 	var user model.User
 
-	err := r.executeWithRetryConnection(func(db *sql.DB) error {
-		query := `SELECT * FROM users WHERE login = $1`
+	err := r.executeWithRetryConnection(ctx, func(ctx context.Context, db *sql.DB) error {
+		query := `SELECT u.id, u.login, u.password, ` + userRolesColumn + `, u.created_at FROM users u WHERE u.login = $1`
 
-		row := db.QueryRow(query, login)
+		var roles pq.StringArray
+		if err := db.QueryRowContext(ctx, query, login).Scan(&user.ID, &user.Login, &user.Password, &roles, &user.CreatedAt); err != nil {
+			return err
+		}
+		user.Roles = toRoles(roles)
 
-		return row.Scan(&user.ID, &user.Login, &user.Password, &user.CreatedAt)
+		return nil
 	})
 
 	if err != nil {
@@ -109,189 +119,404 @@ func (r *Repository) GetUserByLogin(login string) *model.User {
 	return &user
 }
 
-func (r *Repository) CreateUser(user model.User) (int64, error) {
+func (r *Repository) GetUserByID(ctx context.Context, id int64) *model.User {
+	var user model.User
+
+	err := r.executeWithRetryConnection(ctx, func(ctx context.Context, db *sql.DB) error {
+		query := `SELECT u.id, u.login, u.password, ` + userRolesColumn + `, u.created_at FROM users u WHERE u.id = $1`
+
+		var roles pq.StringArray
+		if err := db.QueryRowContext(ctx, query, id).Scan(&user.ID, &user.Login, &user.Password, &roles, &user.CreatedAt); err != nil {
+			return err
+		}
+		user.Roles = toRoles(roles)
+
+		return nil
+	})
+
+	if err != nil {
+		return nil
+	}
+
+	return &user
+}
+
+func (r *Repository) CreateUser(ctx context.Context, user model.User) (int64, error) {
 	var userID int64
 
-	err := r.executeWithRetryConnection(func(db *sql.DB) error {
+	err := r.executeWithRetryConnection(ctx, func(ctx context.Context, db *sql.DB) error {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
 		query := `INSERT INTO users (login, password) VALUES ($1, $2) RETURNING id`
 
-		row := db.QueryRow(query, user.Login, user.Password)
+		row := tx.QueryRowContext(ctx, query, user.Login, user.Password)
+		if err = row.Scan(&userID); err != nil {
+			return err
+		}
+
+		queryInsertRole := `INSERT INTO user_roles (user_id, role) VALUES ($1, $2)`
+		if _, err = tx.ExecContext(ctx, queryInsertRole, userID, model.RoleUser); err != nil {
+			return err
+		}
+
+		err = insertOutboxEvent(ctx, tx, model.DomainEventUserRegistered, userID, model.UserRegisteredData{Login: user.Login})
+		if err != nil {
+			return err
+		}
 
-		return row.Scan(&userID)
+		return tx.Commit()
 	})
 
 	return userID, err
 }
 
-func (r *Repository) CreateOrder(userID int64, number string) error {
-	return r.executeWithRetryConnection(func(db *sql.DB) error {
-		querySelectOrder := `SELECT user_id, number FROM orders WHERE number = $1`
+func (r *Repository) CreateOrder(ctx context.Context, userID int64, number string) error {
+	return r.executeWithRetryConnection(ctx, func(ctx context.Context, db *sql.DB) error {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
 
-		var order model.Order
-		_ = db.QueryRow(querySelectOrder, number).Scan(&order.UserID, &order.Number)
+		querySelectOrder := `SELECT user_id FROM orders WHERE number = $1`
 
-		if order.UserID != 0 && order.Number != "" {
-			if order.UserID == userID {
-				return model.ErrOrderHasBeenLoadedCurrentUser
-			}
-			if order.UserID != userID {
-				return model.ErrOrderHasBeenLoadedSomeUser
-			}
+		var ownerID int64
+		err = tx.QueryRowContext(ctx, querySelectOrder, number).Scan(&ownerID)
+		if err == nil {
+			return orderOwnerError(ownerID, userID)
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return err
 		}
 
 		queryInsertOrder := `INSERT INTO orders (user_id, number) VALUES ($1, $2)`
+		_, err = tx.ExecContext(ctx, queryInsertOrder, userID, number)
+		if hasErrorCode(err, ErrIsExistCode) {
+			// заказ успели загрузить параллельным запросом: транзакция прервана, владельца читаем вне её
+			_ = tx.Rollback()
+			if err = db.QueryRowContext(ctx, querySelectOrder, number).Scan(&ownerID); err != nil {
+				return err
+			}
+			return orderOwnerError(ownerID, userID)
+		}
+		if err != nil {
+			return err
+		}
 
-		_, err := db.Exec(queryInsertOrder, userID, number)
+		queryInsertHistory := `INSERT INTO order_status_history (order_number, status) VALUES ($1, $2)`
+		if _, err = tx.ExecContext(ctx, queryInsertHistory, number, model.OrderStatusNew); err != nil {
+			return err
+		}
 
-		return err
+		// задание на опрос системы расчёта создаётся вместе с заказом
+		queryInsertJob := `INSERT INTO accrual_jobs (order_number) VALUES ($1)`
+		if _, err = tx.ExecContext(ctx, queryInsertJob, number); err != nil {
+			return err
+		}
+
+		err = insertOutboxEvent(ctx, tx, model.DomainEventOrderUploaded, userID, model.OrderUploadedData{Order: number})
+		if err != nil {
+			return err
+		}
+
+		return tx.Commit()
 	})
 }
 
-func (r *Repository) GetOrdersByUserID(userID int64) ([]model.Order, error) {
-	result := make([]model.Order, 0)
+// orderOwnerError - ошибка повторной загрузки заказа в зависимости от того, кто загрузил его первым
+func orderOwnerError(ownerID, userID int64) error {
+	if ownerID == userID {
+		return model.ErrOrderHasBeenLoadedCurrentUser
+	}
 
-	err := r.executeWithRetryConnection(func(db *sql.DB) error {
-		query := `SELECT number, status, accrual, uploaded_at 
-			FROM orders WHERE user_id = $1 ORDER BY uploaded_at DESC`
+	return model.ErrOrderHasBeenLoadedSomeUser
+}
 
-		rows, err := db.Query(query, userID)
+func (r *Repository) GetOrdersByUserID(ctx context.Context, userID int64, filter model.ListFilter) (*model.OrdersPage, error) {
+	page := &model.OrdersPage{}
+
+	err := r.executeWithRetryConnection(ctx, func(ctx context.Context, db *sql.DB) error {
+		page.Orders = make([]model.Order, 0, filter.Limit)
+		page.Next = nil
+
+		q := &listQuery{}
+		q.where("user_id = $%d", userID)
+		if len(filter.Statuses) > 0 {
+			statuses := make([]string, len(filter.Statuses))
+			for i, status := range filter.Statuses {
+				statuses[i] = string(status)
+			}
+			q.where("status = ANY($%d)", pq.StringArray(statuses))
+		}
+
+		query := `SELECT id, number, status, accrual, uploaded_at FROM orders` + q.applyFilter("uploaded_at", filter)
+
+		rows, err := db.QueryContext(ctx, query, q.args...)
 		if err != nil {
 			return err
 		}
 		defer rows.Close()
 
+		var lastUploadedAt time.Time
 		for rows.Next() {
+			if len(page.Orders) == filter.Limit {
+				last := page.Orders[len(page.Orders)-1]
+				page.Next = &model.Cursor{At: lastUploadedAt, ID: last.ID}
+				break
+			}
+
 			var order model.Order
-			if err := rows.Scan(&order.Number, &order.Status, &order.Accrual, &order.UploadedAt); err != nil {
+			if err := rows.Scan(&order.ID, &order.Number, &order.Status, &order.Accrual, &lastUploadedAt); err != nil {
 				return err
 			}
+			order.UploadedAt = lastUploadedAt.Format(time.RFC3339Nano)
 
-			result = append(result, order)
+			page.Orders = append(page.Orders, order)
+		}
+
+		return rows.Err()
+	})
+
+	return page, err
+}
+
+// GetOrderDetails - заказ по номеру с историей статусов; model.ErrOrderNotFound, если заказа нет
+func (r *Repository) GetOrderDetails(ctx context.Context, number string) (*model.OrderDetails, error) {
+	var details model.OrderDetails
+
+	err := r.executeWithRetryConnection(ctx, func(ctx context.Context, db *sql.DB) error {
+		details = model.OrderDetails{History: make([]model.OrderStatusChange, 0)}
+
+		var uploadedAt time.Time
+		queryOrder := `SELECT id, user_id, number, status, accrual, uploaded_at FROM orders WHERE number = $1`
+		err := db.QueryRowContext(ctx, queryOrder, number).Scan(
+			&details.ID, &details.UserID, &details.Number, &details.Status, &details.Accrual, &uploadedAt,
+		)
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.ErrOrderNotFound
+		}
+		if err != nil {
+			return err
+		}
+		details.UploadedAt = uploadedAt.Format(time.RFC3339Nano)
+
+		queryHistory := `SELECT status, changed_at FROM order_status_history
+			WHERE order_number = $1 ORDER BY changed_at, id`
+
+		rows, err := db.QueryContext(ctx, queryHistory, number)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var (
+				change    model.OrderStatusChange
+				changedAt time.Time
+			)
+			if err := rows.Scan(&change.Status, &changedAt); err != nil {
+				return err
+			}
+			change.ChangedAt = changedAt.Format(time.RFC3339Nano)
+
+			details.History = append(details.History, change)
 		}
 
 		return rows.Err()
 	})
 
 	if err != nil {
-		return result, err
+		return nil, err
 	}
 
-	return result, nil
+	return &details, nil
 }
 
-func (r *Repository) GetBalanceByUserID(userID int64) (*model.Balance, error) {
+func (r *Repository) GetBalanceByUserID(ctx context.Context, userID int64) (*model.Balance, error) {
 	var balance model.Balance
 
-	err := r.executeWithRetryConnection(func(db *sql.DB) error {
-		query := `SELECT COALESCE(SUM(amount), 0) AS current, 
-			COALESCE(SUM(CASE WHEN amount < 0 THEN ABS(amount) ELSE 0 END), 0) AS withdrawn
-			FROM balance WHERE user_id = $1`
+	err := r.executeWithRetryConnection(ctx, func(ctx context.Context, db *sql.DB) error {
+		query := `SELECT current, withdrawn,
+				(SELECT COALESCE(SUM(remaining), 0) FROM point_lots
+					WHERE user_id = $1 AND remaining > 0 AND expires_at <= $2)
+			FROM user_balances WHERE user_id = $1`
 
-		row := db.QueryRow(query, userID)
+		row := db.QueryRowContext(ctx, query, userID, time.Now().Add(r.expiringSoon))
 
-		return row.Scan(&balance.Current, &balance.Withdrawn)
+		err := row.Scan(&balance.Current, &balance.Withdrawn, &balance.ExpiringSoon)
+		if errors.Is(err, sql.ErrNoRows) {
+			// снимок создаётся при первой записи в журнал
+			balance = model.Balance{}
+			return nil
+		}
+
+		return err
 	})
 
 	return &balance, err
 }
 
-func (r *Repository) SetWithdraw(userID int64, input model.SetWithdrawDTO) error {
-	return r.executeWithRetryConnection(func(db *sql.DB) error {
-		ctx := context.Background()
-
-		tx, err := r.db.BeginTx(ctx, nil)
+// SetWithdraw - списывает баллы; непустой idempotencyKey завершается в той же транзакции
+func (r *Repository) SetWithdraw(ctx context.Context, userID int64, input model.SetWithdrawDTO, idempotencyKey string) error {
+	return r.executeWithRetryConnection(ctx, func(ctx context.Context, db *sql.DB) error {
+		tx, err := db.BeginTx(ctx, nil)
 		if err != nil {
 			return err
 		}
 		defer tx.Rollback()
 
-		// блокируем строки баланса
-		_, err = tx.ExecContext(ctx, `SELECT id FROM balance WHERE user_id = $1 FOR UPDATE`, userID)
-		if err != nil {
+		// сумма уже проверена сервисом: положительная
+		amount := input.Sum
+
+		// номера, повторённые до уникального индекса, индекс не покрывает - проверяем явно
+		var used bool
+		queryUsed := `SELECT EXISTS (SELECT 1 FROM balance WHERE order_number = $1 AND kind = $2)`
+		if err = tx.QueryRowContext(ctx, queryUsed, input.Order, model.LedgerKindWithdrawal).Scan(&used); err != nil {
+			return err
+		}
+		if used {
+			return model.ErrWithdrawOrderUsed
+		}
+
+		// блокируем строку снимка баланса; недостаток средств отсекает CHECK
+		if err = updateBalance(ctx, tx, userID, -amount, amount); err != nil {
 			return err
 		}
 
-		// текущий баланс
-		var current float64
-		querySelectCurrentBalance := `SELECT COALESCE(SUM(amount), 0) AS current FROM balance WHERE user_id = $1`
-		err = tx.QueryRowContext(ctx, querySelectCurrentBalance, userID).Scan(&current)
+		// вставляем новую запись
+		queryInsertBalance := `INSERT INTO balance (user_id, order_number, amount, kind) VALUES ($1, $2, $3, $4)`
+		_, err = tx.ExecContext(ctx, queryInsertBalance, userID, input.Order, -amount, model.LedgerKindWithdrawal)
+		if hasErrorCode(err, ErrIsExistCode) {
+			return model.ErrWithdrawOrderUsed
+		}
 		if err != nil {
 			return err
 		}
 
-		// проверка остатка
-		absAmount := math.Abs(input.Sum)
-		if current < absAmount {
-			return model.ErrInsufficientFunds
+		if err = consumePointLots(ctx, tx, userID, amount); err != nil {
+			return err
 		}
 
-		// вставляем новую запись
-		queryInsertBalance := `INSERT INTO balance (user_id, order_number, amount) VALUES ($1, $2, $3)`
-		_, err = tx.ExecContext(ctx, queryInsertBalance, userID, input.Order, -absAmount)
+		withdrawn := model.PointsWithdrawnData{
+			UserID: userID,
+			Order:  input.Order,
+			Sum:    amount,
+		}
 
-		if err != nil {
+		if err = insertOutboxEvent(ctx, tx, model.DomainEventPointsWithdrawn, userID, withdrawn); err != nil {
+			return err
+		}
+
+		if err = enqueueWebhookEvent(ctx, tx, model.WebhookEventPointsWithdrawn, withdrawn); err != nil {
 			return err
 		}
 
+		if idempotencyKey != "" {
+			if err = completeIdempotencyKeyTx(ctx, tx, userID, idempotencyKey); err != nil {
+				return err
+			}
+		}
+
 		if err = tx.Commit(); err != nil {
 			return err
 		}
 
+		metrics.PointsWithdrawn(amount)
+
 		return nil
 	})
 }
 
-func (r *Repository) GetWithdrawsByUserID(userID int64) ([]model.Withdraw, error) {
-	result := make([]model.Withdraw, 0)
+func (r *Repository) GetWithdrawsByUserID(ctx context.Context, userID int64, filter model.ListFilter) (*model.WithdrawsPage, error) {
+	page := &model.WithdrawsPage{}
+
+	err := r.executeWithRetryConnection(ctx, func(ctx context.Context, db *sql.DB) error {
+		page.Withdraws = make([]model.Withdraw, 0, filter.Limit)
+		page.Next = nil
+
+		q := &listQuery{}
+		q.where("user_id = $%d", userID)
+		q.where("kind = $%d", model.LedgerKindWithdrawal)
 
-	err := r.executeWithRetryConnection(func(db *sql.DB) error {
-		query := `SELECT id, user_id, order_number, ABS(amount), uploaded_at
-			FROM balance WHERE user_id = $1 AND amount < 0 ORDER BY uploaded_at DESC`
+		query := `SELECT id, user_id, order_number, ABS(amount),
+				CASE WHEN EXISTS (SELECT 1 FROM balance r WHERE r.refund_of = balance.id) THEN 'REFUNDED' ELSE 'PROCESSED' END,
+				uploaded_at FROM balance` + q.applyFilter("uploaded_at", filter)
 
-		rows, err := db.Query(query, userID)
+		rows, err := db.QueryContext(ctx, query, q.args...)
 		if err != nil {
 			return err
 		}
 		defer rows.Close()
 
+		var lastProcessedAt time.Time
 		for rows.Next() {
-			var withdraw model.Withdraw
+			if len(page.Withdraws) == filter.Limit {
+				last := page.Withdraws[len(page.Withdraws)-1]
+				page.Next = &model.Cursor{At: lastProcessedAt, ID: last.ID}
+				break
+			}
 
-			if err := rows.Scan(&withdraw.ID, &withdraw.UserID, &withdraw.OrderNumber, &withdraw.Amount, &withdraw.UploadedAt); err != nil {
+			var withdraw model.Withdraw
+			if err := rows.Scan(&withdraw.ID, &withdraw.UserID, &withdraw.OrderNumber, &withdraw.Amount, &withdraw.Status, &lastProcessedAt); err != nil {
 				return err
 			}
+			withdraw.UploadedAt = lastProcessedAt.Format(time.RFC3339Nano)
 
-			result = append(result, withdraw)
+			page.Withdraws = append(page.Withdraws, withdraw)
 		}
 
 		return rows.Err()
 	})
 
-	return result, err
+	return page, err
 }
 
-func (r *Repository) Shutdown() error {
-	r.StopOrdersAccrualUpdater()
+// SetQueryTimeout - предел одной попытки операции с базой (запрос или транзакция целиком)
+func (r *Repository) SetQueryTimeout(timeout time.Duration) {
+	r.queryTimeout = timeout
+}
 
+func (r *Repository) Shutdown() error {
 	return r.db.Close()
 }
 
-func (r *Repository) executeWithRetryConnection(operation func(*sql.DB) error) error {
-	err := operation(r.db)
+// executeWithRetryConnection - выполняет operation, повторяя её при временных ошибках базы.
+// Каждая попытка ограничена queryTimeout; после отмены ctx новых попыток нет.
+func (r *Repository) executeWithRetryConnection(ctx context.Context, operation func(context.Context, *sql.DB) error) error {
+	err := r.attempt(ctx, operation)
 	if err == nil {
 		return nil
 	}
 
 	var lastErr error
 	for attempt := 0; attempt < maxAttempts; attempt++ {
-		if r.classifier.Classify(err) != Retriable {
+		classification := r.classifier.Classify(err)
+		r.observeDBError(err, classification)
+		if classification != Retriable || ctx.Err() != nil {
 			return err
 		}
 
+		metrics.DBRetry()
 		delay := getAttemptDelay(attempt)
-		time.Sleep(delay)
+		trace.SpanFromContext(ctx).AddEvent("db.retry", trace.WithAttributes(
+			attribute.Int("attempt", attempt+1),
+			attribute.String("delay", delay.String()),
+			attribute.String("error", err.Error()),
+		))
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return errors.Join(err, ctx.Err())
+		case <-timer.C:
+		}
 
-		err = operation(r.db)
+		err = r.attempt(ctx, operation)
 		if err == nil {
 			return nil
 		}
@@ -299,9 +524,29 @@ func (r *Repository) executeWithRetryConnection(operation func(*sql.DB) error) e
 		lastErr = err
 	}
 
+	r.observeDBError(lastErr, r.classifier.Classify(lastErr))
+
 	return lastErr // Возвращаем последнюю ошибку после 3 попыток
 }
 
+// observeDBError - в метрику ошибок базы попадают только ошибки драйвера и сервера,
+// иначе каждый обычный ответ вроде "заказ не найден" выглядел бы как сбой
+func (r *Repository) observeDBError(err error, classification ErrorClassification) {
+	if isDatabaseError(err) {
+		metrics.DBError(classification.String())
+	}
+}
+
+func (r *Repository) attempt(ctx context.Context, operation func(context.Context, *sql.DB) error) error {
+	if r.queryTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.queryTimeout)
+		defer cancel()
+	}
+
+	return operation(ctx, r.db)
+}
+
 func getAttemptDelay(attempt int) time.Duration {
 	switch attempt {
 	case 0:
@@ -1,13 +1,17 @@
 package pg
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"net/http"
 	"testing"
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/ibeloyar/gophermart/internal/model"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -19,19 +23,20 @@ func TestRepository_GetUserByLogin_Found(t *testing.T) {
 	repo := &Repository{db: db, classifier: NewPostgresErrorClassifier()}
 
 	createdAt := time.Now()
-	rows := sqlmock.NewRows([]string{"id", "login", "password", "created_at"}).
-		AddRow(123, "testuser", "hashed", createdAt)
+	rows := sqlmock.NewRows([]string{"id", "login", "password", "roles", "created_at"}).
+		AddRow(123, "testuser", "hashed", "{admin,user}", createdAt)
 
-	mock.ExpectQuery("SELECT \\* FROM users WHERE login = \\$1").
+	mock.ExpectQuery("SELECT u.id, u.login, u.password, ARRAY\\(SELECT role FROM user_roles WHERE user_id = u.id ORDER BY role\\), u.created_at FROM users u WHERE u.login = \\$1").
 		WithArgs("testuser").
 		WillReturnRows(rows)
 
-	result := repo.GetUserByLogin("testuser")
+	result := repo.GetUserByLogin(context.Background(), "testuser")
 
 	assert.NotNil(t, result)
 	assert.Equal(t, int64(123), result.ID)
 	assert.Equal(t, "testuser", result.Login)
 	assert.Equal(t, "hashed", result.Password)
+	assert.Equal(t, []model.Role{model.RoleAdmin, model.RoleUser}, result.Roles)
 	assert.WithinDuration(t, createdAt, result.CreatedAt, time.Second)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
@@ -43,11 +48,11 @@ func TestRepository_GetUserByLogin_NotFound(t *testing.T) {
 
 	repo := &Repository{db: db, classifier: NewPostgresErrorClassifier()}
 
-	mock.ExpectQuery("SELECT \\* FROM users WHERE login = \\$1").
+	mock.ExpectQuery("SELECT u.id, u.login, u.password, ARRAY\\(SELECT role FROM user_roles WHERE user_id = u.id ORDER BY role\\), u.created_at FROM users u WHERE u.login = \\$1").
 		WithArgs("nonexistent").
 		WillReturnError(sql.ErrNoRows)
 
-	result := repo.GetUserByLogin("nonexistent")
+	result := repo.GetUserByLogin(context.Background(), "nonexistent")
 
 	assert.Nil(t, result)
 	assert.NoError(t, mock.ExpectationsWereMet())
@@ -60,11 +65,19 @@ func TestRepository_CreateUser_Success(t *testing.T) {
 
 	repo := &Repository{db: db, classifier: NewPostgresErrorClassifier()}
 
+	mock.ExpectBegin()
 	mock.ExpectQuery("INSERT INTO users \\(login, password\\) VALUES \\(\\$1, \\$2\\) RETURNING id").
 		WithArgs("testuser", "hashed").
 		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(int64(123)))
+	mock.ExpectExec("INSERT INTO user_roles \\(user_id, role\\) VALUES \\(\\$1, \\$2\\)").
+		WithArgs(int64(123), model.RoleUser).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO events_outbox \\(event_type, user_id, payload\\) VALUES \\(\\$1, \\$2, \\$3\\)").
+		WithArgs(model.DomainEventUserRegistered, int64(123), `{"login":"testuser"}`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
 
-	userID, err := repo.CreateUser(model.User{Login: "testuser", Password: "hashed"})
+	userID, err := repo.CreateUser(context.Background(), model.User{Login: "testuser", Password: "hashed"})
 
 	assert.NoError(t, err)
 	assert.Equal(t, int64(123), userID)
@@ -78,12 +91,13 @@ func TestRepository_CreateOrder_CurrentUser(t *testing.T) {
 
 	repo := &Repository{db: db, classifier: NewPostgresErrorClassifier()}
 
-	mock.ExpectQuery("SELECT user_id, number FROM orders WHERE number = \\$1").
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT user_id FROM orders WHERE number = \\$1").
 		WithArgs("order123").
-		WillReturnRows(sqlmock.NewRows([]string{"user_id", "number"}).
-			AddRow(int64(123), "order123"))
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(int64(123)))
+	mock.ExpectRollback()
 
-	err = repo.CreateOrder(123, "order123")
+	err = repo.CreateOrder(context.Background(), 123, "order123")
 
 	assert.ErrorIs(t, err, model.ErrOrderHasBeenLoadedCurrentUser)
 	assert.NoError(t, mock.ExpectationsWereMet())
@@ -96,14 +110,51 @@ func TestRepository_GetOrdersByUserID_Empty(t *testing.T) {
 
 	repo := &Repository{db: db, classifier: NewPostgresErrorClassifier()}
 
-	mock.ExpectQuery("SELECT number, status, accrual, uploaded_at FROM orders WHERE user_id = \\$1 ORDER BY uploaded_at DESC").
-		WithArgs(int64(123)).
-		WillReturnRows(sqlmock.NewRows([]string{"number", "status", "accrual", "uploaded_at"}))
+	mock.ExpectQuery("SELECT id, number, status, accrual, uploaded_at FROM orders WHERE user_id = \\$1 ORDER BY uploaded_at DESC, id DESC LIMIT \\$2").
+		WithArgs(int64(123), 101).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "number", "status", "accrual", "uploaded_at"}))
 
-	orders, err := repo.GetOrdersByUserID(123)
+	page, err := repo.GetOrdersByUserID(context.Background(), 123, model.ListFilter{Limit: 100, Sort: model.SortDesc})
 
 	assert.NoError(t, err)
-	assert.Len(t, orders, 0)
+	assert.Len(t, page.Orders, 0)
+	assert.Nil(t, page.Next)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_GetOrdersByUserID_NextPage(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := &Repository{db: db, classifier: NewPostgresErrorClassifier()}
+
+	first := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	second := first.Add(time.Hour)
+	after := &model.Cursor{At: first.Add(-time.Hour), ID: 1}
+	from := first.Add(-24 * time.Hour)
+
+	mock.ExpectQuery("SELECT id, number, status, accrual, uploaded_at FROM orders "+
+		"WHERE user_id = \\$1 AND status = ANY\\(\\$2\\) AND uploaded_at >= \\$3 AND \\(uploaded_at, id\\) > \\(\\$4, \\$5\\) "+
+		"ORDER BY uploaded_at ASC, id ASC LIMIT \\$6").
+		WithArgs(int64(123), sqlmock.AnyArg(), from, after.At, after.ID, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "number", "status", "accrual", "uploaded_at"}).
+			AddRow(int64(2), "1", "NEW", "0", first).
+			AddRow(int64(3), "2", "NEW", "0", second).
+			AddRow(int64(4), "3", "NEW", "0", second))
+
+	page, err := repo.GetOrdersByUserID(context.Background(), 123, model.ListFilter{
+		Limit:    2,
+		After:    after,
+		Statuses: []model.OrderStatus{model.OrderStatusNew},
+		From:     &from,
+		Sort:     model.SortAsc,
+	})
+
+	assert.NoError(t, err)
+	assert.Len(t, page.Orders, 2)
+	assert.Equal(t, first.Format(time.RFC3339Nano), page.Orders[0].UploadedAt)
+	assert.Equal(t, &model.Cursor{At: second, ID: 3}, page.Next)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
@@ -114,12 +165,13 @@ func TestRepository_CreateOrder_OtherUser(t *testing.T) {
 
 	repo := &Repository{db: db, classifier: NewPostgresErrorClassifier()}
 
-	mock.ExpectQuery("SELECT user_id, number FROM orders WHERE number = \\$1").
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT user_id FROM orders WHERE number = \\$1").
 		WithArgs("order123").
-		WillReturnRows(sqlmock.NewRows([]string{"user_id", "number"}).
-			AddRow(int64(456), "order123"))
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(int64(456)))
+	mock.ExpectRollback()
 
-	err = repo.CreateOrder(123, "order123")
+	err = repo.CreateOrder(context.Background(), 123, "order123")
 
 	assert.ErrorIs(t, err, model.ErrOrderHasBeenLoadedSomeUser)
 	assert.NoError(t, mock.ExpectationsWereMet())
@@ -132,17 +184,53 @@ func TestRepository_CreateOrder_NewOrder(t *testing.T) {
 
 	repo := &Repository{db: db, classifier: NewPostgresErrorClassifier()}
 
-	mock.ExpectQuery("SELECT user_id, number FROM orders WHERE number = \\$1").
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT user_id FROM orders WHERE number = \\$1").
 		WithArgs("neworder").
 		WillReturnError(sql.ErrNoRows)
-
 	mock.ExpectExec("INSERT INTO orders \\(user_id, number\\) VALUES \\(\\$1, \\$2\\)").
 		WithArgs(int64(123), "neworder").
 		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO order_status_history \\(order_number, status\\) VALUES \\(\\$1, \\$2\\)").
+		WithArgs("neworder", model.OrderStatusNew).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO accrual_jobs \\(order_number\\) VALUES \\(\\$1\\)").
+		WithArgs("neworder").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO events_outbox \\(event_type, user_id, payload\\) VALUES \\(\\$1, \\$2, \\$3\\)").
+		WithArgs(model.DomainEventOrderUploaded, int64(123), `{"order":"neworder"}`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
 
-	err = repo.CreateOrder(123, "neworder")
+	err = repo.CreateOrder(context.Background(), 123, "neworder")
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
 
+func TestRepository_CreateOrder_ConcurrentUpload(t *testing.T) {
+	db, mock, err := sqlmock.New()
 	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := &Repository{db: db, classifier: NewPostgresErrorClassifier()}
+
+	// заказ вставлен параллельной транзакцией между проверкой и INSERT
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT user_id FROM orders WHERE number = \\$1").
+		WithArgs("order123").
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectExec("INSERT INTO orders \\(user_id, number\\) VALUES \\(\\$1, \\$2\\)").
+		WithArgs(int64(123), "order123").
+		WillReturnError(&pgconn.PgError{Code: ErrIsExistCode})
+	mock.ExpectRollback()
+	mock.ExpectQuery("SELECT user_id FROM orders WHERE number = \\$1").
+		WithArgs("order123").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(int64(456)))
+
+	err = repo.CreateOrder(context.Background(), 123, "order123")
+
+	assert.ErrorIs(t, err, model.ErrOrderHasBeenLoadedSomeUser)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
@@ -153,16 +241,199 @@ func TestRepository_GetBalanceByUserID_Success(t *testing.T) {
 
 	repo := &Repository{db: db, classifier: NewPostgresErrorClassifier()}
 
-	mock.ExpectQuery(`SELECT COALESCE\(SUM\(amount\), 0\) AS current, COALESCE\(SUM\(CASE WHEN amount < 0 THEN ABS\(amount\) ELSE 0 END\), 0\) AS withdrawn FROM balance WHERE user_id = \$1`).
+	mock.ExpectQuery(`SELECT current, withdrawn, \(SELECT COALESCE\(SUM\(remaining\), 0\) FROM point_lots .+\) FROM user_balances WHERE user_id = \$1`).
+		WithArgs(int64(123), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"current", "withdrawn", "expiring_soon"}).
+			AddRow("100.50", "50.00", "20.00"))
+
+	balance, err := repo.GetBalanceByUserID(context.Background(), 123)
+
+	assert.NoError(t, err)
+	assert.Equal(t, model.Money(10050), balance.Current)
+	assert.Equal(t, model.Money(5000), balance.Withdrawn)
+	assert.Equal(t, model.Money(2000), balance.ExpiringSoon)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_GetBalanceByUserID_NoSnapshot(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := &Repository{db: db, classifier: NewPostgresErrorClassifier()}
+
+	mock.ExpectQuery(`SELECT current, withdrawn, .+ FROM user_balances WHERE user_id = \$1`).
+		WithArgs(int64(123), sqlmock.AnyArg()).
+		WillReturnError(sql.ErrNoRows)
+
+	balance, err := repo.GetBalanceByUserID(context.Background(), 123)
+
+	assert.NoError(t, err)
+	assert.Equal(t, model.Balance{}, *balance)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_SetWithdraw_Success(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := &Repository{db: db, classifier: NewPostgresErrorClassifier()}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM balance WHERE order_number = \$1 AND kind = \$2\)`).
+		WithArgs("order123", model.LedgerKindWithdrawal).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectExec(`UPDATE user_balances SET\s+current = current \+ \$2,\s+withdrawn = withdrawn \+ \$3,\s+updated_at = now\(\)\s+WHERE user_id = \$1`).
+		WithArgs(int64(123), model.Money(-1050), model.Money(1050)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO balance \(user_id, order_number, amount, kind\) VALUES \(\$1, \$2, \$3, \$4\)`).
+		WithArgs(int64(123), "order123", model.Money(-1050), model.LedgerKindWithdrawal).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	// партии расходуются от старых к новым
+	mock.ExpectQuery(`SELECT id, remaining FROM point_lots\s+WHERE user_id = \$1 AND remaining > 0\s+ORDER BY id\s+FOR UPDATE`).
 		WithArgs(int64(123)).
-		WillReturnRows(sqlmock.NewRows([]string{"current", "withdrawn"}).
-			AddRow(float32(100.5), float32(50.0)))
+		WillReturnRows(sqlmock.NewRows([]string{"id", "remaining"}).
+			AddRow(int64(1), "3.00").
+			AddRow(int64(2), "20.00").
+			AddRow(int64(3), "5.00"))
+	mock.ExpectExec(`UPDATE point_lots SET remaining = remaining - \$1 WHERE id = \$2`).
+		WithArgs(model.Money(300), int64(1)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE point_lots SET remaining = remaining - \$1 WHERE id = \$2`).
+		WithArgs(model.Money(750), int64(2)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO events_outbox \(event_type, user_id, payload\) VALUES \(\$1, \$2, \$3\)`).
+		WithArgs(model.DomainEventPointsWithdrawn, int64(123), `{"user_id":123,"order":"order123","sum":10.5}`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`INSERT INTO webhook_outbox \(endpoint_id, event_id, event_type, payload\)`).
+		WithArgs(sqlmock.AnyArg(), model.WebhookEventPointsWithdrawn, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
 
-	balance, err := repo.GetBalanceByUserID(123)
+	err = repo.SetWithdraw(context.Background(), 123, model.SetWithdrawDTO{Order: "order123", Sum: 1050}, "")
 
 	assert.NoError(t, err)
-	assert.Equal(t, float32(100.5), balance.Current)
-	assert.Equal(t, float32(50.0), balance.Withdrawn)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_SetWithdraw_CompletesIdempotencyKey(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := &Repository{db: db, classifier: NewPostgresErrorClassifier()}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT EXISTS`).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectExec(`UPDATE user_balances SET`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO balance`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery(`SELECT id, remaining FROM point_lots`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "remaining"}))
+	mock.ExpectExec(`INSERT INTO events_outbox`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`INSERT INTO webhook_outbox`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	// ключ завершается до коммита списания
+	mock.ExpectExec(`UPDATE idempotency_keys SET response_code = \$1, response_body = '' WHERE user_id = \$2 AND key = \$3`).
+		WithArgs(http.StatusOK, int64(123), "key-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err = repo.SetWithdraw(context.Background(), 123, model.SetWithdrawDTO{Order: "order123", Sum: 1050}, "key-1")
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_SetWithdraw_InsufficientFunds(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := &Repository{db: db, classifier: NewPostgresErrorClassifier()}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM balance WHERE order_number = \$1 AND kind = \$2\)`).
+		WithArgs("order123", model.LedgerKindWithdrawal).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectExec(`UPDATE user_balances SET`).
+		WithArgs(int64(123), model.Money(-1050), model.Money(1050)).
+		WillReturnError(&pgconn.PgError{Code: ErrCheckViolationCode})
+	mock.ExpectRollback()
+
+	err = repo.SetWithdraw(context.Background(), 123, model.SetWithdrawDTO{Order: "order123", Sum: 1050}, "")
+
+	assert.ErrorIs(t, err, model.ErrInsufficientFunds)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_SetWithdraw_NoBalance(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := &Repository{db: db, classifier: NewPostgresErrorClassifier()}
+
+	// снимка ещё нет: пользователю нечего списывать
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT EXISTS`).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectExec(`UPDATE user_balances SET`).
+		WithArgs(int64(123), model.Money(-1050), model.Money(1050)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	err = repo.SetWithdraw(context.Background(), 123, model.SetWithdrawDTO{Order: "order123", Sum: 1050}, "")
+
+	assert.ErrorIs(t, err, model.ErrInsufficientFunds)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_SetWithdraw_OrderUsed(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := &Repository{db: db, classifier: NewPostgresErrorClassifier()}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM balance WHERE order_number = \$1 AND kind = \$2\)`).
+		WithArgs("order123", model.LedgerKindWithdrawal).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectRollback()
+
+	err = repo.SetWithdraw(context.Background(), 123, model.SetWithdrawDTO{Order: "order123", Sum: 1050}, "")
+
+	assert.ErrorIs(t, err, model.ErrWithdrawOrderUsed)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_SetWithdraw_OrderUsedConcurrently(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := &Repository{db: db, classifier: NewPostgresErrorClassifier()}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM balance WHERE order_number = \$1 AND kind = \$2\)`).
+		WithArgs("order123", model.LedgerKindWithdrawal).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectExec(`UPDATE user_balances SET`).
+		WithArgs(int64(123), model.Money(-1050), model.Money(1050)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO balance`).
+		WithArgs(int64(123), "order123", model.Money(-1050), model.LedgerKindWithdrawal).
+		WillReturnError(&pgconn.PgError{Code: ErrIsExistCode})
+	mock.ExpectRollback()
+
+	err = repo.SetWithdraw(context.Background(), 123, model.SetWithdrawDTO{Order: "order123", Sum: 1050}, "")
+
+	assert.ErrorIs(t, err, model.ErrWithdrawOrderUsed)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
@@ -174,20 +445,22 @@ func TestRepository_GetWithdrawsByUserID_Success(t *testing.T) {
 	repo := &Repository{db: db, classifier: NewPostgresErrorClassifier()}
 
 	now := time.Now()
-	rows := sqlmock.NewRows([]string{"id", "user_id", "order_number", "amount", "uploaded_at"}).
-		AddRow(int64(1), int64(123), "order123", 10.5, now)
+	rows := sqlmock.NewRows([]string{"id", "user_id", "order_number", "amount", "status", "uploaded_at"}).
+		AddRow(int64(1), int64(123), "order123", "10.50", "REFUNDED", now)
 
-	mock.ExpectQuery(`SELECT id, user_id, order_number, ABS\(amount\), uploaded_at FROM balance WHERE user_id = \$1 AND amount < 0 ORDER BY uploaded_at DESC`).
-		WithArgs(int64(123)).
+	mock.ExpectQuery(`SELECT id, user_id, order_number, ABS\(amount\),\s+CASE WHEN EXISTS \(SELECT 1 FROM balance r WHERE r.refund_of = balance.id\) THEN 'REFUNDED' ELSE 'PROCESSED' END,\s+uploaded_at FROM balance WHERE user_id = \$1 AND kind = \$2 ORDER BY uploaded_at DESC, id DESC LIMIT \$3`).
+		WithArgs(int64(123), model.LedgerKindWithdrawal, 101).
 		WillReturnRows(rows)
 
-	withdraws, err := repo.GetWithdrawsByUserID(123)
+	page, err := repo.GetWithdrawsByUserID(context.Background(), 123, model.ListFilter{Limit: 100, Sort: model.SortDesc})
 
 	assert.NoError(t, err)
-	assert.Len(t, withdraws, 1)
-	assert.Equal(t, int64(1), withdraws[0].ID)
-	assert.Equal(t, "order123", withdraws[0].OrderNumber)
-	assert.Equal(t, 10.5, withdraws[0].Amount)
+	assert.Len(t, page.Withdraws, 1)
+	assert.Equal(t, int64(1), page.Withdraws[0].ID)
+	assert.Equal(t, "order123", page.Withdraws[0].OrderNumber)
+	assert.Equal(t, model.Money(1050), page.Withdraws[0].Amount)
+	assert.Equal(t, model.WithdrawStatusRefunded, page.Withdraws[0].Status)
+	assert.Nil(t, page.Next)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
@@ -209,3 +482,112 @@ func TestRepository_getAttemptDelay(t *testing.T) {
 		})
 	}
 }
+
+func TestRepository_executeWithRetryConnection_StopsWhenContextDone(t *testing.T) {
+	repo := &Repository{classifier: NewPostgresErrorClassifier()}
+	deadlock := &pq.Error{Code: "40P01"}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+
+	start := time.Now()
+	err := repo.executeWithRetryConnection(ctx, func(context.Context, *sql.DB) error {
+		calls++
+		time.AfterFunc(10*time.Millisecond, cancel)
+		return deadlock
+	})
+
+	// ожидание перед повтором (1s) прерывается отменой, второй попытки нет
+	assert.ErrorIs(t, err, deadlock)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 1, calls)
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+}
+
+func TestRepository_executeWithRetryConnection_QueryTimeout(t *testing.T) {
+	repo := &Repository{classifier: NewPostgresErrorClassifier(), queryTimeout: 10 * time.Millisecond}
+
+	err := repo.executeWithRetryConnection(context.Background(), func(ctx context.Context, _ *sql.DB) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestRepository_GetOrderDetails_Success(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := &Repository{db: db, classifier: NewPostgresErrorClassifier()}
+
+	uploadedAt := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	processedAt := uploadedAt.Add(time.Minute)
+
+	mock.ExpectQuery(`SELECT id, user_id, number, status, accrual, uploaded_at FROM orders WHERE number = \$1`).
+		WithArgs("order123").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "number", "status", "accrual", "uploaded_at"}).
+			AddRow(int64(1), int64(123), "order123", "PROCESSED", "500.00", uploadedAt))
+	mock.ExpectQuery(`SELECT status, changed_at FROM order_status_history\s+WHERE order_number = \$1 ORDER BY changed_at, id`).
+		WithArgs("order123").
+		WillReturnRows(sqlmock.NewRows([]string{"status", "changed_at"}).
+			AddRow("NEW", uploadedAt).
+			AddRow("PROCESSED", processedAt))
+
+	details, err := repo.GetOrderDetails(context.Background(), "order123")
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(123), details.UserID)
+	assert.Equal(t, model.Money(50000), details.Accrual)
+	assert.Equal(t, []model.OrderStatusChange{
+		{Status: model.OrderStatusNew, ChangedAt: uploadedAt.Format(time.RFC3339Nano)},
+		{Status: model.OrderStatusProcessed, ChangedAt: processedAt.Format(time.RFC3339Nano)},
+	}, details.History)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_GetOrderDetails_NotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := &Repository{db: db, classifier: NewPostgresErrorClassifier()}
+
+	mock.ExpectQuery(`SELECT id, user_id, number, status, accrual, uploaded_at FROM orders WHERE number = \$1`).
+		WithArgs("order123").
+		WillReturnError(sql.ErrNoRows)
+
+	details, err := repo.GetOrderDetails(context.Background(), "order123")
+
+	assert.Nil(t, details)
+	assert.ErrorIs(t, err, model.ErrOrderNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_GetOrderEventsAfter(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := &Repository{db: db, classifier: NewPostgresErrorClassifier()}
+	changedAt := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery(`SELECT h.id, h.order_number, h.status, h.accrual, h.changed_at\s+FROM order_status_history h`).
+		WithArgs(int64(123), int64(5), 100).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "order_number", "status", "accrual", "changed_at"}).
+			AddRow(int64(6), "order123", "PROCESSED", "500.00", changedAt))
+
+	events, err := repo.GetOrderEventsAfter(context.Background(), 123, 5, 100)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []model.OrderEvent{{
+		ID:        6,
+		UserID:    123,
+		Number:    "order123",
+		Status:    model.OrderStatusProcessed,
+		Accrual:   50000,
+		ChangedAt: changedAt.Format(time.RFC3339Nano),
+	}}, events)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
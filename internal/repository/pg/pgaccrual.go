@@ -0,0 +1,213 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/ibeloyar/gophermart/internal/metrics"
+	"github.com/ibeloyar/gophermart/internal/model"
+)
+
+// ClaimAccrualJobs - забирает задания, время которых наступило, и продлевает их на lease.
+// FOR UPDATE SKIP LOCKED не даёт нескольким репликам взять одни и те же заказы.
+func (r *Repository) ClaimAccrualJobs(ctx context.Context, limit int, lease time.Duration) ([]model.AccrualJob, error) {
+	var result []model.AccrualJob
+
+	err := r.executeWithRetryConnection(ctx, func(ctx context.Context, db *sql.DB) error {
+		result = make([]model.AccrualJob, 0, limit)
+
+		query := `WITH claimed AS (
+				SELECT order_number FROM accrual_jobs
+				WHERE next_attempt_at <= now()
+				ORDER BY next_attempt_at
+				LIMIT $1
+				FOR UPDATE SKIP LOCKED
+			)
+			UPDATE accrual_jobs j SET next_attempt_at = now() + make_interval(secs => $2), updated_at = now()
+			FROM claimed c, orders o
+			WHERE j.order_number = c.order_number AND o.number = c.order_number
+			RETURNING j.order_number, o.user_id, j.attempts`
+
+		rows, err := db.QueryContext(ctx, query, limit, lease.Seconds())
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var job model.AccrualJob
+			if err := rows.Scan(&job.OrderNumber, &job.UserID, &job.Attempts); err != nil {
+				return err
+			}
+
+			result = append(result, job)
+		}
+
+		return rows.Err()
+	})
+
+	return result, err
+}
+
+// UpdateOrderAccrual - обновление статуса заказа и суммы начислений.
+// Статус и начисление меняются в одной транзакции; заказ в финальном статусе
+// повторно не обновляется, а уникальный индекс по номеру заказа для ACCRUAL
+// делает повторное начисление no-op (второй опрос, ретрай, другая реплика).
+// Если статус изменился, возвращает событие для подписчиков (иначе nil).
+func (r *Repository) UpdateOrderAccrual(ctx context.Context, job model.AccrualJob, accrual model.Accrual, nextAttemptAt time.Time) (*model.OrderEvent, error) {
+	var (
+		event    *model.OrderEvent
+		credited model.Money
+	)
+
+	err := r.executeWithRetryConnection(ctx, func(ctx context.Context, db *sql.DB) error {
+		event = nil
+		credited = 0
+
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		var currentStatus model.OrderStatus
+		err = tx.QueryRowContext(ctx, `SELECT status FROM orders WHERE number = $1 FOR UPDATE`, job.OrderNumber).
+			Scan(&currentStatus)
+		if err != nil {
+			return err
+		}
+
+		if !currentStatus.IsFinal() {
+			_, err = tx.ExecContext(ctx, `UPDATE orders SET status = $1, accrual = $2 WHERE number = $3`,
+				accrual.Status,
+				accrual.Accrual,
+				job.OrderNumber,
+			)
+			if err != nil {
+				return err
+			}
+
+			if accrual.Status != currentStatus {
+				event = &model.OrderEvent{
+					UserID:  job.UserID,
+					Number:  job.OrderNumber,
+					Status:  accrual.Status,
+					Accrual: accrual.Accrual,
+				}
+
+				var changedAt time.Time
+				err = tx.QueryRowContext(ctx, `INSERT INTO order_status_history (order_number, status, accrual) VALUES ($1, $2, $3)
+					RETURNING id, changed_at`,
+					job.OrderNumber,
+					accrual.Status,
+					accrual.Accrual,
+				).Scan(&event.ID, &changedAt)
+				if err != nil {
+					return err
+				}
+				event.ChangedAt = changedAt.Format(time.RFC3339Nano)
+			}
+
+			if accrual.Status == model.OrderStatusProcessed && accrual.Accrual > 0 {
+				var balanceID int64
+				err := tx.QueryRowContext(ctx, `INSERT INTO balance (user_id, order_number, amount, kind) VALUES ($1, $2, $3, $4)
+					ON CONFLICT (order_number) WHERE kind = 'ACCRUAL' DO NOTHING
+					RETURNING id`,
+					job.UserID,
+					job.OrderNumber,
+					accrual.Accrual,
+					model.LedgerKindAccrual,
+				).Scan(&balanceID)
+				inserted := err == nil
+				if err != nil && !errors.Is(err, sql.ErrNoRows) {
+					return err
+				}
+
+				// снимок баланса, партия и события меняются, только если начисление действительно записано
+				if inserted {
+					if err = applyBalanceDelta(ctx, tx, job.UserID, accrual.Accrual, 0); err != nil {
+						return err
+					}
+
+					if err = insertPointLot(ctx, tx, job.UserID, balanceID, accrual.Accrual, r.lotExpiresAt()); err != nil {
+						return err
+					}
+
+					accrued := model.OrderAccruedData{
+						UserID:  job.UserID,
+						Order:   job.OrderNumber,
+						Accrual: accrual.Accrual,
+					}
+
+					if err = insertOutboxEvent(ctx, tx, model.DomainEventPointsCredited, job.UserID, accrued); err != nil {
+						return err
+					}
+
+					if err = enqueueWebhookEvent(ctx, tx, model.WebhookEventOrderAccrued, accrued); err != nil {
+						return err
+					}
+
+					credited = accrual.Accrual
+				}
+			}
+		}
+
+		if currentStatus.IsFinal() || accrual.Status.IsFinal() {
+			_, err = tx.ExecContext(ctx, `DELETE FROM accrual_jobs WHERE order_number = $1`, job.OrderNumber)
+		} else {
+			_, err = tx.ExecContext(ctx, `UPDATE accrual_jobs
+				SET next_attempt_at = $1, attempts = 0, last_error = NULL, updated_at = now()
+				WHERE order_number = $2`, nextAttemptAt, job.OrderNumber)
+		}
+		if err != nil {
+			return err
+		}
+
+		return tx.Commit()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if credited > 0 {
+		metrics.PointsCredited(credited)
+	}
+
+	return event, nil
+}
+
+// CountDueAccrualJobs - число заданий, время опроса которых уже наступило (глубина очереди).
+// Вызывается при сборе метрик, поэтому выполняется одной попыткой без пауз между повторами
+func (r *Repository) CountDueAccrualJobs(ctx context.Context) (int64, error) {
+	var count int64
+
+	err := r.attempt(ctx, func(ctx context.Context, db *sql.DB) error {
+		return db.QueryRowContext(ctx, `SELECT count(*) FROM accrual_jobs WHERE next_attempt_at <= now()`).Scan(&count)
+	})
+	if err != nil {
+		r.observeDBError(err, r.classifier.Classify(err))
+	}
+
+	return count, err
+}
+
+func (r *Repository) RescheduleAccrualJob(ctx context.Context, orderNumber string, nextAttemptAt time.Time, lastErr error) error {
+	attemptInc := 0
+	lastErrText := sql.NullString{}
+	if lastErr != nil {
+		attemptInc = 1
+		lastErrText = sql.NullString{String: lastErr.Error(), Valid: true}
+	}
+
+	return r.executeWithRetryConnection(ctx, func(ctx context.Context, db *sql.DB) error {
+		query := `UPDATE accrual_jobs
+			SET next_attempt_at = $1, attempts = attempts + $2, last_error = COALESCE($3, last_error), updated_at = now()
+			WHERE order_number = $4`
+
+		_, err := db.ExecContext(ctx, query, nextAttemptAt, attemptInc, lastErrText, orderNumber)
+
+		return err
+	})
+}
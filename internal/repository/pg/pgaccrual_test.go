@@ -0,0 +1,276 @@
+package pg
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ibeloyar/gophermart/internal/model"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepository_ClaimAccrualJobs(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := &Repository{db: db, classifier: NewPostgresErrorClassifier()}
+
+	mock.ExpectQuery(`FOR UPDATE SKIP LOCKED`).
+		WithArgs(10, float64(60)).
+		WillReturnRows(sqlmock.NewRows([]string{"order_number", "user_id", "attempts"}).
+			AddRow("order123", int64(1), 2))
+
+	jobs, err := repo.ClaimAccrualJobs(context.Background(), 10, time.Minute)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []model.AccrualJob{{OrderNumber: "order123", UserID: 1, Attempts: 2}}, jobs)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_UpdateOrderAccrual_Processed(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := &Repository{db: db, classifier: NewPostgresErrorClassifier()}
+	job := model.AccrualJob{OrderNumber: "order123", UserID: 1}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT status FROM orders WHERE number = \$1 FOR UPDATE`).
+		WithArgs("order123").
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(model.OrderStatusProcessing))
+	mock.ExpectExec(`UPDATE orders SET status = \$1, accrual = \$2 WHERE number = \$3`).
+		WithArgs(model.OrderStatusProcessed, model.Money(10050), "order123").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO order_status_history \(order_number, status, accrual\) VALUES \(\$1, \$2, \$3\)\s+RETURNING id, changed_at`).
+		WithArgs("order123", model.OrderStatusProcessed, model.Money(10050)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "changed_at"}).AddRow(int64(5), time.Now()))
+	mock.ExpectQuery(`INSERT INTO balance \(user_id, order_number, amount, kind\) VALUES \(\$1, \$2, \$3, \$4\)\s+ON CONFLICT \(order_number\) WHERE kind = 'ACCRUAL' DO NOTHING\s+RETURNING id`).
+		WithArgs(int64(1), "order123", model.Money(10050), model.LedgerKindAccrual).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(int64(9)))
+	mock.ExpectExec(`INSERT INTO user_balances \(user_id, current, withdrawn\) VALUES \(\$1, \$2, \$3\)`).
+		WithArgs(int64(1), model.Money(10050), model.Money(0)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO point_lots \(user_id, balance_id, amount, remaining, expires_at\) VALUES \(\$1, \$2, \$3, \$3, \$4\)`).
+		WithArgs(int64(1), int64(9), model.Money(10050), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`INSERT INTO events_outbox \(event_type, user_id, payload\) VALUES \(\$1, \$2, \$3\)`).
+		WithArgs(model.DomainEventPointsCredited, int64(1), `{"user_id":1,"order":"order123","accrual":100.5}`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`INSERT INTO webhook_outbox \(endpoint_id, event_id, event_type, payload\)`).
+		WithArgs(sqlmock.AnyArg(), model.WebhookEventOrderAccrued, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(`DELETE FROM accrual_jobs WHERE order_number = \$1`).
+		WithArgs("order123").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	event, err := repo.UpdateOrderAccrual(context.Background(), job, model.Accrual{
+		Order:   "order123",
+		Status:  model.OrderStatusProcessed,
+		Accrual: 10050,
+	}, time.Now())
+
+	assert.NoError(t, err)
+	require.NotNil(t, event)
+	assert.Equal(t, int64(5), event.ID)
+	assert.Equal(t, model.Money(10050), event.Accrual)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_UpdateOrderAccrual_ProcessingReschedules(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := &Repository{db: db, classifier: NewPostgresErrorClassifier()}
+	job := model.AccrualJob{OrderNumber: "order123", UserID: 1}
+	nextAttemptAt := time.Now().Add(time.Minute)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT status FROM orders WHERE number = \$1 FOR UPDATE`).
+		WithArgs("order123").
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(model.OrderStatusNew))
+	mock.ExpectExec(`UPDATE orders SET status = \$1, accrual = \$2 WHERE number = \$3`).
+		WithArgs(model.OrderStatusProcessing, model.Money(0), "order123").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO order_status_history \(order_number, status, accrual\) VALUES \(\$1, \$2, \$3\)\s+RETURNING id, changed_at`).
+		WithArgs("order123", model.OrderStatusProcessing, model.Money(0)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "changed_at"}).AddRow(int64(5), time.Now()))
+	mock.ExpectExec(`UPDATE accrual_jobs SET next_attempt_at = \$1, attempts = 0`).
+		WithArgs(nextAttemptAt, "order123").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	event, err := repo.UpdateOrderAccrual(context.Background(), job, model.Accrual{
+		Order:  "order123",
+		Status: model.OrderStatusProcessing,
+	}, nextAttemptAt)
+
+	assert.NoError(t, err)
+	require.NotNil(t, event)
+	assert.Equal(t, model.OrderStatusProcessing, event.Status)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_UpdateOrderAccrual_RetriesTransaction(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := &Repository{db: db, classifier: NewPostgresErrorClassifier()}
+	job := model.AccrualJob{OrderNumber: "order123", UserID: 1}
+	nextAttemptAt := time.Now().Add(time.Minute)
+
+	// транзакция целиком повторяется после временной ошибки
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT status FROM orders WHERE number = \$1 FOR UPDATE`).
+		WithArgs("order123").
+		WillReturnError(&pq.Error{Code: "40001"})
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT status FROM orders WHERE number = \$1 FOR UPDATE`).
+		WithArgs("order123").
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(model.OrderStatusProcessing))
+	mock.ExpectExec(`UPDATE orders SET status = \$1, accrual = \$2 WHERE number = \$3`).
+		WithArgs(model.OrderStatusProcessing, model.Money(0), "order123").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE accrual_jobs SET next_attempt_at = \$1, attempts = 0`).
+		WithArgs(nextAttemptAt, "order123").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	event, err := repo.UpdateOrderAccrual(context.Background(), job, model.Accrual{
+		Order:  "order123",
+		Status: model.OrderStatusProcessing,
+	}, nextAttemptAt)
+
+	assert.NoError(t, err)
+	assert.Nil(t, event)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_UpdateOrderAccrual_AlreadyProcessedIsNoop(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := &Repository{db: db, classifier: NewPostgresErrorClassifier()}
+	job := model.AccrualJob{OrderNumber: "order123", UserID: 1}
+
+	// заказ уже обработан другой репликой - ни статус, ни баланс не трогаем
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT status FROM orders WHERE number = \$1 FOR UPDATE`).
+		WithArgs("order123").
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(model.OrderStatusProcessed))
+	mock.ExpectExec(`DELETE FROM accrual_jobs WHERE order_number = \$1`).
+		WithArgs("order123").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	event, err := repo.UpdateOrderAccrual(context.Background(), job, model.Accrual{
+		Order:   "order123",
+		Status:  model.OrderStatusProcessed,
+		Accrual: 10050,
+	}, time.Now())
+
+	assert.NoError(t, err)
+	assert.Nil(t, event)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_RescheduleAccrualJob_WithError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := &Repository{db: db, classifier: NewPostgresErrorClassifier()}
+	nextAttemptAt := time.Now().Add(time.Minute)
+
+	mock.ExpectExec(`UPDATE accrual_jobs SET next_attempt_at = \$1, attempts = attempts \+ \$2`).
+		WithArgs(nextAttemptAt, 1, "timeout", "order123").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err = repo.RescheduleAccrualJob(context.Background(), "order123", nextAttemptAt, errors.New("timeout"))
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_UpdateOrderAccrual_DuplicateAccrualKeepsSnapshot(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := &Repository{db: db, classifier: NewPostgresErrorClassifier()}
+	job := model.AccrualJob{OrderNumber: "order123", UserID: 1}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT status FROM orders WHERE number = \$1 FOR UPDATE`).
+		WithArgs("order123").
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(model.OrderStatusProcessing))
+	mock.ExpectExec(`UPDATE orders SET status = \$1, accrual = \$2 WHERE number = \$3`).
+		WithArgs(model.OrderStatusProcessed, model.Money(10050), "order123").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO order_status_history \(order_number, status, accrual\) VALUES \(\$1, \$2, \$3\)\s+RETURNING id, changed_at`).
+		WithArgs("order123", model.OrderStatusProcessed, model.Money(10050)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "changed_at"}).AddRow(int64(5), time.Now()))
+	mock.ExpectQuery(`INSERT INTO balance`).
+		WithArgs(int64(1), "order123", model.Money(10050), model.LedgerKindAccrual).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectExec(`DELETE FROM accrual_jobs WHERE order_number = \$1`).
+		WithArgs("order123").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	event, err := repo.UpdateOrderAccrual(context.Background(), job, model.Accrual{
+		Order:   "order123",
+		Status:  model.OrderStatusProcessed,
+		Accrual: 10050,
+	}, time.Now())
+
+	assert.NoError(t, err)
+	assert.NotNil(t, event)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_CountDueAccrualJobs(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := &Repository{db: db, classifier: NewPostgresErrorClassifier()}
+
+	mock.ExpectQuery(`SELECT count\(\*\) FROM accrual_jobs WHERE next_attempt_at <= now\(\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(int64(42)))
+
+	count, err := repo.CountDueAccrualJobs(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(42), count)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_CountDueAccrualJobs_NoRetry(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := &Repository{db: db, classifier: NewPostgresErrorClassifier()}
+
+	// временная ошибка не повторяется: скрейп метрик не должен ждать пауз между попытками
+	mock.ExpectQuery(`SELECT count\(\*\) FROM accrual_jobs`).
+		WillReturnError(&pq.Error{Code: "40001"})
+
+	start := time.Now()
+	_, err = repo.CountDueAccrualJobs(context.Background())
+
+	assert.Error(t, err)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
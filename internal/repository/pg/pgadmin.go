@@ -0,0 +1,359 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/ibeloyar/gophermart/internal/model"
+	"github.com/lib/pq"
+)
+
+const maxUserSearchResults = 50
+
+// execer - *sql.DB или *sql.Tx: аудит пишется и отдельно, и внутри транзакции действия
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+func insertAdminAudit(ctx context.Context, db execer, entry model.AdminAuditEntry) error {
+	query := `INSERT INTO admin_audit_log (admin_id, action, user_id, order_number, details) VALUES ($1, $2, $3, $4, $5)`
+
+	_, err := db.ExecContext(ctx, query,
+		entry.AdminID,
+		entry.Action,
+		sql.NullInt64{Int64: entry.UserID, Valid: entry.UserID != 0},
+		sql.NullString{String: entry.OrderNumber, Valid: entry.OrderNumber != ""},
+		sql.NullString{String: entry.Details, Valid: entry.Details != ""},
+	)
+
+	return err
+}
+
+// WriteAdminAudit - запись аудита для действий без изменения данных (поиск, просмотр)
+func (r *Repository) WriteAdminAudit(ctx context.Context, entry model.AdminAuditEntry) error {
+	return r.executeWithRetryConnection(ctx, func(ctx context.Context, db *sql.DB) error {
+		return insertAdminAudit(ctx, db, entry)
+	})
+}
+
+// SearchUsersByLogin - пользователи, в логине которых есть подстрока login (без учёта регистра)
+func (r *Repository) SearchUsersByLogin(ctx context.Context, login string) ([]model.UserSummary, error) {
+	var result []model.UserSummary
+
+	err := r.executeWithRetryConnection(ctx, func(ctx context.Context, db *sql.DB) error {
+		result = make([]model.UserSummary, 0)
+
+		query := `SELECT u.id, u.login, ` + userRolesColumn + `, u.created_at FROM users u
+			WHERE strpos(lower(u.login), lower($1)) > 0
+			ORDER BY u.login
+			LIMIT $2`
+
+		rows, err := db.QueryContext(ctx, query, login, maxUserSearchResults)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var (
+				user  model.UserSummary
+				roles pq.StringArray
+			)
+			if err := rows.Scan(&user.ID, &user.Login, &roles, &user.CreatedAt); err != nil {
+				return err
+			}
+			user.Roles = toRoles(roles)
+
+			result = append(result, user)
+		}
+
+		return rows.Err()
+	})
+
+	return result, err
+}
+
+// GetLedgerByUserID - все записи журнала баланса пользователя
+func (r *Repository) GetLedgerByUserID(ctx context.Context, userID int64, filter model.ListFilter) (*model.LedgerPage, error) {
+	page := &model.LedgerPage{}
+
+	err := r.executeWithRetryConnection(ctx, func(ctx context.Context, db *sql.DB) error {
+		page.Entries = make([]model.LedgerEntry, 0, filter.Limit)
+		page.Next = nil
+
+		q := &listQuery{}
+		q.where("user_id = $%d", userID)
+
+		query := `SELECT id, order_number, amount, kind, COALESCE(reason, ''), uploaded_at FROM balance` +
+			q.applyFilter("uploaded_at", filter)
+
+		rows, err := db.QueryContext(ctx, query, q.args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		var lastCreatedAt time.Time
+		for rows.Next() {
+			if len(page.Entries) == filter.Limit {
+				last := page.Entries[len(page.Entries)-1]
+				page.Next = &model.Cursor{At: lastCreatedAt, ID: last.ID}
+				break
+			}
+
+			var entry model.LedgerEntry
+			if err := rows.Scan(&entry.ID, &entry.Order, &entry.Amount, &entry.Kind, &entry.Reason, &lastCreatedAt); err != nil {
+				return err
+			}
+			entry.CreatedAt = lastCreatedAt.Format(time.RFC3339Nano)
+
+			page.Entries = append(page.Entries, entry)
+		}
+
+		return rows.Err()
+	})
+
+	return page, err
+}
+
+// AdjustBalance - ручное начисление или списание с записью ADJUSTMENT в журнал.
+// Снимок баланса, доменное событие и аудит пишутся в той же транзакции.
+func (r *Repository) AdjustBalance(ctx context.Context, adminID, userID int64, input model.BalanceAdjustmentDTO) (*model.LedgerEntry, error) {
+	var entry model.LedgerEntry
+
+	err := r.executeWithRetryConnection(ctx, func(ctx context.Context, db *sql.DB) error {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		var exists bool
+		err = tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM users WHERE id = $1)`, userID).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return model.ErrUserNotFound
+		}
+
+		// начисление может создать снимок, списание меняет только существующий
+		if input.Amount > 0 {
+			err = applyBalanceDelta(ctx, tx, userID, input.Amount, 0)
+		} else {
+			err = updateBalance(ctx, tx, userID, input.Amount, 0)
+		}
+		if err != nil {
+			return err
+		}
+
+		var createdAt time.Time
+		query := `INSERT INTO balance (user_id, order_number, amount, kind, reason) VALUES ($1, $2, $3, $4, $5)
+			RETURNING id, uploaded_at`
+		err = tx.QueryRowContext(ctx, query, userID, input.Order, input.Amount, model.LedgerKindAdjustment, input.Reason).
+			Scan(&entry.ID, &createdAt)
+		if err != nil {
+			return err
+		}
+
+		// начисление становится новой партией, списание расходует старые
+		if input.Amount > 0 {
+			err = insertPointLot(ctx, tx, userID, entry.ID, input.Amount, r.lotExpiresAt())
+		} else {
+			err = consumePointLots(ctx, tx, userID, -input.Amount)
+		}
+		if err != nil {
+			return err
+		}
+
+		entry.Order = input.Order
+		entry.Amount = input.Amount
+		entry.Kind = model.LedgerKindAdjustment
+		entry.Reason = input.Reason
+		entry.CreatedAt = createdAt.Format(time.RFC3339Nano)
+
+		err = insertOutboxEvent(ctx, tx, model.DomainEventPointsAdjusted, userID, model.PointsAdjustedData{
+			AdminID: adminID,
+			Order:   input.Order,
+			Amount:  input.Amount,
+			Reason:  input.Reason,
+		})
+		if err != nil {
+			return err
+		}
+
+		err = insertAdminAudit(ctx, tx, model.AdminAuditEntry{
+			AdminID:     adminID,
+			Action:      model.AdminActionAdjustBalance,
+			UserID:      userID,
+			OrderNumber: input.Order,
+			Details:     input.Amount.String() + ": " + input.Reason,
+		})
+		if err != nil {
+			return err
+		}
+
+		return tx.Commit()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &entry, nil
+}
+
+// ResetOrderToNew - возвращает заказ в NEW и ставит задание опроса системы расчёта на ближайший цикл.
+// Обработанный заказ не трогаем: начисление по нему уже в журнале и партиях баллов,
+// повторный опрос разошёлся бы с балансом - для исправления нужна корректировка.
+// Возвращает событие для подписчиков SSE.
+func (r *Repository) ResetOrderToNew(ctx context.Context, adminID int64, number, reason string) (*model.OrderEvent, error) {
+	var event *model.OrderEvent
+
+	err := r.executeWithRetryConnection(ctx, func(ctx context.Context, db *sql.DB) error {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		userID, status, err := lockOrder(ctx, tx, number)
+		if err != nil {
+			return err
+		}
+
+		if status == model.OrderStatusProcessed {
+			return model.ErrOrderAlreadyProcessed
+		}
+
+		if _, err = tx.ExecContext(ctx, `UPDATE orders SET status = $1, accrual = 0 WHERE number = $2`, model.OrderStatusNew, number); err != nil {
+			return err
+		}
+
+		changed, err := insertOrderStatusChange(ctx, tx, userID, number, model.OrderStatusNew)
+		if err != nil {
+			return err
+		}
+
+		queryUpsertJob := `INSERT INTO accrual_jobs (order_number) VALUES ($1)
+			ON CONFLICT (order_number) DO UPDATE SET next_attempt_at = now(), attempts = 0, last_error = NULL, updated_at = now()`
+		if _, err = tx.ExecContext(ctx, queryUpsertJob, number); err != nil {
+			return err
+		}
+
+		err = insertAdminAudit(ctx, tx, model.AdminAuditEntry{
+			AdminID:     adminID,
+			Action:      model.AdminActionReprocessOrder,
+			UserID:      userID,
+			OrderNumber: number,
+			Details:     reason,
+		})
+		if err != nil {
+			return err
+		}
+
+		if err = tx.Commit(); err != nil {
+			return err
+		}
+
+		event = changed
+		return nil
+	})
+
+	return event, err
+}
+
+// InvalidateOrder - переводит заказ в INVALID и снимает задание опроса.
+// Обработанный заказ не трогаем: начисление по нему уже в журнале, для отмены нужна корректировка.
+// Возвращает событие для подписчиков SSE (nil, если заказ уже был INVALID).
+func (r *Repository) InvalidateOrder(ctx context.Context, adminID int64, number, reason string) (*model.OrderEvent, error) {
+	var event *model.OrderEvent
+
+	err := r.executeWithRetryConnection(ctx, func(ctx context.Context, db *sql.DB) error {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		userID, status, err := lockOrder(ctx, tx, number)
+		if err != nil {
+			return err
+		}
+
+		if status == model.OrderStatusProcessed {
+			return model.ErrOrderAlreadyProcessed
+		}
+
+		var changed *model.OrderEvent
+		if status != model.OrderStatusInvalid {
+			if _, err = tx.ExecContext(ctx, `UPDATE orders SET status = $1 WHERE number = $2`, model.OrderStatusInvalid, number); err != nil {
+				return err
+			}
+
+			changed, err = insertOrderStatusChange(ctx, tx, userID, number, model.OrderStatusInvalid)
+			if err != nil {
+				return err
+			}
+		}
+
+		if _, err = tx.ExecContext(ctx, `DELETE FROM accrual_jobs WHERE order_number = $1`, number); err != nil {
+			return err
+		}
+
+		err = insertAdminAudit(ctx, tx, model.AdminAuditEntry{
+			AdminID:     adminID,
+			Action:      model.AdminActionInvalidateOrder,
+			UserID:      userID,
+			OrderNumber: number,
+			Details:     reason,
+		})
+		if err != nil {
+			return err
+		}
+
+		if err = tx.Commit(); err != nil {
+			return err
+		}
+
+		event = changed
+		return nil
+	})
+
+	return event, err
+}
+
+// insertOrderStatusChange - записывает смену статуса в историю и возвращает событие для подписчиков
+func insertOrderStatusChange(ctx context.Context, tx *sql.Tx, userID int64, number string, status model.OrderStatus) (*model.OrderEvent, error) {
+	event := &model.OrderEvent{
+		UserID: userID,
+		Number: number,
+		Status: status,
+	}
+
+	var changedAt time.Time
+	query := `INSERT INTO order_status_history (order_number, status) VALUES ($1, $2) RETURNING id, changed_at`
+	if err := tx.QueryRowContext(ctx, query, number, status).Scan(&event.ID, &changedAt); err != nil {
+		return nil, err
+	}
+	event.ChangedAt = changedAt.Format(time.RFC3339Nano)
+
+	return event, nil
+}
+
+// lockOrder - блокирует заказ до конца транзакции, чтобы не пересечься с опросом системы расчёта
+func lockOrder(ctx context.Context, tx *sql.Tx, number string) (int64, model.OrderStatus, error) {
+	var (
+		userID int64
+		status model.OrderStatus
+	)
+
+	err := tx.QueryRowContext(ctx, `SELECT user_id, status FROM orders WHERE number = $1 FOR UPDATE`, number).
+		Scan(&userID, &status)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, "", model.ErrOrderNotFound
+	}
+
+	return userID, status, err
+}
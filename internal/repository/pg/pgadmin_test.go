@@ -0,0 +1,188 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ibeloyar/gophermart/internal/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRepository_AdjustBalance_Debit(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := &Repository{db: db, classifier: NewPostgresErrorClassifier()}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM users WHERE id = \$1\)`).
+		WithArgs(int64(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectExec(`UPDATE user_balances SET`).
+		WithArgs(int64(7), model.Money(-1050), model.Money(0)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO balance \(user_id, order_number, amount, kind, reason\) VALUES \(\$1, \$2, \$3, \$4, \$5\)\s+RETURNING id, uploaded_at`).
+		WithArgs(int64(7), "", model.Money(-1050), model.LedgerKindAdjustment, "chargeback").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "uploaded_at"}).AddRow(int64(3), time.Now()))
+	mock.ExpectQuery(`SELECT id, remaining FROM point_lots`).
+		WithArgs(int64(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "remaining"}).AddRow(int64(4), "50.00"))
+	mock.ExpectExec(`UPDATE point_lots SET remaining = remaining - \$1 WHERE id = \$2`).
+		WithArgs(model.Money(1050), int64(4)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO events_outbox`).
+		WithArgs(model.DomainEventPointsAdjusted, int64(7), `{"admin_id":1,"amount":-10.5,"reason":"chargeback"}`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`INSERT INTO admin_audit_log \(admin_id, action, user_id, order_number, details\)`).
+		WithArgs(int64(1), model.AdminActionAdjustBalance, sql.NullInt64{Int64: 7, Valid: true}, sql.NullString{}, sql.NullString{String: "-10.5: chargeback", Valid: true}).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	entry, err := repo.AdjustBalance(context.Background(), 1, 7, model.BalanceAdjustmentDTO{Amount: -1050, Reason: "chargeback"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), entry.ID)
+	assert.Equal(t, model.LedgerKindAdjustment, entry.Kind)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_AdjustBalance_UserNotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := &Repository{db: db, classifier: NewPostgresErrorClassifier()}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM users WHERE id = \$1\)`).
+		WithArgs(int64(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectRollback()
+
+	_, err = repo.AdjustBalance(context.Background(), 1, 7, model.BalanceAdjustmentDTO{Amount: 500, Reason: "goodwill"})
+
+	assert.ErrorIs(t, err, model.ErrUserNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_ResetOrderToNew(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := &Repository{db: db, classifier: NewPostgresErrorClassifier()}
+	changedAt := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT user_id, status FROM orders WHERE number = \$1 FOR UPDATE`).
+		WithArgs("12345678903").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "status"}).AddRow(int64(7), model.OrderStatusInvalid))
+	mock.ExpectExec(`UPDATE orders SET status = \$1, accrual = 0 WHERE number = \$2`).
+		WithArgs(model.OrderStatusNew, "12345678903").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO order_status_history \(order_number, status\) VALUES \(\$1, \$2\) RETURNING id, changed_at`).
+		WithArgs("12345678903", model.OrderStatusNew).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "changed_at"}).AddRow(int64(42), changedAt))
+	mock.ExpectExec(`INSERT INTO accrual_jobs \(order_number\) VALUES \(\$1\)\s+ON CONFLICT \(order_number\) DO UPDATE`).
+		WithArgs("12345678903").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO admin_audit_log`).
+		WithArgs(int64(1), model.AdminActionReprocessOrder, sql.NullInt64{Int64: 7, Valid: true},
+			sql.NullString{String: "12345678903", Valid: true}, sql.NullString{String: "accrual was wrong", Valid: true}).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	event, err := repo.ResetOrderToNew(context.Background(), 1, "12345678903", "accrual was wrong")
+
+	assert.NoError(t, err)
+	assert.Equal(t, &model.OrderEvent{
+		ID:        42,
+		UserID:    7,
+		Number:    "12345678903",
+		Status:    model.OrderStatusNew,
+		ChangedAt: changedAt.Format(time.RFC3339Nano),
+	}, event)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_ResetOrderToNew_Processed(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := &Repository{db: db, classifier: NewPostgresErrorClassifier()}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT user_id, status FROM orders WHERE number = \$1 FOR UPDATE`).
+		WithArgs("12345678903").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "status"}).AddRow(int64(7), model.OrderStatusProcessed))
+	mock.ExpectRollback()
+
+	_, err = repo.ResetOrderToNew(context.Background(), 1, "12345678903", "accrual was wrong")
+
+	assert.ErrorIs(t, err, model.ErrOrderAlreadyProcessed)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_InvalidateOrder_Processed(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := &Repository{db: db, classifier: NewPostgresErrorClassifier()}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT user_id, status FROM orders WHERE number = \$1 FOR UPDATE`).
+		WithArgs("12345678903").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "status"}).AddRow(int64(7), model.OrderStatusProcessed))
+	mock.ExpectRollback()
+
+	_, err = repo.InvalidateOrder(context.Background(), 1, "12345678903", "fraud")
+
+	assert.ErrorIs(t, err, model.ErrOrderAlreadyProcessed)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_InvalidateOrder_NotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := &Repository{db: db, classifier: NewPostgresErrorClassifier()}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT user_id, status FROM orders WHERE number = \$1 FOR UPDATE`).
+		WithArgs("unknown").
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	_, err = repo.InvalidateOrder(context.Background(), 1, "unknown", "fraud")
+
+	assert.ErrorIs(t, err, model.ErrOrderNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_SearchUsersByLogin(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := &Repository{db: db, classifier: NewPostgresErrorClassifier()}
+
+	mock.ExpectQuery(`SELECT u.id, u.login, ARRAY\(SELECT role FROM user_roles WHERE user_id = u.id ORDER BY role\), u.created_at FROM users u\s+WHERE strpos\(lower\(u.login\), lower\(\$1\)\) > 0`).
+		WithArgs("Bob", maxUserSearchResults).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "login", "roles", "created_at"}).
+			AddRow(int64(7), "bobby", "{support,user}", time.Now()))
+
+	users, err := repo.SearchUsersByLogin(context.Background(), "Bob")
+
+	assert.NoError(t, err)
+	assert.Len(t, users, 1)
+	assert.Equal(t, "bobby", users[0].Login)
+	assert.Equal(t, []model.Role{model.RoleSupport, model.RoleUser}, users[0].Roles)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,188 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/ibeloyar/gophermart/internal/model"
+)
+
+// applyBalanceDelta - зачисляет баллы на снимок баланса в той же транзакции, что и запись в журнал.
+// Только для начислений: CHECK проверяется на предлагаемой строке вставки до ON CONFLICT,
+// поэтому отрицательная дельта падала бы даже при достаточном балансе. Списания идут через updateBalance.
+func applyBalanceDelta(ctx context.Context, tx *sql.Tx, userID int64, current, withdrawn model.Money) error {
+	query := `INSERT INTO user_balances (user_id, current, withdrawn) VALUES ($1, $2, $3)
+		ON CONFLICT (user_id) DO UPDATE SET
+			current = user_balances.current + EXCLUDED.current,
+			withdrawn = user_balances.withdrawn + EXCLUDED.withdrawn,
+			updated_at = now()`
+
+	_, err := tx.ExecContext(ctx, query, userID, current, withdrawn)
+
+	return err
+}
+
+// updateBalance - изменяет существующий снимок баланса (списания и возвраты).
+// Блокируется одна строка user_balances; отсутствие снимка или уход в минус (CHECK)
+// возвращается как model.ErrInsufficientFunds.
+func updateBalance(ctx context.Context, tx *sql.Tx, userID int64, current, withdrawn model.Money) error {
+	query := `UPDATE user_balances SET
+			current = current + $2,
+			withdrawn = withdrawn + $3,
+			updated_at = now()
+		WHERE user_id = $1`
+
+	result, err := tx.ExecContext(ctx, query, userID, current, withdrawn)
+	if hasErrorCode(err, ErrCheckViolationCode) {
+		return model.ErrInsufficientFunds
+	}
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return model.ErrInsufficientFunds
+	}
+
+	return nil
+}
+
+// ReconcileBalances - сверяет снимки балансов с журналом и возвращает расхождения.
+// При fix = true снимки пересчитываются по журналу под блокировкой строки пользователя;
+// отрицательный журнал снимок не примет (CHECK), его исправляют ручной корректировкой.
+func (r *Repository) ReconcileBalances(ctx context.Context, fix bool) ([]model.BalanceDrift, error) {
+	var drifts []model.BalanceDrift
+
+	err := r.executeWithRetryConnection(ctx, func(ctx context.Context, db *sql.DB) error {
+		drifts = make([]model.BalanceDrift, 0)
+
+		query := `WITH ledger AS (
+				SELECT user_id,
+					SUM(amount) AS current,
+					SUM(CASE WHEN kind IN ('WITHDRAWAL', 'REFUND') THEN -amount ELSE 0 END) AS withdrawn
+				FROM balance GROUP BY user_id
+			)
+			SELECT u.id,
+				COALESCE(b.current, 0), COALESCE(b.withdrawn, 0),
+				COALESCE(l.current, 0), COALESCE(l.withdrawn, 0)
+			FROM users u
+			LEFT JOIN user_balances b ON b.user_id = u.id
+			LEFT JOIN ledger l ON l.user_id = u.id
+			WHERE COALESCE(b.current, 0) <> COALESCE(l.current, 0)
+				OR COALESCE(b.withdrawn, 0) <> COALESCE(l.withdrawn, 0)
+			ORDER BY u.id`
+
+		rows, err := db.QueryContext(ctx, query)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var drift model.BalanceDrift
+			err := rows.Scan(&drift.UserID,
+				&drift.SnapshotCurrent, &drift.SnapshotWithdrawn,
+				&drift.LedgerCurrent, &drift.LedgerWithdrawn,
+			)
+			if err != nil {
+				return err
+			}
+
+			drifts = append(drifts, drift)
+		}
+
+		return rows.Err()
+	})
+	if err != nil || !fix {
+		return drifts, err
+	}
+
+	for _, drift := range drifts {
+		if drift.NeedsAdjustment() {
+			continue
+		}
+
+		err := r.executeWithRetryConnection(ctx, func(ctx context.Context, db *sql.DB) error {
+			return rebuildBalanceSnapshot(ctx, db, drift.UserID)
+		})
+		if err != nil {
+			return drifts, err
+		}
+	}
+
+	return drifts, nil
+}
+
+// rebuildBalanceSnapshot - пересчитывает снимок пользователя по журналу.
+// Строка снимка блокируется до подсчёта, поэтому параллельные списания и начисления
+// не попадают между подсчётом и записью.
+func rebuildBalanceSnapshot(ctx context.Context, db *sql.DB, userID int64) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO user_balances (user_id) VALUES ($1) ON CONFLICT (user_id) DO NOTHING`, userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `SELECT user_id FROM user_balances WHERE user_id = $1 FOR UPDATE`, userID)
+	if err != nil {
+		return err
+	}
+
+	query := `UPDATE user_balances SET
+			current = l.current,
+			withdrawn = l.withdrawn,
+			updated_at = now()
+		FROM (
+			SELECT COALESCE(SUM(amount), 0) AS current,
+				COALESCE(SUM(CASE WHEN kind IN ('WITHDRAWAL', 'REFUND') THEN -amount ELSE 0 END), 0) AS withdrawn
+			FROM balance WHERE user_id = $1
+		) l
+		WHERE user_balances.user_id = $1`
+
+	if _, err = tx.ExecContext(ctx, query, userID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetLedgerAnomalies - возвращает расхождения журнала, записанные миграциями
+func (r *Repository) GetLedgerAnomalies(ctx context.Context) ([]model.LedgerAnomaly, error) {
+	var anomalies []model.LedgerAnomaly
+
+	err := r.executeWithRetryConnection(ctx, func(ctx context.Context, db *sql.DB) error {
+		anomalies = make([]model.LedgerAnomaly, 0)
+
+		query := `SELECT id, user_id, kind, COALESCE(order_number, ''), amount, detected_at
+			FROM ledger_anomalies ORDER BY id`
+
+		rows, err := db.QueryContext(ctx, query)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var anomaly model.LedgerAnomaly
+			err := rows.Scan(&anomaly.ID, &anomaly.UserID, &anomaly.Kind, &anomaly.Order, &anomaly.Amount, &anomaly.DetectedAt)
+			if err != nil {
+				return err
+			}
+
+			anomalies = append(anomalies, anomaly)
+		}
+
+		return rows.Err()
+	})
+
+	return anomalies, err
+}
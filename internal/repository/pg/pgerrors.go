@@ -1,8 +1,13 @@
 package pg
 
 import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
 	"errors"
+	"net"
 
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/lib/pq"
 )
 
@@ -12,9 +17,19 @@ const (
 	NonRetriable ErrorClassification = iota
 	Retriable
 
-	ErrIsExistCode = "23505"
+	ErrIsExistCode        = "23505"
+	ErrCheckViolationCode = "23514"
 )
 
+// String - значение метки classification в метриках
+func (c ErrorClassification) String() string {
+	if c == Retriable {
+		return "retriable"
+	}
+
+	return "non_retriable"
+}
+
 type PostgresErrorClassifier struct{}
 
 func NewPostgresErrorClassifier() *PostgresErrorClassifier {
@@ -60,7 +75,7 @@ func classifyPgError(pqErr *pq.Error) ErrorClassification {
 
 	// Класс 23 - Нарушение ограничений целостности
 	switch pqErr.Code {
-	case "23000", "23001", "23502", "23503", ErrIsExistCode, "23514":
+	case "23000", "23001", "23502", "23503", ErrIsExistCode, ErrCheckViolationCode:
 		return NonRetriable
 	}
 
@@ -73,3 +88,37 @@ func classifyPgError(pqErr *pq.Error) ErrorClassification {
 	// По умолчанию считаем ошибку неповторяемой
 	return NonRetriable
 }
+
+// hasErrorCode - ошибка PostgreSQL с указанным кодом (от pgx или lib/pq)
+func hasErrorCode(err error, code string) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == code
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return string(pqErr.Code) == code
+	}
+
+	return false
+}
+
+// isDatabaseError - ошибка сервера PostgreSQL, соединения или таймаут попытки.
+// Доменные результаты операций (sql.ErrNoRows, model.ErrInsufficientFunds и т.п.) сюда не относятся.
+func isDatabaseError(err error) bool {
+	var (
+		pgErr      *pgconn.PgError
+		pqErr      *pq.Error
+		connectErr *pgconn.ConnectError
+		netErr     net.Error
+	)
+
+	return errors.As(err, &pgErr) ||
+		errors.As(err, &pqErr) ||
+		errors.As(err, &connectErr) ||
+		errors.As(err, &netErr) ||
+		errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, sql.ErrConnDone) ||
+		errors.Is(err, context.DeadlineExceeded)
+}
@@ -1,9 +1,15 @@
 package pg
 
 import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
 	"errors"
+	"fmt"
 	"testing"
 
+	"github.com/ibeloyar/gophermart/internal/model"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
 )
@@ -122,3 +128,30 @@ func TestPostgresErrorClassifier_Classify_UnknownError_NonRetriable(t *testing.T
 		})
 	}
 }
+
+func TestErrorClassification_String(t *testing.T) {
+	assert.Equal(t, "retriable", Retriable.String())
+	assert.Equal(t, "non_retriable", NonRetriable.String())
+}
+
+func TestIsDatabaseError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "pgx error", err: fmt.Errorf("insert: %w", &pgconn.PgError{Code: "40001"}), want: true},
+		{name: "lib/pq error", err: &pq.Error{Code: "08006"}, want: true},
+		{name: "bad connection", err: driver.ErrBadConn, want: true},
+		{name: "attempt timeout", err: context.DeadlineExceeded, want: true},
+		{name: "no rows", err: sql.ErrNoRows, want: false},
+		{name: "domain error", err: model.ErrInsufficientFunds, want: false},
+		{name: "client gone", err: context.Canceled, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, isDatabaseError(tt.err))
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/ibeloyar/gophermart/internal/model"
+)
+
+// orderEventsChannel - канал NOTIFY, в который пишет триггер на order_status_history
+const orderEventsChannel = "order_events"
+
+// listenRetryDelay - пауза перед переподключением LISTEN после ошибки
+const listenRetryDelay = 5 * time.Second
+
+// GetOrderEventsAfter - события заказов пользователя с id больше afterID (для Last-Event-ID).
+// Первая запись NEW (загрузка заказа) событием не считается, возврат в NEW администратором - считается.
+func (r *Repository) GetOrderEventsAfter(ctx context.Context, userID, afterID int64, limit int) ([]model.OrderEvent, error) {
+	var result []model.OrderEvent
+
+	err := r.executeWithRetryConnection(ctx, func(ctx context.Context, db *sql.DB) error {
+		result = make([]model.OrderEvent, 0)
+
+		query := `SELECT h.id, h.order_number, h.status, h.accrual, h.changed_at
+			FROM order_status_history h
+			JOIN orders o ON o.number = h.order_number
+			WHERE o.user_id = $1 AND h.id > $2
+				AND (h.status <> 'NEW' OR EXISTS (
+					SELECT 1 FROM order_status_history p WHERE p.order_number = h.order_number AND p.id < h.id
+				))
+			ORDER BY h.id
+			LIMIT $3`
+
+		rows, err := db.QueryContext(ctx, query, userID, afterID, limit)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var changedAt time.Time
+			event := model.OrderEvent{UserID: userID}
+			if err := rows.Scan(&event.ID, &event.Number, &event.Status, &event.Accrual, &changedAt); err != nil {
+				return err
+			}
+			event.ChangedAt = changedAt.Format(time.RFC3339Nano)
+
+			result = append(result, event)
+		}
+
+		return rows.Err()
+	})
+
+	return result, err
+}
+
+// orderEventPayload - тело уведомления из триггера notify_order_event
+type orderEventPayload struct {
+	ID        int64             `json:"id"`
+	UserID    int64             `json:"user_id"`
+	Number    string            `json:"number"`
+	Status    model.OrderStatus `json:"status"`
+	Accrual   model.Money       `json:"accrual"`
+	ChangedAt time.Time         `json:"changed_at"`
+}
+
+// ListenOrderEvents - подписывается на NOTIFY order_events и передаёт события в handler
+// до отмены ctx. Соединение восстанавливается после ошибок; события, пропущенные
+// за время переподключения, клиенты получают по Last-Event-ID.
+func (r *Repository) ListenOrderEvents(ctx context.Context, handler func(model.OrderEvent)) {
+	for {
+		err := r.listenOrderEvents(ctx, handler)
+		if ctx.Err() != nil {
+			return
+		}
+
+		r.lg.Warnf("order events listener error: %v", err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(listenRetryDelay):
+		}
+	}
+}
+
+func (r *Repository) listenOrderEvents(ctx context.Context, handler func(model.OrderEvent)) error {
+	pooled, err := r.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+
+	// соединение в режиме LISTEN не возвращаем в пул
+	conn := pooled.Hijack()
+	defer conn.Close(context.Background())
+
+	if _, err = conn.Exec(ctx, "LISTEN "+orderEventsChannel); err != nil {
+		return err
+	}
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+
+		var payload orderEventPayload
+		if err := json.Unmarshal([]byte(notification.Payload), &payload); err != nil {
+			r.lg.Errorf("decoding order event %q error: %v", notification.Payload, err)
+			continue
+		}
+		if payload.UserID == 0 {
+			r.lg.Errorf("order event %d without owner", payload.ID)
+			continue
+		}
+
+		handler(model.OrderEvent{
+			ID:        payload.ID,
+			UserID:    payload.UserID,
+			Number:    payload.Number,
+			Status:    payload.Status,
+			Accrual:   payload.Accrual,
+			ChangedAt: payload.ChangedAt.Format(time.RFC3339Nano),
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package pg
+
+import (
+	"context"
+	"fmt"
+)
+
+// Ping - проверка соединения с базой для /readyz; без повторов, чтобы не затягивать ответ
+func (r *Repository) Ping(ctx context.Context) error {
+	return r.pool.Ping(ctx)
+}
+
+// CheckMigrations - схема не откатана и не осталась в dirty после сбоя миграции.
+// Версия новее ожидаемой допустима: её накатила реплика следующего релиза, миграции обратно совместимы.
+func (r *Repository) CheckMigrations(ctx context.Context) error {
+	var (
+		version int64
+		dirty   bool
+	)
+
+	err := r.db.QueryRowContext(ctx, `SELECT version, dirty FROM `+migrationsTable).Scan(&version, &dirty)
+	if err != nil {
+		return err
+	}
+
+	if dirty {
+		return fmt.Errorf("migration %d is dirty", version)
+	}
+
+	if version < int64(r.migrationVersion) {
+		return fmt.Errorf("schema version %d, expected %d", version, r.migrationVersion)
+	}
+
+	return nil
+}
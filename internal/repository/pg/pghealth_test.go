@@ -0,0 +1,45 @@
+package pg
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRepository_CheckMigrations(t *testing.T) {
+	tests := []struct {
+		name    string
+		version int64
+		dirty   bool
+		wantErr bool
+	}{
+		{"expected version", 19, false, false},
+		{"newer version", 20, false, false},
+		{"older version", 18, false, true},
+		{"dirty", 19, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer db.Close()
+
+			repo := &Repository{db: db, classifier: NewPostgresErrorClassifier(), migrationVersion: 19}
+
+			mock.ExpectQuery(`SELECT version, dirty FROM schema_migrations`).
+				WillReturnRows(sqlmock.NewRows([]string{"version", "dirty"}).AddRow(tt.version, tt.dirty))
+
+			err = repo.CheckMigrations(context.Background())
+
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/ibeloyar/gophermart/internal/model"
+)
+
+// idempotencyKeyLease - сколько ключ может оставаться "в процессе". Успешное списание
+// завершает ключ в своей транзакции, поэтому незавершённый ключ старше аренды означает,
+// что запрос оборвался до фиксации, и его можно выполнить повторно.
+const idempotencyKeyLease = time.Minute
+
+// ReserveIdempotencyKey - резервирует ключ за запросом. Если ключ уже занят
+// (и не истёк), возвращает существующую запись и reserved = false.
+// Зависший ключ с тем же запросом перезанимается по истечении аренды.
+func (r *Repository) ReserveIdempotencyKey(ctx context.Context, record model.IdempotencyRecord) (*model.IdempotencyRecord, bool, error) {
+	var (
+		existing model.IdempotencyRecord
+		reserved bool
+	)
+
+	err := r.executeWithRetryConnection(ctx, func(ctx context.Context, db *sql.DB) error {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		// истёкшие ключи пользователя больше не действуют - удаляем их
+		queryDeleteExpired := `DELETE FROM idempotency_keys WHERE user_id = $1 AND expires_at <= now()`
+		if _, err = tx.ExecContext(ctx, queryDeleteExpired, record.UserID); err != nil {
+			return err
+		}
+
+		queryInsert := `INSERT INTO idempotency_keys (user_id, key, request_hash, expires_at) VALUES ($1, $2, $3, $4)
+			ON CONFLICT (user_id, key) DO UPDATE SET created_at = now(), expires_at = EXCLUDED.expires_at
+			WHERE idempotency_keys.response_code IS NULL
+				AND idempotency_keys.request_hash = EXCLUDED.request_hash
+				AND idempotency_keys.created_at <= now() - make_interval(secs => $5)`
+		result, err := tx.ExecContext(ctx, queryInsert, record.UserID, record.Key, record.RequestHash, record.ExpiresAt,
+			idempotencyKeyLease.Seconds())
+		if err != nil {
+			return err
+		}
+
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		reserved = affected == 1
+		if reserved {
+			existing = record
+			return tx.Commit()
+		}
+
+		var (
+			responseCode sql.NullInt64
+			responseBody sql.NullString
+		)
+
+		querySelect := `SELECT request_hash, response_code, response_body, expires_at
+			FROM idempotency_keys WHERE user_id = $1 AND key = $2`
+		err = tx.QueryRowContext(ctx, querySelect, record.UserID, record.Key).
+			Scan(&existing.RequestHash, &responseCode, &responseBody, &existing.ExpiresAt)
+		if err != nil {
+			return err
+		}
+
+		existing.UserID = record.UserID
+		existing.Key = record.Key
+		existing.ResponseCode = int(responseCode.Int64)
+		existing.ResponseBody = responseBody.String
+
+		return tx.Commit()
+	})
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	return &existing, reserved, nil
+}
+
+// CompleteIdempotencyKey - сохраняет результат запроса для повторной выдачи
+func (r *Repository) CompleteIdempotencyKey(ctx context.Context, userID int64, key string, responseCode int, responseBody string) error {
+	return r.executeWithRetryConnection(ctx, func(ctx context.Context, db *sql.DB) error {
+		query := `UPDATE idempotency_keys SET response_code = $1, response_body = $2 WHERE user_id = $3 AND key = $4`
+
+		result, err := db.ExecContext(ctx, query, responseCode, responseBody, userID, key)
+		if err != nil {
+			return err
+		}
+
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if affected == 0 {
+			return errors.New("idempotency key not found")
+		}
+
+		return nil
+	})
+}
+
+// completeIdempotencyKeyTx - фиксирует успешный результат в транзакции самого запроса,
+// чтобы сбой между коммитом и CompleteIdempotencyKey не оставил ключ "в процессе"
+func completeIdempotencyKeyTx(ctx context.Context, tx *sql.Tx, userID int64, key string) error {
+	query := `UPDATE idempotency_keys SET response_code = $1, response_body = '' WHERE user_id = $2 AND key = $3`
+
+	_, err := tx.ExecContext(ctx, query, http.StatusOK, userID, key)
+
+	return err
+}
+
+// ReleaseIdempotencyKey - освобождает ключ, чтобы клиент мог повторить запрос
+func (r *Repository) ReleaseIdempotencyKey(ctx context.Context, userID int64, key string) error {
+	return r.executeWithRetryConnection(ctx, func(ctx context.Context, db *sql.DB) error {
+		query := `DELETE FROM idempotency_keys WHERE user_id = $1 AND key = $2`
+
+		_, err := db.ExecContext(ctx, query, userID, key)
+
+		return err
+	})
+}
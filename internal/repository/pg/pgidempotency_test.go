@@ -0,0 +1,86 @@
+package pg
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ibeloyar/gophermart/internal/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRepository_ReserveIdempotencyKey_New(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := &Repository{db: db, classifier: NewPostgresErrorClassifier()}
+	record := model.IdempotencyRecord{UserID: 1, Key: "key-1", RequestHash: "hash", ExpiresAt: time.Now().Add(time.Hour)}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM idempotency_keys WHERE user_id = \$1 AND expires_at <= now\(\)`).
+		WithArgs(int64(1)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO idempotency_keys`).
+		WithArgs(int64(1), "key-1", "hash", record.ExpiresAt, idempotencyKeyLease.Seconds()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	existing, reserved, err := repo.ReserveIdempotencyKey(context.Background(), record)
+
+	assert.NoError(t, err)
+	assert.True(t, reserved)
+	assert.Equal(t, record, *existing)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_ReserveIdempotencyKey_CanceledContext(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := &Repository{db: db, classifier: NewPostgresErrorClassifier()}
+	record := model.IdempotencyRecord{UserID: 1, Key: "key-1", RequestHash: "hash", ExpiresAt: time.Now().Add(time.Hour)}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// транзакция не открывается для отменённого запроса
+	_, _, err = repo.ReserveIdempotencyKey(ctx, record)
+
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_ReserveIdempotencyKey_Existing(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := &Repository{db: db, classifier: NewPostgresErrorClassifier()}
+	record := model.IdempotencyRecord{UserID: 1, Key: "key-1", RequestHash: "hash", ExpiresAt: time.Now().Add(time.Hour)}
+	storedExpiresAt := time.Now().Add(30 * time.Minute)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM idempotency_keys`).
+		WithArgs(int64(1)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO idempotency_keys`).
+		WithArgs(int64(1), "key-1", "hash", record.ExpiresAt, idempotencyKeyLease.Seconds()).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT request_hash, response_code, response_body, expires_at\s+FROM idempotency_keys WHERE user_id = \$1 AND key = \$2`).
+		WithArgs(int64(1), "key-1").
+		WillReturnRows(sqlmock.NewRows([]string{"request_hash", "response_code", "response_body", "expires_at"}).
+			AddRow("hash", http.StatusOK, "", storedExpiresAt))
+	mock.ExpectCommit()
+
+	existing, reserved, err := repo.ReserveIdempotencyKey(context.Background(), record)
+
+	assert.NoError(t, err)
+	assert.False(t, reserved)
+	assert.Equal(t, http.StatusOK, existing.ResponseCode)
+	assert.Equal(t, storedExpiresAt, existing.ExpiresAt)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,132 @@
+package pg
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ibeloyar/gophermart/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// integrationRepository - репозиторий поверх настоящей базы из TEST_DATABASE_URI.
+// sqlmock не проверяет ограничения схемы, поэтому запросы к снимку баланса гоняются здесь;
+// без переменной окружения тесты пропускаются.
+func integrationRepository(t *testing.T) *Repository {
+	t.Helper()
+
+	uri := os.Getenv("TEST_DATABASE_URI")
+	if uri == "" {
+		t.Skip("TEST_DATABASE_URI is not set")
+	}
+
+	// миграции читаются по пути относительно корня репозитория
+	t.Chdir("../../..")
+
+	repo, err := New(uri, zap.NewNop().Sugar())
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = repo.Shutdown() })
+
+	return repo
+}
+
+// integrationUser - новый пользователь с начислением amount
+func integrationUser(t *testing.T, repo *Repository, amount model.Money) int64 {
+	t.Helper()
+	ctx := context.Background()
+
+	userID, err := repo.CreateUser(ctx, model.User{
+		Login:    fmt.Sprintf("it-%d", time.Now().UnixNano()),
+		Password: "hash",
+	})
+	require.NoError(t, err)
+
+	if amount > 0 {
+		_, err = repo.AdjustBalance(ctx, userID, userID, model.BalanceAdjustmentDTO{Amount: amount, Reason: "seed"})
+		require.NoError(t, err)
+	}
+
+	return userID
+}
+
+func TestIntegration_SetWithdraw(t *testing.T) {
+	repo := integrationRepository(t)
+	ctx := context.Background()
+
+	userID := integrationUser(t, repo, 5000)
+
+	err := repo.SetWithdraw(ctx, userID, model.SetWithdrawDTO{Order: fmt.Sprintf("w-%d", userID), Sum: 1050}, "")
+	require.NoError(t, err)
+
+	balance, err := repo.GetBalanceByUserID(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, model.Money(3950), balance.Current)
+	assert.Equal(t, model.Money(1050), balance.Withdrawn)
+
+	err = repo.SetWithdraw(ctx, userID, model.SetWithdrawDTO{Order: fmt.Sprintf("w2-%d", userID), Sum: 4000}, "")
+	assert.ErrorIs(t, err, model.ErrInsufficientFunds)
+
+	noBalanceID := integrationUser(t, repo, 0)
+	err = repo.SetWithdraw(ctx, noBalanceID, model.SetWithdrawDTO{Order: fmt.Sprintf("w-%d", noBalanceID), Sum: 100}, "")
+	assert.ErrorIs(t, err, model.ErrInsufficientFunds)
+}
+
+func TestIntegration_RefundWithdraw(t *testing.T) {
+	repo := integrationRepository(t)
+	ctx := context.Background()
+
+	userID := integrationUser(t, repo, 5000)
+	order := fmt.Sprintf("r-%d", userID)
+
+	err := repo.SetWithdraw(ctx, userID, model.SetWithdrawDTO{Order: order, Sum: 1050}, "")
+	require.NoError(t, err)
+
+	_, err = repo.RefundWithdraw(ctx, userID, order, "order cancelled")
+	require.NoError(t, err)
+
+	balance, err := repo.GetBalanceByUserID(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, model.Money(5000), balance.Current)
+	assert.Equal(t, model.Money(0), balance.Withdrawn)
+}
+
+func TestIntegration_ExpirePoints(t *testing.T) {
+	repo := integrationRepository(t)
+	ctx := context.Background()
+
+	repo.SetPointsExpiry(time.Hour, 0)
+	userID := integrationUser(t, repo, 5000)
+
+	err := repo.SetWithdraw(ctx, userID, model.SetWithdrawDTO{Order: fmt.Sprintf("e-%d", userID), Sum: 1050}, "")
+	require.NoError(t, err)
+
+	_, err = repo.ExpirePoints(ctx, time.Now().Add(2*time.Hour), 1000)
+	require.NoError(t, err)
+
+	// сгорает остаток партии, списанное уже не участвует
+	balance, err := repo.GetBalanceByUserID(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, model.Money(0), balance.Current)
+	assert.Equal(t, model.Money(1050), balance.Withdrawn)
+}
+
+func TestIntegration_AdjustBalance_Debit(t *testing.T) {
+	repo := integrationRepository(t)
+	ctx := context.Background()
+
+	userID := integrationUser(t, repo, 5000)
+
+	_, err := repo.AdjustBalance(ctx, userID, userID, model.BalanceAdjustmentDTO{Amount: -1050, Reason: "chargeback"})
+	require.NoError(t, err)
+
+	balance, err := repo.GetBalanceByUserID(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, model.Money(3950), balance.Current)
+
+	_, err = repo.AdjustBalance(ctx, userID, userID, model.BalanceAdjustmentDTO{Amount: -5000, Reason: "chargeback"})
+	assert.ErrorIs(t, err, model.ErrInsufficientFunds)
+}
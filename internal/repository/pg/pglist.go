@@ -0,0 +1,50 @@
+package pg
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ibeloyar/gophermart/internal/model"
+)
+
+// listQuery - сборщик WHERE для постраничных выборок с нумерованными плейсхолдерами
+type listQuery struct {
+	conditions []string
+	args       []any
+}
+
+// where - добавляет условие; каждый %d в cond заменяется номером очередного аргумента
+func (q *listQuery) where(cond string, args ...any) {
+	placeholders := make([]any, len(args))
+	for i, arg := range args {
+		q.args = append(q.args, arg)
+		placeholders[i] = len(q.args)
+	}
+
+	q.conditions = append(q.conditions, fmt.Sprintf(cond, placeholders...))
+}
+
+// applyFilter - фильтр по времени, курсор (keyset по (timeColumn, id)), сортировка и LIMIT.
+// Запрашивается на одну запись больше, чтобы понять, есть ли следующая страница.
+func (q *listQuery) applyFilter(timeColumn string, filter model.ListFilter) string {
+	if filter.From != nil {
+		q.where(timeColumn+" >= $%d", *filter.From)
+	}
+	if filter.To != nil {
+		q.where(timeColumn+" < $%d", *filter.To)
+	}
+
+	direction, cmp := "DESC", "<"
+	if filter.Sort == model.SortAsc {
+		direction, cmp = "ASC", ">"
+	}
+
+	if filter.After != nil {
+		q.where("("+timeColumn+", id) "+cmp+" ($%d, $%d)", filter.After.At, filter.After.ID)
+	}
+
+	q.args = append(q.args, filter.Limit+1)
+
+	return fmt.Sprintf(" WHERE %s ORDER BY %s %s, id %s LIMIT $%d",
+		strings.Join(q.conditions, " AND "), timeColumn, direction, direction, len(q.args))
+}
@@ -0,0 +1,172 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/ibeloyar/gophermart/internal/model"
+)
+
+func insertLoginAttempt(ctx context.Context, db execer, attempt model.LoginAttempt) error {
+	query := `INSERT INTO login_attempts (login, user_id, result, ip, user_agent) VALUES ($1, $2, $3, $4, $5)`
+
+	_, err := db.ExecContext(ctx, query,
+		attempt.Login,
+		sql.NullInt64{Int64: attempt.UserID, Valid: attempt.UserID != 0},
+		attempt.Result,
+		attempt.IP,
+		attempt.UserAgent,
+	)
+
+	return err
+}
+
+// GetLoginLockedUntil - время окончания действующей блокировки логина; nil - входить можно
+func (r *Repository) GetLoginLockedUntil(ctx context.Context, login string) (*time.Time, error) {
+	var lockedUntil time.Time
+
+	err := r.executeWithRetryConnection(ctx, func(ctx context.Context, db *sql.DB) error {
+		query := `SELECT locked_until FROM login_lockouts WHERE login = $1 AND locked_until > now()`
+
+		return db.QueryRowContext(ctx, query, login).Scan(&lockedUntil)
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &lockedUntil, nil
+}
+
+// RecordLoginAttempt - пишет успешный или заблокированный вход; успешный сбрасывает счётчик неудач
+func (r *Repository) RecordLoginAttempt(ctx context.Context, attempt model.LoginAttempt) error {
+	return r.executeWithRetryConnection(ctx, func(ctx context.Context, db *sql.DB) error {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		if err = insertLoginAttempt(ctx, tx, attempt); err != nil {
+			return err
+		}
+
+		if attempt.Result == model.LoginResultSuccess {
+			if _, err = tx.ExecContext(ctx, `DELETE FROM login_lockouts WHERE login = $1`, attempt.Login); err != nil {
+				return err
+			}
+		}
+
+		return tx.Commit()
+	})
+}
+
+// RecordLoginFailure - пишет неудачный вход и считает неудачи логина в окне policy.Window.
+// Возвращает время окончания блокировки, если эта неудача к ней привела.
+func (r *Repository) RecordLoginFailure(ctx context.Context, attempt model.LoginAttempt, policy model.LockoutPolicy) (*time.Time, error) {
+	var result *time.Time
+
+	err := r.executeWithRetryConnection(ctx, func(ctx context.Context, db *sql.DB) error {
+		now := time.Now()
+		result = nil
+
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		if err = insertLoginAttempt(ctx, tx, attempt); err != nil {
+			return err
+		}
+
+		// строка создаётся заранее, чтобы параллельные неудачи ждали друг друга на FOR UPDATE
+		queryInsert := `INSERT INTO login_lockouts (login, window_started_at) VALUES ($1, $2) ON CONFLICT (login) DO NOTHING`
+		if _, err = tx.ExecContext(ctx, queryInsert, attempt.Login, now); err != nil {
+			return err
+		}
+
+		var (
+			failures, lockouts int
+			windowStartedAt    time.Time
+			lockedUntil        sql.NullTime
+		)
+		querySelect := `SELECT failures, window_started_at, lockouts, locked_until FROM login_lockouts WHERE login = $1 FOR UPDATE`
+		err = tx.QueryRowContext(ctx, querySelect, attempt.Login).Scan(&failures, &windowStartedAt, &lockouts, &lockedUntil)
+		if err != nil {
+			return err
+		}
+
+		if now.Sub(windowStartedAt) > policy.Window {
+			failures, windowStartedAt = 0, now
+		}
+		// давно не блокировался - длительность блокировки снова с начала
+		if lockedUntil.Valid && now.Sub(lockedUntil.Time) > policy.MaxLockout {
+			lockouts = 0
+		}
+
+		failures++
+		if policy.MaxFailures > 0 && failures >= policy.MaxFailures {
+			lockouts++
+			until := now.Add(policy.LockoutDuration(lockouts))
+			lockedUntil = sql.NullTime{Time: until, Valid: true}
+			failures, windowStartedAt = 0, now
+			result = &until
+		}
+
+		queryUpdate := `UPDATE login_lockouts SET failures = $1, window_started_at = $2, lockouts = $3, locked_until = $4 WHERE login = $5`
+		if _, err = tx.ExecContext(ctx, queryUpdate, failures, windowStartedAt, lockouts, lockedUntil, attempt.Login); err != nil {
+			return err
+		}
+
+		return tx.Commit()
+	})
+
+	return result, err
+}
+
+// GetLoginAttemptsByUserID - история входов пользователя, по умолчанию сначала новые
+func (r *Repository) GetLoginAttemptsByUserID(ctx context.Context, userID int64, filter model.ListFilter) (*model.LoginAttemptsPage, error) {
+	page := &model.LoginAttemptsPage{}
+
+	err := r.executeWithRetryConnection(ctx, func(ctx context.Context, db *sql.DB) error {
+		page.Attempts = make([]model.LoginAttempt, 0, filter.Limit)
+		page.Next = nil
+
+		q := &listQuery{}
+		q.where("user_id = $%d", userID)
+
+		query := `SELECT id, result, ip, user_agent, created_at FROM login_attempts` + q.applyFilter("created_at", filter)
+
+		rows, err := db.QueryContext(ctx, query, q.args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		var lastCreatedAt time.Time
+		for rows.Next() {
+			if len(page.Attempts) == filter.Limit {
+				last := page.Attempts[len(page.Attempts)-1]
+				page.Next = &model.Cursor{At: lastCreatedAt, ID: last.ID}
+				break
+			}
+
+			attempt := model.LoginAttempt{UserID: userID}
+			if err := rows.Scan(&attempt.ID, &attempt.Result, &attempt.IP, &attempt.UserAgent, &lastCreatedAt); err != nil {
+				return err
+			}
+			attempt.CreatedAt = lastCreatedAt.Format(time.RFC3339Nano)
+
+			page.Attempts = append(page.Attempts, attempt)
+		}
+
+		return rows.Err()
+	})
+
+	return page, err
+}
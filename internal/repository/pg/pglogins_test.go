@@ -0,0 +1,147 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ibeloyar/gophermart/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testLockoutPolicy = model.LockoutPolicy{
+	MaxFailures: 3,
+	Window:      15 * time.Minute,
+	Lockout:     time.Minute,
+	MaxLockout:  time.Hour,
+}
+
+func TestRepository_RecordLoginFailure_Locks(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := &Repository{db: db, classifier: NewPostgresErrorClassifier()}
+	attempt := model.LoginAttempt{Login: "bob", UserID: 7, Result: model.LoginResultInvalidCredentials, IP: "10.0.0.1", UserAgent: "curl/8.0"}
+
+	// третья неудача в окне, до этого уже была одна блокировка - вторая вдвое длиннее
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO login_attempts \(login, user_id, result, ip, user_agent\) VALUES \(\$1, \$2, \$3, \$4, \$5\)`).
+		WithArgs("bob", sql.NullInt64{Int64: 7, Valid: true}, model.LoginResultInvalidCredentials, "10.0.0.1", "curl/8.0").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`INSERT INTO login_lockouts \(login, window_started_at\) VALUES \(\$1, \$2\) ON CONFLICT \(login\) DO NOTHING`).
+		WithArgs("bob", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT failures, window_started_at, lockouts, locked_until FROM login_lockouts WHERE login = \$1 FOR UPDATE`).
+		WithArgs("bob").
+		WillReturnRows(sqlmock.NewRows([]string{"failures", "window_started_at", "lockouts", "locked_until"}).
+			AddRow(2, time.Now().Add(-time.Minute), 1, time.Now().Add(-5*time.Minute)))
+	mock.ExpectExec(`UPDATE login_lockouts SET failures = \$1, window_started_at = \$2, lockouts = \$3, locked_until = \$4 WHERE login = \$5`).
+		WithArgs(0, sqlmock.AnyArg(), 2, sqlmock.AnyArg(), "bob").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	lockedUntil, err := repo.RecordLoginFailure(context.Background(), attempt, testLockoutPolicy)
+
+	require.NoError(t, err)
+	require.NotNil(t, lockedUntil)
+	assert.WithinDuration(t, time.Now().Add(2*time.Minute), *lockedUntil, time.Second)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_RecordLoginFailure_WindowExpired(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := &Repository{db: db, classifier: NewPostgresErrorClassifier()}
+	attempt := model.LoginAttempt{Login: "nobody", Result: model.LoginResultInvalidCredentials}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO login_attempts`).
+		WithArgs("nobody", sql.NullInt64{}, model.LoginResultInvalidCredentials, "", "").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`INSERT INTO login_lockouts`).
+		WithArgs("nobody", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT failures, window_started_at, lockouts, locked_until FROM login_lockouts`).
+		WithArgs("nobody").
+		WillReturnRows(sqlmock.NewRows([]string{"failures", "window_started_at", "lockouts", "locked_until"}).
+			AddRow(2, time.Now().Add(-time.Hour), 0, nil))
+	mock.ExpectExec(`UPDATE login_lockouts SET`).
+		WithArgs(1, sqlmock.AnyArg(), 0, sql.NullTime{}, "nobody").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	lockedUntil, err := repo.RecordLoginFailure(context.Background(), attempt, testLockoutPolicy)
+
+	require.NoError(t, err)
+	assert.Nil(t, lockedUntil)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_RecordLoginAttempt_SuccessResetsLockout(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := &Repository{db: db, classifier: NewPostgresErrorClassifier()}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO login_attempts`).
+		WithArgs("bob", sql.NullInt64{Int64: 7, Valid: true}, model.LoginResultSuccess, "10.0.0.1", "").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`DELETE FROM login_lockouts WHERE login = \$1`).
+		WithArgs("bob").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err = repo.RecordLoginAttempt(context.Background(), model.LoginAttempt{Login: "bob", UserID: 7, Result: model.LoginResultSuccess, IP: "10.0.0.1"})
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_GetLoginLockedUntil_NotLocked(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := &Repository{db: db, classifier: NewPostgresErrorClassifier()}
+
+	mock.ExpectQuery(`SELECT locked_until FROM login_lockouts WHERE login = \$1 AND locked_until > now\(\)`).
+		WithArgs("bob").
+		WillReturnError(sql.ErrNoRows)
+
+	lockedUntil, err := repo.GetLoginLockedUntil(context.Background(), "bob")
+
+	assert.NoError(t, err)
+	assert.Nil(t, lockedUntil)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_GetLoginAttemptsByUserID(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := &Repository{db: db, classifier: NewPostgresErrorClassifier()}
+
+	mock.ExpectQuery(`SELECT id, result, ip, user_agent, created_at FROM login_attempts WHERE user_id = \$1 ORDER BY created_at DESC, id DESC LIMIT \$2`).
+		WithArgs(int64(7), 2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "result", "ip", "user_agent", "created_at"}).
+			AddRow(int64(3), model.LoginResultSuccess, "10.0.0.1", "curl/8.0", time.Now()).
+			AddRow(int64(2), model.LoginResultInvalidCredentials, "10.0.0.2", "", time.Now().Add(-time.Minute)))
+
+	page, err := repo.GetLoginAttemptsByUserID(context.Background(), 7, model.ListFilter{Limit: 1})
+
+	assert.NoError(t, err)
+	assert.Len(t, page.Attempts, 1)
+	assert.Equal(t, model.LoginResultSuccess, page.Attempts[0].Result)
+	assert.NotNil(t, page.Next)
+	assert.Equal(t, int64(3), page.Next.ID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
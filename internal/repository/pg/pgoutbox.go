@@ -0,0 +1,170 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/ibeloyar/gophermart/internal/model"
+	"github.com/lib/pq"
+)
+
+// outboxPublisherLock - ключ advisory-блокировки: ленту публикует одна реплика, иначе порядок нарушится
+const outboxPublisherLock = 7_201_011
+
+// insertOutboxEvent - пишет доменное событие в events_outbox внутри транзакции изменения
+func insertOutboxEvent(ctx context.Context, tx *sql.Tx, eventType model.DomainEventType, userID int64, data any) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO events_outbox (event_type, user_id, payload) VALUES ($1, $2, $3)`
+	_, err = tx.ExecContext(ctx, query, eventType, userID, string(payload))
+
+	return err
+}
+
+// PublishOutboxEvents - передаёт publish пачку неопубликованных событий
+// и помечает их опубликованными, только если publish завершился без ошибки.
+// Каждому событию выдаётся номер в ленте (Seq) под блокировкой публикатора: событие,
+// зафиксированное позже, получит больший номер, даже если его id меньше.
+// Если ленту уже публикует другая реплика, возвращает 0.
+func (r *Repository) PublishOutboxEvents(ctx context.Context, limit int, publish func([]model.DomainEvent) error) (int, error) {
+	var published int
+
+	err := r.executeWithRetryConnection(ctx, func(ctx context.Context, db *sql.DB) error {
+		published = 0
+
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		var locked bool
+		if err = tx.QueryRowContext(ctx, `SELECT pg_try_advisory_xact_lock($1)`, outboxPublisherLock).Scan(&locked); err != nil {
+			return err
+		}
+
+		if !locked {
+			return nil
+		}
+
+		query := `SELECT id, event_type, user_id, payload, created_at FROM events_outbox
+			WHERE published_at IS NULL
+			ORDER BY id
+			LIMIT $1`
+
+		rows, err := tx.QueryContext(ctx, query, limit)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		events := make([]model.DomainEvent, 0, limit)
+		ids := make(pq.Int64Array, 0, limit)
+		for rows.Next() {
+			var (
+				event   model.DomainEvent
+				payload string
+			)
+
+			if err = rows.Scan(&event.ID, &event.Type, &event.UserID, &payload, &event.CreatedAt); err != nil {
+				return err
+			}
+
+			event.Data = json.RawMessage(payload)
+			events = append(events, event)
+			ids = append(ids, event.ID)
+		}
+
+		if err = rows.Err(); err != nil {
+			return err
+		}
+
+		if len(events) == 0 {
+			return nil
+		}
+
+		seqs, err := nextOutboxSeqs(ctx, tx, len(events))
+		if err != nil {
+			return err
+		}
+		for i := range events {
+			events[i].Seq = seqs[i]
+		}
+
+		if err = publish(events); err != nil {
+			return err
+		}
+
+		queryPublished := `UPDATE events_outbox e SET published_at = now(), seq = b.seq
+			FROM unnest($1::bigint[], $2::bigint[]) AS b(id, seq)
+			WHERE e.id = b.id`
+		if _, err = tx.ExecContext(ctx, queryPublished, ids, seqs); err != nil {
+			return err
+		}
+
+		if err = tx.Commit(); err != nil {
+			return err
+		}
+
+		published = len(events)
+
+		return nil
+	})
+
+	return published, err
+}
+
+// nextOutboxSeqs - выдаёт n возрастающих номеров ленты. Номера откатившейся публикации
+// не возвращаются, поэтому в ленте возможны пропуски, но не перестановки.
+func nextOutboxSeqs(ctx context.Context, tx *sql.Tx, n int) (pq.Int64Array, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT nextval('events_outbox_seq') FROM generate_series(1, $1) ORDER BY 1`, n)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	seqs := make(pq.Int64Array, 0, n)
+	for rows.Next() {
+		var seq int64
+		if err = rows.Scan(&seq); err != nil {
+			return nil, err
+		}
+
+		seqs = append(seqs, seq)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(seqs) != n {
+		return nil, fmt.Errorf("got %d outbox sequence numbers, expected %d", len(seqs), n)
+	}
+
+	return seqs, nil
+}
+
+// PurgeOutboxEvents - периодически удаляет события, опубликованные раньше retention, пока не отменён ctx.
+// Неопубликованные события не удаляются.
+func (r *Repository) PurgeOutboxEvents(ctx context.Context, retention, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_, err := r.db.ExecContext(ctx, `DELETE FROM events_outbox WHERE published_at < $1`, time.Now().Add(-retention))
+			if err != nil && ctx.Err() == nil {
+				r.lg.Errorf("purging published domain events error: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package pg
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ibeloyar/gophermart/internal/model"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepository_PublishOutboxEvents_MarksPublished(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := &Repository{db: db, classifier: NewPostgresErrorClassifier()}
+	createdAt := time.Now()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT pg_try_advisory_xact_lock\(\$1\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"locked"}).AddRow(true))
+	mock.ExpectQuery(`SELECT id, event_type, user_id, payload, created_at FROM events_outbox\s+WHERE published_at IS NULL\s+ORDER BY id\s+LIMIT \$1`).
+		WithArgs(10).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "event_type", "user_id", "payload", "created_at"}).
+			AddRow(int64(1), "user.registered", int64(5), `{"login":"user"}`, createdAt).
+			AddRow(int64(2), "order.uploaded", int64(5), `{"order":"123"}`, createdAt))
+	mock.ExpectQuery(`SELECT nextval\('events_outbox_seq'\) FROM generate_series\(1, \$1\) ORDER BY 1`).
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"nextval"}).AddRow(int64(11)).AddRow(int64(12)))
+	mock.ExpectExec(`UPDATE events_outbox e SET published_at = now\(\), seq = b.seq\s+FROM unnest\(\$1::bigint\[\], \$2::bigint\[\]\) AS b\(id, seq\)`).
+		WithArgs(pq.Int64Array{1, 2}, pq.Int64Array{11, 12}).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	var got []model.DomainEvent
+	published, err := repo.PublishOutboxEvents(context.Background(), 10, func(events []model.DomainEvent) error {
+		got = events
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, published)
+	require.Len(t, got, 2)
+	assert.Equal(t, model.DomainEventUserRegistered, got[0].Type)
+	assert.Equal(t, int64(11), got[0].Seq)
+	assert.Equal(t, int64(12), got[1].Seq)
+	assert.Equal(t, json.RawMessage(`{"order":"123"}`), got[1].Data)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_PublishOutboxEvents_LockedByOtherReplica(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := &Repository{db: db, classifier: NewPostgresErrorClassifier()}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT pg_try_advisory_xact_lock\(\$1\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"locked"}).AddRow(false))
+	mock.ExpectRollback()
+
+	published, err := repo.PublishOutboxEvents(context.Background(), 10, func([]model.DomainEvent) error {
+		t.Fatal("publish must not be called without the lock")
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 0, published)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_PublishOutboxEvents_SinkErrorKeepsEvents(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := &Repository{db: db, classifier: NewPostgresErrorClassifier()}
+	sinkErr := errors.New("disk full")
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT pg_try_advisory_xact_lock\(\$1\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"locked"}).AddRow(true))
+	mock.ExpectQuery(`SELECT id, event_type, user_id, payload, created_at FROM events_outbox`).
+		WithArgs(10).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "event_type", "user_id", "payload", "created_at"}).
+			AddRow(int64(1), "user.registered", int64(5), `{"login":"user"}`, time.Now()))
+	mock.ExpectQuery(`SELECT nextval`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"nextval"}).AddRow(int64(11)))
+	mock.ExpectRollback()
+
+	published, err := repo.PublishOutboxEvents(context.Background(), 10, func([]model.DomainEvent) error {
+		return sinkErr
+	})
+
+	assert.ErrorIs(t, err, sinkErr)
+	assert.Equal(t, 0, published)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
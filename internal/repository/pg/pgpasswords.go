@@ -0,0 +1,135 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/ibeloyar/gophermart/internal/model"
+)
+
+// ChangePassword - меняет хеш пароля и отзывает все сессии пользователя, кроме keepSessionID.
+// Невыполненные токены сброса тоже перестают действовать.
+func (r *Repository) ChangePassword(ctx context.Context, userID int64, passwordHash, keepSessionID string) error {
+	return r.executeWithRetryConnection(ctx, func(ctx context.Context, db *sql.DB) error {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		if err = setUserPassword(ctx, tx, userID, passwordHash, keepSessionID); err != nil {
+			return err
+		}
+
+		return tx.Commit()
+	})
+}
+
+// CreatePasswordResetToken - сохраняет хеш нового токена сброса вместо прежних неиспользованных
+func (r *Repository) CreatePasswordResetToken(ctx context.Context, adminID, userID int64, tokenHash string, expiresAt time.Time, reason string) error {
+	return r.executeWithRetryConnection(ctx, func(ctx context.Context, db *sql.DB) error {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		var exists bool
+		err = tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM users WHERE id = $1)`, userID).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return model.ErrUserNotFound
+		}
+
+		if _, err = tx.ExecContext(ctx, `DELETE FROM password_reset_tokens WHERE user_id = $1 AND used_at IS NULL`, userID); err != nil {
+			return err
+		}
+
+		query := `INSERT INTO password_reset_tokens (token_hash, user_id, issued_by, expires_at) VALUES ($1, $2, $3, $4)`
+		if _, err = tx.ExecContext(ctx, query, tokenHash, userID, adminID, expiresAt); err != nil {
+			return err
+		}
+
+		err = insertAdminAudit(ctx, tx, model.AdminAuditEntry{
+			AdminID: adminID,
+			Action:  model.AdminActionPasswordReset,
+			UserID:  userID,
+			Details: reason,
+		})
+		if err != nil {
+			return err
+		}
+
+		return tx.Commit()
+	})
+}
+
+// ResetPassword - гасит токен сброса и меняет по нему пароль; все сессии пользователя отзываются
+func (r *Repository) ResetPassword(ctx context.Context, tokenHash, passwordHash string) error {
+	return r.executeWithRetryConnection(ctx, func(ctx context.Context, db *sql.DB) error {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		var userID int64
+		query := `SELECT user_id FROM password_reset_tokens
+			WHERE token_hash = $1 AND used_at IS NULL AND expires_at > now()
+			FOR UPDATE`
+		err = tx.QueryRowContext(ctx, query, tokenHash).Scan(&userID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return model.ErrResetTokenInvalid
+			}
+			return err
+		}
+
+		if _, err = tx.ExecContext(ctx, `UPDATE password_reset_tokens SET used_at = now() WHERE token_hash = $1`, tokenHash); err != nil {
+			return err
+		}
+
+		if err = setUserPassword(ctx, tx, userID, passwordHash, ""); err != nil {
+			return err
+		}
+
+		return tx.Commit()
+	})
+}
+
+// UpdatePasswordHash - пересчитанный хеш того же пароля; не трогает пароль, если его успели сменить
+func (r *Repository) UpdatePasswordHash(ctx context.Context, userID int64, oldHash, newHash string) error {
+	return r.executeWithRetryConnection(ctx, func(ctx context.Context, db *sql.DB) error {
+		_, err := db.ExecContext(ctx, `UPDATE users SET password = $1 WHERE id = $2 AND password = $3`, newHash, userID, oldHash)
+
+		return err
+	})
+}
+
+func setUserPassword(ctx context.Context, tx *sql.Tx, userID int64, passwordHash, keepSessionID string) error {
+	result, err := tx.ExecContext(ctx, `UPDATE users SET password = $1 WHERE id = $2`, passwordHash, userID)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return model.ErrUserNotFound
+	}
+
+	querySessions := `UPDATE sessions SET revoked_at = now() WHERE user_id = $1 AND id <> $2 AND revoked_at IS NULL`
+	if _, err = tx.ExecContext(ctx, querySessions, userID, keepSessionID); err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM password_reset_tokens WHERE user_id = $1 AND used_at IS NULL`, userID)
+
+	return err
+}
@@ -0,0 +1,123 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ibeloyar/gophermart/internal/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRepository_ChangePassword(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := &Repository{db: db, classifier: NewPostgresErrorClassifier()}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE users SET password = \$1 WHERE id = \$2`).
+		WithArgs("new-hash", int64(7)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE sessions SET revoked_at = now\(\) WHERE user_id = \$1 AND id <> \$2 AND revoked_at IS NULL`).
+		WithArgs(int64(7), "session-1").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(`DELETE FROM password_reset_tokens WHERE user_id = \$1 AND used_at IS NULL`).
+		WithArgs(int64(7)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	err = repo.ChangePassword(context.Background(), 7, "new-hash", "session-1")
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_ResetPassword(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := &Repository{db: db, classifier: NewPostgresErrorClassifier()}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT user_id FROM password_reset_tokens\s+WHERE token_hash = \$1 AND used_at IS NULL AND expires_at > now\(\)\s+FOR UPDATE`).
+		WithArgs("token-hash").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(int64(7)))
+	mock.ExpectExec(`UPDATE password_reset_tokens SET used_at = now\(\) WHERE token_hash = \$1`).
+		WithArgs("token-hash").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE users SET password = \$1 WHERE id = \$2`).
+		WithArgs("new-hash", int64(7)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE sessions SET revoked_at = now\(\)`).
+		WithArgs(int64(7), "").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM password_reset_tokens`).
+		WithArgs(int64(7)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	err = repo.ResetPassword(context.Background(), "token-hash", "new-hash")
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_ResetPassword_InvalidToken(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := &Repository{db: db, classifier: NewPostgresErrorClassifier()}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT user_id FROM password_reset_tokens`).
+		WithArgs("token-hash").
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	err = repo.ResetPassword(context.Background(), "token-hash", "new-hash")
+
+	assert.ErrorIs(t, err, model.ErrResetTokenInvalid)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_CreatePasswordResetToken_UserNotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := &Repository{db: db, classifier: NewPostgresErrorClassifier()}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM users WHERE id = \$1\)`).
+		WithArgs(int64(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectRollback()
+
+	err = repo.CreatePasswordResetToken(context.Background(), 1, 7, "token-hash", time.Now(), "locked out")
+
+	assert.ErrorIs(t, err, model.ErrUserNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_UpdatePasswordHash(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := &Repository{db: db, classifier: NewPostgresErrorClassifier()}
+
+	mock.ExpectExec(`UPDATE users SET password = \$1 WHERE id = \$2 AND password = \$3`).
+		WithArgs("new-hash", int64(7), "old-hash").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err = repo.UpdatePasswordHash(context.Background(), 7, "old-hash", "new-hash")
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,173 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/ibeloyar/gophermart/internal/model"
+)
+
+// SetPointsExpiry - срок жизни новых партий баллов (0 - бессрочно) и окно,
+// за которое сгорающие баллы попадают в expiring_soon баланса
+func (r *Repository) SetPointsExpiry(period, soonWindow time.Duration) {
+	r.pointsExpiry = period
+	r.expiringSoon = soonWindow
+}
+
+func (r *Repository) lotExpiresAt() sql.NullTime {
+	if r.pointsExpiry <= 0 {
+		return sql.NullTime{}
+	}
+
+	return sql.NullTime{Time: time.Now().Add(r.pointsExpiry), Valid: true}
+}
+
+// insertPointLot - новая партия баллов, привязанная к записи журнала balanceID
+func insertPointLot(ctx context.Context, tx *sql.Tx, userID, balanceID int64, amount model.Money, expiresAt sql.NullTime) error {
+	query := `INSERT INTO point_lots (user_id, balance_id, amount, remaining, expires_at) VALUES ($1, $2, $3, $3, $4)`
+
+	_, err := tx.ExecContext(ctx, query, userID, balanceID, amount, expiresAt)
+
+	return err
+}
+
+// consumePointLots - расходует партии пользователя от старых к новым.
+// Достаточность средств проверяет снимок баланса, поэтому вызывается после updateBalance
+// (тот же порядок блокировок, что и у списания сгоревших баллов).
+func consumePointLots(ctx context.Context, tx *sql.Tx, userID int64, amount model.Money) error {
+	type lot struct {
+		id        int64
+		remaining model.Money
+	}
+
+	rows, err := tx.QueryContext(ctx, `SELECT id, remaining FROM point_lots
+		WHERE user_id = $1 AND remaining > 0
+		ORDER BY id
+		FOR UPDATE`, userID)
+	if err != nil {
+		return err
+	}
+
+	var lots []lot
+	for rows.Next() {
+		var l lot
+		if err := rows.Scan(&l.id, &l.remaining); err != nil {
+			rows.Close()
+			return err
+		}
+		lots = append(lots, l)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for _, l := range lots {
+		if amount <= 0 {
+			break
+		}
+
+		take := min(amount, l.remaining)
+		if _, err = tx.ExecContext(ctx, `UPDATE point_lots SET remaining = remaining - $1 WHERE id = $2`, take, l.id); err != nil {
+			return err
+		}
+		amount -= take
+	}
+
+	return nil
+}
+
+// ExpirePoints - списывает остатки партий, срок которых истёк к now, не более чем у limit пользователей.
+// Возвращает число обработанных пользователей.
+func (r *Repository) ExpirePoints(ctx context.Context, now time.Time, limit int) (int, error) {
+	var userIDs []int64
+
+	err := r.executeWithRetryConnection(ctx, func(ctx context.Context, db *sql.DB) error {
+		userIDs = make([]int64, 0, limit)
+
+		query := `SELECT DISTINCT user_id FROM point_lots
+			WHERE remaining > 0 AND expires_at <= $1
+			ORDER BY user_id
+			LIMIT $2`
+
+		rows, err := db.QueryContext(ctx, query, now, limit)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var userID int64
+			if err := rows.Scan(&userID); err != nil {
+				return err
+			}
+			userIDs = append(userIDs, userID)
+		}
+
+		return rows.Err()
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for i, userID := range userIDs {
+		err := r.executeWithRetryConnection(ctx, func(ctx context.Context, db *sql.DB) error {
+			return expireUserPoints(ctx, db, userID, now)
+		})
+		if err != nil {
+			return i, err
+		}
+	}
+
+	return len(userIDs), nil
+}
+
+// expireUserPoints - обнуляет просроченные партии пользователя и пишет в журнал одно списание EXPIRATION.
+// Снимок блокируется первым, как при списании, чтобы не было взаимной блокировки с SetWithdraw.
+func expireUserPoints(ctx context.Context, db *sql.DB, userID int64, now time.Time) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var current model.Money
+	err = tx.QueryRowContext(ctx, `SELECT current FROM user_balances WHERE user_id = $1 FOR UPDATE`, userID).Scan(&current)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	var expired model.Money
+	query := `WITH expired AS (
+			SELECT id, remaining FROM point_lots
+			WHERE user_id = $1 AND remaining > 0 AND expires_at <= $2
+			FOR UPDATE
+		), cleared AS (
+			UPDATE point_lots SET remaining = 0 FROM expired WHERE point_lots.id = expired.id
+		)
+		SELECT COALESCE(SUM(remaining), 0) FROM expired`
+	if err = tx.QueryRowContext(ctx, query, userID, now).Scan(&expired); err != nil {
+		return err
+	}
+
+	// снимок не уходит в минус, даже если партии разошлись с журналом
+	amount := min(expired, current)
+	if amount > 0 {
+		queryInsertBalance := `INSERT INTO balance (user_id, order_number, amount, kind) VALUES ($1, $2, $3, $4)`
+		if _, err = tx.ExecContext(ctx, queryInsertBalance, userID, "", -amount, model.LedgerKindExpiration); err != nil {
+			return err
+		}
+
+		if err = updateBalance(ctx, tx, userID, -amount, 0); err != nil {
+			return err
+		}
+
+		if err = insertOutboxEvent(ctx, tx, model.DomainEventPointsExpired, userID, model.PointsExpiredData{Amount: amount}); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
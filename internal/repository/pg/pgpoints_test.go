@@ -0,0 +1,71 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ibeloyar/gophermart/internal/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRepository_ExpirePoints(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := &Repository{db: db, classifier: NewPostgresErrorClassifier()}
+	now := time.Now()
+
+	mock.ExpectQuery(`SELECT DISTINCT user_id FROM point_lots\s+WHERE remaining > 0 AND expires_at <= \$1`).
+		WithArgs(now, 100).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(int64(7)))
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT current FROM user_balances WHERE user_id = \$1 FOR UPDATE`).
+		WithArgs(int64(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"current"}).AddRow("30.00"))
+	mock.ExpectQuery(`WITH expired AS \(.+\) SELECT COALESCE\(SUM\(remaining\), 0\) FROM expired`).
+		WithArgs(int64(7), now).
+		WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow("12.50"))
+	mock.ExpectExec(`INSERT INTO balance \(user_id, order_number, amount, kind\) VALUES \(\$1, \$2, \$3, \$4\)`).
+		WithArgs(int64(7), "", model.Money(-1250), model.LedgerKindExpiration).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`UPDATE user_balances SET`).
+		WithArgs(int64(7), model.Money(-1250), model.Money(0)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO events_outbox`).
+		WithArgs(model.DomainEventPointsExpired, int64(7), `{"amount":12.5}`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	processed, err := repo.ExpirePoints(context.Background(), now, 100)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, processed)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_ExpirePoints_CappedBySnapshot(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := &Repository{db: db, classifier: NewPostgresErrorClassifier()}
+	now := time.Now()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT current FROM user_balances`).
+		WithArgs(int64(7)).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery(`WITH expired AS`).
+		WithArgs(int64(7), now).
+		WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow("12.50"))
+	mock.ExpectCommit()
+
+	err = expireUserPoints(context.Background(), repo.db, 7, now)
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
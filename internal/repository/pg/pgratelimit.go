@@ -0,0 +1,65 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/ibeloyar/gophermart/pgk/ratelimit"
+)
+
+// rateLimitBucketTTL - корзины, не менявшиеся дольше, заведомо полны и удаляются
+const rateLimitBucketTTL = 24 * time.Hour
+
+// TakeToken - token bucket в rate_limit_buckets: пополнение и списание токена одним UPSERT.
+// Без повторов executeWithRetryConnection: лимитер не должен добавлять задержку к запросу.
+func (r *Repository) TakeToken(ctx context.Context, key string, limit ratelimit.Limit, now time.Time) (bool, time.Duration, error) {
+	query := `INSERT INTO rate_limit_buckets (key, tokens, updated_at) VALUES ($1, $2 - 1, $3)
+		ON CONFLICT (key) DO UPDATE SET
+			tokens = LEAST($2, rate_limit_buckets.tokens + GREATEST(0, EXTRACT(EPOCH FROM ($3 - rate_limit_buckets.updated_at))) * $4) - 1,
+			updated_at = $3
+		WHERE LEAST($2, rate_limit_buckets.tokens + GREATEST(0, EXTRACT(EPOCH FROM ($3 - rate_limit_buckets.updated_at))) * $4) >= 1
+		RETURNING tokens`
+
+	var tokens float64
+	err := r.db.QueryRowContext(ctx, query, key, float64(limit.Burst), now, limit.Rate()).Scan(&tokens)
+	if err == nil {
+		return true, 0, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return false, 0, err
+	}
+
+	// токена нет: корзина не менялась, считаем время до следующего
+	var updatedAt time.Time
+	err = r.db.QueryRowContext(ctx, `SELECT tokens, updated_at FROM rate_limit_buckets WHERE key = $1`, key).
+		Scan(&tokens, &updatedAt)
+	if err != nil {
+		return false, 0, err
+	}
+
+	available := math.Min(float64(limit.Burst), tokens+math.Max(0, now.Sub(updatedAt).Seconds())*limit.Rate())
+	wait := time.Duration((1 - available) / limit.Rate() * float64(time.Second))
+
+	return false, wait, nil
+}
+
+// PurgeRateLimitBuckets - периодически удаляет старые корзины, пока не отменён ctx
+func (r *Repository) PurgeRateLimitBuckets(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_, err := r.db.ExecContext(ctx, `DELETE FROM rate_limit_buckets WHERE updated_at < $1`, time.Now().Add(-rateLimitBucketTTL))
+			if err != nil && ctx.Err() == nil {
+				r.lg.Errorf("purging rate limit buckets error: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ibeloyar/gophermart/pgk/ratelimit"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRepository_TakeToken(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := &Repository{db: db, classifier: NewPostgresErrorClassifier()}
+	limit := ratelimit.Limit{Requests: 10, Period: time.Minute, Burst: 5}
+	now := time.Now()
+
+	mock.ExpectQuery(`INSERT INTO rate_limit_buckets \(key, tokens, updated_at\) VALUES \(\$1, \$2 - 1, \$3\)\s+ON CONFLICT \(key\) DO UPDATE`).
+		WithArgs("login:ip:10.0.0.1", float64(5), now, limit.Rate()).
+		WillReturnRows(sqlmock.NewRows([]string{"tokens"}).AddRow(4.0))
+
+	allowed, wait, err := repo.TakeToken(context.Background(), "login:ip:10.0.0.1", limit, now)
+
+	assert.NoError(t, err)
+	assert.True(t, allowed)
+	assert.Zero(t, wait)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_TakeToken_Empty(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := &Repository{db: db, classifier: NewPostgresErrorClassifier()}
+	limit := ratelimit.Limit{Requests: 10, Period: time.Minute, Burst: 5}
+	now := time.Now()
+
+	mock.ExpectQuery(`INSERT INTO rate_limit_buckets`).
+		WithArgs("login:ip:10.0.0.1", float64(5), now, limit.Rate()).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery(`SELECT tokens, updated_at FROM rate_limit_buckets WHERE key = \$1`).
+		WithArgs("login:ip:10.0.0.1").
+		WillReturnRows(sqlmock.NewRows([]string{"tokens", "updated_at"}).AddRow(0.5, now.Add(-time.Second)))
+
+	allowed, wait, err := repo.TakeToken(context.Background(), "login:ip:10.0.0.1", limit, now)
+
+	assert.NoError(t, err)
+	assert.False(t, allowed)
+	// 0.5 + 1с * 1/6 токена в секунду = 2/3 токена, до целого - 2 секунды
+	assert.InDelta(t, 2*time.Second, wait, float64(time.Millisecond))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
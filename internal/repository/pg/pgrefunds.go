@@ -0,0 +1,105 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/ibeloyar/gophermart/internal/model"
+)
+
+// RefundWithdraw - возвращает баллы последнего списания по заказу order записью REFUND со ссылкой на него.
+// Повторный возврат того же списания отсекает уникальный индекс по refund_of и блокировка строки списания.
+func (r *Repository) RefundWithdraw(ctx context.Context, actorID int64, order, reason string) (*model.LedgerEntry, error) {
+	var entry model.LedgerEntry
+
+	err := r.executeWithRetryConnection(ctx, func(ctx context.Context, db *sql.DB) error {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		var (
+			withdrawID int64
+			userID     int64
+			amount     model.Money
+		)
+		queryWithdraw := `SELECT id, user_id, ABS(amount) FROM balance
+			WHERE order_number = $1 AND kind = $2
+			ORDER BY id DESC
+			LIMIT 1
+			FOR UPDATE`
+		err = tx.QueryRowContext(ctx, queryWithdraw, order, model.LedgerKindWithdrawal).Scan(&withdrawID, &userID, &amount)
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.ErrWithdrawNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		var refunded bool
+		err = tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM balance WHERE refund_of = $1)`, withdrawID).Scan(&refunded)
+		if err != nil {
+			return err
+		}
+		if refunded {
+			return model.ErrWithdrawAlreadyRefunded
+		}
+
+		if err = updateBalance(ctx, tx, userID, amount, -amount); err != nil {
+			return err
+		}
+
+		var createdAt time.Time
+		queryInsertRefund := `INSERT INTO balance (user_id, order_number, amount, kind, reason, refund_of) VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING id, uploaded_at`
+		err = tx.QueryRowContext(ctx, queryInsertRefund, userID, order, amount, model.LedgerKindRefund, reason, withdrawID).
+			Scan(&entry.ID, &createdAt)
+		if hasErrorCode(err, ErrIsExistCode) {
+			return model.ErrWithdrawAlreadyRefunded
+		}
+		if err != nil {
+			return err
+		}
+
+		// возвращённые баллы - новая партия, прежние к этому времени могли сгореть
+		if err = insertPointLot(ctx, tx, userID, entry.ID, amount, r.lotExpiresAt()); err != nil {
+			return err
+		}
+
+		entry.Order = order
+		entry.Amount = amount
+		entry.Kind = model.LedgerKindRefund
+		entry.Reason = reason
+		entry.CreatedAt = createdAt.Format(time.RFC3339Nano)
+
+		err = insertOutboxEvent(ctx, tx, model.DomainEventPointsRefunded, userID, model.PointsRefundedData{
+			Order:  order,
+			Sum:    amount,
+			Reason: reason,
+		})
+		if err != nil {
+			return err
+		}
+
+		err = insertAdminAudit(ctx, tx, model.AdminAuditEntry{
+			AdminID:     actorID,
+			Action:      model.AdminActionRefundWithdraw,
+			UserID:      userID,
+			OrderNumber: order,
+			Details:     amount.String() + ": " + reason,
+		})
+		if err != nil {
+			return err
+		}
+
+		return tx.Commit()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &entry, nil
+}
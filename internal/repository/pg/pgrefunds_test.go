@@ -0,0 +1,94 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ibeloyar/gophermart/internal/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRepository_RefundWithdraw(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := &Repository{db: db, classifier: NewPostgresErrorClassifier()}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id, user_id, ABS\(amount\) FROM balance\s+WHERE order_number = \$1 AND kind = \$2\s+ORDER BY id DESC\s+LIMIT 1\s+FOR UPDATE`).
+		WithArgs("12345678903", model.LedgerKindWithdrawal).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "amount"}).AddRow(int64(11), int64(7), "10.50"))
+	mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM balance WHERE refund_of = \$1\)`).
+		WithArgs(int64(11)).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectExec(`UPDATE user_balances SET`).
+		WithArgs(int64(7), model.Money(1050), model.Money(-1050)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO balance \(user_id, order_number, amount, kind, reason, refund_of\)`).
+		WithArgs(int64(7), "12345678903", model.Money(1050), model.LedgerKindRefund, "order cancelled", int64(11)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "uploaded_at"}).AddRow(int64(12), time.Now()))
+	mock.ExpectExec(`INSERT INTO point_lots`).
+		WithArgs(int64(7), int64(12), model.Money(1050), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`INSERT INTO events_outbox`).
+		WithArgs(model.DomainEventPointsRefunded, int64(7), `{"order":"12345678903","sum":10.5,"reason":"order cancelled"}`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`INSERT INTO admin_audit_log`).
+		WithArgs(int64(1), model.AdminActionRefundWithdraw, sql.NullInt64{Int64: 7, Valid: true},
+			sql.NullString{String: "12345678903", Valid: true}, sql.NullString{String: "10.5: order cancelled", Valid: true}).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	entry, err := repo.RefundWithdraw(context.Background(), 1, "12345678903", "order cancelled")
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(12), entry.ID)
+	assert.Equal(t, model.LedgerKindRefund, entry.Kind)
+	assert.Equal(t, model.Money(1050), entry.Amount)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_RefundWithdraw_AlreadyRefunded(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := &Repository{db: db, classifier: NewPostgresErrorClassifier()}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id, user_id, ABS\(amount\) FROM balance`).
+		WithArgs("12345678903", model.LedgerKindWithdrawal).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "amount"}).AddRow(int64(11), int64(7), "10.50"))
+	mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM balance WHERE refund_of = \$1\)`).
+		WithArgs(int64(11)).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectRollback()
+
+	_, err = repo.RefundWithdraw(context.Background(), 1, "12345678903", "order cancelled")
+
+	assert.ErrorIs(t, err, model.ErrWithdrawAlreadyRefunded)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_RefundWithdraw_NotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := &Repository{db: db, classifier: NewPostgresErrorClassifier()}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id, user_id, ABS\(amount\) FROM balance`).
+		WithArgs("12345678903", model.LedgerKindWithdrawal).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	_, err = repo.RefundWithdraw(context.Background(), 1, "12345678903", "order cancelled")
+
+	assert.ErrorIs(t, err, model.ErrWithdrawNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,82 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/ibeloyar/gophermart/internal/model"
+	"github.com/lib/pq"
+)
+
+// userRolesColumn - роли пользователя u одним массивом в выборке из users
+const userRolesColumn = `ARRAY(SELECT role FROM user_roles WHERE user_id = u.id ORDER BY role)`
+
+func toRoles(values pq.StringArray) []model.Role {
+	roles := make([]model.Role, 0, len(values))
+	for _, value := range values {
+		roles = append(roles, model.Role(value))
+	}
+
+	return roles
+}
+
+// GrantRole - выдаёт роль пользователю; повторная выдача - no-op, аудит пишется в той же транзакции
+func (r *Repository) GrantRole(ctx context.Context, adminID, userID int64, role model.Role) error {
+	return r.changeRole(ctx, adminID, userID, role, model.AdminActionGrantRole,
+		`INSERT INTO user_roles (user_id, role) VALUES ($1, $2) ON CONFLICT DO NOTHING`)
+}
+
+// RevokeRole - отзывает роль и все сессии пользователя: выданные токены с этой ролью перестают приниматься
+func (r *Repository) RevokeRole(ctx context.Context, adminID, userID int64, role model.Role) error {
+	return r.changeRole(ctx, adminID, userID, role, model.AdminActionRevokeRole,
+		`DELETE FROM user_roles WHERE user_id = $1 AND role = $2`)
+}
+
+func (r *Repository) changeRole(ctx context.Context, adminID, userID int64, role model.Role, action model.AdminAction, query string) error {
+	return r.executeWithRetryConnection(ctx, func(ctx context.Context, db *sql.DB) error {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		var exists bool
+		err = tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM users WHERE id = $1)`, userID).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return model.ErrUserNotFound
+		}
+
+		result, err := tx.ExecContext(ctx, query, userID, role)
+		if err != nil {
+			return err
+		}
+
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		// роли читаются из access-токена: без отзыва сессий снятая роль действовала бы до его истечения
+		if action == model.AdminActionRevokeRole && affected > 0 {
+			querySessions := `UPDATE sessions SET revoked_at = now() WHERE user_id = $1 AND revoked_at IS NULL`
+			if _, err = tx.ExecContext(ctx, querySessions, userID); err != nil {
+				return err
+			}
+		}
+
+		err = insertAdminAudit(ctx, tx, model.AdminAuditEntry{
+			AdminID: adminID,
+			Action:  action,
+			UserID:  userID,
+			Details: string(role),
+		})
+		if err != nil {
+			return err
+		}
+
+		return tx.Commit()
+	})
+}
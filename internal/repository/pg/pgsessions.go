@@ -0,0 +1,124 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/ibeloyar/gophermart/internal/model"
+)
+
+func (r *Repository) CreateSession(ctx context.Context, session model.Session) error {
+	return r.executeWithRetryConnection(ctx, func(ctx context.Context, db *sql.DB) error {
+		query := `INSERT INTO sessions (id, user_id, refresh_token_hash, expires_at) VALUES ($1, $2, $3, $4)`
+
+		_, err := db.ExecContext(ctx, query, session.ID, session.UserID, session.RefreshTokenHash, session.ExpiresAt)
+
+		return err
+	})
+}
+
+func (r *Repository) GetSessionByID(ctx context.Context, id string) (*model.Session, error) {
+	var session model.Session
+
+	err := r.executeWithRetryConnection(ctx, func(ctx context.Context, db *sql.DB) error {
+		query := `SELECT id, user_id, refresh_token_hash, expires_at, revoked_at, created_at
+			FROM sessions WHERE id = $1`
+
+		row := db.QueryRowContext(ctx, query, id)
+
+		return row.Scan(&session.ID, &session.UserID, &session.RefreshTokenHash,
+			&session.ExpiresAt, &session.RevokedAt, &session.CreatedAt)
+	})
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, model.ErrSessionNotFound
+		}
+		return nil, err
+	}
+
+	return &session, nil
+}
+
+// RotateSession - заменяет refresh-токен сессии; срабатывает только если
+// сессия активна и предъявлен текущий токен (защита от гонки двух refresh).
+// Хеш прежнего токена сохраняется, чтобы распознать его повторное предъявление.
+func (r *Repository) RotateSession(ctx context.Context, id, oldHash, newHash string, expiresAt time.Time) error {
+	return r.executeWithRetryConnection(ctx, func(ctx context.Context, db *sql.DB) error {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		query := `UPDATE sessions SET refresh_token_hash = $1, expires_at = $2
+			WHERE id = $3 AND refresh_token_hash = $4 AND revoked_at IS NULL AND expires_at > now()`
+
+		result, err := tx.ExecContext(ctx, query, newHash, expiresAt, id, oldHash)
+		if err != nil {
+			return err
+		}
+
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if affected == 0 {
+			return model.ErrSessionNotFound
+		}
+
+		queryRotated := `INSERT INTO session_rotated_tokens (session_id, token_hash) VALUES ($1, $2)
+			ON CONFLICT DO NOTHING`
+		if _, err = tx.ExecContext(ctx, queryRotated, id, oldHash); err != nil {
+			return err
+		}
+
+		return tx.Commit()
+	})
+}
+
+// IsRefreshTokenRotated - предъявлен ли refresh-токен, который сессия уже ротировала
+func (r *Repository) IsRefreshTokenRotated(ctx context.Context, id, hash string) (bool, error) {
+	var rotated bool
+
+	err := r.executeWithRetryConnection(ctx, func(ctx context.Context, db *sql.DB) error {
+		query := `SELECT EXISTS (SELECT 1 FROM session_rotated_tokens WHERE session_id = $1 AND token_hash = $2)`
+
+		return db.QueryRowContext(ctx, query, id, hash).Scan(&rotated)
+	})
+
+	return rotated, err
+}
+
+func (r *Repository) RevokeSession(ctx context.Context, id string) error {
+	return r.executeWithRetryConnection(ctx, func(ctx context.Context, db *sql.DB) error {
+		query := `UPDATE sessions SET revoked_at = now() WHERE id = $1 AND revoked_at IS NULL`
+
+		_, err := db.ExecContext(ctx, query, id)
+
+		return err
+	})
+}
+
+// IsSessionRevoked - сессия считается отозванной, если её нет, она отозвана или истекла
+func (r *Repository) IsSessionRevoked(ctx context.Context, id string) (bool, error) {
+	var revoked bool
+
+	err := r.executeWithRetryConnection(ctx, func(ctx context.Context, db *sql.DB) error {
+		query := `SELECT revoked_at IS NOT NULL OR expires_at <= now() FROM sessions WHERE id = $1`
+
+		return db.QueryRowContext(ctx, query, id).Scan(&revoked)
+	})
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return true, nil
+		}
+		return true, err
+	}
+
+	return revoked, nil
+}
@@ -0,0 +1,110 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ibeloyar/gophermart/internal/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRepository_GetSessionByID_NotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := &Repository{db: db, classifier: NewPostgresErrorClassifier()}
+
+	mock.ExpectQuery(`SELECT id, user_id, refresh_token_hash, expires_at, revoked_at, created_at FROM sessions WHERE id = \$1`).
+		WithArgs("session-1").
+		WillReturnError(sql.ErrNoRows)
+
+	session, err := repo.GetSessionByID(context.Background(), "session-1")
+
+	assert.Nil(t, session)
+	assert.ErrorIs(t, err, model.ErrSessionNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_RotateSession_Stale(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := &Repository{db: db, classifier: NewPostgresErrorClassifier()}
+	expiresAt := time.Now().Add(time.Hour)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE sessions SET refresh_token_hash = \$1, expires_at = \$2 WHERE id = \$3 AND refresh_token_hash = \$4`).
+		WithArgs("new-hash", expiresAt, "session-1", "old-hash").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	err = repo.RotateSession(context.Background(), "session-1", "old-hash", "new-hash", expiresAt)
+
+	assert.ErrorIs(t, err, model.ErrSessionNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_RotateSession_KeepsOldHash(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := &Repository{db: db, classifier: NewPostgresErrorClassifier()}
+	expiresAt := time.Now().Add(time.Hour)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE sessions SET refresh_token_hash = \$1`).
+		WithArgs("new-hash", expiresAt, "session-1", "old-hash").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO session_rotated_tokens \(session_id, token_hash\) VALUES \(\$1, \$2\)`).
+		WithArgs("session-1", "old-hash").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err = repo.RotateSession(context.Background(), "session-1", "old-hash", "new-hash", expiresAt)
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_IsSessionRevoked(t *testing.T) {
+	tests := []struct {
+		name    string
+		rows    *sqlmock.Rows
+		err     error
+		revoked bool
+	}{
+		{"active", sqlmock.NewRows([]string{"revoked"}).AddRow(false), nil, false},
+		{"revoked", sqlmock.NewRows([]string{"revoked"}).AddRow(true), nil, true},
+		{"unknown", nil, sql.ErrNoRows, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer db.Close()
+
+			repo := &Repository{db: db, classifier: NewPostgresErrorClassifier()}
+
+			query := mock.ExpectQuery(`SELECT revoked_at IS NOT NULL OR expires_at <= now\(\) FROM sessions WHERE id = \$1`).
+				WithArgs("session-1")
+			if tt.err != nil {
+				query.WillReturnError(tt.err)
+			} else {
+				query.WillReturnRows(tt.rows)
+			}
+
+			revoked, err := repo.IsSessionRevoked(context.Background(), "session-1")
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.revoked, revoked)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
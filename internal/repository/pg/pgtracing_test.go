@@ -0,0 +1,14 @@
+package pg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSQLOperation(t *testing.T) {
+	assert.Equal(t, "SELECT", sqlOperation("select status FROM orders WHERE number = $1 FOR UPDATE"))
+	assert.Equal(t, "INSERT", sqlOperation("\n\t\t\tINSERT INTO balance (user_id) VALUES ($1)"))
+	assert.Equal(t, "BEGIN", sqlOperation("begin"))
+	assert.Equal(t, "SQL", sqlOperation("  "))
+}
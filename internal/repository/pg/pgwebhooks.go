@@ -0,0 +1,241 @@
+package pg
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/ibeloyar/gophermart/internal/model"
+	"github.com/lib/pq"
+)
+
+// enqueueWebhookEvent - пишет событие в outbox для активного endpoint'а, подписанного на eventType.
+// События не привязаны к магазину, поэтому активный endpoint один - sink оператора (000022).
+// Вызывается внутри транзакции изменения баланса: событие появится только вместе с ним.
+func enqueueWebhookEvent(ctx context.Context, tx *sql.Tx, eventType model.WebhookEventType, data any) error {
+	eventID, err := newWebhookEventID()
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(model.WebhookEvent{
+		ID:        eventID,
+		Type:      eventType,
+		CreatedAt: time.Now().UTC().Format(time.RFC3339Nano),
+		Data:      data,
+	})
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO webhook_outbox (endpoint_id, event_id, event_type, payload)
+		SELECT id, $1, $2, $3 FROM webhook_endpoints WHERE active AND $2 = ANY(event_types)`
+	_, err = tx.ExecContext(ctx, query, eventID, eventType, string(payload))
+
+	return err
+}
+
+func newWebhookEventID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// CreateWebhookEndpoint - регистрирует sink оператора; второй активный endpoint отклоняется
+func (r *Repository) CreateWebhookEndpoint(ctx context.Context, endpoint model.WebhookEndpoint) (*model.WebhookEndpoint, error) {
+	created := endpoint
+	created.Active = true
+
+	err := r.executeWithRetryConnection(ctx, func(ctx context.Context, db *sql.DB) error {
+		query := `INSERT INTO webhook_endpoints (tenant, url, secret, event_types) VALUES ($1, $2, $3, $4)
+			RETURNING id, created_at`
+
+		err := db.QueryRowContext(ctx, query, endpoint.Tenant, endpoint.URL, endpoint.Secret, webhookEventTypes(endpoint.EventTypes)).
+			Scan(&created.ID, &created.CreatedAt)
+		if hasErrorCode(err, ErrIsExistCode) {
+			return model.ErrWebhookSinkExists
+		}
+
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &created, nil
+}
+
+// GetWebhookEndpoints - endpoint'ы магазина; пустой tenant - все магазины
+func (r *Repository) GetWebhookEndpoints(ctx context.Context, tenant string) ([]model.WebhookEndpoint, error) {
+	var result []model.WebhookEndpoint
+
+	err := r.executeWithRetryConnection(ctx, func(ctx context.Context, db *sql.DB) error {
+		result = make([]model.WebhookEndpoint, 0)
+
+		query := `SELECT id, tenant, url, secret, event_types, active, created_at FROM webhook_endpoints
+			WHERE $1 = '' OR tenant = $1
+			ORDER BY id`
+
+		rows, err := db.QueryContext(ctx, query, tenant)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var (
+				endpoint   model.WebhookEndpoint
+				eventTypes pq.StringArray
+			)
+
+			err = rows.Scan(&endpoint.ID, &endpoint.Tenant, &endpoint.URL, &endpoint.Secret, &eventTypes, &endpoint.Active, &endpoint.CreatedAt)
+			if err != nil {
+				return err
+			}
+
+			for _, eventType := range eventTypes {
+				endpoint.EventTypes = append(endpoint.EventTypes, model.WebhookEventType(eventType))
+			}
+
+			result = append(result, endpoint)
+		}
+
+		return rows.Err()
+	})
+
+	return result, err
+}
+
+// DisableWebhookEndpoint - отключает endpoint; новые события на него не пишутся
+func (r *Repository) DisableWebhookEndpoint(ctx context.Context, id int64) error {
+	return r.executeWithRetryConnection(ctx, func(ctx context.Context, db *sql.DB) error {
+		result, err := db.ExecContext(ctx, `UPDATE webhook_endpoints SET active = FALSE WHERE id = $1`, id)
+		if err != nil {
+			return err
+		}
+
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if affected == 0 {
+			return model.ErrWebhookEndpointNotFound
+		}
+
+		return nil
+	})
+}
+
+// RequeueDeadWebhookDeliveries - возвращает доставки endpoint'а из dead-letter в очередь
+func (r *Repository) RequeueDeadWebhookDeliveries(ctx context.Context, endpointID int64) (int64, error) {
+	var requeued int64
+
+	err := r.executeWithRetryConnection(ctx, func(ctx context.Context, db *sql.DB) error {
+		query := `UPDATE webhook_outbox SET status = $1, attempts = 0, next_attempt_at = now()
+			WHERE endpoint_id = $2 AND status = $3`
+
+		result, err := db.ExecContext(ctx, query, model.WebhookDeliveryPending, endpointID, model.WebhookDeliveryDead)
+		if err != nil {
+			return err
+		}
+
+		requeued, err = result.RowsAffected()
+
+		return err
+	})
+
+	return requeued, err
+}
+
+// ClaimWebhookDeliveries - забирает готовые к отправке доставки и продлевает их на lease,
+// как и ClaimAccrualJobs, чтобы несколько реплик не отправили одно событие одновременно
+func (r *Repository) ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]model.WebhookDelivery, error) {
+	var result []model.WebhookDelivery
+
+	err := r.executeWithRetryConnection(ctx, func(ctx context.Context, db *sql.DB) error {
+		result = make([]model.WebhookDelivery, 0, limit)
+
+		query := `WITH claimed AS (
+				SELECT id FROM webhook_outbox
+				WHERE status = $1 AND next_attempt_at <= now()
+				ORDER BY next_attempt_at
+				LIMIT $2
+				FOR UPDATE SKIP LOCKED
+			)
+			UPDATE webhook_outbox o SET next_attempt_at = now() + make_interval(secs => $3)
+			FROM claimed c, webhook_endpoints e
+			WHERE o.id = c.id AND e.id = o.endpoint_id
+			RETURNING o.id, o.endpoint_id, e.url, e.secret, o.event_id, o.event_type, o.payload, o.attempts`
+
+		rows, err := db.QueryContext(ctx, query, model.WebhookDeliveryPending, limit, lease.Seconds())
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var (
+				delivery model.WebhookDelivery
+				payload  string
+			)
+
+			err = rows.Scan(&delivery.ID, &delivery.EndpointID, &delivery.URL, &delivery.Secret,
+				&delivery.EventID, &delivery.EventType, &payload, &delivery.Attempts)
+			if err != nil {
+				return err
+			}
+
+			delivery.Payload = []byte(payload)
+			result = append(result, delivery)
+		}
+
+		return rows.Err()
+	})
+
+	return result, err
+}
+
+func (r *Repository) MarkWebhookDelivered(ctx context.Context, id int64) error {
+	return r.executeWithRetryConnection(ctx, func(ctx context.Context, db *sql.DB) error {
+		query := `UPDATE webhook_outbox SET status = $1, attempts = attempts + 1, last_error = NULL, delivered_at = now()
+			WHERE id = $2`
+
+		_, err := db.ExecContext(ctx, query, model.WebhookDeliveryDelivered, id)
+
+		return err
+	})
+}
+
+// FailWebhookDelivery - фиксирует неудачную попытку: переносит доставку на nextAttemptAt
+// или, если dead = true, переводит её в dead-letter
+func (r *Repository) FailWebhookDelivery(ctx context.Context, id int64, nextAttemptAt time.Time, dead bool, lastErr error) error {
+	status := model.WebhookDeliveryPending
+	if dead {
+		status = model.WebhookDeliveryDead
+	}
+
+	return r.executeWithRetryConnection(ctx, func(ctx context.Context, db *sql.DB) error {
+		query := `UPDATE webhook_outbox SET status = $1, attempts = attempts + 1, next_attempt_at = $2, last_error = $3
+			WHERE id = $4`
+
+		_, err := db.ExecContext(ctx, query, status, nextAttemptAt, lastErr.Error(), id)
+
+		return err
+	})
+}
+
+func webhookEventTypes(eventTypes []model.WebhookEventType) pq.StringArray {
+	result := make(pq.StringArray, 0, len(eventTypes))
+	for _, eventType := range eventTypes {
+		result = append(result, string(eventType))
+	}
+
+	return result
+}
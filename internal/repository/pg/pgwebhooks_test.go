@@ -0,0 +1,126 @@
+package pg
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ibeloyar/gophermart/internal/model"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRepository_CreateWebhookEndpoint(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := &Repository{db: db, classifier: NewPostgresErrorClassifier()}
+	createdAt := time.Now()
+
+	mock.ExpectQuery(`INSERT INTO webhook_endpoints \(tenant, url, secret, event_types\) VALUES \(\$1, \$2, \$3, \$4\)`).
+		WithArgs("shop", "https://shop.example/hook", "s3cret", pq.StringArray{"order.accrued"}).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(int64(7), createdAt))
+
+	endpoint, err := repo.CreateWebhookEndpoint(context.Background(), model.WebhookEndpoint{
+		Tenant:     "shop",
+		URL:        "https://shop.example/hook",
+		Secret:     "s3cret",
+		EventTypes: []model.WebhookEventType{model.WebhookEventOrderAccrued},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7), endpoint.ID)
+	assert.True(t, endpoint.Active)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_CreateWebhookEndpoint_SinkExists(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := &Repository{db: db, classifier: NewPostgresErrorClassifier()}
+
+	// уникальный индекс по активным endpoint'ам: sink оператора один
+	mock.ExpectQuery(`INSERT INTO webhook_endpoints`).
+		WillReturnError(&pgconn.PgError{Code: ErrIsExistCode})
+
+	_, err = repo.CreateWebhookEndpoint(context.Background(), model.WebhookEndpoint{
+		Tenant:     "operator",
+		URL:        "https://loyalty.example/hook",
+		Secret:     "s3cret",
+		EventTypes: []model.WebhookEventType{model.WebhookEventOrderAccrued},
+	})
+
+	assert.ErrorIs(t, err, model.ErrWebhookSinkExists)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_DisableWebhookEndpoint_NotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := &Repository{db: db, classifier: NewPostgresErrorClassifier()}
+
+	mock.ExpectExec(`UPDATE webhook_endpoints SET active = FALSE WHERE id = \$1`).
+		WithArgs(int64(7)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err = repo.DisableWebhookEndpoint(context.Background(), 7)
+
+	assert.ErrorIs(t, err, model.ErrWebhookEndpointNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_ClaimWebhookDeliveries(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := &Repository{db: db, classifier: NewPostgresErrorClassifier()}
+
+	rows := sqlmock.NewRows([]string{"id", "endpoint_id", "url", "secret", "event_id", "event_type", "payload", "attempts"}).
+		AddRow(int64(1), int64(7), "https://shop.example/hook", "s3cret", "abc", "order.accrued", `{"id":"abc"}`, 2)
+
+	mock.ExpectQuery(`WITH claimed AS \(.*FOR UPDATE SKIP LOCKED.*\) UPDATE webhook_outbox`).
+		WithArgs(model.WebhookDeliveryPending, 10, float64(60)).
+		WillReturnRows(rows)
+
+	deliveries, err := repo.ClaimWebhookDeliveries(context.Background(), 10, time.Minute)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []model.WebhookDelivery{{
+		ID:         1,
+		EndpointID: 7,
+		URL:        "https://shop.example/hook",
+		Secret:     "s3cret",
+		EventID:    "abc",
+		EventType:  model.WebhookEventOrderAccrued,
+		Payload:    []byte(`{"id":"abc"}`),
+		Attempts:   2,
+	}}, deliveries)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_FailWebhookDelivery_Dead(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := &Repository{db: db, classifier: NewPostgresErrorClassifier()}
+	nextAttemptAt := time.Now()
+
+	mock.ExpectExec(`UPDATE webhook_outbox SET status = \$1, attempts = attempts \+ 1, next_attempt_at = \$2, last_error = \$3`).
+		WithArgs(model.WebhookDeliveryDead, nextAttemptAt, "receiver returned 500", int64(1)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err = repo.FailWebhookDelivery(context.Background(), 1, nextAttemptAt, true, errors.New("receiver returned 500"))
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,278 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/ibeloyar/gophermart/internal/model"
+	"github.com/ibeloyar/gophermart/internal/tracing"
+)
+
+const maxAdminReasonLen = 500
+
+// AdminSearchUsers - поиск пользователей по подстроке логина
+func (s *Service) AdminSearchUsers(ctx context.Context, adminID int64, login string) ([]model.UserSummary, *model.APIError) {
+	ctx, span := tracing.Start(ctx, "Service.AdminSearchUsers")
+	defer span.End()
+
+	login = strings.TrimSpace(login)
+	if login == "" {
+		return nil, &model.APIError{
+			Code:    http.StatusBadRequest,
+			Message: model.ErrLoginQueryRequiredMessage,
+		}
+	}
+
+	if apiErr := s.audit(ctx, model.AdminAuditEntry{AdminID: adminID, Action: model.AdminActionSearchUsers, Details: login}); apiErr != nil {
+		return nil, apiErr
+	}
+
+	users, err := s.storage.SearchUsersByLogin(ctx, login)
+	if err != nil {
+		return nil, &model.APIError{
+			Code:    http.StatusInternalServerError,
+			Message: model.ErrInternalServerMessage,
+		}
+	}
+
+	return users, nil
+}
+
+// AdminGetUserOrders - заказы пользователя; в отличие от GetOrders пустой список - это 200
+func (s *Service) AdminGetUserOrders(ctx context.Context, adminID, userID int64, filter model.ListFilter) (*model.OrdersPage, *model.APIError) {
+	ctx, span := tracing.Start(ctx, "Service.AdminGetUserOrders")
+	defer span.End()
+
+	filter, err := normalizeListFilter(filter)
+	if err != nil {
+		return nil, &model.APIError{
+			Code:    http.StatusBadRequest,
+			Message: model.ErrInvalidListFilterMessage,
+		}
+	}
+
+	if apiErr := s.audit(ctx, model.AdminAuditEntry{AdminID: adminID, Action: model.AdminActionViewOrders, UserID: userID}); apiErr != nil {
+		return nil, apiErr
+	}
+
+	page, err := s.storage.GetOrdersByUserID(ctx, userID, filter)
+	if err != nil {
+		return nil, &model.APIError{
+			Code:    http.StatusInternalServerError,
+			Message: model.ErrInternalServerMessage,
+		}
+	}
+
+	return page, nil
+}
+
+// AdminGetUserLedger - журнал баланса пользователя: начисления, списания и корректировки
+func (s *Service) AdminGetUserLedger(ctx context.Context, adminID, userID int64, filter model.ListFilter) (*model.LedgerPage, *model.APIError) {
+	ctx, span := tracing.Start(ctx, "Service.AdminGetUserLedger")
+	defer span.End()
+
+	filter, err := normalizeListFilter(filter)
+	if err != nil || len(filter.Statuses) > 0 {
+		return nil, &model.APIError{
+			Code:    http.StatusBadRequest,
+			Message: model.ErrInvalidListFilterMessage,
+		}
+	}
+
+	if apiErr := s.audit(ctx, model.AdminAuditEntry{AdminID: adminID, Action: model.AdminActionViewLedger, UserID: userID}); apiErr != nil {
+		return nil, apiErr
+	}
+
+	page, err := s.storage.GetLedgerByUserID(ctx, userID, filter)
+	if err != nil {
+		return nil, &model.APIError{
+			Code:    http.StatusInternalServerError,
+			Message: model.ErrInternalServerMessage,
+		}
+	}
+
+	return page, nil
+}
+
+// AdminAdjustBalance - ручное начисление (amount > 0) или списание (amount < 0) с обязательной причиной
+func (s *Service) AdminAdjustBalance(ctx context.Context, adminID, userID int64, input model.BalanceAdjustmentDTO) (*model.LedgerEntry, *model.APIError) {
+	ctx, span := tracing.Start(ctx, "Service.AdminAdjustBalance")
+	defer span.End()
+
+	reason, apiErr := validateAdminReason(input.Reason)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	input.Reason = reason
+
+	if input.Amount == 0 {
+		return nil, &model.APIError{
+			Code:    http.StatusUnprocessableEntity,
+			Message: model.ErrAdjustmentAmountMessage,
+		}
+	}
+
+	entry, err := s.storage.AdjustBalance(ctx, adminID, userID, input)
+	if err != nil {
+		if errors.Is(err, model.ErrUserNotFound) {
+			return nil, &model.APIError{
+				Code:    http.StatusNotFound,
+				Message: model.ErrUserNotFoundMessage,
+			}
+		}
+		if errors.Is(err, model.ErrInsufficientFunds) {
+			return nil, &model.APIError{
+				Code:    http.StatusPaymentRequired,
+				Message: model.ErrInsufficientFundsMessage,
+			}
+		}
+		return nil, &model.APIError{
+			Code:    http.StatusInternalServerError,
+			Message: model.ErrInternalServerMessage,
+		}
+	}
+
+	return entry, nil
+}
+
+// AdminReprocessOrder - возвращает заказ в NEW, чтобы система расчёта была опрошена заново; обработанные заказы не меняются (409)
+func (s *Service) AdminReprocessOrder(ctx context.Context, adminID int64, number, reason string) *model.APIError {
+	ctx, span := tracing.Start(ctx, "Service.AdminReprocessOrder")
+	defer span.End()
+
+	reason, apiErr := validateAdminReason(reason)
+	if apiErr != nil {
+		return apiErr
+	}
+
+	event, err := s.storage.ResetOrderToNew(ctx, adminID, number, reason)
+	if err != nil {
+		return orderActionError(err)
+	}
+
+	s.publishOrderEvent(event)
+
+	return nil
+}
+
+// AdminInvalidateOrder - помечает заказ INVALID; обработанные заказы не меняются (409)
+func (s *Service) AdminInvalidateOrder(ctx context.Context, adminID int64, number, reason string) *model.APIError {
+	ctx, span := tracing.Start(ctx, "Service.AdminInvalidateOrder")
+	defer span.End()
+
+	reason, apiErr := validateAdminReason(reason)
+	if apiErr != nil {
+		return apiErr
+	}
+
+	event, err := s.storage.InvalidateOrder(ctx, adminID, number, reason)
+	if err != nil {
+		return orderActionError(err)
+	}
+
+	s.publishOrderEvent(event)
+
+	return nil
+}
+
+// AdminGrantRole - выдаёт роль; в токенах пользователя она появится после обновления access-токена
+func (s *Service) AdminGrantRole(ctx context.Context, adminID, userID int64, role model.Role) *model.APIError {
+	ctx, span := tracing.Start(ctx, "Service.AdminGrantRole")
+	defer span.End()
+
+	if !role.IsValid() {
+		return &model.APIError{
+			Code:    http.StatusBadRequest,
+			Message: model.ErrInvalidRoleMessage,
+		}
+	}
+
+	return roleActionError(s.storage.GrantRole(ctx, adminID, userID, role))
+}
+
+// AdminRevokeRole - отзывает роль; снять admin с самого себя нельзя, чтобы не остаться без администраторов
+func (s *Service) AdminRevokeRole(ctx context.Context, adminID, userID int64, role model.Role) *model.APIError {
+	ctx, span := tracing.Start(ctx, "Service.AdminRevokeRole")
+	defer span.End()
+
+	if !role.IsValid() {
+		return &model.APIError{
+			Code:    http.StatusBadRequest,
+			Message: model.ErrInvalidRoleMessage,
+		}
+	}
+
+	if adminID == userID && role == model.RoleAdmin {
+		return &model.APIError{
+			Code:    http.StatusConflict,
+			Message: model.ErrRevokeOwnAdminRoleMessage,
+		}
+	}
+
+	return roleActionError(s.storage.RevokeRole(ctx, adminID, userID, role))
+}
+
+// audit - запись аудита для действий на чтение; без неё действие не выполняется
+func (s *Service) audit(ctx context.Context, entry model.AdminAuditEntry) *model.APIError {
+	if err := s.storage.WriteAdminAudit(ctx, entry); err != nil {
+		return &model.APIError{
+			Code:    http.StatusInternalServerError,
+			Message: model.ErrInternalServerMessage,
+		}
+	}
+
+	return nil
+}
+
+func orderActionError(err error) *model.APIError {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, model.ErrOrderNotFound):
+		return &model.APIError{
+			Code:    http.StatusNotFound,
+			Message: model.ErrOrderNotFoundMessage,
+		}
+	case errors.Is(err, model.ErrOrderAlreadyProcessed):
+		return &model.APIError{
+			Code:    http.StatusConflict,
+			Message: model.ErrOrderAlreadyProcessedMessage,
+		}
+	default:
+		return &model.APIError{
+			Code:    http.StatusInternalServerError,
+			Message: model.ErrInternalServerMessage,
+		}
+	}
+}
+
+func roleActionError(err error) *model.APIError {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, model.ErrUserNotFound):
+		return &model.APIError{
+			Code:    http.StatusNotFound,
+			Message: model.ErrUserNotFoundMessage,
+		}
+	default:
+		return &model.APIError{
+			Code:    http.StatusInternalServerError,
+			Message: model.ErrInternalServerMessage,
+		}
+	}
+}
+
+func validateAdminReason(reason string) (string, *model.APIError) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" || len(reason) > maxAdminReasonLen {
+		return "", &model.APIError{
+			Code:    http.StatusBadRequest,
+			Message: model.ErrAdminReasonRequiredMessage,
+		}
+	}
+
+	return reason, nil
+}
@@ -0,0 +1,182 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/ibeloyar/gophermart/internal/model"
+	"github.com/ibeloyar/gophermart/internal/orderevents"
+	"github.com/ibeloyar/gophermart/pgk/auth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	mockPG "github.com/ibeloyar/gophermart/internal/repository/pg/mocks"
+)
+
+func TestService_AdminSearchUsers_WritesAudit(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := mockPG.NewMockStorageRepo(ctrl)
+	svc := New(mockStorage, 3, 1*time.Hour, 24*time.Hour, 24*time.Hour, auth.NewHMACKeySet("secret"), nil)
+
+	gomock.InOrder(
+		mockStorage.EXPECT().
+			WriteAdminAudit(gomock.Any(), model.AdminAuditEntry{AdminID: 1, Action: model.AdminActionSearchUsers, Details: "bob"}).
+			Return(nil),
+		mockStorage.EXPECT().
+			SearchUsersByLogin(gomock.Any(), "bob").
+			Return([]model.UserSummary{{ID: 7, Login: "bobby"}}, nil),
+	)
+
+	users, apiErr := svc.AdminSearchUsers(context.Background(), 1, " bob ")
+
+	assert.Nil(t, apiErr)
+	assert.Len(t, users, 1)
+}
+
+func TestService_AdminSearchUsers_AuditFailureBlocksAction(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := mockPG.NewMockStorageRepo(ctrl)
+	svc := New(mockStorage, 3, 1*time.Hour, 24*time.Hour, 24*time.Hour, auth.NewHMACKeySet("secret"), nil)
+
+	mockStorage.EXPECT().WriteAdminAudit(gomock.Any(), gomock.Any()).Return(errors.New("db down"))
+
+	_, apiErr := svc.AdminSearchUsers(context.Background(), 1, "bob")
+
+	require.NotNil(t, apiErr)
+	assert.Equal(t, http.StatusInternalServerError, apiErr.Code)
+}
+
+func TestService_AdminAdjustBalance(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      model.BalanceAdjustmentDTO
+		storageErr error
+		wantCode   int
+	}{
+		{"credit", model.BalanceAdjustmentDTO{Amount: 500, Reason: "goodwill"}, nil, 0},
+		{"missing reason", model.BalanceAdjustmentDTO{Amount: 500, Reason: "  "}, nil, http.StatusBadRequest},
+		{"zero amount", model.BalanceAdjustmentDTO{Amount: 0, Reason: "goodwill"}, nil, http.StatusUnprocessableEntity},
+		{"unknown user", model.BalanceAdjustmentDTO{Amount: 500, Reason: "goodwill"}, model.ErrUserNotFound, http.StatusNotFound},
+		{"overdraft", model.BalanceAdjustmentDTO{Amount: -500, Reason: "chargeback"}, model.ErrInsufficientFunds, http.StatusPaymentRequired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockStorage := mockPG.NewMockStorageRepo(ctrl)
+			svc := New(mockStorage, 3, 1*time.Hour, 24*time.Hour, 24*time.Hour, auth.NewHMACKeySet("secret"), nil)
+
+			if tt.wantCode == 0 || tt.storageErr != nil {
+				mockStorage.EXPECT().
+					AdjustBalance(gomock.Any(), int64(1), int64(7), tt.input).
+					Return(&model.LedgerEntry{ID: 3, Amount: tt.input.Amount}, tt.storageErr)
+			}
+
+			entry, apiErr := svc.AdminAdjustBalance(context.Background(), 1, 7, tt.input)
+
+			if tt.wantCode == 0 {
+				assert.Nil(t, apiErr)
+				assert.Equal(t, tt.input.Amount, entry.Amount)
+				return
+			}
+
+			require.NotNil(t, apiErr)
+			assert.Equal(t, tt.wantCode, apiErr.Code)
+		})
+	}
+}
+
+func TestService_AdminInvalidateOrder(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := mockPG.NewMockStorageRepo(ctrl)
+	svc := New(mockStorage, 3, 1*time.Hour, 24*time.Hour, 24*time.Hour, auth.NewHMACKeySet("secret"), nil)
+
+	mockStorage.EXPECT().InvalidateOrder(gomock.Any(), int64(1), "12345678903", "fraud").Return(nil, model.ErrOrderAlreadyProcessed)
+	mockStorage.EXPECT().InvalidateOrder(gomock.Any(), int64(1), "unknown", "fraud").Return(nil, model.ErrOrderNotFound)
+
+	apiErr := svc.AdminInvalidateOrder(context.Background(), 1, "12345678903", "fraud")
+	require.NotNil(t, apiErr)
+	assert.Equal(t, http.StatusConflict, apiErr.Code)
+
+	apiErr = svc.AdminInvalidateOrder(context.Background(), 1, "unknown", "fraud")
+	require.NotNil(t, apiErr)
+	assert.Equal(t, http.StatusNotFound, apiErr.Code)
+}
+
+func TestService_AdminReprocessOrder_PublishesEvent(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	hub := orderevents.NewHub()
+	mockStorage := mockPG.NewMockStorageRepo(ctrl)
+	svc := New(mockStorage, 3, 1*time.Hour, 24*time.Hour, 24*time.Hour, auth.NewHMACKeySet("secret"), hub)
+
+	sub := hub.Subscribe(7)
+	defer sub.Close()
+
+	event := &model.OrderEvent{ID: 42, UserID: 7, Number: "12345678903", Status: model.OrderStatusNew}
+	mockStorage.EXPECT().ResetOrderToNew(gomock.Any(), int64(1), "12345678903", "accrual was wrong").Return(event, nil)
+
+	apiErr := svc.AdminReprocessOrder(context.Background(), 1, "12345678903", "accrual was wrong")
+	require.Nil(t, apiErr)
+
+	assert.Equal(t, *event, <-sub.Events())
+}
+
+func TestService_AdminRevokeRole(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := mockPG.NewMockStorageRepo(ctrl)
+	svc := New(mockStorage, 3, 1*time.Hour, 24*time.Hour, 24*time.Hour, auth.NewHMACKeySet("secret"), nil)
+
+	apiErr := svc.AdminRevokeRole(context.Background(), 1, 7, model.Role("root"))
+	require.NotNil(t, apiErr)
+	assert.Equal(t, http.StatusBadRequest, apiErr.Code)
+
+	apiErr = svc.AdminRevokeRole(context.Background(), 1, 1, model.RoleAdmin)
+	require.NotNil(t, apiErr)
+	assert.Equal(t, http.StatusConflict, apiErr.Code)
+
+	mockStorage.EXPECT().RevokeRole(gomock.Any(), int64(1), int64(7), model.RoleSupport).Return(model.ErrUserNotFound)
+
+	apiErr = svc.AdminRevokeRole(context.Background(), 1, 7, model.RoleSupport)
+	require.NotNil(t, apiErr)
+	assert.Equal(t, http.StatusNotFound, apiErr.Code)
+}
+
+func TestService_Login_RolesClaim(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := mockPG.NewMockStorageRepo(ctrl)
+	svc := New(mockStorage, 3, 1*time.Hour, 24*time.Hour, 24*time.Hour, auth.NewHMACKeySet("secret"), nil)
+
+	hash, err := svc.hasher.Hash("password")
+	require.NoError(t, err)
+
+	mockStorage.EXPECT().GetUserByLogin(gomock.Any(), "admin").Return(&model.User{ID: 1, Login: "admin", Password: hash, Roles: []model.Role{model.RoleAdmin, model.RoleUser}})
+	mockStorage.EXPECT().GetLoginLockedUntil(gomock.Any(), "admin").Return(nil, nil)
+	mockStorage.EXPECT().RecordLoginAttempt(gomock.Any(), gomock.Any()).Return(nil)
+	mockStorage.EXPECT().CreateSession(gomock.Any(), gomock.Any()).Return(nil)
+
+	tokens, apiErr := svc.Login(context.Background(), model.LoginDTO{Login: "admin", Password: "password"}, model.ClientInfo{})
+	require.Nil(t, apiErr)
+
+	info, err := auth.VerifyJWTBearerToken[model.TokenInfo](tokens.AccessToken, svc.tokenKeys)
+	require.NoError(t, err)
+	assert.True(t, info.HasAnyRole(model.RoleAdmin))
+	assert.False(t, info.HasAnyRole(model.RoleMerchant))
+}
@@ -0,0 +1,108 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/ibeloyar/gophermart/internal/model"
+)
+
+const maxIdempotencyKeyLength = 255
+
+// withIdempotencyKey - выполняет fn не более одного раза для пары (userID, key).
+// Повтор с тем же телом получает сохранённый ответ, с другим телом - 422.
+func (s *Service) withIdempotencyKey(ctx context.Context, userID int64, key string, request any, fn func() *model.APIError) *model.APIError {
+	if len(key) > maxIdempotencyKeyLength {
+		return &model.APIError{
+			Code:    http.StatusBadRequest,
+			Message: model.ErrIdempotencyKeyInvalidMessage,
+		}
+	}
+
+	requestHash, err := hashRequest(request)
+	if err != nil {
+		return &model.APIError{
+			Code:    http.StatusInternalServerError,
+			Message: model.ErrInternalServerMessage,
+		}
+	}
+
+	record, reserved, err := s.storage.ReserveIdempotencyKey(ctx, model.IdempotencyRecord{
+		UserID:      userID,
+		Key:         key,
+		RequestHash: requestHash,
+		ExpiresAt:   time.Now().Add(s.idemKeyTTL),
+	})
+	if err != nil {
+		return &model.APIError{
+			Code:    http.StatusInternalServerError,
+			Message: model.ErrInternalServerMessage,
+		}
+	}
+
+	if !reserved {
+		return replayIdempotentResponse(record, requestHash)
+	}
+
+	apiErr := fn()
+
+	// результат фиксируется, даже если клиент уже отключился: иначе ключ зависнет до истечения TTL
+	ctx = context.WithoutCancel(ctx)
+
+	// внутренние ошибки не фиксируем: клиент должен иметь возможность повторить запрос
+	if apiErr != nil && apiErr.Code >= http.StatusInternalServerError {
+		_ = s.storage.ReleaseIdempotencyKey(ctx, userID, key)
+		return apiErr
+	}
+
+	responseCode, responseBody := http.StatusOK, ""
+	if apiErr != nil {
+		responseCode, responseBody = apiErr.Code, apiErr.Message
+	}
+
+	// успешное списание уже завершило ключ в своей транзакции; здесь фиксируются отказы.
+	// При ошибке сохранения ключ останется "в процессе" до истечения аренды в репозитории
+	_ = s.storage.CompleteIdempotencyKey(ctx, userID, key, responseCode, responseBody)
+
+	return apiErr
+}
+
+func replayIdempotentResponse(record *model.IdempotencyRecord, requestHash string) *model.APIError {
+	if record.RequestHash != requestHash {
+		return &model.APIError{
+			Code:    http.StatusUnprocessableEntity,
+			Message: model.ErrIdempotencyKeyReusedMessage,
+		}
+	}
+
+	if record.ResponseCode == 0 {
+		return &model.APIError{
+			Code:    http.StatusConflict,
+			Message: model.ErrIdempotencyKeyPendingMessage,
+		}
+	}
+
+	if record.ResponseCode == http.StatusOK {
+		return nil
+	}
+
+	return &model.APIError{
+		Code:    record.ResponseCode,
+		Message: record.ResponseBody,
+	}
+}
+
+func hashRequest(request any) (string, error) {
+	data, err := json.Marshal(request)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+
+	return hex.EncodeToString(sum[:]), nil
+}
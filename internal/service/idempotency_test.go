@@ -0,0 +1,151 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/ibeloyar/gophermart/internal/model"
+	"github.com/ibeloyar/gophermart/pgk/auth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	mockPG "github.com/ibeloyar/gophermart/internal/repository/pg/mocks"
+)
+
+func TestService_SetWithdraw_IdempotencyKey_FirstRequest(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := mockPG.NewMockStorageRepo(ctrl)
+	svc := New(mockStorage, 3, 1*time.Hour, 24*time.Hour, 24*time.Hour, auth.NewHMACKeySet("secret"), nil)
+
+	input := model.SetWithdrawDTO{Order: validOrderNumber, Sum: 1050}
+
+	mockStorage.EXPECT().
+		ReserveIdempotencyKey(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, record model.IdempotencyRecord) (*model.IdempotencyRecord, bool, error) {
+			assert.Equal(t, int64(123), record.UserID)
+			assert.Equal(t, "key-1", record.Key)
+			assert.NotEmpty(t, record.RequestHash)
+			assert.WithinDuration(t, time.Now().Add(24*time.Hour), record.ExpiresAt, time.Minute)
+			return &record, true, nil
+		}).
+		Times(1)
+	mockStorage.EXPECT().SetWithdraw(gomock.Any(), int64(123), input, "key-1").Return(nil).Times(1)
+	mockStorage.EXPECT().CompleteIdempotencyKey(gomock.Any(), int64(123), "key-1", http.StatusOK, "").Return(nil).Times(1)
+
+	apiErr := svc.SetWithdraw(context.Background(), 123, input, "key-1")
+
+	assert.Nil(t, apiErr)
+}
+
+func TestService_SetWithdraw_IdempotencyKey_StoresClientError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := mockPG.NewMockStorageRepo(ctrl)
+	svc := New(mockStorage, 3, 1*time.Hour, 24*time.Hour, 24*time.Hour, auth.NewHMACKeySet("secret"), nil)
+
+	input := model.SetWithdrawDTO{Order: validOrderNumber, Sum: 1050}
+
+	mockStorage.EXPECT().
+		ReserveIdempotencyKey(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, record model.IdempotencyRecord) (*model.IdempotencyRecord, bool, error) {
+			return &record, true, nil
+		})
+	mockStorage.EXPECT().SetWithdraw(gomock.Any(), int64(123), input, "key-1").Return(model.ErrInsufficientFunds)
+	mockStorage.EXPECT().
+		CompleteIdempotencyKey(gomock.Any(), int64(123), "key-1", http.StatusPaymentRequired, model.ErrInsufficientFundsMessage).
+		Return(nil)
+
+	apiErr := svc.SetWithdraw(context.Background(), 123, input, "key-1")
+
+	require.NotNil(t, apiErr)
+	assert.Equal(t, http.StatusPaymentRequired, apiErr.Code)
+}
+
+func TestService_SetWithdraw_IdempotencyKey_ReleasesOnInternalError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := mockPG.NewMockStorageRepo(ctrl)
+	svc := New(mockStorage, 3, 1*time.Hour, 24*time.Hour, 24*time.Hour, auth.NewHMACKeySet("secret"), nil)
+
+	input := model.SetWithdrawDTO{Order: validOrderNumber, Sum: 1050}
+
+	mockStorage.EXPECT().
+		ReserveIdempotencyKey(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, record model.IdempotencyRecord) (*model.IdempotencyRecord, bool, error) {
+			return &record, true, nil
+		})
+	mockStorage.EXPECT().SetWithdraw(gomock.Any(), int64(123), input, "key-1").Return(errors.New("db error"))
+	mockStorage.EXPECT().ReleaseIdempotencyKey(gomock.Any(), int64(123), "key-1").Return(nil)
+
+	apiErr := svc.SetWithdraw(context.Background(), 123, input, "key-1")
+
+	require.NotNil(t, apiErr)
+	assert.Equal(t, http.StatusInternalServerError, apiErr.Code)
+}
+
+func TestService_SetWithdraw_IdempotencyKey_Replay(t *testing.T) {
+	input := model.SetWithdrawDTO{Order: validOrderNumber, Sum: 1050}
+	requestHash, err := hashRequest(input)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		existing model.IdempotencyRecord
+		wantCode int
+		wantMsg  string
+	}{
+		{
+			name:     "stored success",
+			existing: model.IdempotencyRecord{RequestHash: requestHash, ResponseCode: http.StatusOK},
+		},
+		{
+			name:     "stored error",
+			existing: model.IdempotencyRecord{RequestHash: requestHash, ResponseCode: http.StatusPaymentRequired, ResponseBody: model.ErrInsufficientFundsMessage},
+			wantCode: http.StatusPaymentRequired,
+			wantMsg:  model.ErrInsufficientFundsMessage,
+		},
+		{
+			name:     "in progress",
+			existing: model.IdempotencyRecord{RequestHash: requestHash},
+			wantCode: http.StatusConflict,
+			wantMsg:  model.ErrIdempotencyKeyPendingMessage,
+		},
+		{
+			name:     "different body",
+			existing: model.IdempotencyRecord{RequestHash: "other", ResponseCode: http.StatusOK},
+			wantCode: http.StatusUnprocessableEntity,
+			wantMsg:  model.ErrIdempotencyKeyReusedMessage,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockStorage := mockPG.NewMockStorageRepo(ctrl)
+			svc := New(mockStorage, 3, 1*time.Hour, 24*time.Hour, 24*time.Hour, auth.NewHMACKeySet("secret"), nil)
+
+			existing := tt.existing
+			mockStorage.EXPECT().ReserveIdempotencyKey(gomock.Any(), gomock.Any()).Return(&existing, false, nil)
+
+			apiErr := svc.SetWithdraw(context.Background(), 123, input, "key-1")
+
+			if tt.wantCode == 0 {
+				assert.Nil(t, apiErr)
+				return
+			}
+			require.NotNil(t, apiErr)
+			assert.Equal(t, tt.wantCode, apiErr.Code)
+			assert.Equal(t, tt.wantMsg, apiErr.Message)
+		})
+	}
+}
@@ -5,6 +5,7 @@
 package service
 
 import (
+	context "context"
 	reflect "reflect"
 
 	gomock "github.com/golang/mock/gomock"
@@ -34,105 +35,353 @@ func (m *MockService) EXPECT() *MockServiceMockRecorder {
 	return m.recorder
 }
 
+// AdminAdjustBalance mocks base method.
+func (m *MockService) AdminAdjustBalance(ctx context.Context, adminID, userID int64, input model.BalanceAdjustmentDTO) (*model.LedgerEntry, *model.APIError) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AdminAdjustBalance", ctx, adminID, userID, input)
+	ret0, _ := ret[0].(*model.LedgerEntry)
+	ret1, _ := ret[1].(*model.APIError)
+	return ret0, ret1
+}
+
+// AdminAdjustBalance indicates an expected call of AdminAdjustBalance.
+func (mr *MockServiceMockRecorder) AdminAdjustBalance(ctx, adminID, userID, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AdminAdjustBalance", reflect.TypeOf((*MockService)(nil).AdminAdjustBalance), ctx, adminID, userID, input)
+}
+
+// AdminGetUserLedger mocks base method.
+func (m *MockService) AdminGetUserLedger(ctx context.Context, adminID, userID int64, filter model.ListFilter) (*model.LedgerPage, *model.APIError) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AdminGetUserLedger", ctx, adminID, userID, filter)
+	ret0, _ := ret[0].(*model.LedgerPage)
+	ret1, _ := ret[1].(*model.APIError)
+	return ret0, ret1
+}
+
+// AdminGetUserLedger indicates an expected call of AdminGetUserLedger.
+func (mr *MockServiceMockRecorder) AdminGetUserLedger(ctx, adminID, userID, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AdminGetUserLedger", reflect.TypeOf((*MockService)(nil).AdminGetUserLedger), ctx, adminID, userID, filter)
+}
+
+// AdminGetUserOrders mocks base method.
+func (m *MockService) AdminGetUserOrders(ctx context.Context, adminID, userID int64, filter model.ListFilter) (*model.OrdersPage, *model.APIError) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AdminGetUserOrders", ctx, adminID, userID, filter)
+	ret0, _ := ret[0].(*model.OrdersPage)
+	ret1, _ := ret[1].(*model.APIError)
+	return ret0, ret1
+}
+
+// AdminGetUserOrders indicates an expected call of AdminGetUserOrders.
+func (mr *MockServiceMockRecorder) AdminGetUserOrders(ctx, adminID, userID, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AdminGetUserOrders", reflect.TypeOf((*MockService)(nil).AdminGetUserOrders), ctx, adminID, userID, filter)
+}
+
+// AdminGrantRole mocks base method.
+func (m *MockService) AdminGrantRole(ctx context.Context, adminID, userID int64, role model.Role) *model.APIError {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AdminGrantRole", ctx, adminID, userID, role)
+	ret0, _ := ret[0].(*model.APIError)
+	return ret0
+}
+
+// AdminGrantRole indicates an expected call of AdminGrantRole.
+func (mr *MockServiceMockRecorder) AdminGrantRole(ctx, adminID, userID, role interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AdminGrantRole", reflect.TypeOf((*MockService)(nil).AdminGrantRole), ctx, adminID, userID, role)
+}
+
+// AdminInvalidateOrder mocks base method.
+func (m *MockService) AdminInvalidateOrder(ctx context.Context, adminID int64, number, reason string) *model.APIError {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AdminInvalidateOrder", ctx, adminID, number, reason)
+	ret0, _ := ret[0].(*model.APIError)
+	return ret0
+}
+
+// AdminInvalidateOrder indicates an expected call of AdminInvalidateOrder.
+func (mr *MockServiceMockRecorder) AdminInvalidateOrder(ctx, adminID, number, reason interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AdminInvalidateOrder", reflect.TypeOf((*MockService)(nil).AdminInvalidateOrder), ctx, adminID, number, reason)
+}
+
+// AdminIssuePasswordReset mocks base method.
+func (m *MockService) AdminIssuePasswordReset(ctx context.Context, adminID, userID int64, input model.AdminPasswordResetDTO) (*model.PasswordResetToken, *model.APIError) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AdminIssuePasswordReset", ctx, adminID, userID, input)
+	ret0, _ := ret[0].(*model.PasswordResetToken)
+	ret1, _ := ret[1].(*model.APIError)
+	return ret0, ret1
+}
+
+// AdminIssuePasswordReset indicates an expected call of AdminIssuePasswordReset.
+func (mr *MockServiceMockRecorder) AdminIssuePasswordReset(ctx, adminID, userID, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AdminIssuePasswordReset", reflect.TypeOf((*MockService)(nil).AdminIssuePasswordReset), ctx, adminID, userID, input)
+}
+
+// AdminReprocessOrder mocks base method.
+func (m *MockService) AdminReprocessOrder(ctx context.Context, adminID int64, number, reason string) *model.APIError {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AdminReprocessOrder", ctx, adminID, number, reason)
+	ret0, _ := ret[0].(*model.APIError)
+	return ret0
+}
+
+// AdminReprocessOrder indicates an expected call of AdminReprocessOrder.
+func (mr *MockServiceMockRecorder) AdminReprocessOrder(ctx, adminID, number, reason interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AdminReprocessOrder", reflect.TypeOf((*MockService)(nil).AdminReprocessOrder), ctx, adminID, number, reason)
+}
+
+// AdminRevokeRole mocks base method.
+func (m *MockService) AdminRevokeRole(ctx context.Context, adminID, userID int64, role model.Role) *model.APIError {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AdminRevokeRole", ctx, adminID, userID, role)
+	ret0, _ := ret[0].(*model.APIError)
+	return ret0
+}
+
+// AdminRevokeRole indicates an expected call of AdminRevokeRole.
+func (mr *MockServiceMockRecorder) AdminRevokeRole(ctx, adminID, userID, role interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AdminRevokeRole", reflect.TypeOf((*MockService)(nil).AdminRevokeRole), ctx, adminID, userID, role)
+}
+
+// AdminSearchUsers mocks base method.
+func (m *MockService) AdminSearchUsers(ctx context.Context, adminID int64, login string) ([]model.UserSummary, *model.APIError) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AdminSearchUsers", ctx, adminID, login)
+	ret0, _ := ret[0].([]model.UserSummary)
+	ret1, _ := ret[1].(*model.APIError)
+	return ret0, ret1
+}
+
+// AdminSearchUsers indicates an expected call of AdminSearchUsers.
+func (mr *MockServiceMockRecorder) AdminSearchUsers(ctx, adminID, login interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AdminSearchUsers", reflect.TypeOf((*MockService)(nil).AdminSearchUsers), ctx, adminID, login)
+}
+
+// CancelWithdraw mocks base method.
+func (m *MockService) CancelWithdraw(ctx context.Context, actorID int64, order string, input model.CancelWithdrawDTO) (*model.LedgerEntry, *model.APIError) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CancelWithdraw", ctx, actorID, order, input)
+	ret0, _ := ret[0].(*model.LedgerEntry)
+	ret1, _ := ret[1].(*model.APIError)
+	return ret0, ret1
+}
+
+// CancelWithdraw indicates an expected call of CancelWithdraw.
+func (mr *MockServiceMockRecorder) CancelWithdraw(ctx, actorID, order, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CancelWithdraw", reflect.TypeOf((*MockService)(nil).CancelWithdraw), ctx, actorID, order, input)
+}
+
+// ChangePassword mocks base method.
+func (m *MockService) ChangePassword(ctx context.Context, userID int64, sessionID string, input model.ChangePasswordDTO) *model.APIError {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ChangePassword", ctx, userID, sessionID, input)
+	ret0, _ := ret[0].(*model.APIError)
+	return ret0
+}
+
+// ChangePassword indicates an expected call of ChangePassword.
+func (mr *MockServiceMockRecorder) ChangePassword(ctx, userID, sessionID, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ChangePassword", reflect.TypeOf((*MockService)(nil).ChangePassword), ctx, userID, sessionID, input)
+}
+
 // CreateOrder mocks base method.
-func (m *MockService) CreateOrder(userID int64, orderNumber string) *model.APIError {
+func (m *MockService) CreateOrder(ctx context.Context, userID int64, orderNumber string) *model.APIError {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateOrder", userID, orderNumber)
+	ret := m.ctrl.Call(m, "CreateOrder", ctx, userID, orderNumber)
 	ret0, _ := ret[0].(*model.APIError)
 	return ret0
 }
 
 // CreateOrder indicates an expected call of CreateOrder.
-func (mr *MockServiceMockRecorder) CreateOrder(userID, orderNumber interface{}) *gomock.Call {
+func (mr *MockServiceMockRecorder) CreateOrder(ctx, userID, orderNumber interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateOrder", reflect.TypeOf((*MockService)(nil).CreateOrder), userID, orderNumber)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateOrder", reflect.TypeOf((*MockService)(nil).CreateOrder), ctx, userID, orderNumber)
 }
 
 // GetBalance mocks base method.
-func (m *MockService) GetBalance(userID int64) (*model.Balance, *model.APIError) {
+func (m *MockService) GetBalance(ctx context.Context, userID int64) (*model.Balance, *model.APIError) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetBalance", userID)
+	ret := m.ctrl.Call(m, "GetBalance", ctx, userID)
 	ret0, _ := ret[0].(*model.Balance)
 	ret1, _ := ret[1].(*model.APIError)
 	return ret0, ret1
 }
 
 // GetBalance indicates an expected call of GetBalance.
-func (mr *MockServiceMockRecorder) GetBalance(userID interface{}) *gomock.Call {
+func (mr *MockServiceMockRecorder) GetBalance(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBalance", reflect.TypeOf((*MockService)(nil).GetBalance), ctx, userID)
+}
+
+// GetLoginAttempts mocks base method.
+func (m *MockService) GetLoginAttempts(ctx context.Context, userID int64, filter model.ListFilter) (*model.LoginAttemptsPage, *model.APIError) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLoginAttempts", ctx, userID, filter)
+	ret0, _ := ret[0].(*model.LoginAttemptsPage)
+	ret1, _ := ret[1].(*model.APIError)
+	return ret0, ret1
+}
+
+// GetLoginAttempts indicates an expected call of GetLoginAttempts.
+func (mr *MockServiceMockRecorder) GetLoginAttempts(ctx, userID, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLoginAttempts", reflect.TypeOf((*MockService)(nil).GetLoginAttempts), ctx, userID, filter)
+}
+
+// GetOrder mocks base method.
+func (m *MockService) GetOrder(ctx context.Context, userID int64, number string) (*model.OrderDetails, *model.APIError) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOrder", ctx, userID, number)
+	ret0, _ := ret[0].(*model.OrderDetails)
+	ret1, _ := ret[1].(*model.APIError)
+	return ret0, ret1
+}
+
+// GetOrder indicates an expected call of GetOrder.
+func (mr *MockServiceMockRecorder) GetOrder(ctx, userID, number interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBalance", reflect.TypeOf((*MockService)(nil).GetBalance), userID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrder", reflect.TypeOf((*MockService)(nil).GetOrder), ctx, userID, number)
 }
 
 // GetOrders mocks base method.
-func (m *MockService) GetOrders(userID int64) ([]model.Order, *model.APIError) {
+func (m *MockService) GetOrders(ctx context.Context, userID int64, filter model.ListFilter) (*model.OrdersPage, *model.APIError) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetOrders", userID)
-	ret0, _ := ret[0].([]model.Order)
+	ret := m.ctrl.Call(m, "GetOrders", ctx, userID, filter)
+	ret0, _ := ret[0].(*model.OrdersPage)
 	ret1, _ := ret[1].(*model.APIError)
 	return ret0, ret1
 }
 
 // GetOrders indicates an expected call of GetOrders.
-func (mr *MockServiceMockRecorder) GetOrders(userID interface{}) *gomock.Call {
+func (mr *MockServiceMockRecorder) GetOrders(ctx, userID, filter interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrders", reflect.TypeOf((*MockService)(nil).GetOrders), userID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrders", reflect.TypeOf((*MockService)(nil).GetOrders), ctx, userID, filter)
 }
 
 // GetWithdraws mocks base method.
-func (m *MockService) GetWithdraws(userID int64) ([]model.Withdraw, *model.APIError) {
+func (m *MockService) GetWithdraws(ctx context.Context, userID int64, filter model.ListFilter) (*model.WithdrawsPage, *model.APIError) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetWithdraws", userID)
-	ret0, _ := ret[0].([]model.Withdraw)
+	ret := m.ctrl.Call(m, "GetWithdraws", ctx, userID, filter)
+	ret0, _ := ret[0].(*model.WithdrawsPage)
 	ret1, _ := ret[1].(*model.APIError)
 	return ret0, ret1
 }
 
 // GetWithdraws indicates an expected call of GetWithdraws.
-func (mr *MockServiceMockRecorder) GetWithdraws(userID interface{}) *gomock.Call {
+func (mr *MockServiceMockRecorder) GetWithdraws(ctx, userID, filter interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWithdraws", reflect.TypeOf((*MockService)(nil).GetWithdraws), userID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWithdraws", reflect.TypeOf((*MockService)(nil).GetWithdraws), ctx, userID, filter)
 }
 
 // Login mocks base method.
-func (m *MockService) Login(input model.LoginDTO) (string, *model.APIError) {
+func (m *MockService) Login(ctx context.Context, input model.LoginDTO, client model.ClientInfo) (*model.Tokens, *model.APIError) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Login", input)
-	ret0, _ := ret[0].(string)
+	ret := m.ctrl.Call(m, "Login", ctx, input, client)
+	ret0, _ := ret[0].(*model.Tokens)
 	ret1, _ := ret[1].(*model.APIError)
 	return ret0, ret1
 }
 
 // Login indicates an expected call of Login.
-func (mr *MockServiceMockRecorder) Login(input interface{}) *gomock.Call {
+func (mr *MockServiceMockRecorder) Login(ctx, input, client interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Login", reflect.TypeOf((*MockService)(nil).Login), ctx, input, client)
+}
+
+// Logout mocks base method.
+func (m *MockService) Logout(ctx context.Context, sessionID string) *model.APIError {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Logout", ctx, sessionID)
+	ret0, _ := ret[0].(*model.APIError)
+	return ret0
+}
+
+// Logout indicates an expected call of Logout.
+func (mr *MockServiceMockRecorder) Logout(ctx, sessionID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Logout", reflect.TypeOf((*MockService)(nil).Logout), ctx, sessionID)
+}
+
+// RefreshTokens mocks base method.
+func (m *MockService) RefreshTokens(ctx context.Context, refreshToken string) (*model.Tokens, *model.APIError) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RefreshTokens", ctx, refreshToken)
+	ret0, _ := ret[0].(*model.Tokens)
+	ret1, _ := ret[1].(*model.APIError)
+	return ret0, ret1
+}
+
+// RefreshTokens indicates an expected call of RefreshTokens.
+func (mr *MockServiceMockRecorder) RefreshTokens(ctx, refreshToken interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Login", reflect.TypeOf((*MockService)(nil).Login), input)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RefreshTokens", reflect.TypeOf((*MockService)(nil).RefreshTokens), ctx, refreshToken)
 }
 
 // Register mocks base method.
-func (m *MockService) Register(input model.RegisterDTO) (string, *model.APIError) {
+func (m *MockService) Register(ctx context.Context, input model.RegisterDTO) (*model.Tokens, *model.APIError) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Register", input)
-	ret0, _ := ret[0].(string)
+	ret := m.ctrl.Call(m, "Register", ctx, input)
+	ret0, _ := ret[0].(*model.Tokens)
 	ret1, _ := ret[1].(*model.APIError)
 	return ret0, ret1
 }
 
 // Register indicates an expected call of Register.
-func (mr *MockServiceMockRecorder) Register(input interface{}) *gomock.Call {
+func (mr *MockServiceMockRecorder) Register(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Register", reflect.TypeOf((*MockService)(nil).Register), ctx, input)
+}
+
+// ResetPassword mocks base method.
+func (m *MockService) ResetPassword(ctx context.Context, input model.ResetPasswordDTO) *model.APIError {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResetPassword", ctx, input)
+	ret0, _ := ret[0].(*model.APIError)
+	return ret0
+}
+
+// ResetPassword indicates an expected call of ResetPassword.
+func (mr *MockServiceMockRecorder) ResetPassword(ctx, input interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Register", reflect.TypeOf((*MockService)(nil).Register), input)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResetPassword", reflect.TypeOf((*MockService)(nil).ResetPassword), ctx, input)
 }
 
 // SetWithdraw mocks base method.
-func (m *MockService) SetWithdraw(userID int64, input model.SetWithdrawDTO) *model.APIError {
+func (m *MockService) SetWithdraw(ctx context.Context, userID int64, input model.SetWithdrawDTO, idempotencyKey string) *model.APIError {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "SetWithdraw", userID, input)
+	ret := m.ctrl.Call(m, "SetWithdraw", ctx, userID, input, idempotencyKey)
 	ret0, _ := ret[0].(*model.APIError)
 	return ret0
 }
 
 // SetWithdraw indicates an expected call of SetWithdraw.
-func (mr *MockServiceMockRecorder) SetWithdraw(userID, input interface{}) *gomock.Call {
+func (mr *MockServiceMockRecorder) SetWithdraw(ctx, userID, input, idempotencyKey interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetWithdraw", reflect.TypeOf((*MockService)(nil).SetWithdraw), ctx, userID, input, idempotencyKey)
+}
+
+// SubscribeOrderEvents mocks base method.
+func (m *MockService) SubscribeOrderEvents(ctx context.Context, userID, lastEventID int64) (<-chan model.OrderEvent, *model.APIError) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SubscribeOrderEvents", ctx, userID, lastEventID)
+	ret0, _ := ret[0].(<-chan model.OrderEvent)
+	ret1, _ := ret[1].(*model.APIError)
+	return ret0, ret1
+}
+
+// SubscribeOrderEvents indicates an expected call of SubscribeOrderEvents.
+func (mr *MockServiceMockRecorder) SubscribeOrderEvents(ctx, userID, lastEventID interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetWithdraw", reflect.TypeOf((*MockService)(nil).SetWithdraw), userID, input)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SubscribeOrderEvents", reflect.TypeOf((*MockService)(nil).SubscribeOrderEvents), ctx, userID, lastEventID)
 }
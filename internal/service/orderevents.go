@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/ibeloyar/gophermart/internal/model"
+	"github.com/ibeloyar/gophermart/internal/tracing"
+)
+
+// maxReplayedEvents - сколько пропущенных событий отдаётся при переподключении;
+// если пропущено больше, вместо них отправляется model.OrderEventsReset
+const maxReplayedEvents = 1000
+
+// SetOrderEventsNotify - события приходят в хаб через LISTEN order_events (со всех реплик),
+// поэтому сервис не публикует их сам, чтобы подписчики не получили их дважды
+func (s *Service) SetOrderEventsNotify(enabled bool) {
+	s.eventsNotify = enabled
+}
+
+// publishOrderEvent - рассылает событие, порождённое самим сервисом (действия администратора)
+func (s *Service) publishOrderEvent(event *model.OrderEvent) {
+	if event == nil || s.events == nil || s.eventsNotify {
+		return
+	}
+
+	s.events.Publish(*event)
+}
+
+// SubscribeOrderEvents - поток событий заказов пользователя: сначала пропущенные после
+// lastEventID, затем новые из хаба. Канал закрывается при отмене ctx, остановке хаба
+// или отключении медленного подписчика - клиент переподключается с Last-Event-ID.
+func (s *Service) SubscribeOrderEvents(ctx context.Context, userID, lastEventID int64) (<-chan model.OrderEvent, *model.APIError) {
+	ctx, span := tracing.Start(ctx, "Service.SubscribeOrderEvents")
+	defer span.End()
+
+	if s.events == nil {
+		return nil, &model.APIError{
+			Code:    http.StatusServiceUnavailable,
+			Message: http.StatusText(http.StatusServiceUnavailable),
+		}
+	}
+
+	// подписываемся до чтения истории, чтобы не потерять события между ними
+	sub := s.events.Subscribe(userID)
+
+	var missed []model.OrderEvent
+	if lastEventID > 0 {
+		var err error
+		missed, err = s.storage.GetOrderEventsAfter(ctx, userID, lastEventID, maxReplayedEvents+1)
+		if err != nil {
+			sub.Close()
+			return nil, &model.APIError{
+				Code:    http.StatusInternalServerError,
+				Message: model.ErrInternalServerMessage,
+			}
+		}
+
+		// частичная история хуже никакой: клиент решил бы, что видел всё
+		if len(missed) > maxReplayedEvents {
+			missed = []model.OrderEvent{model.OrderEventsReset}
+		}
+	}
+
+	out := make(chan model.OrderEvent)
+
+	go func() {
+		defer close(out)
+		defer sub.Close()
+
+		replayed := make(map[int64]struct{}, len(missed))
+		for _, event := range missed {
+			select {
+			case out <- event:
+				replayed[event.ID] = struct{}{}
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		for {
+			select {
+			case event, ok := <-sub.Events():
+				if !ok {
+					return
+				}
+				if _, ok := replayed[event.ID]; ok {
+					continue
+				}
+				select {
+				case out <- event:
+				case <-ctx.Done():
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out, nil
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/ibeloyar/gophermart/internal/model"
+	"github.com/ibeloyar/gophermart/internal/orderevents"
+	"github.com/ibeloyar/gophermart/pgk/auth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	mockPG "github.com/ibeloyar/gophermart/internal/repository/pg/mocks"
+)
+
+func TestService_SubscribeOrderEvents_ReplayThenLive(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	hub := orderevents.NewHub()
+	mockStorage := mockPG.NewMockStorageRepo(ctrl)
+	svc := New(mockStorage, 3, 1*time.Hour, 24*time.Hour, 24*time.Hour, auth.NewHMACKeySet("secret"), hub)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mockStorage.EXPECT().
+		GetOrderEventsAfter(gomock.Any(), int64(123), int64(5), maxReplayedEvents+1).
+		DoAndReturn(func(context.Context, int64, int64, int) ([]model.OrderEvent, error) {
+			// событие 6 пришло в хаб, пока читалась история: клиент не должен получить его дважды
+			hub.Publish(model.OrderEvent{ID: 6, UserID: 123})
+			return []model.OrderEvent{{ID: 6, UserID: 123}}, nil
+		})
+
+	events, apiErr := svc.SubscribeOrderEvents(ctx, 123, 5)
+	require.Nil(t, apiErr)
+
+	hub.Publish(model.OrderEvent{ID: 7, UserID: 123})
+	hub.Publish(model.OrderEvent{ID: 8, UserID: 456})
+
+	assert.Equal(t, int64(6), (<-events).ID)
+	assert.Equal(t, int64(7), (<-events).ID)
+
+	cancel()
+	for range events {
+	}
+}
+
+func TestService_SubscribeOrderEvents_TooManyMissed(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := mockPG.NewMockStorageRepo(ctrl)
+	svc := New(mockStorage, 3, 1*time.Hour, 24*time.Hour, 24*time.Hour, auth.NewHMACKeySet("secret"), orderevents.NewHub())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mockStorage.EXPECT().
+		GetOrderEventsAfter(gomock.Any(), int64(123), int64(5), maxReplayedEvents+1).
+		Return(make([]model.OrderEvent, maxReplayedEvents+1), nil)
+
+	events, apiErr := svc.SubscribeOrderEvents(ctx, 123, 5)
+	require.Nil(t, apiErr)
+
+	assert.Equal(t, model.OrderEventsReset, <-events)
+
+	cancel()
+	for range events {
+	}
+}
+
+func TestService_SubscribeOrderEvents_NoHub(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	svc := New(mockPG.NewMockStorageRepo(ctrl), 3, 1*time.Hour, 24*time.Hour, 24*time.Hour, auth.NewHMACKeySet("secret"), nil)
+
+	_, apiErr := svc.SubscribeOrderEvents(context.Background(), 123, 0)
+
+	require.NotNil(t, apiErr)
+	assert.Equal(t, http.StatusServiceUnavailable, apiErr.Code)
+}
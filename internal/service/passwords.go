@@ -0,0 +1,205 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/ibeloyar/gophermart/internal/model"
+	"github.com/ibeloyar/gophermart/internal/tracing"
+	"github.com/ibeloyar/gophermart/pgk/auth"
+	"github.com/ibeloyar/gophermart/pgk/password"
+)
+
+const defaultResetTokenTTL = time.Hour
+
+// commonPasswords - запрещены всегда, список из конфигурации добавляется к ним
+var commonPasswords = []string{
+	"password", "password1", "passw0rd", "qwerty", "qwerty123", "qwertyuiop", "letmein", "welcome",
+	"admin", "iloveyou", "monkey", "dragon", "football", "baseball", "sunshine", "princess",
+	"trustno1", "abc123", "1q2w3e4r", "1qaz2wsx", "zxcvbnm", "asdfgh", "123qwe", "qazwsx",
+	"1234", "12345", "123456", "1234567", "12345678", "123456789", "1234567890", "0987654321",
+	"111111", "123123", "123321", "654321", "666666", "000000", "112233", "121212",
+}
+
+// SetPasswordPolicy - требования к новым паролям; Denylist дополняет встроенный список
+func (s *Service) SetPasswordPolicy(policy model.PasswordPolicy) {
+	policy.Denylist = slices.Concat(commonPasswords, policy.Denylist)
+	s.passwordPolicy = policy
+}
+
+// SetPasswordResetTTL - срок действия токенов сброса пароля
+func (s *Service) SetPasswordResetTTL(ttl time.Duration) {
+	s.resetTokenTTL = ttl
+}
+
+// SetPasswordHasher - алгоритм для новых хешей; старые пересчитываются при следующем входе
+func (s *Service) SetPasswordHasher(hasher password.Hasher) {
+	s.hasher = hasher
+}
+
+// hashPassword - хеширование в своём span'е: bcrypt и argon2id намеренно медленные
+func (s *Service) hashPassword(ctx context.Context, plain string) (string, error) {
+	_, span := tracing.Start(ctx, "password.Hash")
+	defer span.End()
+
+	return s.hasher.Hash(plain)
+}
+
+func checkPassword(ctx context.Context, plain, hash string) bool {
+	_, span := tracing.Start(ctx, "password.Check")
+	defer span.End()
+
+	return password.CheckPasswordHash(plain, hash)
+}
+
+// rehashPassword - пересчитывает хеш, сделанный другим алгоритмом или с устаревшей стоимостью.
+// Ошибка не мешает входу: хеш обновится при следующем.
+func (s *Service) rehashPassword(ctx context.Context, user *model.User, plain string) {
+	if !s.hasher.NeedsRehash(user.Password) {
+		return
+	}
+
+	newHash, err := s.hashPassword(ctx, plain)
+	if err != nil {
+		return
+	}
+
+	_ = s.storage.UpdatePasswordHash(ctx, user.ID, user.Password, newHash)
+}
+
+// ChangePassword - смена пароля по текущему; остальные сессии пользователя отзываются
+func (s *Service) ChangePassword(ctx context.Context, userID int64, sessionID string, input model.ChangePasswordDTO) *model.APIError {
+	ctx, span := tracing.Start(ctx, "Service.ChangePassword")
+	defer span.End()
+
+	if err := validatePassword(input.NewPassword, s.passwordPolicy); err != nil {
+		return &model.APIError{
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+		}
+	}
+
+	user := s.storage.GetUserByID(ctx, userID)
+	if user == nil {
+		return &model.APIError{
+			Code:    http.StatusNotFound,
+			Message: model.ErrUserNotFoundMessage,
+		}
+	}
+
+	if !checkPassword(ctx, input.OldPassword, user.Password) {
+		return &model.APIError{
+			Code:    http.StatusForbidden,
+			Message: model.ErrWrongPasswordMessage,
+		}
+	}
+
+	passwordHash, err := s.hashPassword(ctx, input.NewPassword)
+	if err != nil {
+		return &model.APIError{
+			Code:    http.StatusInternalServerError,
+			Message: model.ErrInternalServerMessage,
+		}
+	}
+
+	if err = s.storage.ChangePassword(ctx, userID, passwordHash, sessionID); err != nil {
+		if errors.Is(err, model.ErrUserNotFound) {
+			return &model.APIError{
+				Code:    http.StatusNotFound,
+				Message: model.ErrUserNotFoundMessage,
+			}
+		}
+		return &model.APIError{
+			Code:    http.StatusInternalServerError,
+			Message: model.ErrInternalServerMessage,
+		}
+	}
+
+	return nil
+}
+
+// AdminIssuePasswordReset - выдаёт одноразовый токен сброса пароля пользователя.
+// Токен возвращается только здесь, в базе остаётся хеш.
+func (s *Service) AdminIssuePasswordReset(ctx context.Context, adminID, userID int64, input model.AdminPasswordResetDTO) (*model.PasswordResetToken, *model.APIError) {
+	ctx, span := tracing.Start(ctx, "Service.AdminIssuePasswordReset")
+	defer span.End()
+
+	reason, apiErr := validateAdminReason(input.Reason)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	token, hash, err := auth.NewResetToken()
+	if err != nil {
+		return nil, &model.APIError{
+			Code:    http.StatusInternalServerError,
+			Message: model.ErrInternalServerMessage,
+		}
+	}
+
+	expiresAt := time.Now().Add(s.resetTokenTTL)
+
+	if err = s.storage.CreatePasswordResetToken(ctx, adminID, userID, hash, expiresAt, reason); err != nil {
+		if errors.Is(err, model.ErrUserNotFound) {
+			return nil, &model.APIError{
+				Code:    http.StatusNotFound,
+				Message: model.ErrUserNotFoundMessage,
+			}
+		}
+		return nil, &model.APIError{
+			Code:    http.StatusInternalServerError,
+			Message: model.ErrInternalServerMessage,
+		}
+	}
+
+	return &model.PasswordResetToken{
+		Token:     token,
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+// ResetPassword - новый пароль по токену сброса; все сессии пользователя отзываются
+func (s *Service) ResetPassword(ctx context.Context, input model.ResetPasswordDTO) *model.APIError {
+	ctx, span := tracing.Start(ctx, "Service.ResetPassword")
+	defer span.End()
+
+	if input.Token == "" {
+		return &model.APIError{
+			Code:    http.StatusUnauthorized,
+			Message: model.ErrInvalidResetTokenMessage,
+		}
+	}
+
+	if err := validatePassword(input.NewPassword, s.passwordPolicy); err != nil {
+		return &model.APIError{
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+		}
+	}
+
+	passwordHash, err := s.hashPassword(ctx, input.NewPassword)
+	if err != nil {
+		return &model.APIError{
+			Code:    http.StatusInternalServerError,
+			Message: model.ErrInternalServerMessage,
+		}
+	}
+
+	if err = s.storage.ResetPassword(ctx, auth.HashResetToken(input.Token), passwordHash); err != nil {
+		if errors.Is(err, model.ErrResetTokenInvalid) {
+			return &model.APIError{
+				Code:    http.StatusUnauthorized,
+				Message: model.ErrInvalidResetTokenMessage,
+			}
+		}
+		return &model.APIError{
+			Code:    http.StatusInternalServerError,
+			Message: model.ErrInternalServerMessage,
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/ibeloyar/gophermart/internal/model"
+	"github.com/ibeloyar/gophermart/pgk/auth"
+	"github.com/ibeloyar/gophermart/pgk/password"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	mockPG "github.com/ibeloyar/gophermart/internal/repository/pg/mocks"
+)
+
+func TestService_ChangePassword(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := mockPG.NewMockStorageRepo(ctrl)
+	svc := New(mockStorage, 3, 1*time.Hour, 24*time.Hour, 24*time.Hour, auth.NewHMACKeySet("secret"), nil)
+
+	hash, err := svc.hasher.Hash("old-pass")
+	require.NoError(t, err)
+
+	mockStorage.EXPECT().GetUserByID(gomock.Any(), int64(7)).Return(&model.User{ID: 7, Password: hash}).Times(2)
+	mockStorage.EXPECT().
+		ChangePassword(gomock.Any(), int64(7), gomock.Any(), "session-1").
+		DoAndReturn(func(_ context.Context, _ int64, newHash, _ string) error {
+			assert.True(t, password.CheckPasswordHash("new-pass", newHash))
+			return nil
+		})
+
+	apiErr := svc.ChangePassword(context.Background(), 7, "session-1", model.ChangePasswordDTO{OldPassword: "wrong", NewPassword: "new-pass"})
+	require.NotNil(t, apiErr)
+	assert.Equal(t, http.StatusForbidden, apiErr.Code)
+
+	apiErr = svc.ChangePassword(context.Background(), 7, "session-1", model.ChangePasswordDTO{OldPassword: "old-pass", NewPassword: "new-pass"})
+	assert.Nil(t, apiErr)
+}
+
+func TestService_ChangePassword_Policy(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := mockPG.NewMockStorageRepo(ctrl)
+	svc := New(mockStorage, 3, 1*time.Hour, 24*time.Hour, 24*time.Hour, auth.NewHMACKeySet("secret"), nil)
+	svc.SetPasswordPolicy(model.PasswordPolicy{MinClasses: 2})
+
+	apiErr := svc.ChangePassword(context.Background(), 7, "session-1", model.ChangePasswordDTO{OldPassword: "old-pass", NewPassword: "onlyletters"})
+	require.NotNil(t, apiErr)
+	assert.Equal(t, http.StatusBadRequest, apiErr.Code)
+	assert.Equal(t, model.ErrPasswordTooWeakMessage, apiErr.Message)
+
+	// встроенный список распространённых паролей действует и с политикой из конфигурации
+	apiErr = svc.ChangePassword(context.Background(), 7, "session-1", model.ChangePasswordDTO{OldPassword: "old-pass", NewPassword: "Password1"})
+	require.NotNil(t, apiErr)
+	assert.Equal(t, model.ErrPasswordTooCommonMessage, apiErr.Message)
+}
+
+func TestService_AdminIssuePasswordReset(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := mockPG.NewMockStorageRepo(ctrl)
+	svc := New(mockStorage, 3, 1*time.Hour, 24*time.Hour, 24*time.Hour, auth.NewHMACKeySet("secret"), nil)
+
+	var storedHash string
+	mockStorage.EXPECT().
+		CreatePasswordResetToken(gomock.Any(), int64(1), int64(7), gomock.Any(), gomock.Any(), "locked out").
+		DoAndReturn(func(_ context.Context, _, _ int64, hash string, _ time.Time, _ string) error {
+			storedHash = hash
+			return nil
+		})
+
+	token, apiErr := svc.AdminIssuePasswordReset(context.Background(), 1, 7, model.AdminPasswordResetDTO{Reason: " locked out "})
+
+	require.Nil(t, apiErr)
+	assert.NotEqual(t, token.Token, storedHash)
+	assert.Equal(t, auth.HashResetToken(token.Token), storedHash)
+	assert.WithinDuration(t, time.Now().Add(defaultResetTokenTTL), token.ExpiresAt, time.Second)
+}
+
+func TestService_ResetPassword(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := mockPG.NewMockStorageRepo(ctrl)
+	svc := New(mockStorage, 3, 1*time.Hour, 24*time.Hour, 24*time.Hour, auth.NewHMACKeySet("secret"), nil)
+
+	mockStorage.EXPECT().
+		ResetPassword(gomock.Any(), auth.HashResetToken("used-token"), gomock.Any()).
+		Return(model.ErrResetTokenInvalid)
+	mockStorage.EXPECT().
+		ResetPassword(gomock.Any(), auth.HashResetToken("fresh-token"), gomock.Any()).
+		Return(nil)
+
+	apiErr := svc.ResetPassword(context.Background(), model.ResetPasswordDTO{Token: "used-token", NewPassword: "new-pass"})
+	require.NotNil(t, apiErr)
+	assert.Equal(t, http.StatusUnauthorized, apiErr.Code)
+
+	apiErr = svc.ResetPassword(context.Background(), model.ResetPasswordDTO{Token: "fresh-token", NewPassword: "new-pass"})
+	assert.Nil(t, apiErr)
+}
+
+func TestService_Login_RehashesOutdatedHash(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := mockPG.NewMockStorageRepo(ctrl)
+	svc := New(mockStorage, 3, 1*time.Hour, 24*time.Hour, 24*time.Hour, auth.NewHMACKeySet("secret"), nil)
+	svc.SetPasswordHasher(password.NewArgon2idHasher(password.Argon2Params{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}))
+
+	// хеш со старой стоимостью bcrypt по-прежнему подходит для входа
+	oldHash, err := password.HashPassword("testpass123", 4)
+	require.NoError(t, err)
+
+	mockStorage.EXPECT().GetUserByLogin(gomock.Any(), "testuser").Return(&model.User{ID: 7, Login: "testuser", Password: oldHash})
+	mockStorage.EXPECT().GetLoginLockedUntil(gomock.Any(), "testuser").Return(nil, nil)
+	mockStorage.EXPECT().RecordLoginAttempt(gomock.Any(), gomock.Any()).Return(nil)
+	mockStorage.EXPECT().
+		UpdatePasswordHash(gomock.Any(), int64(7), oldHash, gomock.Any()).
+		DoAndReturn(func(_ context.Context, _ int64, _, newHash string) error {
+			assert.True(t, strings.HasPrefix(newHash, "$argon2id$"))
+			assert.True(t, password.CheckPasswordHash("testpass123", newHash))
+			return nil
+		})
+	mockStorage.EXPECT().CreateSession(gomock.Any(), gomock.Any()).Return(nil)
+
+	tokens, apiErr := svc.Login(context.Background(), model.LoginDTO{Login: "testuser", Password: "testpass123"}, model.ClientInfo{})
+
+	require.Nil(t, apiErr)
+	assert.NotEmpty(t, tokens.AccessToken)
+}
@@ -0,0 +1,43 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/ibeloyar/gophermart/internal/model"
+	"github.com/ibeloyar/gophermart/internal/tracing"
+)
+
+// CancelWithdraw - возвращает баллы списания по заказу order; каждое списание возвращается не больше одного раза
+func (s *Service) CancelWithdraw(ctx context.Context, actorID int64, order string, input model.CancelWithdrawDTO) (*model.LedgerEntry, *model.APIError) {
+	ctx, span := tracing.Start(ctx, "Service.CancelWithdraw")
+	defer span.End()
+
+	reason, apiErr := validateAdminReason(input.Reason)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	entry, err := s.storage.RefundWithdraw(ctx, actorID, order, reason)
+	if err != nil {
+		if errors.Is(err, model.ErrWithdrawNotFound) {
+			return nil, &model.APIError{
+				Code:    http.StatusNotFound,
+				Message: model.ErrWithdrawNotFoundMessage,
+			}
+		}
+		if errors.Is(err, model.ErrWithdrawAlreadyRefunded) {
+			return nil, &model.APIError{
+				Code:    http.StatusConflict,
+				Message: model.ErrWithdrawAlreadyRefundedMessage,
+			}
+		}
+		return nil, &model.APIError{
+			Code:    http.StatusInternalServerError,
+			Message: model.ErrInternalServerMessage,
+		}
+	}
+
+	return entry, nil
+}
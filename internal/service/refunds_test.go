@@ -0,0 +1,58 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/ibeloyar/gophermart/internal/model"
+	"github.com/ibeloyar/gophermart/pgk/auth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	mockPG "github.com/ibeloyar/gophermart/internal/repository/pg/mocks"
+)
+
+func TestService_CancelWithdraw(t *testing.T) {
+	tests := []struct {
+		name     string
+		reason   string
+		repoErr  error
+		wantCode int
+	}{
+		{"refunded", "order cancelled", nil, 0},
+		{"no reason", "  ", nil, http.StatusBadRequest},
+		{"unknown withdrawal", "order cancelled", model.ErrWithdrawNotFound, http.StatusNotFound},
+		{"second refund", "order cancelled", model.ErrWithdrawAlreadyRefunded, http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockStorage := mockPG.NewMockStorageRepo(ctrl)
+			svc := New(mockStorage, 3, 1*time.Hour, 24*time.Hour, 24*time.Hour, auth.NewHMACKeySet("secret"), nil)
+
+			if tt.wantCode != http.StatusBadRequest {
+				var entry *model.LedgerEntry
+				if tt.repoErr == nil {
+					entry = &model.LedgerEntry{ID: 12, Amount: 1050, Kind: model.LedgerKindRefund}
+				}
+				mockStorage.EXPECT().RefundWithdraw(gomock.Any(), int64(1), "12345678903", tt.reason).Return(entry, tt.repoErr)
+			}
+
+			entry, apiErr := svc.CancelWithdraw(context.Background(), 1, "12345678903", model.CancelWithdrawDTO{Reason: tt.reason})
+
+			if tt.wantCode == 0 {
+				require.Nil(t, apiErr)
+				assert.Equal(t, model.LedgerKindRefund, entry.Kind)
+				return
+			}
+			require.NotNil(t, apiErr)
+			assert.Equal(t, tt.wantCode, apiErr.Code)
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/ibeloyar/gophermart/internal/model"
+	"github.com/ibeloyar/gophermart/internal/tracing"
+)
+
+var defaultLockoutPolicy = model.LockoutPolicy{
+	MaxFailures: 5,
+	Window:      15 * time.Minute,
+	Lockout:     time.Minute,
+	MaxLockout:  24 * time.Hour,
+}
+
+// SetLoginLockout - политика блокировки входа; MaxFailures = 0 выключает блокировку
+func (s *Service) SetLoginLockout(policy model.LockoutPolicy) {
+	s.loginLockout = policy
+}
+
+// GetLoginAttempts - история входов пользователя (успешных и неудачных)
+func (s *Service) GetLoginAttempts(ctx context.Context, userID int64, filter model.ListFilter) (*model.LoginAttemptsPage, *model.APIError) {
+	ctx, span := tracing.Start(ctx, "Service.GetLoginAttempts")
+	defer span.End()
+
+	filter, err := normalizeListFilter(filter)
+	if err != nil || len(filter.Statuses) > 0 {
+		return nil, &model.APIError{
+			Code:    http.StatusBadRequest,
+			Message: model.ErrInvalidListFilterMessage,
+		}
+	}
+
+	page, err := s.storage.GetLoginAttemptsByUserID(ctx, userID, filter)
+	if err != nil {
+		return nil, &model.APIError{
+			Code:    http.StatusInternalServerError,
+			Message: model.ErrInternalServerMessage,
+		}
+	}
+
+	return page, nil
+}
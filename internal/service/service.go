@@ -1,133 +1,239 @@
 package service
 
 import (
+	"context"
 	"errors"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/ibeloyar/gophermart/internal/model"
+	"github.com/ibeloyar/gophermart/internal/orderevents"
 	"github.com/ibeloyar/gophermart/internal/repository/pg"
+	"github.com/ibeloyar/gophermart/internal/tracing"
 	"github.com/ibeloyar/gophermart/pgk/auth"
 	"github.com/ibeloyar/gophermart/pgk/password"
 )
 
 type StorageRepo interface {
-	CreateUser(user model.User) (int64, error)
-	GetUserByLogin(login string) *model.User
-	CreateOrder(userID int64, number string) error
-	GetOrdersByUserID(userID int64) ([]model.Order, error)
-	GetBalanceByUserID(userID int64) (*model.Balance, error)
-	SetWithdraw(userID int64, input model.SetWithdrawDTO) error
-	GetWithdrawsByUserID(userID int64) ([]model.Withdraw, error)
+	CreateUser(ctx context.Context, user model.User) (int64, error)
+	GetUserByLogin(ctx context.Context, login string) *model.User
+	GetUserByID(ctx context.Context, id int64) *model.User
+	CreateOrder(ctx context.Context, userID int64, number string) error
+	GetOrdersByUserID(ctx context.Context, userID int64, filter model.ListFilter) (*model.OrdersPage, error)
+	GetOrderDetails(ctx context.Context, number string) (*model.OrderDetails, error)
+	GetOrderEventsAfter(ctx context.Context, userID, afterID int64, limit int) ([]model.OrderEvent, error)
+	GetBalanceByUserID(ctx context.Context, userID int64) (*model.Balance, error)
+	SetWithdraw(ctx context.Context, userID int64, input model.SetWithdrawDTO, idempotencyKey string) error
+	GetWithdrawsByUserID(ctx context.Context, userID int64, filter model.ListFilter) (*model.WithdrawsPage, error)
+	RefundWithdraw(ctx context.Context, actorID int64, order, reason string) (*model.LedgerEntry, error)
+
+	CreateSession(ctx context.Context, session model.Session) error
+	GetSessionByID(ctx context.Context, id string) (*model.Session, error)
+	RotateSession(ctx context.Context, id, oldHash, newHash string, expiresAt time.Time) error
+	IsRefreshTokenRotated(ctx context.Context, id, hash string) (bool, error)
+	RevokeSession(ctx context.Context, id string) error
+	IsSessionRevoked(ctx context.Context, id string) (bool, error)
+
+	GetLoginLockedUntil(ctx context.Context, login string) (*time.Time, error)
+	RecordLoginAttempt(ctx context.Context, attempt model.LoginAttempt) error
+	RecordLoginFailure(ctx context.Context, attempt model.LoginAttempt, policy model.LockoutPolicy) (*time.Time, error)
+	GetLoginAttemptsByUserID(ctx context.Context, userID int64, filter model.ListFilter) (*model.LoginAttemptsPage, error)
+
+	ChangePassword(ctx context.Context, userID int64, passwordHash, keepSessionID string) error
+	CreatePasswordResetToken(ctx context.Context, adminID, userID int64, tokenHash string, expiresAt time.Time, reason string) error
+	ResetPassword(ctx context.Context, tokenHash, passwordHash string) error
+	UpdatePasswordHash(ctx context.Context, userID int64, oldHash, newHash string) error
+
+	ReserveIdempotencyKey(ctx context.Context, record model.IdempotencyRecord) (*model.IdempotencyRecord, bool, error)
+	CompleteIdempotencyKey(ctx context.Context, userID int64, key string, responseCode int, responseBody string) error
+	ReleaseIdempotencyKey(ctx context.Context, userID int64, key string) error
+
+	SearchUsersByLogin(ctx context.Context, login string) ([]model.UserSummary, error)
+	GetLedgerByUserID(ctx context.Context, userID int64, filter model.ListFilter) (*model.LedgerPage, error)
+	AdjustBalance(ctx context.Context, adminID, userID int64, input model.BalanceAdjustmentDTO) (*model.LedgerEntry, error)
+	ResetOrderToNew(ctx context.Context, adminID int64, number, reason string) (*model.OrderEvent, error)
+	InvalidateOrder(ctx context.Context, adminID int64, number, reason string) (*model.OrderEvent, error)
+	WriteAdminAudit(ctx context.Context, entry model.AdminAuditEntry) error
+	GrantRole(ctx context.Context, adminID, userID int64, role model.Role) error
+	RevokeRole(ctx context.Context, adminID, userID int64, role model.Role) error
 }
 
 type Service struct {
 	storage      StorageRepo
-	passwordCost int
-	tokenSecret  string
+	hasher       password.Hasher
+	tokenKeys    *auth.KeySet
 	tokenExp     time.Duration
+	refreshExp   time.Duration
+	idemKeyTTL   time.Duration
+	events       *orderevents.Hub
+	eventsNotify bool
+	loginLockout model.LockoutPolicy
+
+	passwordPolicy model.PasswordPolicy
+	resetTokenTTL  time.Duration
 }
 
-func New(storage StorageRepo, passwordCost int, tokenExp time.Duration, tokenSecret string) *Service {
+func New(storage StorageRepo, passwordCost int, tokenExp, refreshExp, idemKeyTTL time.Duration, tokenKeys *auth.KeySet, events *orderevents.Hub) *Service {
 	return &Service{
 		storage:      storage,
-		passwordCost: passwordCost,
+		hasher:       password.NewBcryptHasher(passwordCost),
 		tokenExp:     tokenExp,
-		tokenSecret:  tokenSecret,
+		refreshExp:   refreshExp,
+		idemKeyTTL:   idemKeyTTL,
+		tokenKeys:    tokenKeys,
+		events:       events,
+		loginLockout: defaultLockoutPolicy,
+
+		passwordPolicy: model.PasswordPolicy{Denylist: commonPasswords},
+		resetTokenTTL:  defaultResetTokenTTL,
 	}
 }
 
-func (s *Service) Register(input model.RegisterDTO) (string, *model.APIError) {
-	if err := validateRegisterDTO(input); err != nil {
-		return "", &model.APIError{
+func (s *Service) Register(ctx context.Context, input model.RegisterDTO) (*model.Tokens, *model.APIError) {
+	ctx, span := tracing.Start(ctx, "Service.Register")
+	defer span.End()
+
+	if err := validateRegisterDTO(input, s.passwordPolicy); err != nil {
+		return nil, &model.APIError{
 			Code:    http.StatusBadRequest,
-			Message: model.ErrInvalidLoginOrPasswordMessage,
+			Message: err.Error(),
 		}
 	}
 
-	passwordHash, err := password.HashPassword(input.Password, s.passwordCost)
+	passwordHash, err := s.hashPassword(ctx, input.Password)
 	if err != nil {
-		return "", &model.APIError{
+		return nil, &model.APIError{
 			Code:    http.StatusInternalServerError,
 			Message: model.ErrInternalServerMessage,
 		}
 	}
 
-	userID, err := s.storage.CreateUser(model.User{
+	userID, err := s.storage.CreateUser(ctx, model.User{
 		Login:    input.Login,
 		Password: passwordHash,
 	})
 	if err != nil {
 		if strings.Contains(err.Error(), pg.ErrIsExistCode) {
-			return "", &model.APIError{
+			return nil, &model.APIError{
 				Code:    http.StatusConflict,
 				Message: model.ErrUserAlreadyExistMessage,
 			}
 		}
-		return "", &model.APIError{
+		return nil, &model.APIError{
 			Code:    http.StatusInternalServerError,
 			Message: model.ErrInternalServerMessage,
 		}
 	}
 
-	token, err := auth.GenerateBearerToken(model.TokenInfo{
+	tokens, err := s.startSession(ctx, model.TokenInfo{
 		ID:    userID,
 		Login: input.Login,
-	}, s.tokenExp, s.tokenSecret)
+		Roles: []model.Role{model.RoleUser},
+	})
 	if err != nil {
-		return "", &model.APIError{
+		return nil, &model.APIError{
 			Code:    http.StatusInternalServerError,
 			Message: model.ErrInternalServerMessage,
 		}
 	}
 
-	return token, nil
+	return tokens, nil
 }
 
-func (s *Service) Login(input model.LoginDTO) (string, *model.APIError) {
+func (s *Service) Login(ctx context.Context, input model.LoginDTO, client model.ClientInfo) (*model.Tokens, *model.APIError) {
+	ctx, span := tracing.Start(ctx, "Service.Login")
+	defer span.End()
+
 	if err := validateLoginDTO(input); err != nil {
-		return "", &model.APIError{
+		return nil, &model.APIError{
 			Code:    http.StatusBadRequest,
 			Message: model.ErrInvalidLoginOrPasswordMessage,
 		}
 	}
 
-	user := s.storage.GetUserByLogin(input.Login)
-	if user == nil {
-		return "", &model.APIError{
-			Code:    http.StatusUnauthorized,
-			Message: model.ErrInvalidLoginOrPasswordMessage,
+	user := s.storage.GetUserByLogin(ctx, input.Login)
+
+	attempt := model.LoginAttempt{
+		Login:     input.Login,
+		IP:        client.IP,
+		UserAgent: client.UserAgent,
+	}
+	if user != nil {
+		attempt.UserID = user.ID
+	}
+
+	lockedUntil, err := s.storage.GetLoginLockedUntil(ctx, input.Login)
+	if err != nil {
+		return nil, &model.APIError{
+			Code:    http.StatusInternalServerError,
+			Message: model.ErrInternalServerMessage,
+		}
+	}
+	// при блокировке пароль не проверяем вовсе, чтобы его нельзя было подбирать и дальше
+	if lockedUntil != nil {
+		attempt.Result = model.LoginResultLocked
+		if err := s.storage.RecordLoginAttempt(ctx, attempt); err != nil {
+			return nil, &model.APIError{
+				Code:    http.StatusInternalServerError,
+				Message: model.ErrInternalServerMessage,
+			}
+		}
+		return nil, &model.APIError{
+			Code:    http.StatusTooManyRequests,
+			Message: model.ErrLoginLockedMessage,
 		}
 	}
 
-	if !password.CheckPasswordHash(input.Password, user.Password) {
-		return "", &model.APIError{
+	if user == nil || !checkPassword(ctx, input.Password, user.Password) {
+		attempt.Result = model.LoginResultInvalidCredentials
+		// без отмены: оборванное клиентом соединение не должно выводить подбор пароля из-под блокировки
+		if _, err := s.storage.RecordLoginFailure(context.WithoutCancel(ctx), attempt, s.loginLockout); err != nil {
+			return nil, &model.APIError{
+				Code:    http.StatusInternalServerError,
+				Message: model.ErrInternalServerMessage,
+			}
+		}
+		return nil, &model.APIError{
 			Code:    http.StatusUnauthorized,
 			Message: model.ErrInvalidLoginOrPasswordMessage,
 		}
 	}
 
-	token, err := auth.GenerateBearerToken(model.TokenInfo{
+	attempt.Result = model.LoginResultSuccess
+	if err := s.storage.RecordLoginAttempt(ctx, attempt); err != nil {
+		return nil, &model.APIError{
+			Code:    http.StatusInternalServerError,
+			Message: model.ErrInternalServerMessage,
+		}
+	}
+
+	s.rehashPassword(ctx, user, input.Password)
+
+	tokens, err := s.startSession(ctx, model.TokenInfo{
 		ID:    user.ID,
 		Login: user.Login,
-	}, s.tokenExp, s.tokenSecret)
+		Roles: user.Roles,
+	})
 	if err != nil {
-		return "", &model.APIError{
+		return nil, &model.APIError{
 			Code:    http.StatusInternalServerError,
 			Message: model.ErrInternalServerMessage,
 		}
 	}
 
-	return token, nil
+	return tokens, nil
 }
 
-func (s *Service) CreateOrder(userID int64, orderNumber string) *model.APIError {
+func (s *Service) CreateOrder(ctx context.Context, userID int64, orderNumber string) *model.APIError {
+	ctx, span := tracing.Start(ctx, "Service.CreateOrder")
+	defer span.End()
+
 	if err := validateOrderNumber(orderNumber); err != nil {
 		return err
 	}
 
-	err := s.storage.CreateOrder(userID, orderNumber)
+	err := s.storage.CreateOrder(ctx, userID, orderNumber)
 	if err != nil {
 		// номер заказа уже был загружен этим пользователем;
 		if errors.Is(err, model.ErrOrderHasBeenLoadedCurrentUser) {
@@ -152,8 +258,19 @@ func (s *Service) CreateOrder(userID int64, orderNumber string) *model.APIError
 	return nil
 }
 
-func (s *Service) GetOrders(userID int64) ([]model.Order, *model.APIError) {
-	orders, err := s.storage.GetOrdersByUserID(userID)
+func (s *Service) GetOrders(ctx context.Context, userID int64, filter model.ListFilter) (*model.OrdersPage, *model.APIError) {
+	ctx, span := tracing.Start(ctx, "Service.GetOrders")
+	defer span.End()
+
+	filter, err := normalizeListFilter(filter)
+	if err != nil {
+		return nil, &model.APIError{
+			Code:    http.StatusBadRequest,
+			Message: model.ErrInvalidListFilterMessage,
+		}
+	}
+
+	page, err := s.storage.GetOrdersByUserID(ctx, userID, filter)
 	if err != nil {
 		return nil, &model.APIError{
 			Code:    http.StatusInternalServerError,
@@ -161,18 +278,50 @@ func (s *Service) GetOrders(userID int64) ([]model.Order, *model.APIError) {
 		}
 	}
 
-	if len(orders) == 0 {
+	if len(page.Orders) == 0 {
 		return nil, &model.APIError{
 			Code:    http.StatusNoContent,
 			Message: model.ErrOrdersNotFoundMessage,
 		}
 	}
 
-	return orders, nil
+	return page, nil
 }
 
-func (s *Service) GetBalance(userID int64) (*model.Balance, *model.APIError) {
-	balance, err := s.storage.GetBalanceByUserID(userID)
+// GetOrder - заказ пользователя с историей статусов
+func (s *Service) GetOrder(ctx context.Context, userID int64, number string) (*model.OrderDetails, *model.APIError) {
+	ctx, span := tracing.Start(ctx, "Service.GetOrder")
+	defer span.End()
+
+	details, err := s.storage.GetOrderDetails(ctx, number)
+	if err != nil {
+		if errors.Is(err, model.ErrOrderNotFound) {
+			return nil, &model.APIError{
+				Code:    http.StatusNotFound,
+				Message: model.ErrOrderNotFoundMessage,
+			}
+		}
+		return nil, &model.APIError{
+			Code:    http.StatusInternalServerError,
+			Message: model.ErrInternalServerMessage,
+		}
+	}
+
+	if details.UserID != userID {
+		return nil, &model.APIError{
+			Code:    http.StatusForbidden,
+			Message: model.ErrOrderForbiddenMessage,
+		}
+	}
+
+	return details, nil
+}
+
+func (s *Service) GetBalance(ctx context.Context, userID int64) (*model.Balance, *model.APIError) {
+	ctx, span := tracing.Start(ctx, "Service.GetBalance")
+	defer span.End()
+
+	balance, err := s.storage.GetBalanceByUserID(ctx, userID)
 	if err != nil {
 		return nil, &model.APIError{
 			Code:    http.StatusInternalServerError,
@@ -183,8 +332,28 @@ func (s *Service) GetBalance(userID int64) (*model.Balance, *model.APIError) {
 	return balance, nil
 }
 
-func (s *Service) SetWithdraw(userID int64, input model.SetWithdrawDTO) *model.APIError {
-	err := s.storage.SetWithdraw(userID, input)
+// SetWithdraw - списание баллов; при непустом idempotencyKey повторный запрос
+// с тем же ключом возвращает сохранённый результат без повторного списания
+func (s *Service) SetWithdraw(ctx context.Context, userID int64, input model.SetWithdrawDTO, idempotencyKey string) *model.APIError {
+	ctx, span := tracing.Start(ctx, "Service.SetWithdraw")
+	defer span.End()
+
+	// некорректный запрос не занимает Idempotency-Key
+	if apiErr := validateWithdrawDTO(input); apiErr != nil {
+		return apiErr
+	}
+
+	if idempotencyKey == "" {
+		return s.setWithdraw(ctx, userID, input, "")
+	}
+
+	return s.withIdempotencyKey(ctx, userID, idempotencyKey, input, func() *model.APIError {
+		return s.setWithdraw(ctx, userID, input, idempotencyKey)
+	})
+}
+
+func (s *Service) setWithdraw(ctx context.Context, userID int64, input model.SetWithdrawDTO, idempotencyKey string) *model.APIError {
+	err := s.storage.SetWithdraw(ctx, userID, input, idempotencyKey)
 	if err != nil {
 		if errors.Is(err, model.ErrInsufficientFunds) {
 			return &model.APIError{
@@ -192,6 +361,12 @@ func (s *Service) SetWithdraw(userID int64, input model.SetWithdrawDTO) *model.A
 				Message: model.ErrInsufficientFundsMessage,
 			}
 		}
+		if errors.Is(err, model.ErrWithdrawOrderUsed) {
+			return &model.APIError{
+				Code:    http.StatusConflict,
+				Message: model.ErrWithdrawOrderUsedMessage,
+			}
+		}
 		return &model.APIError{
 			Code:    http.StatusInternalServerError,
 			Message: model.ErrInternalServerMessage,
@@ -201,8 +376,19 @@ func (s *Service) SetWithdraw(userID int64, input model.SetWithdrawDTO) *model.A
 	return nil
 }
 
-func (s *Service) GetWithdraws(userID int64) ([]model.Withdraw, *model.APIError) {
-	withdraws, err := s.storage.GetWithdrawsByUserID(userID)
+func (s *Service) GetWithdraws(ctx context.Context, userID int64, filter model.ListFilter) (*model.WithdrawsPage, *model.APIError) {
+	ctx, span := tracing.Start(ctx, "Service.GetWithdraws")
+	defer span.End()
+
+	filter, err := normalizeListFilter(filter)
+	if err != nil || len(filter.Statuses) > 0 {
+		return nil, &model.APIError{
+			Code:    http.StatusBadRequest,
+			Message: model.ErrInvalidListFilterMessage,
+		}
+	}
+
+	page, err := s.storage.GetWithdrawsByUserID(ctx, userID, filter)
 	if err != nil {
 		return nil, &model.APIError{
 			Code:    http.StatusInternalServerError,
@@ -210,5 +396,5 @@ func (s *Service) GetWithdraws(userID int64) ([]model.Withdraw, *model.APIError)
 		}
 	}
 
-	return withdraws, nil
+	return page, nil
 }
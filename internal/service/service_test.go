@@ -1,6 +1,7 @@
 package service
 
 import (
+	"context"
 	"errors"
 	"net/http"
 	"testing"
@@ -9,20 +10,23 @@ import (
 	"github.com/golang/mock/gomock"
 	"github.com/ibeloyar/gophermart/internal/model"
 	"github.com/ibeloyar/gophermart/internal/repository/pg"
-	"github.com/ibeloyar/gophermart/pgk/password"
+	"github.com/ibeloyar/gophermart/pgk/auth"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	mockPG "github.com/ibeloyar/gophermart/internal/repository/pg/mocks"
 )
 
 const validOrderNumber = "27220117637"
 
+var defaultListFilter = model.ListFilter{Limit: model.DefaultPageLimit, Sort: model.SortDesc}
+
 func TestService_Register_Success(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
 	mockStorage := mockPG.NewMockStorageRepo(ctrl)
-	svc := New(mockStorage, 3, 1*time.Hour, "secret")
+	svc := New(mockStorage, 3, 1*time.Hour, 24*time.Hour, 24*time.Hour, auth.NewHMACKeySet("secret"), nil)
 
 	input := model.RegisterDTO{
 		Login:    "testuser",
@@ -30,14 +34,20 @@ func TestService_Register_Success(t *testing.T) {
 	}
 
 	mockStorage.EXPECT().
-		CreateUser(gomock.Any()).
+		CreateUser(gomock.Any(), gomock.Any()).
 		Return(int64(123), nil).
 		Times(1)
 
-	token, apiErr := svc.Register(input)
+	mockStorage.EXPECT().
+		CreateSession(gomock.Any(), gomock.Any()).
+		Return(nil).
+		Times(1)
+
+	token, apiErr := svc.Register(context.Background(), input)
 
 	assert.Nil(t, apiErr)
-	assert.NotEmpty(t, token)
+	assert.NotEmpty(t, token.AccessToken)
+	assert.NotEmpty(t, token.RefreshToken)
 }
 
 func TestService_Register_CreateUserConflict(t *testing.T) {
@@ -45,7 +55,7 @@ func TestService_Register_CreateUserConflict(t *testing.T) {
 	defer ctrl.Finish()
 
 	mockStorage := mockPG.NewMockStorageRepo(ctrl)
-	svc := New(mockStorage, 3, 1*time.Hour, "secret")
+	svc := New(mockStorage, 3, 1*time.Hour, 24*time.Hour, 24*time.Hour, auth.NewHMACKeySet("secret"), nil)
 
 	input := model.RegisterDTO{
 		Login:    "testuser",
@@ -53,10 +63,10 @@ func TestService_Register_CreateUserConflict(t *testing.T) {
 	}
 
 	mockStorage.EXPECT().
-		CreateUser(gomock.Any()).
+		CreateUser(gomock.Any(), gomock.Any()).
 		Return(int64(0), errors.New(pg.ErrIsExistCode))
 
-	token, apiErr := svc.Register(input)
+	token, apiErr := svc.Register(context.Background(), input)
 
 	assert.Empty(t, token)
 	assert.NotNil(t, apiErr)
@@ -69,7 +79,7 @@ func TestService_Register_CreateUserError(t *testing.T) {
 	defer ctrl.Finish()
 
 	mockStorage := mockPG.NewMockStorageRepo(ctrl)
-	svc := New(mockStorage, 3, 1*time.Hour, "secret")
+	svc := New(mockStorage, 3, 1*time.Hour, 24*time.Hour, 24*time.Hour, auth.NewHMACKeySet("secret"), nil)
 
 	input := model.RegisterDTO{
 		Login:    "testuser",
@@ -77,10 +87,10 @@ func TestService_Register_CreateUserError(t *testing.T) {
 	}
 
 	mockStorage.EXPECT().
-		CreateUser(gomock.Any()).
+		CreateUser(gomock.Any(), gomock.Any()).
 		Return(int64(0), errors.New("database connection failed"))
 
-	token, apiErr := svc.Register(input)
+	token, apiErr := svc.Register(context.Background(), input)
 
 	assert.Empty(t, token)
 	assert.NotNil(t, apiErr)
@@ -93,7 +103,7 @@ func TestService_Register_UserExists(t *testing.T) {
 	defer ctrl.Finish()
 
 	mockStorage := mockPG.NewMockStorageRepo(ctrl)
-	svc := New(mockStorage, 3, 1*time.Hour, "secret")
+	svc := New(mockStorage, 3, 1*time.Hour, 24*time.Hour, 24*time.Hour, auth.NewHMACKeySet("secret"), nil)
 
 	input := model.RegisterDTO{
 		Login:    "testuser",
@@ -101,11 +111,11 @@ func TestService_Register_UserExists(t *testing.T) {
 	}
 
 	mockStorage.EXPECT().
-		CreateUser(gomock.Any()).
+		CreateUser(gomock.Any(), gomock.Any()).
 		Return(int64(0), errors.New(pg.ErrIsExistCode)).
 		Times(1)
 
-	token, apiErr := svc.Register(input)
+	token, apiErr := svc.Register(context.Background(), input)
 
 	assert.Empty(t, token)
 	assert.NotNil(t, apiErr)
@@ -117,14 +127,14 @@ func TestService_Login_Success(t *testing.T) {
 	defer ctrl.Finish()
 
 	mockStorage := mockPG.NewMockStorageRepo(ctrl)
-	svc := New(mockStorage, 3, 1*time.Hour, "secret")
+	svc := New(mockStorage, 3, 1*time.Hour, 24*time.Hour, 24*time.Hour, auth.NewHMACKeySet("secret"), nil)
 
 	input := model.LoginDTO{
 		Login:    "testuser",
 		Password: "testpass123",
 	}
 
-	hashedPass, err := password.HashPassword("testpass123", svc.passwordCost)
+	hashedPass, err := svc.hasher.Hash("testpass123")
 	assert.NoError(t, err)
 
 	user := &model.User{
@@ -134,14 +144,30 @@ func TestService_Login_Success(t *testing.T) {
 	}
 
 	mockStorage.EXPECT().
-		GetUserByLogin("testuser").
+		GetUserByLogin(gomock.Any(), "testuser").
 		Return(user).
 		Times(1)
 
-	token, apiErr := svc.Login(input)
+	mockStorage.EXPECT().GetLoginLockedUntil(gomock.Any(), "testuser").Return(nil, nil)
+	mockStorage.EXPECT().
+		RecordLoginAttempt(gomock.Any(), model.LoginAttempt{Login: "testuser", UserID: 123, Result: model.LoginResultSuccess, IP: "10.0.0.1", UserAgent: "curl/8.0"}).
+		Return(nil)
+
+	mockStorage.EXPECT().
+		CreateSession(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, session model.Session) error {
+			assert.Equal(t, int64(123), session.UserID)
+			assert.NotEmpty(t, session.ID)
+			assert.NotEmpty(t, session.RefreshTokenHash)
+			return nil
+		}).
+		Times(1)
+
+	token, apiErr := svc.Login(context.Background(), input, model.ClientInfo{IP: "10.0.0.1", UserAgent: "curl/8.0"})
 
 	assert.Nil(t, apiErr)
-	assert.NotEmpty(t, token)
+	assert.NotEmpty(t, token.AccessToken)
+	assert.NotEmpty(t, token.RefreshToken)
 }
 
 func TestService_Login_InvalidInput(t *testing.T) {
@@ -149,7 +175,7 @@ func TestService_Login_InvalidInput(t *testing.T) {
 	defer ctrl.Finish()
 
 	mockStorage := mockPG.NewMockStorageRepo(ctrl)
-	svc := New(mockStorage, 3, 1*time.Hour, "secret")
+	svc := New(mockStorage, 3, 1*time.Hour, 24*time.Hour, 24*time.Hour, auth.NewHMACKeySet("secret"), nil)
 
 	input := model.LoginDTO{
 		Login:    "testuser",
@@ -157,16 +183,20 @@ func TestService_Login_InvalidInput(t *testing.T) {
 	}
 
 	mockStorage.EXPECT().
-		GetUserByLogin("testuser").
-		Return(&model.User{Login: "testuser", Password: "not_test"}).
+		GetUserByLogin(gomock.Any(), "testuser").
+		Return(&model.User{ID: 5, Login: "testuser", Password: "not_test"}).
 		Times(1)
+	mockStorage.EXPECT().GetLoginLockedUntil(gomock.Any(), "testuser").Return(nil, nil)
+	mockStorage.EXPECT().
+		RecordLoginFailure(gomock.Any(), model.LoginAttempt{Login: "testuser", UserID: 5, Result: model.LoginResultInvalidCredentials}, svc.loginLockout).
+		Return(nil, nil)
 
-	token, apiErr := svc.Login(input)
+	token, apiErr := svc.Login(context.Background(), input, model.ClientInfo{})
 
 	assert.Empty(t, token)
 	assert.NotNil(t, apiErr)
 	assert.Equal(t, http.StatusUnauthorized, apiErr.Code)
-	mockStorage.EXPECT().GetUserByLogin(gomock.Any()).Times(0)
+	mockStorage.EXPECT().GetUserByLogin(gomock.Any(), gomock.Any()).Times(0)
 }
 
 func TestService_Login_UserNotFound(t *testing.T) {
@@ -174,7 +204,7 @@ func TestService_Login_UserNotFound(t *testing.T) {
 	defer ctrl.Finish()
 
 	mockStorage := mockPG.NewMockStorageRepo(ctrl)
-	svc := New(mockStorage, 3, 1*time.Hour, "secret")
+	svc := New(mockStorage, 3, 1*time.Hour, 24*time.Hour, 24*time.Hour, auth.NewHMACKeySet("secret"), nil)
 
 	input := model.LoginDTO{
 		Login:    "nonexistent",
@@ -182,30 +212,87 @@ func TestService_Login_UserNotFound(t *testing.T) {
 	}
 
 	mockStorage.EXPECT().
-		GetUserByLogin("nonexistent").
+		GetUserByLogin(gomock.Any(), "nonexistent").
 		Return(nil).
 		Times(1)
+	mockStorage.EXPECT().GetLoginLockedUntil(gomock.Any(), "nonexistent").Return(nil, nil)
+	mockStorage.EXPECT().
+		RecordLoginFailure(gomock.Any(), model.LoginAttempt{Login: "nonexistent", Result: model.LoginResultInvalidCredentials}, svc.loginLockout).
+		Return(nil, nil)
 
-	token, apiErr := svc.Login(input)
+	token, apiErr := svc.Login(context.Background(), input, model.ClientInfo{})
 
 	assert.Empty(t, token)
 	assert.NotNil(t, apiErr)
 	assert.Equal(t, http.StatusUnauthorized, apiErr.Code)
 }
 
+func TestService_Login_ClientGoneStillRecordsFailure(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := mockPG.NewMockStorageRepo(ctrl)
+	svc := New(mockStorage, 3, 1*time.Hour, 24*time.Hour, 24*time.Hour, auth.NewHMACKeySet("secret"), nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	mockStorage.EXPECT().GetUserByLogin(gomock.Any(), "nonexistent").Return(nil)
+	mockStorage.EXPECT().GetLoginLockedUntil(gomock.Any(), "nonexistent").
+		DoAndReturn(func(context.Context, string) (*time.Time, error) {
+			cancel() // клиент отключился посреди входа
+			return nil, nil
+		})
+	mockStorage.EXPECT().RecordLoginFailure(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, _ model.LoginAttempt, _ model.LockoutPolicy) (*time.Time, error) {
+			assert.NoError(t, ctx.Err())
+			return nil, nil
+		})
+
+	_, apiErr := svc.Login(ctx, model.LoginDTO{Login: "nonexistent", Password: "testpass123"}, model.ClientInfo{})
+
+	require.NotNil(t, apiErr)
+	assert.Equal(t, http.StatusUnauthorized, apiErr.Code)
+}
+
+func TestService_Login_Locked(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := mockPG.NewMockStorageRepo(ctrl)
+	svc := New(mockStorage, 3, 1*time.Hour, 24*time.Hour, 24*time.Hour, auth.NewHMACKeySet("secret"), nil)
+
+	hashedPass, err := svc.hasher.Hash("testpass123")
+	require.NoError(t, err)
+
+	lockedUntil := time.Now().Add(time.Minute)
+
+	mockStorage.EXPECT().GetUserByLogin(gomock.Any(), "testuser").Return(&model.User{ID: 5, Login: "testuser", Password: hashedPass})
+	mockStorage.EXPECT().GetLoginLockedUntil(gomock.Any(), "testuser").Return(&lockedUntil, nil)
+	mockStorage.EXPECT().
+		RecordLoginAttempt(gomock.Any(), model.LoginAttempt{Login: "testuser", UserID: 5, Result: model.LoginResultLocked, IP: "10.0.0.1"}).
+		Return(nil)
+
+	// верный пароль во время блокировки не помогает
+	token, apiErr := svc.Login(context.Background(), model.LoginDTO{Login: "testuser", Password: "testpass123"}, model.ClientInfo{IP: "10.0.0.1"})
+
+	assert.Nil(t, token)
+	require.NotNil(t, apiErr)
+	assert.Equal(t, http.StatusTooManyRequests, apiErr.Code)
+}
+
 func TestService_CreateOrder_Success(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
 	mockStorage := mockPG.NewMockStorageRepo(ctrl)
-	svc := New(mockStorage, 3, 1*time.Hour, "secret")
+	svc := New(mockStorage, 3, 1*time.Hour, 24*time.Hour, 24*time.Hour, auth.NewHMACKeySet("secret"), nil)
 
 	mockStorage.EXPECT().
-		CreateOrder(int64(123), validOrderNumber).
+		CreateOrder(gomock.Any(), int64(123), validOrderNumber).
 		Return(nil).
 		Times(1)
 
-	apiErr := svc.CreateOrder(123, validOrderNumber)
+	apiErr := svc.CreateOrder(context.Background(), 123, validOrderNumber)
 
 	assert.Nil(t, apiErr)
 }
@@ -215,14 +302,14 @@ func TestService_CreateOrder_InvalidOrderNumber(t *testing.T) {
 	defer ctrl.Finish()
 
 	mockStorage := mockPG.NewMockStorageRepo(ctrl)
-	svc := New(mockStorage, 3, 1*time.Hour, "secret")
+	svc := New(mockStorage, 3, 1*time.Hour, 24*time.Hour, 24*time.Hour, auth.NewHMACKeySet("secret"), nil)
 
 	invalidOrderNumber := "1"
 
-	apiErr := svc.CreateOrder(123, invalidOrderNumber)
+	apiErr := svc.CreateOrder(context.Background(), 123, invalidOrderNumber)
 
 	assert.NotNil(t, apiErr)
-	mockStorage.EXPECT().CreateOrder(gomock.Any(), gomock.Any()).Times(0)
+	mockStorage.EXPECT().CreateOrder(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
 }
 
 func TestService_CreateOrder_AlreadyLoadedCurrentUser(t *testing.T) {
@@ -230,14 +317,14 @@ func TestService_CreateOrder_AlreadyLoadedCurrentUser(t *testing.T) {
 	defer ctrl.Finish()
 
 	mockStorage := mockPG.NewMockStorageRepo(ctrl)
-	svc := New(mockStorage, 3, 1*time.Hour, "secret")
+	svc := New(mockStorage, 3, 1*time.Hour, 24*time.Hour, 24*time.Hour, auth.NewHMACKeySet("secret"), nil)
 
 	mockStorage.EXPECT().
-		CreateOrder(int64(123), validOrderNumber).
+		CreateOrder(gomock.Any(), int64(123), validOrderNumber).
 		Return(model.ErrOrderHasBeenLoadedCurrentUser).
 		Times(1)
 
-	apiErr := svc.CreateOrder(123, validOrderNumber)
+	apiErr := svc.CreateOrder(context.Background(), 123, validOrderNumber)
 
 	assert.NotNil(t, apiErr)
 	assert.Equal(t, http.StatusOK, apiErr.Code)
@@ -252,11 +339,11 @@ func TestService_CreateOrder_AlreadyLoadedOtherUser(t *testing.T) {
 	svc := &Service{storage: mockStorage}
 
 	mockStorage.EXPECT().
-		CreateOrder(int64(123), validOrderNumber).
+		CreateOrder(gomock.Any(), int64(123), validOrderNumber).
 		Return(model.ErrOrderHasBeenLoadedSomeUser).
 		Times(1)
 
-	apiErr := svc.CreateOrder(123, validOrderNumber)
+	apiErr := svc.CreateOrder(context.Background(), 123, validOrderNumber)
 
 	assert.NotNil(t, apiErr)
 	assert.Equal(t, http.StatusConflict, apiErr.Code)
@@ -273,11 +360,11 @@ func TestService_CreateOrder_StorageError(t *testing.T) {
 	unexpectedErr := errors.New("unexpected database error")
 
 	mockStorage.EXPECT().
-		CreateOrder(int64(123), validOrderNumber).
+		CreateOrder(gomock.Any(), int64(123), validOrderNumber).
 		Return(unexpectedErr).
 		Times(1)
 
-	apiErr := svc.CreateOrder(123, validOrderNumber)
+	apiErr := svc.CreateOrder(context.Background(), 123, validOrderNumber)
 
 	assert.NotNil(t, apiErr)
 	assert.Equal(t, http.StatusInternalServerError, apiErr.Code)
@@ -289,19 +376,19 @@ func TestService_GetOrders_Success(t *testing.T) {
 	defer ctrl.Finish()
 
 	mockStorage := mockPG.NewMockStorageRepo(ctrl)
-	svc := New(mockStorage, 3, 1*time.Hour, "secret")
+	svc := New(mockStorage, 3, 1*time.Hour, 24*time.Hour, 24*time.Hour, auth.NewHMACKeySet("secret"), nil)
 
 	orders := []model.Order{{Number: validOrderNumber}}
 
 	mockStorage.EXPECT().
-		GetOrdersByUserID(int64(123)).
-		Return(orders, nil).
+		GetOrdersByUserID(gomock.Any(), int64(123), defaultListFilter).
+		Return(&model.OrdersPage{Orders: orders}, nil).
 		Times(1)
 
-	result, apiErr := svc.GetOrders(123)
+	result, apiErr := svc.GetOrders(context.Background(), 123, model.ListFilter{})
 
 	assert.Nil(t, apiErr)
-	assert.Equal(t, orders, result)
+	assert.Equal(t, orders, result.Orders)
 }
 
 func TestService_GetOrders_Empty(t *testing.T) {
@@ -309,14 +396,14 @@ func TestService_GetOrders_Empty(t *testing.T) {
 	defer ctrl.Finish()
 
 	mockStorage := mockPG.NewMockStorageRepo(ctrl)
-	svc := New(mockStorage, 3, 1*time.Hour, "secret")
+	svc := New(mockStorage, 3, 1*time.Hour, 24*time.Hour, 24*time.Hour, auth.NewHMACKeySet("secret"), nil)
 
 	mockStorage.EXPECT().
-		GetOrdersByUserID(int64(123)).
-		Return(nil, nil).
+		GetOrdersByUserID(gomock.Any(), int64(123), defaultListFilter).
+		Return(&model.OrdersPage{}, nil).
 		Times(1)
 
-	_, apiErr := svc.GetOrders(123)
+	_, apiErr := svc.GetOrders(context.Background(), 123, model.ListFilter{})
 
 	assert.NotNil(t, apiErr)
 	assert.Equal(t, http.StatusNoContent, apiErr.Code)
@@ -327,14 +414,14 @@ func TestService_GetOrders_Error(t *testing.T) {
 	defer ctrl.Finish()
 
 	mockStorage := mockPG.NewMockStorageRepo(ctrl)
-	svc := New(mockStorage, 3, 1*time.Hour, "secret")
+	svc := New(mockStorage, 3, 1*time.Hour, 24*time.Hour, 24*time.Hour, auth.NewHMACKeySet("secret"), nil)
 
 	mockStorage.EXPECT().
-		GetOrdersByUserID(int64(123)).
+		GetOrdersByUserID(gomock.Any(), int64(123), defaultListFilter).
 		Return(nil, errors.New("db error")).
 		Times(1)
 
-	_, apiErr := svc.GetOrders(123)
+	_, apiErr := svc.GetOrders(context.Background(), 123, model.ListFilter{})
 
 	assert.NotNil(t, apiErr)
 	assert.Equal(t, http.StatusInternalServerError, apiErr.Code)
@@ -345,16 +432,16 @@ func TestService_GetBalance_Success(t *testing.T) {
 	defer ctrl.Finish()
 
 	mockStorage := mockPG.NewMockStorageRepo(ctrl)
-	svc := New(mockStorage, 3, 1*time.Hour, "secret")
+	svc := New(mockStorage, 3, 1*time.Hour, 24*time.Hour, 24*time.Hour, auth.NewHMACKeySet("secret"), nil)
 
-	balance := &model.Balance{Current: 100.5, Withdrawn: 50.0}
+	balance := &model.Balance{Current: 10050, Withdrawn: 5000}
 
 	mockStorage.EXPECT().
-		GetBalanceByUserID(int64(123)).
+		GetBalanceByUserID(gomock.Any(), int64(123)).
 		Return(balance, nil).
 		Times(1)
 
-	result, apiErr := svc.GetBalance(123)
+	result, apiErr := svc.GetBalance(context.Background(), 123)
 
 	assert.Nil(t, apiErr)
 	assert.Equal(t, balance, result)
@@ -365,62 +452,119 @@ func TestService_GetBalance_Error(t *testing.T) {
 	defer ctrl.Finish()
 
 	mockStorage := mockPG.NewMockStorageRepo(ctrl)
-	svc := New(mockStorage, 3, 1*time.Hour, "secret")
+	svc := New(mockStorage, 3, 1*time.Hour, 24*time.Hour, 24*time.Hour, auth.NewHMACKeySet("secret"), nil)
 
 	mockStorage.EXPECT().
-		GetBalanceByUserID(int64(123)).
+		GetBalanceByUserID(gomock.Any(), int64(123)).
 		Return(nil, errors.New("db error")).
 		Times(1)
 
-	_, apiErr := svc.GetBalance(123)
+	_, apiErr := svc.GetBalance(context.Background(), 123)
 
 	assert.NotNil(t, apiErr)
 	assert.Equal(t, http.StatusInternalServerError, apiErr.Code)
 }
 
-func TestService_SetWithdraw_Success(t *testing.T) {
-	ctrl := gomock.NewController(t)
-	defer ctrl.Finish()
-
-	mockStorage := mockPG.NewMockStorageRepo(ctrl)
-	svc := New(mockStorage, 3, 1*time.Hour, "secret")
-
-	input := model.SetWithdrawDTO{
-		Order: validOrderNumber,
-		Sum:   10.5,
+func TestService_SetWithdraw(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    model.SetWithdrawDTO
+		repoErr  error
+		callRepo bool
+		wantCode int
+	}{
+		{
+			name:     "success",
+			input:    model.SetWithdrawDTO{Order: validOrderNumber, Sum: 1050},
+			callRepo: true,
+		},
+		{
+			name:     "empty order",
+			input:    model.SetWithdrawDTO{Order: "", Sum: 1050},
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "order fails luhn check",
+			input:    model.SetWithdrawDTO{Order: "27220117638", Sum: 1050},
+			wantCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:     "order with letters",
+			input:    model.SetWithdrawDTO{Order: "order-123", Sum: 1050},
+			wantCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:     "zero sum",
+			input:    model.SetWithdrawDTO{Order: validOrderNumber, Sum: 0},
+			wantCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:     "negative sum",
+			input:    model.SetWithdrawDTO{Order: validOrderNumber, Sum: -1050},
+			wantCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:     "insufficient funds",
+			input:    model.SetWithdrawDTO{Order: validOrderNumber, Sum: 1050},
+			repoErr:  model.ErrInsufficientFunds,
+			callRepo: true,
+			wantCode: http.StatusPaymentRequired,
+		},
+		{
+			name:     "order already used",
+			input:    model.SetWithdrawDTO{Order: validOrderNumber, Sum: 1050},
+			repoErr:  model.ErrWithdrawOrderUsed,
+			callRepo: true,
+			wantCode: http.StatusConflict,
+		},
+		{
+			name:     "db error",
+			input:    model.SetWithdrawDTO{Order: validOrderNumber, Sum: 1050},
+			repoErr:  errors.New("db error"),
+			callRepo: true,
+			wantCode: http.StatusInternalServerError,
+		},
 	}
 
-	mockStorage.EXPECT().
-		SetWithdraw(int64(123), input).
-		Return(nil).
-		Times(1)
-
-	apiErr := svc.SetWithdraw(123, input)
-
-	assert.Nil(t, apiErr)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockStorage := mockPG.NewMockStorageRepo(ctrl)
+			svc := New(mockStorage, 3, 1*time.Hour, 24*time.Hour, 24*time.Hour, auth.NewHMACKeySet("secret"), nil)
+
+			if tt.callRepo {
+				mockStorage.EXPECT().
+					SetWithdraw(gomock.Any(), int64(123), tt.input, "").
+					Return(tt.repoErr).
+					Times(1)
+			}
+
+			apiErr := svc.SetWithdraw(context.Background(), 123, tt.input, "")
+
+			if tt.wantCode == 0 {
+				assert.Nil(t, apiErr)
+				return
+			}
+			require.NotNil(t, apiErr)
+			assert.Equal(t, tt.wantCode, apiErr.Code)
+		})
+	}
 }
 
-func TestService_SetWithdraw_Error(t *testing.T) {
+func TestService_SetWithdraw_InvalidInputKeepsIdempotencyKey(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
+	// ни резервирования ключа, ни списания
 	mockStorage := mockPG.NewMockStorageRepo(ctrl)
-	svc := New(mockStorage, 3, 1*time.Hour, "secret")
-
-	input := model.SetWithdrawDTO{
-		Order: validOrderNumber,
-		Sum:   10.5,
-	}
+	svc := New(mockStorage, 3, 1*time.Hour, 24*time.Hour, 24*time.Hour, auth.NewHMACKeySet("secret"), nil)
 
-	mockStorage.EXPECT().
-		SetWithdraw(int64(123), input).
-		Return(errors.New("db error")).
-		Times(1)
+	apiErr := svc.SetWithdraw(context.Background(), 123, model.SetWithdrawDTO{Order: "123", Sum: 1050}, "key-1")
 
-	apiErr := svc.SetWithdraw(123, input)
-
-	assert.NotNil(t, apiErr)
-	assert.Equal(t, http.StatusInternalServerError, apiErr.Code)
+	require.NotNil(t, apiErr)
+	assert.Equal(t, http.StatusUnprocessableEntity, apiErr.Code)
 }
 
 func TestService_GetWithdraws_Success(t *testing.T) {
@@ -428,19 +572,19 @@ func TestService_GetWithdraws_Success(t *testing.T) {
 	defer ctrl.Finish()
 
 	mockStorage := mockPG.NewMockStorageRepo(ctrl)
-	svc := New(mockStorage, 3, 1*time.Hour, "secret")
+	svc := New(mockStorage, 3, 1*time.Hour, 24*time.Hour, 24*time.Hour, auth.NewHMACKeySet("secret"), nil)
 
 	withdraws := []model.Withdraw{{OrderNumber: validOrderNumber}}
 
 	mockStorage.EXPECT().
-		GetWithdrawsByUserID(int64(123)).
-		Return(withdraws, nil).
+		GetWithdrawsByUserID(gomock.Any(), int64(123), defaultListFilter).
+		Return(&model.WithdrawsPage{Withdraws: withdraws}, nil).
 		Times(1)
 
-	result, apiErr := svc.GetWithdraws(123)
+	result, apiErr := svc.GetWithdraws(context.Background(), 123, model.ListFilter{})
 
 	assert.Nil(t, apiErr)
-	assert.Equal(t, withdraws, result)
+	assert.Equal(t, withdraws, result.Withdraws)
 }
 
 func TestService_GetWithdraws_Error(t *testing.T) {
@@ -448,15 +592,88 @@ func TestService_GetWithdraws_Error(t *testing.T) {
 	defer ctrl.Finish()
 
 	mockStorage := mockPG.NewMockStorageRepo(ctrl)
-	svc := New(mockStorage, 3, 1*time.Hour, "secret")
+	svc := New(mockStorage, 3, 1*time.Hour, 24*time.Hour, 24*time.Hour, auth.NewHMACKeySet("secret"), nil)
 
 	mockStorage.EXPECT().
-		GetWithdrawsByUserID(int64(123)).
+		GetWithdrawsByUserID(gomock.Any(), int64(123), defaultListFilter).
 		Return(nil, errors.New("db error")).
 		Times(1)
 
-	_, apiErr := svc.GetWithdraws(123)
+	_, apiErr := svc.GetWithdraws(context.Background(), 123, model.ListFilter{})
 
 	assert.NotNil(t, apiErr)
 	assert.Equal(t, http.StatusInternalServerError, apiErr.Code)
 }
+
+func TestService_GetOrders_InvalidFilter(t *testing.T) {
+	from := time.Now()
+
+	tests := []struct {
+		name   string
+		filter model.ListFilter
+	}{
+		{"negative limit", model.ListFilter{Limit: -1}},
+		{"limit too large", model.ListFilter{Limit: model.MaxPageLimit + 1}},
+		{"unknown sort", model.ListFilter{Sort: "sideways"}},
+		{"unknown status", model.ListFilter{Statuses: []model.OrderStatus{"DONE"}}},
+		{"empty range", model.ListFilter{From: &from, To: &from}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			svc := New(mockPG.NewMockStorageRepo(ctrl), 3, 1*time.Hour, 24*time.Hour, 24*time.Hour, auth.NewHMACKeySet("secret"), nil)
+
+			_, apiErr := svc.GetOrders(context.Background(), 123, tt.filter)
+
+			assert.NotNil(t, apiErr)
+			assert.Equal(t, http.StatusBadRequest, apiErr.Code)
+		})
+	}
+}
+
+func TestService_GetOrder(t *testing.T) {
+	details := &model.OrderDetails{
+		Order: model.Order{UserID: 123, Number: validOrderNumber, Status: model.OrderStatusProcessed},
+		History: []model.OrderStatusChange{
+			{Status: model.OrderStatusNew},
+			{Status: model.OrderStatusProcessed},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		details  *model.OrderDetails
+		err      error
+		wantCode int
+	}{
+		{name: "own order", details: details},
+		{name: "unknown order", err: model.ErrOrderNotFound, wantCode: http.StatusNotFound},
+		{name: "other owner", details: &model.OrderDetails{Order: model.Order{UserID: 456}}, wantCode: http.StatusForbidden},
+		{name: "storage error", err: errors.New("db error"), wantCode: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockStorage := mockPG.NewMockStorageRepo(ctrl)
+			svc := New(mockStorage, 3, 1*time.Hour, 24*time.Hour, 24*time.Hour, auth.NewHMACKeySet("secret"), nil)
+
+			mockStorage.EXPECT().GetOrderDetails(gomock.Any(), validOrderNumber).Return(tt.details, tt.err)
+
+			result, apiErr := svc.GetOrder(context.Background(), 123, validOrderNumber)
+
+			if tt.wantCode == 0 {
+				assert.Nil(t, apiErr)
+				assert.Equal(t, tt.details, result)
+				return
+			}
+			assert.NotNil(t, apiErr)
+			assert.Equal(t, tt.wantCode, apiErr.Code)
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/ibeloyar/gophermart/internal/model"
+	"github.com/ibeloyar/gophermart/internal/tracing"
+	"github.com/ibeloyar/gophermart/pgk/auth"
+)
+
+// RefreshTokens - выпускает новую пару токенов по refresh-токену и ротирует его.
+// Повторное предъявление уже ротированного refresh-токена отзывает всю сессию.
+func (s *Service) RefreshTokens(ctx context.Context, refreshToken string) (*model.Tokens, *model.APIError) {
+	ctx, span := tracing.Start(ctx, "Service.RefreshTokens")
+	defer span.End()
+
+	unauthorized := &model.APIError{
+		Code:    http.StatusUnauthorized,
+		Message: model.ErrInvalidRefreshTokenMessage,
+	}
+
+	sessionID, hash, err := auth.ParseRefreshToken(refreshToken)
+	if err != nil {
+		return nil, unauthorized
+	}
+
+	session, err := s.storage.GetSessionByID(ctx, sessionID)
+	if err != nil {
+		if errors.Is(err, model.ErrSessionNotFound) {
+			return nil, unauthorized
+		}
+		return nil, &model.APIError{
+			Code:    http.StatusInternalServerError,
+			Message: model.ErrInternalServerMessage,
+		}
+	}
+
+	if session.RevokedAt != nil || !session.ExpiresAt.After(time.Now()) {
+		return nil, unauthorized
+	}
+
+	if session.RefreshTokenHash != hash {
+		// id сессии публичен (jti access-токена), поэтому чужой токен сессию не трогает;
+		// повтор уже ротированного - вероятна утечка, отзываем сессию целиком
+		rotated, err := s.storage.IsRefreshTokenRotated(ctx, session.ID, hash)
+		if err != nil {
+			return nil, &model.APIError{
+				Code:    http.StatusInternalServerError,
+				Message: model.ErrInternalServerMessage,
+			}
+		}
+		if rotated {
+			_ = s.storage.RevokeSession(ctx, session.ID)
+		}
+		return nil, unauthorized
+	}
+
+	user := s.storage.GetUserByID(ctx, session.UserID)
+	if user == nil {
+		return nil, unauthorized
+	}
+
+	newRefreshToken, newHash, err := auth.NewRefreshToken(session.ID)
+	if err != nil {
+		return nil, &model.APIError{
+			Code:    http.StatusInternalServerError,
+			Message: model.ErrInternalServerMessage,
+		}
+	}
+
+	err = s.storage.RotateSession(ctx, session.ID, hash, newHash, time.Now().Add(s.refreshExp))
+	if err != nil {
+		if errors.Is(err, model.ErrSessionNotFound) {
+			return nil, unauthorized
+		}
+		return nil, &model.APIError{
+			Code:    http.StatusInternalServerError,
+			Message: model.ErrInternalServerMessage,
+		}
+	}
+
+	accessToken, err := auth.GenerateBearerToken(model.TokenInfo{
+		ID:    user.ID,
+		Login: user.Login,
+		Roles: user.Roles,
+	}, session.ID, s.tokenExp, s.tokenKeys)
+	if err != nil {
+		return nil, &model.APIError{
+			Code:    http.StatusInternalServerError,
+			Message: model.ErrInternalServerMessage,
+		}
+	}
+
+	return &model.Tokens{
+		AccessToken:  accessToken,
+		RefreshToken: newRefreshToken,
+	}, nil
+}
+
+func (s *Service) Logout(ctx context.Context, sessionID string) *model.APIError {
+	ctx, span := tracing.Start(ctx, "Service.Logout")
+	defer span.End()
+
+	if err := s.storage.RevokeSession(ctx, sessionID); err != nil {
+		return &model.APIError{
+			Code:    http.StatusInternalServerError,
+			Message: model.ErrInternalServerMessage,
+		}
+	}
+
+	return nil
+}
+
+// IsSessionRevoked - при ошибке хранилища сессия считается отозванной
+func (s *Service) IsSessionRevoked(ctx context.Context, sessionID string) bool {
+	ctx, span := tracing.Start(ctx, "Service.IsSessionRevoked")
+	defer span.End()
+
+	revoked, err := s.storage.IsSessionRevoked(ctx, sessionID)
+	if err != nil {
+		return true
+	}
+
+	return revoked
+}
+
+// startSession - создаёт сессию и выпускает для неё access- и refresh-токены
+func (s *Service) startSession(ctx context.Context, info model.TokenInfo) (*model.Tokens, error) {
+	sessionID, err := auth.NewSessionID()
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, refreshHash, err := auth.NewRefreshToken(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.storage.CreateSession(ctx, model.Session{
+		ID:               sessionID,
+		UserID:           info.ID,
+		RefreshTokenHash: refreshHash,
+		ExpiresAt:        time.Now().Add(s.refreshExp),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken, err := auth.GenerateBearerToken(info, sessionID, s.tokenExp, s.tokenKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.Tokens{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+	}, nil
+}
@@ -0,0 +1,205 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/ibeloyar/gophermart/internal/model"
+	"github.com/ibeloyar/gophermart/pgk/auth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	mockPG "github.com/ibeloyar/gophermart/internal/repository/pg/mocks"
+)
+
+func TestService_RefreshTokens_Success(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := mockPG.NewMockStorageRepo(ctrl)
+	svc := New(mockStorage, 3, 1*time.Hour, 24*time.Hour, 24*time.Hour, auth.NewHMACKeySet("secret"), nil)
+
+	refreshToken, hash, err := auth.NewRefreshToken("session-1")
+	require.NoError(t, err)
+
+	mockStorage.EXPECT().
+		GetSessionByID(gomock.Any(), "session-1").
+		Return(&model.Session{ID: "session-1", UserID: 123, RefreshTokenHash: hash, ExpiresAt: time.Now().Add(time.Hour)}, nil).
+		Times(1)
+
+	mockStorage.EXPECT().
+		GetUserByID(gomock.Any(), int64(123)).
+		Return(&model.User{ID: 123, Login: "testuser"}).
+		Times(1)
+
+	mockStorage.EXPECT().
+		RotateSession(gomock.Any(), "session-1", hash, gomock.Any(), gomock.Any()).
+		Return(nil).
+		Times(1)
+
+	tokens, apiErr := svc.RefreshTokens(context.Background(), refreshToken)
+
+	assert.Nil(t, apiErr)
+	assert.NotEqual(t, refreshToken, tokens.RefreshToken)
+
+	claims, err := auth.ParseJWTBearerToken[model.TokenInfo](tokens.AccessToken, svc.tokenKeys)
+	require.NoError(t, err)
+	assert.Equal(t, "session-1", claims.ID)
+	assert.Equal(t, int64(123), claims.TokenInfo.ID)
+}
+
+func TestService_RefreshTokens_ReuseRevokesSession(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := mockPG.NewMockStorageRepo(ctrl)
+	svc := New(mockStorage, 3, 1*time.Hour, 24*time.Hour, 24*time.Hour, auth.NewHMACKeySet("secret"), nil)
+
+	staleToken, staleHash, err := auth.NewRefreshToken("session-1")
+	require.NoError(t, err)
+
+	mockStorage.EXPECT().
+		GetSessionByID(gomock.Any(), "session-1").
+		Return(&model.Session{ID: "session-1", UserID: 123, RefreshTokenHash: "current", ExpiresAt: time.Now().Add(time.Hour)}, nil).
+		Times(1)
+
+	mockStorage.EXPECT().
+		IsRefreshTokenRotated(gomock.Any(), "session-1", staleHash).
+		Return(true, nil).
+		Times(1)
+
+	mockStorage.EXPECT().
+		RevokeSession(gomock.Any(), "session-1").
+		Return(nil).
+		Times(1)
+
+	tokens, apiErr := svc.RefreshTokens(context.Background(), staleToken)
+
+	assert.Nil(t, tokens)
+	assert.NotNil(t, apiErr)
+	assert.Equal(t, http.StatusUnauthorized, apiErr.Code)
+}
+
+func TestService_RefreshTokens_ForgedTokenKeepsSession(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := mockPG.NewMockStorageRepo(ctrl)
+	svc := New(mockStorage, 3, 1*time.Hour, 24*time.Hour, 24*time.Hour, auth.NewHMACKeySet("secret"), nil)
+
+	// id сессии известен из access-токена, но сам refresh-токен никогда не выдавался
+	forgedToken, forgedHash, err := auth.NewRefreshToken("session-1")
+	require.NoError(t, err)
+
+	mockStorage.EXPECT().
+		GetSessionByID(gomock.Any(), "session-1").
+		Return(&model.Session{ID: "session-1", UserID: 123, RefreshTokenHash: "current", ExpiresAt: time.Now().Add(time.Hour)}, nil).
+		Times(1)
+
+	mockStorage.EXPECT().
+		IsRefreshTokenRotated(gomock.Any(), "session-1", forgedHash).
+		Return(false, nil).
+		Times(1)
+
+	mockStorage.EXPECT().
+		RevokeSession(gomock.Any(), gomock.Any()).
+		Times(0)
+
+	tokens, apiErr := svc.RefreshTokens(context.Background(), forgedToken)
+
+	assert.Nil(t, tokens)
+	require.NotNil(t, apiErr)
+	assert.Equal(t, http.StatusUnauthorized, apiErr.Code)
+}
+
+func TestService_RefreshTokens_Unauthorized(t *testing.T) {
+	refreshToken, hash, err := auth.NewRefreshToken("session-1")
+	require.NoError(t, err)
+	revokedAt := time.Now()
+
+	tests := []struct {
+		name    string
+		session *model.Session
+		err     error
+	}{
+		{
+			name: "unknown session",
+			err:  model.ErrSessionNotFound,
+		},
+		{
+			name:    "revoked session",
+			session: &model.Session{ID: "session-1", RefreshTokenHash: hash, ExpiresAt: time.Now().Add(time.Hour), RevokedAt: &revokedAt},
+		},
+		{
+			name:    "expired session",
+			session: &model.Session{ID: "session-1", RefreshTokenHash: hash, ExpiresAt: time.Now().Add(-time.Hour)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockStorage := mockPG.NewMockStorageRepo(ctrl)
+			svc := New(mockStorage, 3, 1*time.Hour, 24*time.Hour, 24*time.Hour, auth.NewHMACKeySet("secret"), nil)
+
+			mockStorage.EXPECT().
+				GetSessionByID(gomock.Any(), "session-1").
+				Return(tt.session, tt.err).
+				Times(1)
+
+			_, apiErr := svc.RefreshTokens(context.Background(), refreshToken)
+
+			assert.NotNil(t, apiErr)
+			assert.Equal(t, http.StatusUnauthorized, apiErr.Code)
+		})
+	}
+}
+
+func TestService_RefreshTokens_MalformedToken(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := mockPG.NewMockStorageRepo(ctrl)
+	svc := New(mockStorage, 3, 1*time.Hour, 24*time.Hour, 24*time.Hour, auth.NewHMACKeySet("secret"), nil)
+
+	_, apiErr := svc.RefreshTokens(context.Background(), "malformed")
+
+	assert.NotNil(t, apiErr)
+	assert.Equal(t, http.StatusUnauthorized, apiErr.Code)
+}
+
+func TestService_Logout(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := mockPG.NewMockStorageRepo(ctrl)
+	svc := New(mockStorage, 3, 1*time.Hour, 24*time.Hour, 24*time.Hour, auth.NewHMACKeySet("secret"), nil)
+
+	mockStorage.EXPECT().
+		RevokeSession(gomock.Any(), "session-1").
+		Return(nil).
+		Times(1)
+
+	assert.Nil(t, svc.Logout(context.Background(), "session-1"))
+}
+
+func TestService_IsSessionRevoked_StorageErrorFailsClosed(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := mockPG.NewMockStorageRepo(ctrl)
+	svc := New(mockStorage, 3, 1*time.Hour, 24*time.Hour, 24*time.Hour, auth.NewHMACKeySet("secret"), nil)
+
+	mockStorage.EXPECT().
+		IsSessionRevoked(gomock.Any(), "session-1").
+		Return(false, errors.New("db error")).
+		Times(1)
+
+	assert.True(t, svc.IsSessionRevoked(context.Background(), "session-1"))
+}
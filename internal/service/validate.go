@@ -3,6 +3,9 @@ package service
 import (
 	"errors"
 	"net/http"
+	"slices"
+	"strings"
+	"unicode"
 
 	"github.com/ibeloyar/gophermart/internal/model"
 )
@@ -17,24 +20,25 @@ const (
 	asciiTen  = 57
 )
 
+// validateLoginDTO - политика паролей при входе не проверяется: старые пароли могут ей не соответствовать
 func validateLoginDTO(input model.LoginDTO) error {
-	if err := validateLogin(input.Password); err != nil {
+	if err := validateLogin(input.Login); err != nil {
 		return err
 	}
 
-	if err := validatePassword(input.Password); err != nil {
+	if err := validatePassword(input.Password, model.PasswordPolicy{}); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-func validateRegisterDTO(input model.RegisterDTO) error {
-	if err := validateLogin(input.Password); err != nil {
+func validateRegisterDTO(input model.RegisterDTO, policy model.PasswordPolicy) error {
+	if err := validateLogin(input.Login); err != nil {
 		return err
 	}
 
-	if err := validatePassword(input.Password); err != nil {
+	if err := validatePassword(input.Password, policy); err != nil {
 		return err
 	}
 
@@ -49,14 +53,41 @@ func validateLogin(login string) error {
 	return nil
 }
 
-func validatePassword(password string) error {
+func validatePassword(password string, policy model.PasswordPolicy) error {
 	if len(password) < minPassLen || len(password) > maxPassLen {
 		return model.ErrInvalidLoginOrPassword
 	}
 
+	if passwordClasses(password) < policy.MinClasses {
+		return model.ErrPasswordTooWeak
+	}
+
+	if slices.Contains(policy.Denylist, strings.ToLower(password)) {
+		return model.ErrPasswordTooCommon
+	}
+
 	return nil
 }
 
+// passwordClasses - сколько классов символов в пароле: строчные, прописные, цифры, прочие
+func passwordClasses(password string) int {
+	var lower, upper, digit, other int
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = 1
+		case unicode.IsUpper(r):
+			upper = 1
+		case unicode.IsDigit(r):
+			digit = 1
+		default:
+			other = 1
+		}
+	}
+
+	return lower + upper + digit + other
+}
+
 func validateOrderNumber(number string) *model.APIError {
 	if number == "" {
 		return &model.APIError{
@@ -85,6 +116,22 @@ func validateOrderNumber(number string) *model.APIError {
 	return nil
 }
 
+// validateWithdrawDTO - пустой номер - 400, номер не по Луну и неположительная сумма - 422
+func validateWithdrawDTO(input model.SetWithdrawDTO) *model.APIError {
+	if apiErr := validateOrderNumber(input.Order); apiErr != nil {
+		return apiErr
+	}
+
+	if input.Sum <= 0 {
+		return &model.APIError{
+			Code:    http.StatusUnprocessableEntity,
+			Message: model.ErrWithdrawSumMessage,
+		}
+	}
+
+	return nil
+}
+
 func calculateLuhnSum(number string, parity int) (int64, error) {
 	var sum int64
 	for i, d := range number {
@@ -109,3 +156,33 @@ func calculateLuhnSum(number string, parity int) (int64, error) {
 
 	return sum, nil
 }
+
+// normalizeListFilter - проверяет параметры выборки и подставляет значения по умолчанию
+func normalizeListFilter(filter model.ListFilter) (model.ListFilter, error) {
+	switch {
+	case filter.Limit == 0:
+		filter.Limit = model.DefaultPageLimit
+	case filter.Limit < 0 || filter.Limit > model.MaxPageLimit:
+		return filter, errors.New("limit out of range")
+	}
+
+	switch filter.Sort {
+	case "":
+		filter.Sort = model.SortDesc
+	case model.SortAsc, model.SortDesc:
+	default:
+		return filter, errors.New("unknown sort order")
+	}
+
+	for _, status := range filter.Statuses {
+		if !status.IsValid() {
+			return filter, errors.New("unknown order status")
+		}
+	}
+
+	if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+		return filter, errors.New("empty time range")
+	}
+
+	return filter, nil
+}
@@ -21,10 +21,27 @@ func TestValidateRegisterDTO_Valid(t *testing.T) {
 	err := validateRegisterDTO(model.RegisterDTO{
 		Login:    "user123",
 		Password: "pass1234",
-	})
+	}, model.PasswordPolicy{})
 	require.NoError(t, err)
 }
 
+func TestValidateLoginDTO_ShortLogin(t *testing.T) {
+	// логин проверяется сам по себе, а не длиной пароля
+	err := validateLoginDTO(model.LoginDTO{
+		Login:    "ab",
+		Password: "pass1234",
+	})
+	require.ErrorIs(t, err, model.ErrInvalidLoginOrPassword)
+}
+
+func TestValidateRegisterDTO_ShortLogin(t *testing.T) {
+	err := validateRegisterDTO(model.RegisterDTO{
+		Login:    "ab",
+		Password: "pass1234",
+	}, model.PasswordPolicy{})
+	require.ErrorIs(t, err, model.ErrInvalidLoginOrPassword)
+}
+
 func TestValidateLogin_Valid(t *testing.T) {
 	tests := []string{
 		"abc",
@@ -61,7 +78,7 @@ func TestValidatePassword_Valid(t *testing.T) {
 	}
 	for _, pwd := range tests {
 		t.Run(pwd, func(t *testing.T) {
-			err := validatePassword(pwd)
+			err := validatePassword(pwd, model.PasswordPolicy{})
 			require.NoError(t, err)
 		})
 	}
@@ -75,12 +92,37 @@ func TestValidatePassword_Invalid(t *testing.T) {
 	}
 	for _, pwd := range tests {
 		t.Run(pwd, func(t *testing.T) {
-			err := validatePassword(pwd)
+			err := validatePassword(pwd, model.PasswordPolicy{})
 			require.ErrorIs(t, err, model.ErrInvalidLoginOrPassword)
 		})
 	}
 }
 
+func TestValidatePassword_Policy(t *testing.T) {
+	policy := model.PasswordPolicy{MinClasses: 3, Denylist: []string{"passw0rd!"}}
+
+	tests := []struct {
+		password string
+		wantErr  error
+	}{
+		{"Pass1234", nil},
+		{"pass-1234", nil},
+		{"pass1234", model.ErrPasswordTooWeak},
+		{"PASSWORD", model.ErrPasswordTooWeak},
+		{"Passw0rd!", model.ErrPasswordTooCommon},
+	}
+	for _, tt := range tests {
+		t.Run(tt.password, func(t *testing.T) {
+			err := validatePassword(tt.password, policy)
+			if tt.wantErr == nil {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
+
 func TestValidateOrderNumber_Empty(t *testing.T) {
 	err := validateOrderNumber("")
 	assert.Equal(t, &model.APIError{
@@ -0,0 +1,235 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ibeloyar/gophermart/internal/model"
+	"github.com/ibeloyar/gophermart/pgk/retryablehttp"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultPollInterval    = 5 * time.Second
+	defaultBatchSize       = 100
+	defaultLease           = time.Minute
+	defaultDeliveryTimeout = 30 * time.Second
+	defaultMaxAttempts     = 10
+	defaultBaseBackoff     = 10 * time.Second
+	defaultMaxBackoff      = time.Hour
+)
+
+// DeliveryStore - outbox webhook'ов
+type DeliveryStore interface {
+	// ClaimWebhookDeliveries - забирает готовые к отправке доставки и откладывает их на lease
+	ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]model.WebhookDelivery, error)
+	MarkWebhookDelivered(ctx context.Context, id int64) error
+	// FailWebhookDelivery - переносит доставку на nextAttemptAt; dead = true переводит её в dead-letter
+	FailWebhookDelivery(ctx context.Context, id int64, nextAttemptAt time.Time, dead bool, lastErr error) error
+}
+
+type Config struct {
+	PollInterval    time.Duration
+	Workers         int
+	BatchSize       int
+	Lease           time.Duration
+	DeliveryTimeout time.Duration
+	// MaxAttempts - после стольких неудачных попыток доставка уходит в dead-letter
+	MaxAttempts int
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+}
+
+// Dispatcher - периодически отправляет события из outbox на зарегистрированный endpoint
+type Dispatcher struct {
+	store  DeliveryStore
+	client *retryablehttp.RetryableClient
+	cfg    Config
+	lg     *zap.SugaredLogger
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func NewDispatcher(store DeliveryStore, client *retryablehttp.RetryableClient, cfg Config, lg *zap.SugaredLogger) *Dispatcher {
+	if cfg.PollInterval == 0 {
+		cfg.PollInterval = defaultPollInterval
+	}
+	if cfg.Workers == 0 {
+		cfg.Workers = runtime.NumCPU()
+	}
+	if cfg.BatchSize == 0 {
+		cfg.BatchSize = defaultBatchSize
+	}
+	if cfg.Lease == 0 {
+		cfg.Lease = defaultLease
+	}
+	if cfg.DeliveryTimeout == 0 {
+		cfg.DeliveryTimeout = defaultDeliveryTimeout
+	}
+	if cfg.MaxAttempts == 0 {
+		cfg.MaxAttempts = defaultMaxAttempts
+	}
+	if cfg.BaseBackoff == 0 {
+		cfg.BaseBackoff = defaultBaseBackoff
+	}
+	if cfg.MaxBackoff == 0 {
+		cfg.MaxBackoff = defaultMaxBackoff
+	}
+
+	return &Dispatcher{
+		store:  store,
+		client: client,
+		cfg:    cfg,
+		lg:     lg,
+	}
+}
+
+// Start - запускает отправку в отдельной горутине
+func (d *Dispatcher) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	d.cancel = cancel
+	d.done = make(chan struct{})
+
+	go d.run(ctx)
+}
+
+// Shutdown - останавливает отправку и ждёт завершения текущего цикла.
+// Прерванные доставки вернутся в очередь по истечении lease.
+func (d *Dispatcher) Shutdown(ctx context.Context) error {
+	if d.cancel == nil {
+		return nil
+	}
+
+	d.cancel()
+
+	select {
+	case <-d.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (d *Dispatcher) run(ctx context.Context) {
+	defer close(d.done)
+
+	ticker := time.NewTicker(d.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			d.poll(ctx)
+		}
+	}
+}
+
+// poll - один цикл: забирает пачку доставок и отправляет её пулом воркеров
+func (d *Dispatcher) poll(ctx context.Context) {
+	deliveries, err := d.store.ClaimWebhookDeliveries(ctx, d.cfg.BatchSize, d.cfg.Lease)
+	if err != nil {
+		d.lg.Errorf("claiming webhook deliveries error: %v", err)
+		return
+	}
+
+	if len(deliveries) == 0 {
+		return
+	}
+
+	queue := make(chan model.WebhookDelivery)
+	wg := sync.WaitGroup{}
+
+	for i := 0; i < min(d.cfg.Workers, len(deliveries)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for delivery := range queue {
+				d.process(ctx, delivery)
+			}
+		}()
+	}
+
+	for _, delivery := range deliveries {
+		queue <- delivery
+	}
+	close(queue)
+
+	wg.Wait()
+}
+
+func (d *Dispatcher) process(ctx context.Context, delivery model.WebhookDelivery) {
+	if err := d.deliver(ctx, delivery); err != nil {
+		// остановка сервиса - не вина получателя, доставка вернётся в очередь по lease
+		if ctx.Err() != nil {
+			return
+		}
+
+		dead := delivery.Attempts+1 >= d.cfg.MaxAttempts
+		if dead {
+			d.lg.Warnf("webhook delivery %d moved to dead-letter after %d attempts: %v", delivery.ID, delivery.Attempts+1, err)
+		}
+
+		nextAttemptAt := time.Now().Add(d.backoff(delivery.Attempts))
+		if err = d.store.FailWebhookDelivery(ctx, delivery.ID, nextAttemptAt, dead, err); err != nil {
+			d.lg.Errorf("failing webhook delivery error: %v", err)
+		}
+		return
+	}
+
+	if err := d.store.MarkWebhookDelivered(ctx, delivery.ID); err != nil {
+		d.lg.Errorf("marking webhook delivered error: %v", err)
+	}
+}
+
+// deliver - подписанный POST на endpoint; успехом считается любой 2xx
+func (d *Dispatcher) deliver(ctx context.Context, delivery model.WebhookDelivery) error {
+	ctx, cancel := context.WithTimeout(ctx, d.cfg.DeliveryTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return err
+	}
+
+	timestamp := time.Now().Unix()
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderID, delivery.EventID)
+	req.Header.Set(HeaderEvent, string(delivery.EventType))
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(delivery.Secret, timestamp, delivery.Payload))
+
+	resp, err := d.client.Do(ctx, req)
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("receiver returned %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// backoff - экспоненциальная задержка повторной доставки
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	delay := d.cfg.BaseBackoff
+	for i := 0; i < attempts && delay < d.cfg.MaxBackoff; i++ {
+		delay *= 2
+	}
+
+	return min(delay, d.cfg.MaxBackoff)
+}
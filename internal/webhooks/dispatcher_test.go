@@ -0,0 +1,198 @@
+package webhooks
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ibeloyar/gophermart/internal/model"
+	"github.com/ibeloyar/gophermart/pgk/retryablehttp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type failed struct {
+	nextAttemptAt time.Time
+	dead          bool
+	lastErr       error
+}
+
+type fakeStore struct {
+	mu         sync.Mutex
+	deliveries []model.WebhookDelivery
+	delivered  []int64
+	failed     map[int64]failed
+}
+
+func newFakeStore(deliveries ...model.WebhookDelivery) *fakeStore {
+	return &fakeStore{
+		deliveries: deliveries,
+		failed:     make(map[int64]failed),
+	}
+}
+
+func (s *fakeStore) ClaimWebhookDeliveries(_ context.Context, limit int, _ time.Duration) ([]model.WebhookDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := min(limit, len(s.deliveries))
+	claimed := s.deliveries[:n]
+	s.deliveries = s.deliveries[n:]
+
+	return claimed, nil
+}
+
+func (s *fakeStore) MarkWebhookDelivered(_ context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.delivered = append(s.delivered, id)
+
+	return nil
+}
+
+func (s *fakeStore) FailWebhookDelivery(_ context.Context, id int64, nextAttemptAt time.Time, dead bool, lastErr error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.failed[id] = failed{nextAttemptAt: nextAttemptAt, dead: dead, lastErr: lastErr}
+
+	return nil
+}
+
+func newTestDispatcher(store DeliveryStore) *Dispatcher {
+	client := retryablehttp.NewRetryableClient(retryablehttp.RetryConfig{
+		MaxRetries: 1,
+		BaseDelay:  time.Millisecond,
+		MaxDelay:   time.Millisecond,
+		MaxJitter:  time.Millisecond,
+	})
+
+	return NewDispatcher(store, client, Config{
+		PollInterval: 10 * time.Millisecond,
+		Workers:      1,
+		MaxAttempts:  3,
+		BaseBackoff:  time.Second,
+		MaxBackoff:   10 * time.Second,
+	}, zap.NewNop().Sugar())
+}
+
+func TestDispatcher_Poll_DeliversSignedPayload(t *testing.T) {
+	payload := []byte(`{"id":"abc","type":"order.accrued","data":{"user_id":1,"order":"123","accrual":500}}`)
+
+	var (
+		gotBody  []byte
+		verified bool
+		event    string
+	)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotBody, _ = io.ReadAll(r.Body)
+		verified = Verify("s3cret", r.Header.Get(HeaderSignature), r.Header.Get(HeaderTimestamp), gotBody, time.Minute)
+		event = r.Header.Get(HeaderEvent)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	store := newFakeStore(model.WebhookDelivery{
+		ID:        1,
+		URL:       receiver.URL,
+		Secret:    "s3cret",
+		EventID:   "abc",
+		EventType: model.WebhookEventOrderAccrued,
+		Payload:   payload,
+	})
+
+	newTestDispatcher(store).poll(context.Background())
+
+	assert.Equal(t, payload, gotBody)
+	assert.True(t, verified)
+	assert.Equal(t, "order.accrued", event)
+	assert.Equal(t, []int64{1}, store.delivered)
+	assert.Empty(t, store.failed)
+}
+
+func TestDispatcher_Poll_RetriesWithBody(t *testing.T) {
+	payload := []byte(`{"id":"abc"}`)
+
+	var calls atomic.Int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		assert.Equal(t, payload, body)
+
+		// первая попытка падает, повтор внутри retryablehttp должен отправить то же тело
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer receiver.Close()
+
+	store := newFakeStore(model.WebhookDelivery{ID: 1, URL: receiver.URL, Secret: "s3cret", Payload: payload})
+
+	newTestDispatcher(store).poll(context.Background())
+
+	assert.Equal(t, int32(2), calls.Load())
+	assert.Equal(t, []int64{1}, store.delivered)
+}
+
+func TestDispatcher_Poll_FailureBacksOffThenDeadLetters(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer receiver.Close()
+
+	store := newFakeStore(
+		model.WebhookDelivery{ID: 1, URL: receiver.URL, Payload: []byte(`{}`), Attempts: 1},
+		model.WebhookDelivery{ID: 2, URL: receiver.URL, Payload: []byte(`{}`), Attempts: 2},
+	)
+
+	start := time.Now()
+	newTestDispatcher(store).poll(context.Background())
+
+	assert.Empty(t, store.delivered)
+	require.Len(t, store.failed, 2)
+
+	assert.False(t, store.failed[1].dead)
+	assert.EqualError(t, store.failed[1].lastErr, "receiver returned 400")
+	// 1s * 2^1
+	assert.WithinDuration(t, start.Add(2*time.Second), store.failed[1].nextAttemptAt, time.Second)
+
+	// третья неудачная попытка при MaxAttempts = 3
+	assert.True(t, store.failed[2].dead)
+}
+
+func TestVerify(t *testing.T) {
+	body := []byte(`{"id":"abc"}`)
+	now := time.Now().Unix()
+	signature := Sign("s3cret", now, body)
+	timestamp := strconv.FormatInt(now, 10)
+
+	tests := []struct {
+		name      string
+		secret    string
+		signature string
+		timestamp string
+		body      []byte
+		want      bool
+	}{
+		{"valid", "s3cret", signature, timestamp, body, true},
+		{"wrong secret", "other", signature, timestamp, body, false},
+		{"tampered body", "s3cret", signature, timestamp, []byte(`{"id":"abd"}`), false},
+		{"stale timestamp", "s3cret", Sign("s3cret", now-3600, body), strconv.FormatInt(now-3600, 10), body, false},
+		{"bad timestamp", "s3cret", signature, "yesterday", body, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Verify(tt.secret, tt.signature, tt.timestamp, tt.body, time.Minute))
+		})
+	}
+}
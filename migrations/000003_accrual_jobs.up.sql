@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS accrual_jobs (
+    order_number VARCHAR(255) PRIMARY KEY REFERENCES orders(number) ON DELETE CASCADE,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_error TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS accrual_jobs_next_attempt_at_idx ON accrual_jobs (next_attempt_at);
+
+INSERT INTO accrual_jobs (order_number)
+SELECT number FROM orders WHERE status IN ('NEW', 'PROCESSING')
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS balance_accrual_order_number_uniq;
+DROP TABLE IF EXISTS ledger_anomalies;
+ALTER TABLE balance DROP COLUMN IF EXISTS kind;
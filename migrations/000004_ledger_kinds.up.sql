@@ -0,0 +1,30 @@
+ALTER TABLE balance ADD COLUMN IF NOT EXISTS kind VARCHAR(16);
+
+UPDATE balance SET kind = CASE WHEN amount < 0 THEN 'WITHDRAWAL' ELSE 'ACCRUAL' END WHERE kind IS NULL;
+
+ALTER TABLE balance ALTER COLUMN kind SET NOT NULL;
+
+-- расхождения журнала, найденные миграциями; разбираются вручную (см. cmd/reconcile)
+CREATE TABLE IF NOT EXISTS ledger_anomalies (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    kind VARCHAR(32) NOT NULL,
+    order_number VARCHAR(255),
+    amount NUMERIC(12, 2) NOT NULL,
+    detected_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- повторные начисления по одному заказу (после рестартов/ретраев) не удаляем: баллы могли быть уже потрачены.
+-- Самое раннее остаётся ACCRUAL, остальные помечаются ACCRUAL_DUP и выпадают из уникального индекса.
+WITH dup AS (
+    UPDATE balance b SET kind = 'ACCRUAL_DUP'
+    FROM balance d
+    WHERE b.kind = 'ACCRUAL' AND d.kind = 'ACCRUAL'
+      AND b.order_number = d.order_number
+      AND b.id > d.id
+    RETURNING b.user_id, b.order_number, b.amount
+)
+INSERT INTO ledger_anomalies (user_id, kind, order_number, amount)
+SELECT user_id, 'DUPLICATE_ACCRUAL', order_number, amount FROM dup;
+
+CREATE UNIQUE INDEX IF NOT EXISTS balance_accrual_order_number_uniq ON balance (order_number) WHERE kind = 'ACCRUAL';
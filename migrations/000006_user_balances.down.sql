@@ -0,0 +1,2 @@
+DELETE FROM ledger_anomalies WHERE kind = 'NEGATIVE_BALANCE';
+DROP TABLE IF EXISTS user_balances;
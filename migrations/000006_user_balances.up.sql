@@ -0,0 +1,23 @@
+CREATE TABLE IF NOT EXISTS user_balances (
+    user_id INTEGER PRIMARY KEY REFERENCES users(id),
+    current NUMERIC(12, 2) NOT NULL DEFAULT 0 CHECK (current >= 0),
+    withdrawn NUMERIC(12, 2) NOT NULL DEFAULT 0 CHECK (withdrawn >= 0),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- журнал мог уйти в минус (гонки списаний до появления снимков): снимок обрезается до нуля,
+-- а расхождение записывается в ledger_anomalies и показывается cmd/reconcile
+WITH ledger AS (
+    SELECT user_id,
+        SUM(amount) AS current,
+        SUM(CASE WHEN kind = 'WITHDRAWAL' THEN ABS(amount) ELSE 0 END) AS withdrawn
+    FROM balance
+    GROUP BY user_id
+), negative AS (
+    INSERT INTO ledger_anomalies (user_id, kind, amount)
+    SELECT user_id, 'NEGATIVE_BALANCE', current FROM ledger WHERE current < 0
+)
+INSERT INTO user_balances (user_id, current, withdrawn)
+SELECT user_id, GREATEST(current, 0), withdrawn
+FROM ledger
+ON CONFLICT (user_id) DO NOTHING;
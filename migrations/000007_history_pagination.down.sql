@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS balance_user_id_kind_uploaded_at_idx;
+DROP INDEX IF EXISTS orders_user_id_uploaded_at_idx;
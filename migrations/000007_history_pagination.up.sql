@@ -0,0 +1,2 @@
+CREATE INDEX IF NOT EXISTS orders_user_id_uploaded_at_idx ON orders (user_id, uploaded_at, id);
+CREATE INDEX IF NOT EXISTS balance_user_id_kind_uploaded_at_idx ON balance (user_id, kind, uploaded_at, id);
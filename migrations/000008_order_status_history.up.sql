@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS order_status_history (
+    id INTEGER GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    order_number VARCHAR(255) NOT NULL REFERENCES orders(number) ON DELETE CASCADE,
+    status VARCHAR(10) NOT NULL,
+    changed_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS order_status_history_order_number_idx ON order_status_history (order_number, changed_at, id);
+
+-- для существующих заказов известно только время загрузки
+INSERT INTO order_status_history (order_number, status, changed_at)
+SELECT number, 'NEW', uploaded_at FROM orders;
+
+INSERT INTO order_status_history (order_number, status, changed_at)
+SELECT number, status, uploaded_at FROM orders WHERE status <> 'NEW';
@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS order_status_history_notify ON order_status_history;
+DROP FUNCTION IF EXISTS notify_order_event();
+ALTER TABLE order_status_history DROP COLUMN IF EXISTS accrual;
@@ -0,0 +1,26 @@
+ALTER TABLE order_status_history ADD COLUMN IF NOT EXISTS accrual NUMERIC(10, 2) NOT NULL DEFAULT 0;
+
+UPDATE order_status_history h SET accrual = o.accrual
+FROM orders o
+WHERE o.number = h.order_number AND h.status = 'PROCESSED';
+
+-- события опроса системы расчёта для SSE на других репликах (LISTEN order_events)
+CREATE OR REPLACE FUNCTION notify_order_event() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_notify('order_events', json_build_object(
+        'id', NEW.id,
+        'user_id', (SELECT user_id FROM orders WHERE number = NEW.order_number),
+        'number', NEW.order_number,
+        'status', NEW.status,
+        'accrual', NEW.accrual,
+        'changed_at', NEW.changed_at
+    )::text);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER order_status_history_notify
+    AFTER INSERT ON order_status_history
+    FOR EACH ROW
+    WHEN (NEW.status <> 'NEW')
+    EXECUTE FUNCTION notify_order_event();
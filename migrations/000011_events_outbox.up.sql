@@ -0,0 +1,11 @@
+-- доменные события; пишутся в одной транзакции с изменением данных, порядок ленты - по id
+CREATE TABLE IF NOT EXISTS events_outbox (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    event_type VARCHAR(64) NOT NULL,
+    user_id INTEGER NOT NULL,
+    payload TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    published_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS events_outbox_unpublished_idx ON events_outbox (id) WHERE published_at IS NULL;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS admin_audit_log;
+ALTER TABLE balance DROP COLUMN IF EXISTS reason;
+DROP TABLE IF EXISTS user_roles;
@@ -0,0 +1,24 @@
+-- роли пользователей; первого администратора назначают вручную:
+-- INSERT INTO user_roles (user_id, role) SELECT id, 'admin' FROM users WHERE login = '...';
+CREATE TABLE IF NOT EXISTS user_roles (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role VARCHAR(16) NOT NULL CONSTRAINT user_roles_role_check CHECK (role IN ('admin')),
+    granted_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, role)
+);
+
+-- причина ручной корректировки баланса (kind = 'ADJUSTMENT')
+ALTER TABLE balance ADD COLUMN IF NOT EXISTS reason TEXT;
+
+CREATE TABLE IF NOT EXISTS admin_audit_log (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    admin_id INTEGER REFERENCES users(id) NOT NULL,
+    action VARCHAR(32) NOT NULL,
+    user_id INTEGER,
+    order_number VARCHAR(255),
+    details TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS admin_audit_log_admin_id_created_at_idx ON admin_audit_log (admin_id, created_at);
+CREATE INDEX IF NOT EXISTS admin_audit_log_user_id_idx ON admin_audit_log (user_id);
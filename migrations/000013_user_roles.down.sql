@@ -0,0 +1,4 @@
+DELETE FROM user_roles WHERE role <> 'admin';
+
+ALTER TABLE user_roles DROP CONSTRAINT IF EXISTS user_roles_role_check;
+ALTER TABLE user_roles ADD CONSTRAINT user_roles_role_check CHECK (role IN ('admin'));
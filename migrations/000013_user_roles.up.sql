@@ -0,0 +1,6 @@
+-- роли кроме admin: user выдаётся всем пользователям, support и merchant - вручную
+ALTER TABLE user_roles DROP CONSTRAINT IF EXISTS user_roles_role_check;
+ALTER TABLE user_roles ADD CONSTRAINT user_roles_role_check CHECK (role IN ('user', 'support', 'admin', 'merchant'));
+
+INSERT INTO user_roles (user_id, role) SELECT id, 'user' FROM users
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,19 @@
+-- партия баллов: каждое начисление (ACCRUAL или положительная корректировка) с остатком и сроком сгорания.
+-- Списания расходуют партии от старых к новым, просроченный остаток списывается записью EXPIRATION.
+CREATE TABLE IF NOT EXISTS point_lots (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    balance_id INTEGER UNIQUE REFERENCES balance(id),
+    amount NUMERIC(10, 2) NOT NULL CHECK (amount > 0),
+    remaining NUMERIC(10, 2) NOT NULL CHECK (remaining >= 0 AND remaining <= amount),
+    -- NULL - бессрочно
+    expires_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS point_lots_user_id_open_idx ON point_lots (user_id, id) WHERE remaining > 0;
+CREATE INDEX IF NOT EXISTS point_lots_expires_at_open_idx ON point_lots (expires_at) WHERE remaining > 0;
+
+-- накопленный до появления партий баланс не сгорает
+INSERT INTO point_lots (user_id, amount, remaining)
+SELECT user_id, current, current FROM user_balances WHERE current > 0;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS balance_refund_of_uniq;
+ALTER TABLE balance DROP COLUMN IF EXISTS refund_of;
@@ -0,0 +1,4 @@
+-- возврат списания (kind = 'REFUND') ссылается на исходную запись WITHDRAWAL; вернуть её можно только один раз
+ALTER TABLE balance ADD COLUMN IF NOT EXISTS refund_of INTEGER REFERENCES balance(id);
+
+CREATE UNIQUE INDEX IF NOT EXISTS balance_refund_of_uniq ON balance (refund_of) WHERE refund_of IS NOT NULL;
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS balance_withdrawal_order_number_uniq;
@@ -0,0 +1,20 @@
+-- один номер заказа - одно списание.
+-- Повторы, записанные до появления проверки, не трогаем: индекс покрывает только записи после последнего из них,
+-- а повторное использование старых номеров отсекает проверка в SetWithdraw.
+DO $$
+DECLARE
+    cutoff INTEGER;
+BEGIN
+    SELECT COALESCE(MAX(b.id), 0) INTO cutoff
+    FROM balance b
+    WHERE b.kind = 'WITHDRAWAL'
+      AND EXISTS (
+          SELECT 1 FROM balance d
+          WHERE d.kind = 'WITHDRAWAL' AND d.order_number = b.order_number AND d.id <> b.id
+      );
+
+    EXECUTE format(
+        'CREATE UNIQUE INDEX IF NOT EXISTS balance_withdrawal_order_number_uniq ON balance (order_number) WHERE kind = %L AND id > %s',
+        'WITHDRAWAL', cutoff
+    );
+END $$;
@@ -0,0 +1,21 @@
+-- попытки входа: user_id пуст, если логин не найден
+CREATE TABLE IF NOT EXISTS login_attempts (
+    id SERIAL PRIMARY KEY,
+    login VARCHAR(255) NOT NULL,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    result VARCHAR(32) NOT NULL CHECK (result IN ('SUCCESS', 'INVALID_CREDENTIALS', 'LOCKED')),
+    ip VARCHAR(64) NOT NULL DEFAULT '',
+    user_agent VARCHAR(512) NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS login_attempts_user_id_created_at_idx ON login_attempts (user_id, created_at, id);
+
+-- счётчик неудачных входов по логину; lockouts - число блокировок подряд для роста их длительности
+CREATE TABLE IF NOT EXISTS login_lockouts (
+    login VARCHAR(255) PRIMARY KEY,
+    failures INTEGER NOT NULL DEFAULT 0,
+    window_started_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    lockouts INTEGER NOT NULL DEFAULT 0,
+    locked_until TIMESTAMP WITH TIME ZONE
+);
@@ -0,0 +1,27 @@
+-- возврат заказа в NEW администратором - тоже событие для SSE; не рассылается только первая запись NEW (загрузка заказа)
+CREATE OR REPLACE FUNCTION notify_order_event() RETURNS trigger AS $$
+BEGIN
+    IF NEW.status = 'NEW' AND NOT EXISTS (
+        SELECT 1 FROM order_status_history WHERE order_number = NEW.order_number AND id < NEW.id
+    ) THEN
+        RETURN NEW;
+    END IF;
+
+    PERFORM pg_notify('order_events', json_build_object(
+        'id', NEW.id,
+        'user_id', (SELECT user_id FROM orders WHERE number = NEW.order_number),
+        'number', NEW.order_number,
+        'status', NEW.status,
+        'accrual', NEW.accrual,
+        'changed_at', NEW.changed_at
+    )::text);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS order_status_history_notify ON order_status_history;
+
+CREATE TRIGGER order_status_history_notify
+    AFTER INSERT ON order_status_history
+    FOR EACH ROW
+    EXECUTE FUNCTION notify_order_event();
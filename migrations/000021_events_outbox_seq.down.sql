@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS events_outbox_published_at_idx;
+ALTER TABLE events_outbox DROP COLUMN IF EXISTS seq;
+DROP SEQUENCE IF EXISTS events_outbox_seq;
@@ -0,0 +1,18 @@
+-- seq - номер события в ленте, выдаётся при публикации под advisory-блокировкой публикатора.
+-- id отражает порядок вставки, а не фиксации: транзакция с меньшим id может зафиксироваться позже.
+CREATE SEQUENCE IF NOT EXISTS events_outbox_seq;
+
+ALTER TABLE events_outbox ADD COLUMN IF NOT EXISTS seq BIGINT UNIQUE;
+
+-- уже опубликованные события получают номера в порядке публикации
+UPDATE events_outbox e SET seq = p.rn
+FROM (
+    SELECT id, row_number() OVER (ORDER BY published_at, id) AS rn
+    FROM events_outbox WHERE published_at IS NOT NULL
+) p
+WHERE e.id = p.id;
+
+SELECT setval('events_outbox_seq', COALESCE((SELECT MAX(seq) FROM events_outbox), 0) + 1, false);
+
+-- для удаления опубликованных событий старше срока хранения
+CREATE INDEX IF NOT EXISTS events_outbox_published_at_idx ON events_outbox (published_at) WHERE published_at IS NOT NULL;
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS webhook_endpoints_single_active_idx;
@@ -0,0 +1,10 @@
+-- события (начисления и списания) не привязаны к магазину, поэтому каждый endpoint
+-- получал данные всех пользователей. Получатель один - sink оператора программы лояльности.
+-- Все прежние endpoint'ы отключаются, неотправленные им события уходят в dead-letter;
+-- sink оператора регистрируется заново через cmd/webhooks.
+UPDATE webhook_outbox SET status = 'DEAD', last_error = 'endpoint disabled: per-tenant webhooks leaked other tenants'' events'
+WHERE status = 'PENDING';
+
+UPDATE webhook_endpoints SET active = FALSE WHERE active;
+
+CREATE UNIQUE INDEX IF NOT EXISTS webhook_endpoints_single_active_idx ON webhook_endpoints ((TRUE)) WHERE active;
@@ -0,0 +1,8 @@
+-- хеши уже ротированных refresh-токенов сессии. Сессия отзывается только при повторе одного из них:
+-- id сессии публичен (jti access-токена), и произвольный "<sid>.мусор" не должен её отзывать
+CREATE TABLE IF NOT EXISTS session_rotated_tokens (
+    session_id VARCHAR(64) NOT NULL REFERENCES sessions(id) ON DELETE CASCADE,
+    token_hash VARCHAR(64) NOT NULL,
+    rotated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (session_id, token_hash)
+);
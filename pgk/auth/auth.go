@@ -14,23 +14,29 @@ import (
 
 type tokenDataContextKeyType string
 
-const tokenDataContextKey = tokenDataContextKeyType("token")
+const (
+	tokenDataContextKey = tokenDataContextKeyType("token")
+	sessionIDContextKey = tokenDataContextKeyType("session")
+)
+
+// SessionRevokedFunc - проверяет, отозвана ли сессия с указанным ID
+type SessionRevokedFunc func(ctx context.Context, sessionID string) bool
 
 type Claims[T any] struct {
 	jwt.RegisteredClaims
 	TokenInfo T
 }
 
-func GenerateBearerToken[T any](input T, exp time.Duration, secret string) (token string, err error) {
-	tokenData := jwt.NewWithClaims(jwt.SigningMethodHS256, &Claims[T]{
+// GenerateBearerToken - выпускает access-токен, привязанный к сессии sessionID (claim jti)
+func GenerateBearerToken[T any](input T, sessionID string, exp time.Duration, keys *KeySet) (token string, err error) {
+	token, err = keys.sign(&Claims[T]{
 		TokenInfo: input,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        sessionID,
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(exp)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	})
-
-	token, err = tokenData.SignedString([]byte(secret))
 	if err != nil {
 		return "", err
 	}
@@ -38,7 +44,8 @@ func GenerateBearerToken[T any](input T, exp time.Duration, secret string) (toke
 	return fmt.Sprintf("Bearer %s", token), nil
 }
 
-func VerifyJWTBearerToken[T any](tokenString, secret string) (*T, error) {
+// ParseJWTBearerToken - проверяет токен и возвращает все его claims
+func ParseJWTBearerToken[T any](tokenString string, keys *KeySet) (*Claims[T], error) {
 	claims := &Claims[T]{}
 
 	tokenParts := strings.Split(tokenString, " ")
@@ -49,12 +56,7 @@ func VerifyJWTBearerToken[T any](tokenString, secret string) (*T, error) {
 		return nil, jwt.ErrInvalidType
 	}
 
-	token, err := jwt.ParseWithClaims(tokenParts[1], claims, func(token *jwt.Token) (any, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, jwt.ErrInvalidKeyType
-		}
-		return []byte(secret), nil
-	})
+	token, err := jwt.ParseWithClaims(tokenParts[1], claims, keys.keyFunc, jwt.WithValidMethods(keys.validMethods()))
 	if err != nil {
 		return nil, err
 	}
@@ -63,19 +65,36 @@ func VerifyJWTBearerToken[T any](tokenString, secret string) (*T, error) {
 		return nil, jwt.ErrTokenInvalidClaims
 	}
 
+	return claims, nil
+}
+
+func VerifyJWTBearerToken[T any](tokenString string, keys *KeySet) (*T, error) {
+	claims, err := ParseJWTBearerToken[T](tokenString, keys)
+	if err != nil {
+		return nil, err
+	}
+
 	return &claims.TokenInfo, nil
 }
 
-func AuthBearerMiddlewareInit[T any](secret string) func(http.Handler) http.Handler {
+// AuthBearerMiddlewareInit - проверяет access-токен; если передан isRevoked,
+// дополнительно отклоняет токены отозванных сессий
+func AuthBearerMiddlewareInit[T any](keys *KeySet, isRevoked SessionRevokedFunc) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			tokenInfo, err := VerifyJWTBearerToken[T](r.Header.Get("Authorization"), secret)
+			claims, err := ParseJWTBearerToken[T](r.Header.Get("Authorization"), keys)
 			if err != nil {
 				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
 				return
 			}
 
-			ctx := context.WithValue(r.Context(), tokenDataContextKey, tokenInfo)
+			if isRevoked != nil && (claims.ID == "" || isRevoked(r.Context(), claims.ID)) {
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), tokenDataContextKey, &claims.TokenInfo)
+			ctx = ContextWithSessionID(ctx, claims.ID)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
@@ -92,6 +111,17 @@ func GetTokenInfo[T any](r *http.Request) *T {
 	return tokenInfo
 }
 
+// GetSessionID - возвращает ID сессии текущего access-токена
+func GetSessionID(r *http.Request) string {
+	sessionID, _ := r.Context().Value(sessionIDContextKey).(string)
+
+	return sessionID
+}
+
+func ContextWithSessionID(ctx context.Context, sessionID string) context.Context {
+	return context.WithValue(ctx, sessionIDContextKey, sessionID)
+}
+
 func NewAuthenticatedRequest[T any](method, url string, tokenInfo *T, body io.Reader) *http.Request {
 	req := httptest.NewRequest(method, url, body)
 
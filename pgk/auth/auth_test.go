@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -18,7 +19,7 @@ type TokenInfo struct {
 
 func TestGenerateBearerToken_Success(t *testing.T) {
 	tokenInfo := TokenInfo{ID: 123}
-	token, err := GenerateBearerToken(tokenInfo, time.Hour, "secret")
+	token, err := GenerateBearerToken(tokenInfo, "session-1", time.Hour, NewHMACKeySet("secret"))
 
 	assert.NoError(t, err)
 	assert.NotEmpty(t, token)
@@ -27,12 +28,12 @@ func TestGenerateBearerToken_Success(t *testing.T) {
 
 func TestVerifyJWTBearerToken_Valid(t *testing.T) {
 	tokenInfo := TokenInfo{ID: 123}
-	tokenStr, err := GenerateBearerToken(tokenInfo, time.Hour, "secret")
+	tokenStr, err := GenerateBearerToken(tokenInfo, "session-1", time.Hour, NewHMACKeySet("secret"))
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	verified, err := VerifyJWTBearerToken[TokenInfo](tokenStr, "secret")
+	verified, err := VerifyJWTBearerToken[TokenInfo](tokenStr, NewHMACKeySet("secret"))
 
 	assert.NoError(t, err)
 	assert.Equal(t, &tokenInfo, verified)
@@ -47,7 +48,7 @@ func TestVerifyJWTBearerToken_InvalidFormat(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc, func(t *testing.T) {
-			_, err := VerifyJWTBearerToken[TokenInfo](tc, "secret")
+			_, err := VerifyJWTBearerToken[TokenInfo](tc, NewHMACKeySet("secret"))
 			assert.Error(t, err)
 		})
 	}
@@ -55,9 +56,9 @@ func TestVerifyJWTBearerToken_InvalidFormat(t *testing.T) {
 
 func TestVerifyJWTBearerToken_WrongSecret(t *testing.T) {
 	tokenInfo := TokenInfo{ID: 123}
-	tokenStr, _ := GenerateBearerToken(tokenInfo, time.Hour, "secret")
+	tokenStr, _ := GenerateBearerToken(tokenInfo, "session-1", time.Hour, NewHMACKeySet("secret"))
 
-	_, err := VerifyJWTBearerToken[TokenInfo](tokenStr, "wrong-secret")
+	_, err := VerifyJWTBearerToken[TokenInfo](tokenStr, NewHMACKeySet("wrong-secret"))
 
 	assert.Error(t, err)
 }
@@ -74,16 +75,16 @@ func TestVerifyJWTBearerToken_Expired(t *testing.T) {
 	token, _ := tokenData.SignedString([]byte("secret"))
 	fullToken := fmt.Sprintf("Bearer %s", token)
 
-	_, err := VerifyJWTBearerToken[TokenInfo](fullToken, "secret")
+	_, err := VerifyJWTBearerToken[TokenInfo](fullToken, NewHMACKeySet("secret"))
 
 	assert.Error(t, err)
 }
 
 func TestAuthBearerMiddleware_ValidToken(t *testing.T) {
 	tokenInfo := TokenInfo{ID: 123}
-	tokenStr, _ := GenerateBearerToken(tokenInfo, time.Hour, "secret")
+	tokenStr, _ := GenerateBearerToken(tokenInfo, "session-1", time.Hour, NewHMACKeySet("secret"))
 
-	middleware := AuthBearerMiddlewareInit[TokenInfo]("secret")
+	middleware := AuthBearerMiddlewareInit[TokenInfo](NewHMACKeySet("secret"), nil)
 	nextHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		info := GetTokenInfo[TokenInfo](r)
 		assert.Equal(t, &tokenInfo, info)
@@ -99,7 +100,7 @@ func TestAuthBearerMiddleware_ValidToken(t *testing.T) {
 }
 
 func TestAuthBearerMiddleware_InvalidToken(t *testing.T) {
-	middleware := AuthBearerMiddlewareInit[TokenInfo]("secret")
+	middleware := AuthBearerMiddlewareInit[TokenInfo](NewHMACKeySet("secret"), nil)
 	nextHandler := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})
 
 	testCases := []struct {
@@ -124,6 +125,52 @@ func TestAuthBearerMiddleware_InvalidToken(t *testing.T) {
 	}
 }
 
+func TestParseJWTBearerToken_SessionID(t *testing.T) {
+	tokenStr, err := GenerateBearerToken(TokenInfo{ID: 123}, "session-1", time.Hour, NewHMACKeySet("secret"))
+	assert.NoError(t, err)
+
+	claims, err := ParseJWTBearerToken[TokenInfo](tokenStr, NewHMACKeySet("secret"))
+
+	assert.NoError(t, err)
+	assert.Equal(t, "session-1", claims.ID)
+	assert.Equal(t, int64(123), claims.TokenInfo.ID)
+}
+
+func TestAuthBearerMiddleware_SessionRevocation(t *testing.T) {
+	tokenStr, _ := GenerateBearerToken(TokenInfo{ID: 123}, "session-1", time.Hour, NewHMACKeySet("secret"))
+
+	testCases := []struct {
+		name     string
+		revoked  bool
+		wantCode int
+	}{
+		{"active session", false, http.StatusOK},
+		{"revoked session", true, http.StatusUnauthorized},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var checkedID string
+			middleware := AuthBearerMiddlewareInit[TokenInfo](NewHMACKeySet("secret"), func(_ context.Context, sessionID string) bool {
+				checkedID = sessionID
+				return tc.revoked
+			})
+			nextHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "session-1", GetSessionID(r))
+			})
+
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set("Authorization", tokenStr)
+			w := httptest.NewRecorder()
+
+			middleware(nextHandler).ServeHTTP(w, req)
+
+			assert.Equal(t, "session-1", checkedID)
+			assert.Equal(t, tc.wantCode, w.Code)
+		})
+	}
+}
+
 func TestGetTokenInfo_NotFound(t *testing.T) {
 	req := httptest.NewRequest("GET", "/", nil)
 	info := GetTokenInfo[TokenInfo](req)
@@ -0,0 +1,311 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrNoSigningKey       = errors.New("no signing key configured")
+	ErrUnsupportedKeyType = errors.New("unsupported key type")
+	ErrUnknownKeyID       = errors.New("unknown key id")
+)
+
+// verificationKey - публичный ключ, которым принимаются подписи с указанным kid
+type verificationKey struct {
+	id     string
+	method jwt.SigningMethod
+	public crypto.PublicKey
+}
+
+// KeySet - набор ключей для подписи и проверки токенов.
+// Подписывает всегда первый активный ключ; проверка идёт по kid среди
+// активных и выведенных из оборота ключей, что позволяет ротировать ключи
+// без разлогина пользователей. Для обратной совместимости поддерживается
+// режим HS256 с общим секретом (без kid и без публикации в JWKS).
+type KeySet struct {
+	secret []byte
+
+	signingID     string
+	signingMethod jwt.SigningMethod
+	signingKey    crypto.PrivateKey
+
+	keys []verificationKey
+}
+
+func NewHMACKeySet(secret string) *KeySet {
+	return &KeySet{
+		secret:        []byte(secret),
+		signingMethod: jwt.SigningMethodHS256,
+	}
+}
+
+// NewKeySet - собирает набор из приватных ключей (активные, первый подписывает)
+// и публичных ключей выведенных из оборота пар
+func NewKeySet(active []crypto.PrivateKey, retired []crypto.PublicKey) (*KeySet, error) {
+	if len(active) == 0 {
+		return nil, ErrNoSigningKey
+	}
+
+	ks := &KeySet{}
+
+	for i, private := range active {
+		signer, ok := private.(crypto.Signer)
+		if !ok {
+			return nil, ErrUnsupportedKeyType
+		}
+
+		key, err := newVerificationKey(signer.Public())
+		if err != nil {
+			return nil, err
+		}
+
+		if i == 0 {
+			ks.signingID = key.id
+			ks.signingMethod = key.method
+			ks.signingKey = private
+		}
+
+		ks.keys = append(ks.keys, key)
+	}
+
+	for _, public := range retired {
+		key, err := newVerificationKey(public)
+		if err != nil {
+			return nil, err
+		}
+
+		ks.keys = append(ks.keys, key)
+	}
+
+	return ks, nil
+}
+
+// LoadKeySet - читает ключи из PEM-файлов. В retiredFiles допускаются
+// как публичные, так и приватные ключи (используется только публичная часть).
+func LoadKeySet(activeFiles, retiredFiles []string) (*KeySet, error) {
+	active := make([]crypto.PrivateKey, 0, len(activeFiles))
+	for _, path := range activeFiles {
+		key, err := readPEMKey(path)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, ok := key.(crypto.Signer); !ok {
+			return nil, fmt.Errorf("%s: private key expected", path)
+		}
+
+		active = append(active, key)
+	}
+
+	retired := make([]crypto.PublicKey, 0, len(retiredFiles))
+	for _, path := range retiredFiles {
+		key, err := readPEMKey(path)
+		if err != nil {
+			return nil, err
+		}
+
+		if signer, ok := key.(crypto.Signer); ok {
+			key = signer.Public()
+		}
+
+		retired = append(retired, key)
+	}
+
+	return NewKeySet(active, retired)
+}
+
+// SigningKeyID - kid текущего ключа подписи (пустой для HS256)
+func (ks *KeySet) SigningKeyID() string {
+	return ks.signingID
+}
+
+func (ks *KeySet) sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(ks.signingMethod, claims)
+
+	if ks.secret != nil {
+		return token.SignedString(ks.secret)
+	}
+
+	token.Header["kid"] = ks.signingID
+
+	return token.SignedString(ks.signingKey)
+}
+
+func (ks *KeySet) keyFunc(token *jwt.Token) (any, error) {
+	if ks.secret != nil {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, jwt.ErrInvalidKeyType
+		}
+		return ks.secret, nil
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	for _, key := range ks.keys {
+		if key.id != kid {
+			continue
+		}
+
+		if token.Method.Alg() != key.method.Alg() {
+			return nil, jwt.ErrInvalidKeyType
+		}
+
+		return key.public, nil
+	}
+
+	return nil, ErrUnknownKeyID
+}
+
+func (ks *KeySet) validMethods() []string {
+	if ks.secret != nil {
+		return []string{jwt.SigningMethodHS256.Alg()}
+	}
+
+	return []string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg()}
+}
+
+// JWK - публичный ключ в формате RFC 7517
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS - публичные части всех активных и выведенных из оборота ключей
+func (ks *KeySet) JWKS() JWKSet {
+	set := JWKSet{Keys: make([]JWK, 0, len(ks.keys))}
+
+	for _, key := range ks.keys {
+		jwk := JWK{
+			KeyID:     key.id,
+			Use:       "sig",
+			Algorithm: key.method.Alg(),
+		}
+
+		switch public := key.public.(type) {
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		}
+
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	return set
+}
+
+// JWKSHandler - отдаёт JWKS для /.well-known/jwks.json
+func JWKSHandler(ks *KeySet) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := json.Marshal(ks.JWKS())
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		w.WriteHeader(http.StatusOK)
+		w.Write(body)
+	}
+}
+
+func newVerificationKey(public crypto.PublicKey) (verificationKey, error) {
+	var (
+		method     jwt.SigningMethod
+		thumbprint []byte
+		err        error
+	)
+
+	// kid - отпечаток ключа по RFC 7638 (поля в лексикографическом порядке)
+	switch key := public.(type) {
+	case *rsa.PublicKey:
+		method = jwt.SigningMethodRS256
+		thumbprint, err = json.Marshal(struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			Kty: "RSA",
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		})
+	case ed25519.PublicKey:
+		method = jwt.SigningMethodEdDSA
+		thumbprint, err = json.Marshal(struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{
+			Crv: "Ed25519",
+			Kty: "OKP",
+			X:   base64.RawURLEncoding.EncodeToString(key),
+		})
+	default:
+		return verificationKey{}, ErrUnsupportedKeyType
+	}
+
+	if err != nil {
+		return verificationKey{}, err
+	}
+
+	sum := sha256.Sum256(thumbprint)
+
+	return verificationKey{
+		id:     base64.RawURLEncoding.EncodeToString(sum[:]),
+		method: method,
+		public: public,
+	}, nil
+}
+
+func readPEMKey(path string) (any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM block found", path)
+	}
+
+	switch block.Type {
+	case "PRIVATE KEY":
+		return x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		return x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	}
+
+	return nil, fmt.Errorf("%s: unsupported PEM block %q", path, block.Type)
+}
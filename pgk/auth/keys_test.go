@@ -0,0 +1,165 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writePEM(t *testing.T, blockType string, der []byte) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "key.pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+
+	return path
+}
+
+func newEd25519Key(t *testing.T) ed25519.PrivateKey {
+	t.Helper()
+
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	return private
+}
+
+func TestKeySet_EdDSA_SignAndVerify(t *testing.T) {
+	keys, err := NewKeySet([]crypto.PrivateKey{newEd25519Key(t)}, nil)
+	require.NoError(t, err)
+
+	tokenStr, err := GenerateBearerToken(TokenInfo{ID: 123}, "session-1", time.Hour, keys)
+	require.NoError(t, err)
+
+	claims, err := ParseJWTBearerToken[TokenInfo](tokenStr, keys)
+
+	require.NoError(t, err)
+	assert.Equal(t, int64(123), claims.TokenInfo.ID)
+}
+
+func TestKeySet_RS256_KidHeader(t *testing.T) {
+	private, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	keys, err := NewKeySet([]crypto.PrivateKey{private}, nil)
+	require.NoError(t, err)
+
+	tokenStr, err := GenerateBearerToken(TokenInfo{ID: 123}, "session-1", time.Hour, keys)
+	require.NoError(t, err)
+
+	token, _, err := jwt.NewParser().ParseUnverified(tokenStr[len("Bearer "):], &Claims[TokenInfo]{})
+	require.NoError(t, err)
+	assert.Equal(t, "RS256", token.Method.Alg())
+	assert.Equal(t, keys.SigningKeyID(), token.Header["kid"])
+
+	_, err = VerifyJWTBearerToken[TokenInfo](tokenStr, keys)
+	assert.NoError(t, err)
+}
+
+func TestKeySet_Rotation(t *testing.T) {
+	oldKey := newEd25519Key(t)
+	newKey := newEd25519Key(t)
+
+	oldKeys, err := NewKeySet([]crypto.PrivateKey{oldKey}, nil)
+	require.NoError(t, err)
+
+	tokenStr, err := GenerateBearerToken(TokenInfo{ID: 123}, "session-1", time.Hour, oldKeys)
+	require.NoError(t, err)
+
+	// старый ключ выведен из оборота, но токены, подписанные им, ещё принимаются
+	rotated, err := NewKeySet([]crypto.PrivateKey{newKey}, []crypto.PublicKey{oldKey.Public()})
+	require.NoError(t, err)
+	assert.NotEqual(t, oldKeys.SigningKeyID(), rotated.SigningKeyID())
+
+	_, err = VerifyJWTBearerToken[TokenInfo](tokenStr, rotated)
+	assert.NoError(t, err)
+
+	// ключ удалён из набора полностью
+	withoutOld, err := NewKeySet([]crypto.PrivateKey{newKey}, nil)
+	require.NoError(t, err)
+
+	_, err = VerifyJWTBearerToken[TokenInfo](tokenStr, withoutOld)
+	assert.Error(t, err)
+}
+
+func TestKeySet_RejectsHMACToken(t *testing.T) {
+	keys, err := NewKeySet([]crypto.PrivateKey{newEd25519Key(t)}, nil)
+	require.NoError(t, err)
+
+	tokenStr, err := GenerateBearerToken(TokenInfo{ID: 123}, "session-1", time.Hour, NewHMACKeySet("secret"))
+	require.NoError(t, err)
+
+	_, err = VerifyJWTBearerToken[TokenInfo](tokenStr, keys)
+	assert.Error(t, err)
+}
+
+func TestNewKeySet_NoActiveKeys(t *testing.T) {
+	_, err := NewKeySet(nil, nil)
+
+	assert.ErrorIs(t, err, ErrNoSigningKey)
+}
+
+func TestLoadKeySet_FromPEM(t *testing.T) {
+	activeKey := newEd25519Key(t)
+	activeDER, err := x509.MarshalPKCS8PrivateKey(activeKey)
+	require.NoError(t, err)
+
+	retiredKey := newEd25519Key(t)
+	retiredDER, err := x509.MarshalPKIXPublicKey(retiredKey.Public())
+	require.NoError(t, err)
+
+	keys, err := LoadKeySet(
+		[]string{writePEM(t, "PRIVATE KEY", activeDER)},
+		[]string{writePEM(t, "PUBLIC KEY", retiredDER)},
+	)
+	require.NoError(t, err)
+
+	assert.Len(t, keys.JWKS().Keys, 2)
+}
+
+func TestLoadKeySet_PublicKeyAsActive(t *testing.T) {
+	der, err := x509.MarshalPKIXPublicKey(newEd25519Key(t).Public())
+	require.NoError(t, err)
+
+	_, err = LoadKeySet([]string{writePEM(t, "PUBLIC KEY", der)}, nil)
+
+	assert.Error(t, err)
+}
+
+func TestJWKSHandler(t *testing.T) {
+	keys, err := NewKeySet([]crypto.PrivateKey{newEd25519Key(t)}, nil)
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	JWKSHandler(keys)(w, httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+	var set JWKSet
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &set))
+	require.Len(t, set.Keys, 1)
+	assert.Equal(t, "OKP", set.Keys[0].KeyType)
+	assert.Equal(t, "EdDSA", set.Keys[0].Algorithm)
+	assert.Equal(t, keys.SigningKeyID(), set.Keys[0].KeyID)
+	assert.NotEmpty(t, set.Keys[0].X)
+}
+
+func TestJWKS_HMACKeySetIsEmpty(t *testing.T) {
+	assert.Empty(t, NewHMACKeySet("secret").JWKS().Keys)
+}
@@ -0,0 +1,56 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+const (
+	sessionIDBytes    = 16
+	refreshTokenBytes = 32
+)
+
+var ErrInvalidRefreshToken = errors.New("invalid refresh token")
+
+// NewSessionID - генерирует случайный идентификатор сессии
+func NewSessionID() (string, error) {
+	b := make([]byte, sessionIDBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// NewRefreshToken - генерирует refresh-токен вида <sessionID>.<secret>;
+// в хранилище сохраняется только хеш (см. HashRefreshToken)
+func NewRefreshToken(sessionID string) (token, hash string, err error) {
+	b := make([]byte, refreshTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+
+	token = sessionID + "." + base64.RawURLEncoding.EncodeToString(b)
+
+	return token, HashRefreshToken(token), nil
+}
+
+// ParseRefreshToken - возвращает ID сессии и хеш refresh-токена
+func ParseRefreshToken(token string) (sessionID, hash string, err error) {
+	sessionID, secret, found := strings.Cut(token, ".")
+	if !found || sessionID == "" || secret == "" {
+		return "", "", ErrInvalidRefreshToken
+	}
+
+	return sessionID, HashRefreshToken(token), nil
+}
+
+func HashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,44 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSessionID_Unique(t *testing.T) {
+	first, err := NewSessionID()
+	require.NoError(t, err)
+	second, err := NewSessionID()
+	require.NoError(t, err)
+
+	assert.Len(t, first, 32)
+	assert.NotEqual(t, first, second)
+}
+
+func TestNewRefreshToken_RoundTrip(t *testing.T) {
+	token, hash, err := NewRefreshToken("session-1")
+	require.NoError(t, err)
+
+	assert.True(t, strings.HasPrefix(token, "session-1."))
+	assert.NotContains(t, hash, token)
+
+	sessionID, parsedHash, err := ParseRefreshToken(token)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "session-1", sessionID)
+	assert.Equal(t, hash, parsedHash)
+}
+
+func TestParseRefreshToken_Invalid(t *testing.T) {
+	testCases := []string{"", "nodot", ".secret", "session."}
+
+	for _, tc := range testCases {
+		t.Run(tc, func(t *testing.T) {
+			_, _, err := ParseRefreshToken(tc)
+			assert.ErrorIs(t, err, ErrInvalidRefreshToken)
+		})
+	}
+}
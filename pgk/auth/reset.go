@@ -0,0 +1,24 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+)
+
+const resetTokenBytes = 32
+
+// NewResetToken - одноразовый токен сброса пароля и его хеш для хранилища
+func NewResetToken() (token, hash string, err error) {
+	b := make([]byte, resetTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+
+	token = base64.RawURLEncoding.EncodeToString(b)
+
+	return token, HashResetToken(token), nil
+}
+
+func HashResetToken(token string) string {
+	return HashRefreshToken(token)
+}
@@ -0,0 +1,29 @@
+package auth
+
+import "net/http"
+
+// RoleHolder - данные токена, по которым RequireRole проверяет доступ
+type RoleHolder[R ~string] interface {
+	HasAnyRole(roles ...R) bool
+}
+
+// RequireRole - пропускает запрос, если у токена есть хотя бы одна из ролей.
+// Ставится после AuthBearerMiddlewareInit: без данных токена - 401, без роли - 403.
+func RequireRole[T RoleHolder[R], R ~string](roles ...R) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tokenInfo := GetTokenInfo[T](r)
+			if tokenInfo == nil {
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+
+			if !(*tokenInfo).HasAnyRole(roles...) {
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testRole string
+
+type roleTokenInfo struct {
+	ID    int64      `json:"id"`
+	Roles []testRole `json:"roles"`
+}
+
+func (t roleTokenInfo) HasAnyRole(roles ...testRole) bool {
+	for _, role := range roles {
+		if slices.Contains(t.Roles, role) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func TestRequireRole(t *testing.T) {
+	handler := RequireRole[roleTokenInfo]("admin", "support")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name      string
+		tokenInfo *roleTokenInfo
+		want      int
+	}{
+		{"matching role", &roleTokenInfo{ID: 1, Roles: []testRole{"user", "support"}}, http.StatusOK},
+		{"no matching role", &roleTokenInfo{ID: 1, Roles: []testRole{"user"}}, http.StatusForbidden},
+		{"no roles", &roleTokenInfo{ID: 1}, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := NewAuthenticatedRequest(http.MethodGet, "/", tt.tokenInfo, nil)
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.want, w.Code)
+		})
+	}
+
+	t.Run("unauthenticated", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
@@ -22,3 +22,8 @@ func (r *loggingResponseWriter) WriteHeader(statusCode int) {
 	r.ResponseWriter.WriteHeader(statusCode) // записываем код статуса, используя оригинальный http.ResponseWriter
 	r.responseData.status = statusCode       // захватываем код статуса
 }
+
+// Unwrap - доступ к исходному writer для http.ResponseController (Flush для SSE)
+func (r *loggingResponseWriter) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
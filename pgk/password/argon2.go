@@ -0,0 +1,136 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"runtime"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+const argon2idPrefix = "$argon2id$"
+
+// Argon2Params - параметры argon2id: Memory в КиБ, Iterations - число проходов
+type Argon2Params struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultArgon2Params - m = 64 МиБ, t = 3 (как во втором рекомендованном наборе RFC 9106, но p = 2).
+// Каждое вычисление занимает Memory КиБ, поэтому их число ограничено argon2Slots.
+var DefaultArgon2Params = Argon2Params{
+	Memory:      64 * 1024,
+	Iterations:  3,
+	Parallelism: 2,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+// argon2Slots - семафор одновременных вычислений argon2id (хеширование и проверка):
+// без него всплеск входов умножает Memory на число запросов и может исчерпать память
+var argon2Slots = make(chan struct{}, runtime.GOMAXPROCS(0))
+
+// SetArgon2Concurrency - сколько вычислений argon2id идёт одновременно, остальные ждут очереди;
+// n < 1 - по числу CPU. Вызывается при старте, до первого хеширования.
+func SetArgon2Concurrency(n int) {
+	if n < 1 {
+		n = runtime.GOMAXPROCS(0)
+	}
+
+	argon2Slots = make(chan struct{}, n)
+}
+
+// argon2IDKey - argon2.IDKey под семафором
+func argon2IDKey(password, salt []byte, params Argon2Params, keyLength uint32) []byte {
+	argon2Slots <- struct{}{}
+	defer func() { <-argon2Slots }()
+
+	return argon2.IDKey(password, salt, params.Iterations, params.Memory, params.Parallelism, keyLength)
+}
+
+type Argon2idHasher struct {
+	params Argon2Params
+}
+
+func NewArgon2idHasher(params Argon2Params) *Argon2idHasher {
+	return &Argon2idHasher{params: params}
+}
+
+// Hash - хеш в формате PHC: $argon2id$v=19$m=65536,t=3,p=2$<соль>$<ключ>
+func (h *Argon2idHasher) Hash(password string) (string, error) {
+	if err := checkLength(password); err != nil {
+		return "", err
+	}
+
+	salt := make([]byte, h.params.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", ErrPasswordGenerate
+	}
+
+	key := argon2IDKey([]byte(password), salt, h.params, h.params.KeyLength)
+
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2idPrefix, argon2.Version, h.params.Memory, h.params.Iterations, h.params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func (h *Argon2idHasher) NeedsRehash(encoded string) bool {
+	params, salt, key, err := decodeArgon2id(encoded)
+	if err != nil {
+		return true
+	}
+
+	return params.Memory != h.params.Memory ||
+		params.Iterations != h.params.Iterations ||
+		params.Parallelism != h.params.Parallelism ||
+		uint32(len(salt)) != h.params.SaltLength ||
+		uint32(len(key)) != h.params.KeyLength
+}
+
+func checkArgon2id(password, encoded string) bool {
+	params, salt, key, err := decodeArgon2id(encoded)
+	if err != nil {
+		return false
+	}
+
+	actual := argon2IDKey([]byte(password), salt, params, uint32(len(key)))
+
+	return subtle.ConstantTimeCompare(actual, key) == 1
+}
+
+func decodeArgon2id(encoded string) (Argon2Params, []byte, []byte, error) {
+	var params Argon2Params
+
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return params, nil, nil, fmt.Errorf("invalid argon2id hash")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, fmt.Errorf("unsupported argon2id version %q", parts[2])
+	}
+
+	_, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism)
+	if err != nil || params.Iterations == 0 || params.Parallelism == 0 {
+		return params, nil, nil, fmt.Errorf("invalid argon2id params %q", parts[3])
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, fmt.Errorf("invalid argon2id salt: %w", err)
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return params, nil, nil, fmt.Errorf("invalid argon2id key")
+	}
+
+	return params, salt, key, nil
+}
@@ -0,0 +1,30 @@
+package password
+
+import (
+	"bufio"
+	"os"
+	"strings"
+)
+
+// ReadDenylist - читает запрещённые пароли по одному в строке; пустые строки и строки с # пропускаются.
+// Пароли приводятся к нижнему регистру.
+func ReadDenylist(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	result := make([]string, 0)
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		result = append(result, strings.ToLower(line))
+	}
+
+	return result, scanner.Err()
+}
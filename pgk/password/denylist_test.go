@@ -0,0 +1,20 @@
+package password
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadDenylist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "denylist.txt")
+	require.NoError(t, os.WriteFile(path, []byte("# top passwords\nQwerty\n\n  letmein  \n"), 0o600))
+
+	denylist, err := ReadDenylist(path)
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"qwerty", "letmein"}, denylist)
+}
@@ -2,6 +2,7 @@ package password
 
 import (
 	"errors"
+	"strings"
 
 	"golang.org/x/crypto/bcrypt"
 )
@@ -12,23 +13,35 @@ var (
 	ErrPasswordGenerate = errors.New("password generate error")
 )
 
-func HashPassword(password string, passCost int) (string, error) {
-	if len(password) < 1 {
-		return "", ErrPasswordRequired
-	}
-	if len(password) > 64 {
-		return "", ErrPasswordMaxLen64
-	}
-
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), passCost)
-	if err != nil {
-		return "", ErrPasswordGenerate
-	}
+// Hasher - алгоритм хеширования новых паролей. Проверка не зависит от алгоритма (см. CheckPasswordHash),
+// поэтому хеши, сделанные прежним алгоритмом, продолжают работать.
+type Hasher interface {
+	Hash(password string) (string, error)
+	// NeedsRehash - хеш сделан другим алгоритмом или с другими параметрами
+	NeedsRehash(encoded string) bool
+}
 
-	return string(bytes), err
+func HashPassword(password string, passCost int) (string, error) {
+	return NewBcryptHasher(passCost).Hash(password)
 }
 
+// CheckPasswordHash - проверяет пароль по хешу bcrypt ($2a$...) или argon2id в формате PHC ($argon2id$...)
 func CheckPasswordHash(password, hash string) bool {
+	if strings.HasPrefix(hash, argon2idPrefix) {
+		return checkArgon2id(password, hash)
+	}
+
 	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 	return err == nil
 }
+
+func checkLength(password string) error {
+	if len(password) < 1 {
+		return ErrPasswordRequired
+	}
+	if len(password) > 64 {
+		return ErrPasswordMaxLen64
+	}
+
+	return nil
+}
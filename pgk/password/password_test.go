@@ -1,7 +1,9 @@
 package password
 
 import (
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"golang.org/x/crypto/bcrypt"
@@ -75,3 +77,70 @@ func TestHashPassword_Basic(t *testing.T) {
 		})
 	}
 }
+
+func TestArgon2idHasher_PHCFormat(t *testing.T) {
+	hasher := NewArgon2idHasher(Argon2Params{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32})
+
+	hash, err := hasher.Hash("testpass")
+	assert.NoError(t, err)
+
+	assert.True(t, strings.HasPrefix(hash, "$argon2id$v=19$m=1024,t=1,p=1$"))
+	assert.True(t, CheckPasswordHash("testpass", hash))
+	assert.False(t, CheckPasswordHash("wrongpass", hash))
+	assert.False(t, hasher.NeedsRehash(hash))
+}
+
+func TestArgon2idHasher_ConcurrencyLimit(t *testing.T) {
+	SetArgon2Concurrency(1)
+	defer SetArgon2Concurrency(0)
+
+	// единственный слот занят: вычисление ждёт, пока его не освободят
+	argon2Slots <- struct{}{}
+
+	hasher := NewArgon2idHasher(Argon2Params{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _ = hasher.Hash("testpass")
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("hash must wait for a free slot")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	<-argon2Slots
+	<-done
+}
+
+func TestCheckPasswordHash_MalformedArgon2id(t *testing.T) {
+	assert.False(t, CheckPasswordHash("testpass", "$argon2id$v=19$m=1024,t=0,p=0$c2FsdA$a2V5"))
+	assert.False(t, CheckPasswordHash("testpass", "$argon2id$broken"))
+}
+
+func TestHasher_NeedsRehash(t *testing.T) {
+	oldBcrypt, err := HashPassword("testpass", 4)
+	assert.NoError(t, err)
+
+	weakArgon, err := NewArgon2idHasher(Argon2Params{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}).Hash("testpass")
+	assert.NoError(t, err)
+
+	assert.False(t, NewBcryptHasher(4).NeedsRehash(oldBcrypt))
+	assert.True(t, NewBcryptHasher(5).NeedsRehash(oldBcrypt))
+	assert.True(t, NewBcryptHasher(4).NeedsRehash(weakArgon))
+
+	argon := NewArgon2idHasher(Argon2Params{Memory: 2048, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32})
+	assert.True(t, argon.NeedsRehash(oldBcrypt))
+	assert.True(t, argon.NeedsRehash(weakArgon))
+}
+
+func TestNewBcryptHasher_LowCostUsesDefault(t *testing.T) {
+	hash, err := NewBcryptHasher(3).Hash("testpass")
+	assert.NoError(t, err)
+
+	cost, err := bcrypt.Cost([]byte(hash))
+	assert.NoError(t, err)
+	assert.Equal(t, bcrypt.DefaultCost, cost)
+	assert.False(t, NewBcryptHasher(3).NeedsRehash(hash))
+}
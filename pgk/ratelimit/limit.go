@@ -0,0 +1,88 @@
+package ratelimit
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidLimit = errors.New("invalid rate limit")
+
+// Limit - token bucket: Requests запросов за Period, всплеск до Burst запросов подряд
+type Limit struct {
+	Requests int
+	Period   time.Duration
+	Burst    int
+}
+
+// Rate - скорость пополнения, токенов в секунду
+func (l Limit) Rate() float64 {
+	return float64(l.Requests) / l.Period.Seconds()
+}
+
+// ParseLimit - разбирает "10/1m" или "10/1m:20" (20 - всплеск; по умолчанию равен числу запросов)
+func ParseLimit(s string) (Limit, error) {
+	rate, burst, hasBurst := strings.Cut(strings.TrimSpace(s), ":")
+
+	requests, period, ok := strings.Cut(rate, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("%w: %q", ErrInvalidLimit, s)
+	}
+
+	var (
+		limit Limit
+		err   error
+	)
+	if limit.Requests, err = strconv.Atoi(requests); err != nil || limit.Requests <= 0 {
+		return Limit{}, fmt.Errorf("%w: %q", ErrInvalidLimit, s)
+	}
+	if limit.Period, err = time.ParseDuration(period); err != nil || limit.Period <= 0 {
+		return Limit{}, fmt.Errorf("%w: %q", ErrInvalidLimit, s)
+	}
+
+	limit.Burst = limit.Requests
+	if hasBurst {
+		if limit.Burst, err = strconv.Atoi(burst); err != nil || limit.Burst <= 0 {
+			return Limit{}, fmt.Errorf("%w: %q", ErrInvalidLimit, s)
+		}
+	}
+
+	return limit, nil
+}
+
+// ParseRules - лимиты маршрутов вида "login=10/1m", "orders=60/1m:10"
+func ParseRules(rules []string) (map[string]Limit, error) {
+	limits := make(map[string]Limit, len(rules))
+	for _, rule := range rules {
+		name, value, ok := strings.Cut(rule, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidLimit, rule)
+		}
+
+		limit, err := ParseLimit(value)
+		if err != nil {
+			return nil, err
+		}
+		limits[name] = limit
+	}
+
+	return limits, nil
+}
+
+// refill - токены в корзине через elapsed после последнего обновления
+func refill(tokens float64, elapsed time.Duration, limit Limit) float64 {
+	if elapsed < 0 {
+		elapsed = 0
+	}
+
+	return math.Min(float64(limit.Burst), tokens+elapsed.Seconds()*limit.Rate())
+}
+
+// retryAfter - через сколько в корзине появится целый токен
+func retryAfter(tokens float64, limit Limit) time.Duration {
+	return time.Duration((1 - tokens) / limit.Rate() * float64(time.Second))
+}
@@ -0,0 +1,84 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Store - хранилище корзин. TakeToken забирает токен из корзины key;
+// если токена нет - возвращает false и время до появления следующего.
+type Store interface {
+	TakeToken(ctx context.Context, key string, limit Limit, now time.Time) (bool, time.Duration, error)
+}
+
+// KeyFunc - ключ клиента для запроса; false - запрос не ограничивается
+type KeyFunc func(r *http.Request) (string, bool)
+
+// ByIP - ключ по адресу клиента из RemoteAddr (за доверенными прокси его подменяет realip.Middleware)
+func ByIP(r *http.Request) (string, bool) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	return "ip:" + host, host != ""
+}
+
+// Limiter - лимиты маршрутов по именам поверх общего хранилища корзин
+type Limiter struct {
+	store  Store
+	limits map[string]Limit
+	lg     *zap.SugaredLogger
+}
+
+func NewLimiter(store Store, limits map[string]Limit, lg *zap.SugaredLogger) *Limiter {
+	return &Limiter{
+		store:  store,
+		limits: limits,
+		lg:     lg,
+	}
+}
+
+// Handler - middleware для маршрута name. Без настроенного лимита (и у nil Limiter) пропускает всё.
+// Ошибка хранилища не блокирует запрос: недоступная база не должна отключать вход.
+func (l *Limiter) Handler(name string, key KeyFunc) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if l == nil {
+			return next
+		}
+
+		limit, ok := l.limits[name]
+		if !ok {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			clientKey, ok := key(r)
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			allowed, wait, err := l.store.TakeToken(r.Context(), name+":"+clientKey, limit, time.Now())
+			if err != nil {
+				l.lg.Errorf("rate limit store error: %v", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if !allowed {
+				w.Header().Set("Retry-After", strconv.Itoa(max(1, int(math.Ceil(wait.Seconds())))))
+				http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
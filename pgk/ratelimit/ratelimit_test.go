@@ -0,0 +1,150 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Limit
+		wantErr bool
+	}{
+		{in: "10/1m", want: Limit{Requests: 10, Period: time.Minute, Burst: 10}},
+		{in: " 60/1h:5", want: Limit{Requests: 60, Period: time.Hour, Burst: 5}},
+		{in: "10", wantErr: true},
+		{in: "0/1m", wantErr: true},
+		{in: "10/forever", wantErr: true},
+		{in: "10/1m:-1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			limit, err := ParseLimit(tt.in)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidLimit)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, limit)
+		})
+	}
+}
+
+func TestParseRules(t *testing.T) {
+	limits, err := ParseRules([]string{"login=5/1m", "orders=60/1m:10"})
+	require.NoError(t, err)
+	assert.Equal(t, Limit{Requests: 5, Period: time.Minute, Burst: 5}, limits["login"])
+	assert.Equal(t, 10, limits["orders"].Burst)
+
+	_, err = ParseRules([]string{"=5/1m"})
+	assert.ErrorIs(t, err, ErrInvalidLimit)
+}
+
+func TestMemoryStore_TokenBucket(t *testing.T) {
+	store := NewMemoryStore()
+	limit := Limit{Requests: 2, Period: time.Second, Burst: 2}
+	now := time.Now()
+
+	for range 2 {
+		allowed, _, err := store.TakeToken(context.Background(), "k", limit, now)
+		require.NoError(t, err)
+		assert.True(t, allowed)
+	}
+
+	allowed, wait, err := store.TakeToken(context.Background(), "k", limit, now)
+	require.NoError(t, err)
+	assert.False(t, allowed)
+	assert.Equal(t, 500*time.Millisecond, wait)
+
+	// другой ключ - своя корзина
+	allowed, _, _ = store.TakeToken(context.Background(), "other", limit, now)
+	assert.True(t, allowed)
+
+	allowed, _, _ = store.TakeToken(context.Background(), "k", limit, now.Add(500*time.Millisecond))
+	assert.True(t, allowed)
+}
+
+func TestMemoryStore_SweepsFullBuckets(t *testing.T) {
+	store := NewMemoryStore()
+	limit := Limit{Requests: 1, Period: time.Second, Burst: 1}
+	now := time.Now()
+
+	_, _, _ = store.TakeToken(context.Background(), "a", limit, now)
+	_, _, _ = store.TakeToken(context.Background(), "b", limit, now.Add(2*memorySweepInterval))
+
+	assert.Len(t, store.buckets, 1)
+}
+
+type failingStore struct{}
+
+func (failingStore) TakeToken(context.Context, string, Limit, time.Time) (bool, time.Duration, error) {
+	return false, 0, errors.New("connection refused")
+}
+
+func TestLimiter_Handler(t *testing.T) {
+	limiter := NewLimiter(NewMemoryStore(), map[string]Limit{
+		"login": {Requests: 1, Period: time.Minute, Burst: 1},
+	}, zap.NewNop().Sugar())
+
+	handler := limiter.Handler("login", ByIP)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/user/login", nil)
+	req.RemoteAddr = "10.0.0.1:5555"
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "60", w.Header().Get("Retry-After"))
+
+	// другой порт того же адреса - тот же клиент, другой адрес - другой
+	req.RemoteAddr = "10.0.0.1:6666"
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+
+	req.RemoteAddr = "10.0.0.2:5555"
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestLimiter_Handler_PassThrough(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+
+	tests := []struct {
+		name    string
+		limiter *Limiter
+	}{
+		{"nil limiter", nil},
+		{"route without limit", NewLimiter(NewMemoryStore(), map[string]Limit{}, zap.NewNop().Sugar())},
+		{"store error", NewLimiter(failingStore{}, map[string]Limit{"login": {Requests: 1, Period: time.Minute, Burst: 1}}, zap.NewNop().Sugar())},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := tt.limiter.Handler("login", ByIP)(ok)
+
+			for range 3 {
+				w := httptest.NewRecorder()
+				handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/user/login", nil))
+				assert.Equal(t, http.StatusOK, w.Code)
+			}
+		})
+	}
+}
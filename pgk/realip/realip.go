@@ -0,0 +1,97 @@
+package realip
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// TrustedProxies - сети прокси, которым разрешено сообщать адрес клиента в X-Forwarded-For
+type TrustedProxies []netip.Prefix
+
+// ParseTrustedProxies - разбирает список адресов и подсетей ("10.0.0.0/8", "192.168.1.10")
+func ParseTrustedProxies(values []string) (TrustedProxies, error) {
+	proxies := make(TrustedProxies, 0, len(values))
+	for _, value := range values {
+		if strings.Contains(value, "/") {
+			prefix, err := netip.ParsePrefix(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q: %w", value, err)
+			}
+			proxies = append(proxies, prefix.Masked())
+			continue
+		}
+
+		addr, err := netip.ParseAddr(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", value, err)
+		}
+		proxies = append(proxies, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+	}
+
+	return proxies, nil
+}
+
+func (p TrustedProxies) contains(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range p {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Middleware - подменяет r.RemoteAddr адресом клиента, если запрос пришёл от доверенного прокси.
+// X-Forwarded-For читается справа налево до первого недоверенного адреса: левые элементы
+// заголовка клиент может подставить сам. Запросы не от доверенных прокси не меняются.
+func Middleware(proxies TrustedProxies) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if len(proxies) == 0 {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if client, ok := proxies.clientAddr(r); ok {
+				_, port, err := net.SplitHostPort(r.RemoteAddr)
+				if err != nil {
+					port = "0"
+				}
+				r.RemoteAddr = net.JoinHostPort(client.String(), port)
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func (p TrustedProxies) clientAddr(r *http.Request) (netip.Addr, bool) {
+	peer, err := netip.ParseAddrPort(r.RemoteAddr)
+	if err != nil || !p.contains(peer.Addr()) {
+		return netip.Addr{}, false
+	}
+
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(header, ",")...)
+	}
+
+	client := netip.Addr{}
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			// мусор в заголовке: дальше влево доверять нечему
+			break
+		}
+
+		client = addr.Unmap()
+		if !p.contains(client) {
+			break
+		}
+	}
+
+	return client, client.IsValid()
+}
@@ -0,0 +1,89 @@
+package realip
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTrustedProxies(t *testing.T) {
+	proxies, err := ParseTrustedProxies([]string{"10.0.0.0/8", "192.168.1.10", "fd00::/8"})
+	require.NoError(t, err)
+	assert.Len(t, proxies, 3)
+
+	_, err = ParseTrustedProxies([]string{"10.0.0.0/33"})
+	assert.Error(t, err)
+
+	_, err = ParseTrustedProxies([]string{"proxy.local"})
+	assert.Error(t, err)
+}
+
+func TestMiddleware(t *testing.T) {
+	proxies, err := ParseTrustedProxies([]string{"10.0.0.0/8"})
+	require.NoError(t, err)
+
+	var remoteAddr string
+	handler := Middleware(proxies)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		remoteAddr = r.RemoteAddr
+	}))
+
+	tests := []struct {
+		name           string
+		remoteAddr     string
+		forwardedFor   []string
+		wantRemoteAddr string
+	}{
+		{
+			name:           "direct client ignores header",
+			remoteAddr:     "203.0.113.7:5555",
+			forwardedFor:   []string{"198.51.100.1"},
+			wantRemoteAddr: "203.0.113.7:5555",
+		},
+		{
+			name:           "trusted proxy",
+			remoteAddr:     "10.0.0.2:5555",
+			forwardedFor:   []string{"198.51.100.1"},
+			wantRemoteAddr: "198.51.100.1:5555",
+		},
+		{
+			name:           "spoofed left entries are skipped",
+			remoteAddr:     "10.0.0.2:5555",
+			forwardedFor:   []string{"1.2.3.4, 198.51.100.1, 10.0.0.3"},
+			wantRemoteAddr: "198.51.100.1:5555",
+		},
+		{
+			name:           "several headers",
+			remoteAddr:     "10.0.0.2:5555",
+			forwardedFor:   []string{"1.2.3.4", "198.51.100.1"},
+			wantRemoteAddr: "198.51.100.1:5555",
+		},
+		{
+			name:           "trusted proxy without header",
+			remoteAddr:     "10.0.0.2:5555",
+			wantRemoteAddr: "10.0.0.2:5555",
+		},
+		{
+			name:           "garbage header",
+			remoteAddr:     "10.0.0.2:5555",
+			forwardedFor:   []string{"unknown"},
+			wantRemoteAddr: "10.0.0.2:5555",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for _, value := range tt.forwardedFor {
+				req.Header.Add("X-Forwarded-For", value)
+			}
+
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			assert.Equal(t, tt.wantRemoteAddr, remoteAddr)
+		})
+	}
+}
@@ -6,8 +6,16 @@ import (
 	"math/rand"
 	"net/http"
 	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
+const instrumentationName = "github.com/ibeloyar/gophermart/pgk/retryablehttp"
+
 type RetryConfig struct {
 	MaxRetries int           // Максимум попыток (по умолчанию 3)
 	BaseDelay  time.Duration // Базовая задержка (по умолчанию 100ms)
@@ -57,8 +65,34 @@ func (c *RetryableClient) isRetryable(resp *http.Response, err error) bool {
 		(statusCode >= 500 && statusCode <= 599)
 }
 
-// Do - отправляет запрос
+// Do - отправляет запрос. Все попытки - один клиентский span; его контекст уходит
+// получателю в заголовке traceparent (W3C Trace Context).
 func (c *RetryableClient) Do(ctx context.Context, req *http.Request) (*http.Response, error) {
+	ctx, span := otel.Tracer(instrumentationName).Start(ctx, "HTTP "+req.Method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("http.request.method", req.Method),
+			attribute.String("server.address", req.URL.Host),
+			attribute.String("url.full", req.URL.String()),
+		),
+	)
+	defer span.End()
+
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := c.do(ctx, req)
+	if resp != nil {
+		span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	return resp, err
+}
+
+func (c *RetryableClient) do(ctx context.Context, req *http.Request) (*http.Response, error) {
 	var resp *http.Response
 	var err error
 
@@ -67,6 +101,15 @@ func (c *RetryableClient) Do(ctx context.Context, req *http.Request) (*http.Resp
 			return nil, ctx.Err()
 		}
 
+		// тело запроса вычитывается при каждой попытке - восстанавливаем его для повтора
+		if attempt > 0 && req.GetBody != nil {
+			body, bodyErr := req.GetBody()
+			if bodyErr != nil {
+				return nil, bodyErr
+			}
+			req.Body = body
+		}
+
 		resp, err = c.client.Do(req)
 
 		if err == nil && !c.isRetryable(resp, nil) {
@@ -85,6 +128,10 @@ func (c *RetryableClient) Do(ctx context.Context, req *http.Request) (*http.Resp
 		}
 
 		delay := c.backoffDelay(attempt)
+		trace.SpanFromContext(ctx).AddEvent("http.retry", trace.WithAttributes(
+			attribute.Int("attempt", attempt+1),
+			attribute.String("delay", delay.String()),
+		))
 		select {
 		case <-ctx.Done():
 			return nil, ctx.Err()
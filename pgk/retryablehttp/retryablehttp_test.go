@@ -10,6 +10,9 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 )
 
 func TestNewRetryableClient_Defaults(t *testing.T) {
@@ -227,3 +230,38 @@ func TestRetryConfig_CustomValues(t *testing.T) {
 	client := NewRetryableClient(config)
 	assert.Equal(t, config, client.retryConfig)
 }
+
+func TestDo_PropagatesTraceparent(t *testing.T) {
+	provider := sdktrace.NewTracerProvider()
+	defer provider.Shutdown(context.Background())
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "parent")
+	defer parent.End()
+
+	var traceparents []string
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		traceparents = append(traceparents, r.Header.Get("traceparent"))
+		if attempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := NewRetryableClient(RetryConfig{BaseDelay: time.Millisecond, MaxJitter: time.Millisecond})
+	req, _ := http.NewRequest("GET", server.URL, nil)
+
+	result, err := client.Do(ctx, req)
+	require.NoError(t, err)
+	result.Body.Close()
+
+	// повтор несёт тот же traceparent, а trace ID - от span'а вызывающего
+	require.Len(t, traceparents, 2)
+	assert.Equal(t, traceparents[0], traceparents[1])
+	assert.Contains(t, traceparents[0], parent.SpanContext().TraceID().String())
+}